@@ -413,6 +413,8 @@ func ParseInt64(v interface{}) (int64,error) {
 		return int64(v.(float64)),nil
 	case int64:
 		return v.(int64), nil
+	case int32:
+		return int64(v.(int32)), nil
 	case int:
 		return int64(v.(int)),nil
 	case string:
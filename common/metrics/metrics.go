@@ -0,0 +1,177 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+const namespace = "teamsacs"
+
+// RADIUS request results
+const (
+	ResultAccept    = "accept"
+	ResultReject    = "reject"
+	ResultChallenge = "challenge"
+	ResultResponse  = "response"
+	ResultDrop      = "drop"
+)
+
+var (
+	// RadiusRequests
+	// RADIUS requests by request code, the vendor code of the VPE and the result
+	RadiusRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "radius",
+		Name:      "requests_total",
+		Help:      "RADIUS requests by code, vendor and result.",
+	}, []string{"code", "vendor", "result"})
+
+	// RadiusAuthDuration
+	// Access-Request processing time, the reject delay is not included
+	RadiusAuthDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "radius",
+		Name:      "auth_duration_seconds",
+		Help:      "Access-Request processing latency by result.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"result"})
+
+	// RadiusAcctDuration
+	RadiusAcctDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "radius",
+		Name:      "acct_duration_seconds",
+		Help:      "Accounting-Request processing latency by status type.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"status_type"})
+
+	// RadiusRejects
+	RadiusRejects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "radius",
+		Name:      "rejects_total",
+		Help:      "Access-Reject by reason.",
+	}, []string{"reason"})
+
+	// NbiRequestDuration
+	// path is the route template, e.g. /nbi/data/:collname/query
+	NbiRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "nbi",
+		Name:      "request_duration_seconds",
+		Help:      "NBI request latency by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "path", "status"})
+
+	// SyslogMessages
+	SyslogMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "syslog",
+		Name:      "messages_total",
+		Help:      "Syslog messages received by protocol.",
+	}, []string{"proto"})
+
+	// SyslogDrops
+	SyslogDrops = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "syslog",
+		Name:      "drops_total",
+		Help:      "Syslog messages not stored by protocol and reason.",
+	}, []string{"proto", "reason"})
+
+	// MongoCommandDuration
+	MongoCommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "mongo",
+		Name:      "command_duration_seconds",
+		Help:      "MongoDB command latency by command and result.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 5},
+	}, []string{"command", "result"})
+)
+
+var (
+	onlineSessionsOnce  sync.Once
+	onlineSessionsLock  sync.RWMutex
+	onlineSessionsValue func() float64
+)
+
+// RegisterOnlineSessions
+// The online session gauge, value is called at every scrape. The gauge is registered once,
+// a later call replaces the value, e.g. when the model manager is created again.
+func RegisterOnlineSessions(value func() float64) {
+	onlineSessionsLock.Lock()
+	onlineSessionsValue = value
+	onlineSessionsLock.Unlock()
+	onlineSessionsOnce.Do(func() {
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "radius",
+			Name:      "online_sessions",
+			Help:      "RADIUS online sessions.",
+		}, func() float64 {
+			onlineSessionsLock.RLock()
+			defer onlineSessionsLock.RUnlock()
+			return onlineSessionsValue()
+		})
+	})
+}
+
+// Handler
+// The /metrics handler of the default registry, including the go runtime and process metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveRadiusAuth
+func ObserveRadiusAuth(start time.Time, vendor, result string) {
+	RadiusRequests.WithLabelValues("Access-Request", vendor, result).Inc()
+	RadiusAuthDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+}
+
+// ObserveRadiusAcct
+func ObserveRadiusAcct(start time.Time, vendor, statusType, result string) {
+	RadiusRequests.WithLabelValues("Accounting-Request", vendor, result).Inc()
+	RadiusAcctDuration.WithLabelValues(statusType).Observe(time.Since(start).Seconds())
+}
+
+// ObserveNbiRequest
+func ObserveNbiRequest(start time.Time, method, path string, status int) {
+	NbiRequestDuration.WithLabelValues(method, path, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+}
+
+// NewMongoMonitor
+// The command monitor of the mongo client observing the command latencies
+func NewMongoMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			MongoCommandDuration.WithLabelValues(e.CommandName, "ok").Observe(float64(e.DurationNanos) / 1e9)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			MongoCommandDuration.WithLabelValues(e.CommandName, "error").Observe(float64(e.DurationNanos) / 1e9)
+		},
+	}
+}
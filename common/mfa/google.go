@@ -17,16 +17,19 @@
 package mfa
 
 import (
-    "bytes"
     "crypto/hmac"
+    "crypto/rand"
     "crypto/sha1"
     "encoding/base32"
-    "encoding/binary"
     "fmt"
+    "net/url"
     "strings"
     "time"
 )
 
+// Verified codes may be one time step before or after the current one (clock drift)
+const VerifySkewSteps = 1
+
 type GoogleAuth struct {
 }
 
@@ -34,10 +37,6 @@ func NewGoogleAuth() *GoogleAuth {
     return &GoogleAuth{}
 }
 
-func (ga *GoogleAuth) un() int64 {
-    return time.Now().UnixNano() / 1000 / 30
-}
-
 func (ga *GoogleAuth) hmacSha1(key, data []byte) []byte {
     h := hmac.New(sha1.New, key)
     if total := len(data); total > 0 {
@@ -78,37 +77,47 @@ func (ga *GoogleAuth) oneTimePassword(key []byte, data []byte) uint32 {
     return number % 1000000
 }
 
-// 获取秘钥
+// 获取秘钥, 160 bits random key
 func (ga *GoogleAuth) GetSecret() string {
-    var buf bytes.Buffer
-    binary.Write(&buf, binary.BigEndian, ga.un())
-    return strings.ToUpper(ga.base32encode(ga.hmacSha1(buf.Bytes(), nil)))
+    key := make([]byte, 20)
+    _, _ = rand.Read(key)
+    return strings.ToUpper(ga.base32encode(key))
 }
 
 // Get Dynamic Code
 func (ga *GoogleAuth) GetCode(secret string) (string, error) {
+    return ga.getCodeAt(secret, time.Now().Unix()/30)
+}
+
+func (ga *GoogleAuth) getCodeAt(secret string, step int64) (string, error) {
     secretUpper := strings.ToUpper(secret)
     secretKey, err := ga.base32decode(secretUpper)
     if err != nil {
         return "", err
     }
-    number := ga.oneTimePassword(secretKey, ga.toBytes(time.Now().Unix()/30))
+    number := ga.oneTimePassword(secretKey, ga.toBytes(step))
     return fmt.Sprintf("%06d", number), nil
 }
 
 // Get Dynamic Code QR Code Content
 func (ga *GoogleAuth) GetQrcode(user, secret, stype string) string {
-    return fmt.Sprintf("otpauth://totp/%s:%s?issuer=%s&secret=%s", stype, user, stype, secret)
+    return fmt.Sprintf("otpauth://totp/%s:%s?issuer=%s&secret=%s",
+        url.PathEscape(stype), url.PathEscape(user), url.QueryEscape(stype), secret)
 }
 
 // Verify Dynamic Code
 func (ga *GoogleAuth) VerifyCode(secret, code string) (bool, error) {
-    _code, err := ga.GetCode(secret)
-    fmt.Println(_code, code, err)
-    if err != nil {
-        return false, err
+    step := time.Now().Unix() / 30
+    for i := -VerifySkewSteps; i <= VerifySkewSteps; i++ {
+        _code, err := ga.getCodeAt(secret, step+int64(i))
+        if err != nil {
+            return false, err
+        }
+        if hmac.Equal([]byte(_code), []byte(code)) {
+            return true, nil
+        }
     }
-    return _code == code, nil
+    return false, nil
 }
 
 
@@ -19,6 +19,7 @@ package mfa
 import (
 	"fmt"
 	"testing"
+	"time"
 )
 
 
@@ -51,3 +52,22 @@ func TestGoogleAuth_VerifyCode(t *testing.T) {
     }
 }
 
+
+func TestGoogleAuth_VerifySkew(t *testing.T) {
+    ga := NewGoogleAuth()
+    secret := ga.GetSecret()
+    step := time.Now().Unix() / 30
+    for _, i := range []int64{-1, 1} {
+        code, err := ga.getCodeAt(secret, step+i)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if ok, _ := ga.VerifyCode(secret, code); !ok {
+            t.Fatalf("code of step %d is not accepted", i)
+        }
+    }
+    code, _ := ga.getCodeAt(secret, step-3)
+    if ok, _ := ga.VerifyCode(secret, code); ok {
+        t.Fatal("expired code is accepted")
+    }
+}
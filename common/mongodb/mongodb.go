@@ -20,14 +20,21 @@ import (
 	"context"
 	"time"
 
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/ca17/teamsacs/config"
 )
 
-func GetMongodbClient(cfg config.MongodbConfig) (*mongo.Client, error) {
-	client, err := mongo.NewClient(options.Client().ApplyURI(cfg.Url))
+// GetMongodbClient
+// monitor observes the commands of the client if not nil
+func GetMongodbClient(cfg config.MongodbConfig, monitor *event.CommandMonitor) (*mongo.Client, error) {
+	opts := options.Client().ApplyURI(cfg.Url)
+	if monitor != nil {
+		opts.SetMonitor(monitor)
+	}
+	client, err := mongo.NewClient(opts)
 	if err != nil {
 		return nil, err
 	}
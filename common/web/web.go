@@ -213,6 +213,8 @@ func (jp RequestParams) GetInt64(key string) int64 {
 	switch v.(type) {
 	case int64:
 		return v.(int64)
+	case float64:
+		return int64(v.(float64))
 	case string:
 		vvv, err := strconv.ParseInt(v.(string), 10, 64)
 		if err != nil {
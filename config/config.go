@@ -42,11 +42,15 @@ type SysConfig struct {
 	Debug      bool   `yaml:"debug" json:"debug"`
 }
 
+// NBIConfig
+// /metrics requires the NBI token unless MetricsAddr is set, then it is served
+// there without auth and the address should be reachable by the scraper only.
 type NBIConfig struct {
-	Host      string `yaml:"host" json:"host"`
-	Port      int    `yaml:"port" json:"port"`
-	Debug     bool   `yaml:"debug" json:"debug"`
-	JwtSecret string `yaml:"jwt_secret" json:"jwt_secret"`
+	Host        string `yaml:"host" json:"host"`
+	Port        int    `yaml:"port" json:"port"`
+	Debug       bool   `yaml:"debug" json:"debug"`
+	JwtSecret   string `yaml:"jwt_secret" json:"jwt_secret"`
+	MetricsAddr string `yaml:"metrics_addr" json:"metrics_addr"`
 }
 
 type FreeradiusConfig struct {
@@ -62,10 +66,13 @@ type GrpcConfig struct {
 }
 
 type RadiusdConfig struct {
-	Host     string `yaml:"host" json:"host"`
-	AuthPort int    `yaml:"auth_port" json:"auth_port"`
-	AcctPort int    `yaml:"acct_port" json:"acct_port"`
-	Debug    bool   `yaml:"debug" json:"debug"`
+	Host             string `yaml:"host" json:"host"`
+	AuthPort         int    `yaml:"auth_port" json:"auth_port"`
+	AcctPort         int    `yaml:"acct_port" json:"acct_port"`
+	RadsecEnabled    bool   `yaml:"radsec_enabled" json:"radsec_enabled"`
+	RadsecPort       int    `yaml:"radsec_port" json:"radsec_port"`
+	RadsecIpFallback bool   `yaml:"radsec_ip_fallback" json:"radsec_ip_fallback"`
+	Debug            bool   `yaml:"debug" json:"debug"`
 }
 
 type SyslogdConfig struct {
@@ -130,10 +137,11 @@ var DefaultAppConfig = &AppConfig{
 		Aeskey:     "5f8923be3da19452d3acdc9e69fa24e6",
 	},
 	NBI: NBIConfig{
-		Host:      "0.0.0.0",
-		Port:      1979,
-		Debug:     true,
-		JwtSecret: "9b6de5cc-0731-4bf1-zpms-0f568ac9da37",
+		Host:        "0.0.0.0",
+		Port:        1979,
+		Debug:       true,
+		JwtSecret:   "9b6de5cc-0731-4bf1-zpms-0f568ac9da37",
+		MetricsAddr: "",
 	},
 	Freeradius: FreeradiusConfig{
 		Host:  "0.0.0.0",
@@ -146,10 +154,13 @@ var DefaultAppConfig = &AppConfig{
 		Debug: true,
 	},
 	Radiusd: RadiusdConfig{
-		Host:     "0.0.0.0",
-		AuthPort: 1812,
-		AcctPort: 1813,
-		Debug:    true,
+		Host:             "0.0.0.0",
+		AuthPort:         1812,
+		AcctPort:         1813,
+		RadsecEnabled:    false,
+		RadsecPort:       2083,
+		RadsecIpFallback: false,
+		Debug:            true,
 	},
 	Syslogd: SyslogdConfig{
 		Host:        "0.0.0.0",
@@ -208,6 +219,9 @@ func LoadConfig(cfile string) *AppConfig {
 	setEnvInt64Value("TEAMSACS_NBI_PORT", func(v int64) {
 		cfg.NBI.Port = int(v)
 	})
+	setEnvValue("TEAMSACS_NBI_METRICS_ADDR", func(v string) {
+		cfg.NBI.MetricsAddr = v
+	})
 
 	setEnvValue("TEAMSACS_FREERADIUS_WEB_HOST", func(v string) {
 		cfg.Freeradius.Host = v
@@ -248,6 +262,18 @@ func LoadConfig(cfile string) *AppConfig {
 		cfg.Radiusd.AcctPort = int(v)
 	})
 
+	setEnvValue("TEAMSACS_RADSEC_ENABLED", func(v string) {
+		cfg.Radiusd.RadsecEnabled = v == "true"
+	})
+
+	setEnvInt64Value("TEAMSACS_RADSEC_PORT", func(v int64) {
+		cfg.Radiusd.RadsecPort = int(v)
+	})
+
+	setEnvValue("TEAMSACS_RADSEC_IP_FALLBACK", func(v string) {
+		cfg.Radiusd.RadsecIpFallback = v == "true"
+	})
+
 	setEnvValue("TEAMSACS_RADIUS_DEBUG", func(v string) {
 		cfg.Radiusd.Debug = v == "true"
 	})
@@ -17,13 +17,29 @@
 package constant
 
 const (
-	RadiusIgnorePwd          = "RadiusIgnorePwd"
-	RadiusMfaStatus          = "RadiusMfaStatus"
-	RadiuslogHistoryDays     = "RadiuslogHistoryDays"
-	AcctInterimInterval      = "AcctInterimInterval"
-	RadiusAuthlogLevel       = "RadiusAuthlogLevel"
-	RadiusRejectDelay        = "RadiusRejectDelay"
-	RadiusAuthlogHistoryDays = "RadiusAuthlogHistoryDays"
-	FreeRadiusApiUrl         = "FreeRadiusApiUrl"
-	FreeRadiusApiToken       = "FreeRadiusApiToken"
+	RadiusIgnorePwd           = "RadiusIgnorePwd"
+	RadiusMfaStatus           = "RadiusMfaStatus"
+	RadiusEapMethod           = "RadiusEapMethod"
+	RadiuslogHistoryDays      = "RadiuslogHistoryDays"
+	AcctInterimInterval       = "AcctInterimInterval"
+	RadiusOnlineExpireTimes   = "RadiusOnlineExpireTimes"
+	RadiusAuthlogLevel        = "RadiusAuthlogLevel"
+	RadiusRejectDelay         = "RadiusRejectDelay"
+	RadiusAuthlogHistoryDays  = "RadiusAuthlogHistoryDays"
+	RadiusTimelineHistoryDays = "RadiusTimelineHistoryDays"
+	RadiusPurgeBackup         = "RadiusPurgeBackup"
+	SyslogHistoryDays         = "SyslogHistoryDays"
+	RadiusCdrExport           = "RadiusCdrExport"
+	RadiusCdrFormats          = "RadiusCdrFormats"
+	RadiusCdrColumns          = "RadiusCdrColumns"
+	RadiusLockoutFailures     = "RadiusLockoutFailures"
+	RadiusLockoutWindow       = "RadiusLockoutWindow"
+	RadiusLockoutTime         = "RadiusLockoutTime"
+	FreeRadiusApiUrl          = "FreeRadiusApiUrl"
+	FreeRadiusApiToken        = "FreeRadiusApiToken"
+)
+
+// config types besides radius
+const (
+	ConfigTypeAttrmap = "attrmap"
 )
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
 	"time"
 	_ "time/tzdata"
 
@@ -35,6 +36,7 @@ import (
 	"github.com/ca17/teamsacs/models"
 	"github.com/ca17/teamsacs/nbi"
 	"github.com/ca17/teamsacs/radiusd"
+	"github.com/ca17/teamsacs/radiusd/dictionary"
 	"github.com/ca17/teamsacs/radiusd/radlog"
 	"github.com/ca17/teamsacs/syslogd"
 )
@@ -160,8 +162,87 @@ func ionitConfig(appconfig *config.AppConfig) bool {
 	return false
 }
 
+// attrFlags
+// Repeated -attr options
+type attrFlags []string
+
+func (a *attrFlags) String() string {
+	return strings.Join(*a, ",")
+}
+
+func (a *attrFlags) Set(value string) error {
+	*a = append(*a, value)
+	return nil
+}
+
+// runRadtest
+// teamsacs radtest [options], send test requests to radiusd as a NAS
+func runRadtest(args []string) int {
+	fs := flag.NewFlagSet("radtest", flag.ExitOnError)
+	opts := &radiusd.RadtestOptions{}
+	var attrs attrFlags
+	fs.StringVar(&opts.Server, "server", "127.0.0.1", "radius server address")
+	fs.IntVar(&opts.AuthPort, "auth-port", 1812, "radius auth port")
+	fs.IntVar(&opts.AcctPort, "acct-port", 1813, "radius acct port")
+	fs.StringVar(&opts.Secret, "secret", "secret", "radius secret")
+	fs.StringVar(&opts.Username, "u", "test", "username, %d is replaced by the request number in load mode")
+	fs.StringVar(&opts.Password, "p", "", "password")
+	fs.StringVar(&opts.AuthType, "auth", radiusd.RadtestPap, "auth type pap/chap/mschapv2, none to send accounting only")
+	acct := fs.String("acct", "", "accounting requests sent after auth in order, e.g. start,interim,stop")
+	fs.StringVar(&opts.NasIp, "nas-ip", "127.0.0.1", "NAS-IP-Address")
+	fs.StringVar(&opts.NasId, "nas-id", "radtest", "NAS-Identifier")
+	fs.StringVar(&opts.NasPortId, "nas-port-id", "", "NAS-Port-Id")
+	fs.StringVar(&opts.MacAddr, "mac", "", "Calling-Station-Id")
+	fs.StringVar(&opts.SessionId, "session-id", fmt.Sprintf("radtest-%d", time.Now().UnixNano()), "Acct-Session-Id")
+	sessionTime := fs.Uint("session-time", 60, "Acct-Session-Time of interim and stop")
+	fs.Uint64Var(&opts.InputOctets, "input-octets", 0, "input octets of interim and stop, including gigawords")
+	fs.Uint64Var(&opts.OutputOctets, "output-octets", 0, "output octets of interim and stop, including gigawords")
+	fs.Var(&attrs, "attr", "extra attribute Name=value, may be repeated")
+	fs.DurationVar(&opts.Timeout, "timeout", time.Second*3, "reply timeout")
+	count := fs.Int("count", 0, "load mode, the number of Access-Requests")
+	concurrency := fs.Int("concurrency", 10, "load mode concurrent requests")
+	dictdir := fs.String("dict", "", "directory of the FreeRADIUS dictionary files")
+	_ = fs.Parse(args)
+	opts.SessionTime = uint32(*sessionTime)
+	opts.Attrs = attrs
+
+	if *dictdir != "" {
+		d, err := dictionary.LoadDir(*dictdir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "load dictionary error, %s\n", err.Error())
+			return 1
+		}
+		dictionary.SetDefault(d)
+	}
+
+	if *count > 0 {
+		stats, err := radiusd.RunRadtestLoad(opts, *count, *concurrency)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+		fmt.Fprint(os.Stdout, stats.String())
+		return 0
+	}
+
+	var acctList []string
+	if *acct != "" {
+		acctList = strings.Split(*acct, ",")
+	}
+	if err := radiusd.RunRadtest(opts, opts.AuthType != "none", acctList, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	return 0
+}
+
 func main() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	if len(os.Args) > 1 && os.Args[1] == "radtest" {
+		os.Exit(runRadtest(os.Args[2:]))
+	}
+
 	flag.Parse()
 
 	if *showVer {
@@ -194,6 +275,10 @@ func main() {
 		log.Debug("Running for Dev Mode")
 	}
 
+	if _, err := radiusd.LoadDictionary(manager); err != nil {
+		log.Errorf("load radius dictionary error, %s", err.Error())
+	}
+
 	g.Go(func() error {
 		log.Info("Start Radius auth Server ...")
 		return radiusd.ListenRadiusAuthServer(manager)
@@ -204,6 +289,13 @@ func main() {
 		return radiusd.ListenRadiusAcctServer(manager)
 	})
 
+	if appconfig.Radiusd.RadsecEnabled {
+		g.Go(func() error {
+			log.Info("Start Radsec Server ...")
+			return radiusd.ListenRadsecServer(manager)
+		})
+	}
+
 	time.Sleep(time.Millisecond * 50)
 
 	g.Go(func() error {
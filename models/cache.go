@@ -0,0 +1,300 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ca17/teamsacs/common/log"
+)
+
+const CacheDefaultTTL = time.Minute * 5
+
+type cacheItem struct {
+	id     string
+	value  interface{}
+	expire time.Time
+}
+
+// CacheStats
+type CacheStats struct {
+	Name     string  `json:"name"`
+	Size     int     `json:"size"`
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// DocCache
+// In-memory document cache, one document may be cached under several keys
+// (e.g. username and mac), all keys of a document are removed together by its _id.
+type DocCache struct {
+	Name   string
+	ttl    time.Duration
+	lock   sync.RWMutex
+	items  map[string]*cacheItem
+	ids    map[string]map[string]struct{}
+	hits   int64
+	misses int64
+}
+
+func NewDocCache(name string, ttl time.Duration) *DocCache {
+	c := &DocCache{
+		Name:  name,
+		ttl:   ttl,
+		items: make(map[string]*cacheItem),
+		ids:   make(map[string]map[string]struct{}),
+	}
+	go c.cleanLoop()
+	return c
+}
+
+func (c *DocCache) Get(key string) (interface{}, bool) {
+	c.lock.RLock()
+	item, ok := c.items[key]
+	c.lock.RUnlock()
+	if !ok || item.expire.Before(time.Now()) {
+		atomic.AddInt64(&c.misses, 1)
+		return nil, false
+	}
+	atomic.AddInt64(&c.hits, 1)
+	return item.value, true
+}
+
+// Set
+// Cache value under key, id is the document _id, empty for values without a document.
+func (c *DocCache) Set(key, id string, value interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.items[key] = &cacheItem{id: id, value: value, expire: time.Now().Add(c.ttl)}
+	if id == "" {
+		return
+	}
+	keys, ok := c.ids[id]
+	if !ok {
+		keys = make(map[string]struct{})
+		c.ids[id] = keys
+	}
+	keys[key] = struct{}{}
+}
+
+// Remove
+// Remove key and all other keys of the same document.
+func (c *DocCache) Remove(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	item, ok := c.items[key]
+	if !ok {
+		return
+	}
+	delete(c.items, key)
+	if item.id != "" {
+		c.removeIdLocked(item.id)
+	}
+}
+
+func (c *DocCache) RemoveId(id string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.removeIdLocked(id)
+}
+
+func (c *DocCache) removeIdLocked(id string) {
+	for key := range c.ids[id] {
+		delete(c.items, key)
+	}
+	delete(c.ids, id)
+}
+
+func (c *DocCache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.items = make(map[string]*cacheItem)
+	c.ids = make(map[string]map[string]struct{})
+}
+
+func (c *DocCache) Stats() CacheStats {
+	c.lock.RLock()
+	size := len(c.items)
+	c.lock.RUnlock()
+	stats := CacheStats{
+		Name:   c.Name,
+		Size:   size,
+		Hits:   atomic.LoadInt64(&c.hits),
+		Misses: atomic.LoadInt64(&c.misses),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+func (c *DocCache) cleanLoop() {
+	for range time.Tick(c.ttl) {
+		c.lock.Lock()
+		now := time.Now()
+		for key, item := range c.items {
+			if item.expire.Before(now) {
+				delete(c.items, key)
+				if keys, ok := c.ids[item.id]; ok {
+					delete(keys, key)
+					if len(keys) == 0 {
+						delete(c.ids, item.id)
+					}
+				}
+			}
+		}
+		c.lock.Unlock()
+	}
+}
+
+// cacheId
+// The cache id of a document _id, string and ObjectID are both supported.
+func cacheId(id interface{}) string {
+	if id == nil {
+		return ""
+	}
+	return fmt.Sprint(id)
+}
+
+// CacheManager
+// Caches of the documents read on every radius request.
+type CacheManager struct {
+	Vpe       *DocCache
+	Subscribe *DocCache
+	Config    *DocCache
+	Realm     *DocCache
+	Schedule  *DocCache
+	Product   *DocCache
+	Webhook   *DocCache
+}
+
+func NewCacheManager(ttl time.Duration) *CacheManager {
+	return &CacheManager{
+		Vpe:       NewDocCache(TeamsacsVpe, ttl),
+		Subscribe: NewDocCache(TeamsacsSubscribe, ttl),
+		Config:    NewDocCache(TeamsacsConfig, ttl),
+		Realm:     NewDocCache(TeamsacsRealm, ttl),
+		Schedule:  NewDocCache(TeamsacsSchedule, ttl),
+		Product:   NewDocCache(TeamsacsProduct, ttl),
+		Webhook:   NewDocCache(TeamsacsWebhook, ttl),
+	}
+}
+
+func (c *CacheManager) getCache(collname string) *DocCache {
+	switch collname {
+	case TeamsacsVpe:
+		return c.Vpe
+	case TeamsacsSubscribe:
+		return c.Subscribe
+	case TeamsacsConfig:
+		return c.Config
+	case TeamsacsRealm:
+		return c.Realm
+	case TeamsacsSchedule:
+		return c.Schedule
+	case TeamsacsProduct:
+		return c.Product
+	case TeamsacsWebhook:
+		return c.Webhook
+	}
+	return nil
+}
+
+func (c *CacheManager) Stats() []CacheStats {
+	return []CacheStats{c.Vpe.Stats(), c.Subscribe.Stats(), c.Config.Stats(), c.Realm.Stats(), c.Schedule.Stats(), c.Product.Stats(), c.Webhook.Stats()}
+}
+
+func (c *CacheManager) ClearAll() {
+	c.Vpe.Clear()
+	c.Subscribe.Clear()
+	c.Config.Clear()
+	c.Realm.Clear()
+	c.Schedule.Clear()
+	c.Product.Clear()
+	c.Webhook.Clear()
+}
+
+// InvalidateCache
+// Write hook, called after documents of collname are changed, all cached documents
+// of the collection are removed if ids is empty. Config values, realms, schedule policies,
+// products and the webhook list are cached including missing ones, so their caches are always cleared.
+func (m *ModelManager) InvalidateCache(collname string, ids ...string) {
+	cache := m.Cache.getCache(collname)
+	if cache == nil {
+		return
+	}
+	if len(ids) == 0 || collname == TeamsacsConfig || collname == TeamsacsRealm ||
+		collname == TeamsacsSchedule || collname == TeamsacsProduct || collname == TeamsacsWebhook {
+		cache.Clear()
+		return
+	}
+	for _, id := range ids {
+		cache.RemoveId(id)
+	}
+}
+
+type cacheChangeEvent struct {
+	OperationType string `bson:"operationType"`
+	Ns            struct {
+		Coll string `bson:"coll"`
+	} `bson:"ns"`
+	DocumentKey struct {
+		ID interface{} `bson:"_id"`
+	} `bson:"documentKey"`
+}
+
+// WatchCacheChanges
+// Invalidate the cache by mongodb change streams, change streams require a replica set,
+// on a standalone server the cache relies on the write hooks and ttl.
+func (m *ModelManager) WatchCacheChanges() {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"ns.coll": bson.M{"$in": bson.A{TeamsacsVpe, TeamsacsSubscribe, TeamsacsConfig, TeamsacsRealm, TeamsacsSchedule, TeamsacsProduct, TeamsacsWebhook}}}}},
+	}
+	stream, err := m.Mongo.Database(MDBTeamsacs).Watch(context.Background(), pipeline, options.ChangeStream())
+	if err != nil {
+		log.Warningf("mongodb change stream not available, cache invalidated by write hooks only, %s", err.Error())
+		return
+	}
+	defer stream.Close(context.Background())
+	for stream.Next(context.Background()) {
+		var event cacheChangeEvent
+		if err := stream.Decode(&event); err != nil {
+			log.Error(err)
+			continue
+		}
+		switch event.OperationType {
+		case "insert", "update", "replace", "delete":
+			m.InvalidateCache(event.Ns.Coll, cacheId(event.DocumentKey.ID))
+		default:
+			// drop, rename, invalidate
+			m.Cache.ClearAll()
+		}
+	}
+	if err := stream.Err(); err != nil {
+		log.Errorf("mongodb change stream closed, %s", err.Error())
+	}
+	m.Cache.ClearAll()
+}
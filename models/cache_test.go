@@ -0,0 +1,44 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDocCache(t *testing.T) {
+	cache := NewDocCache(TeamsacsSubscribe, time.Minute)
+	user := Subscribe{"_id": "1", "username": "tim", "macaddr": "11:22:33:44:55:66"}
+	cache.Set("user:tim", "1", user)
+	cache.Set("mac:11:22:33:44:55:66", "1", user)
+	if _, ok := cache.Get("user:tim"); !ok {
+		t.Fatal("user:tim must be cached")
+	}
+	if _, ok := cache.Get("user:other"); ok {
+		t.Fatal("user:other must not be cached")
+	}
+	// removing one key removes all keys of the document
+	cache.Remove("user:tim")
+	if _, ok := cache.Get("mac:11:22:33:44:55:66"); ok {
+		t.Fatal("mac key must be removed with user key")
+	}
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Size != 0 {
+		t.Fatalf("stats error %+v", stats)
+	}
+}
@@ -0,0 +1,524 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/common/log"
+	"github.com/ca17/teamsacs/constant"
+)
+
+const (
+	CdrFormatCsv = "csv"
+	CdrFormatXml = "xml"
+
+	// the days before yesterday checked for missing exports
+	cdrCatchupDays = 7
+	// a day is exported after the delay, stale sessions are closed late with the last update time
+	cdrExportDelay = time.Hour
+	cdrNamespace   = "http://www.ipdr.org/namespaces/ipdr"
+)
+
+// DefaultCdrColumns
+// The accounting fields exported if RadiusCdrColumns is not set
+const DefaultCdrColumns = "acct_session_id,username,nas_addr,nas_id,nas_port_id,mac_addr,framed_ipaddr,framed_ipv6_prefix," +
+	"delegated_ipv6_prefix,acct_start_time,acct_stop_time,acct_session_time,acct_input_total,acct_output_total," +
+	"acct_input_packets,acct_output_packets,acct_terminate_cause"
+
+var (
+	cdrColumnNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-]*$`)
+	cdrFileNameRegexp   = regexp.MustCompile(`^cdr-\d{8}\.(csv|xml|sha256|manifest\.json)$`)
+)
+
+// CdrColumn
+// An exported accounting field, Field may be a dotted path (vendor_attrs.xxx), Name is the csv header and xml element
+type CdrColumn struct {
+	Field string `json:"field"`
+	Name  string `json:"name"`
+}
+
+// CdrFile
+type CdrFile struct {
+	Name   string `json:"name"`
+	Format string `json:"format"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// CdrManifest
+// The files of a day export, written last so a day with a manifest is complete
+type CdrManifest struct {
+	Date       string      `json:"date"`
+	Begin      time.Time   `json:"begin"`
+	End        time.Time   `json:"end"`
+	Records    int64       `json:"records"`
+	Columns    []CdrColumn `json:"columns"`
+	Files      []CdrFile   `json:"files"`
+	CreateTime time.Time   `json:"create_time"`
+}
+
+// ParseCdrColumns
+// Comma separated accounting fields, a field may be renamed by field:name
+func ParseCdrColumns(text string) ([]CdrColumn, error) {
+	if strings.TrimSpace(text) == "" {
+		text = DefaultCdrColumns
+	}
+	columns := make([]CdrColumn, 0)
+	for _, item := range strings.Split(text, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		col := CdrColumn{Field: item, Name: item}
+		if i := strings.Index(item, ":"); i >= 0 {
+			col.Field = strings.TrimSpace(item[:i])
+			col.Name = strings.TrimSpace(item[i+1:])
+		}
+		if col.Field == "" || !cdrColumnNameRegexp.MatchString(col.Name) {
+			return nil, fmt.Errorf("invalid cdr column %s", item)
+		}
+		columns = append(columns, col)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("cdr columns is empty")
+	}
+	return columns, nil
+}
+
+// ParseCdrFormats
+// Comma separated formats, csv or xml
+func ParseCdrFormats(text string) ([]string, error) {
+	formats := make([]string, 0)
+	for _, format := range strings.Split(strings.ToLower(text), ",") {
+		format = strings.TrimSpace(format)
+		switch format {
+		case "":
+			continue
+		case CdrFormatCsv, CdrFormatXml:
+			if !common.InSlice(format, formats) {
+				formats = append(formats, format)
+			}
+		default:
+			return nil, fmt.Errorf("invalid cdr format %s", format)
+		}
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("cdr formats is empty")
+	}
+	return formats, nil
+}
+
+// CdrValues
+// The column values of an accounting record, times are RFC3339 in loc
+func CdrValues(record bson.M, columns []CdrColumn, loc *time.Location) []string {
+	values := make([]string, len(columns))
+	for i, col := range columns {
+		var v interface{} = record
+		for _, key := range strings.Split(col.Field, ".") {
+			doc, ok := v.(bson.M)
+			if !ok {
+				v = nil
+				break
+			}
+			v = doc[key]
+		}
+		switch val := v.(type) {
+		case nil:
+		case string:
+			values[i] = val
+		case time.Time:
+			values[i] = val.In(loc).Format(time.RFC3339)
+		case primitive.DateTime:
+			values[i] = val.Time().In(loc).Format(time.RFC3339)
+		default:
+			values[i] = fmt.Sprint(val)
+		}
+	}
+	return values
+}
+
+// cdrWriter
+// Writes the records of one format
+type cdrWriter interface {
+	Write(values []string, stopTime time.Time) error
+	Close(count int64) error
+}
+
+type csvCdrWriter struct {
+	w *csv.Writer
+}
+
+func newCsvCdrWriter(w io.Writer, columns []CdrColumn) (cdrWriter, error) {
+	cw := &csvCdrWriter{w: csv.NewWriter(w)}
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+	}
+	return cw, cw.w.Write(names)
+}
+
+func (cw *csvCdrWriter) Write(values []string, stopTime time.Time) error {
+	return cw.w.Write(values)
+}
+
+func (cw *csvCdrWriter) Close(count int64) error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// xmlCdrWriter
+// IPDR like document, one IPDR element per record and IPDRDoc.End with the record count
+type xmlCdrWriter struct {
+	enc     *xml.Encoder
+	columns []CdrColumn
+	now     time.Time
+}
+
+func newXmlCdrWriter(w io.Writer, columns []CdrColumn, docId string, now time.Time) (cdrWriter, error) {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return nil, err
+	}
+	xw := &xmlCdrWriter{enc: xml.NewEncoder(w), columns: columns, now: now}
+	xw.enc.Indent("", "  ")
+	return xw, xw.enc.EncodeToken(xml.StartElement{
+		Name: xml.Name{Local: "IPDRDoc"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: "xmlns"}, Value: cdrNamespace},
+			{Name: xml.Name{Local: "version"}, Value: "3.5"},
+			{Name: xml.Name{Local: "docId"}, Value: docId},
+			{Name: xml.Name{Local: "creationTime"}, Value: now.Format(time.RFC3339)},
+		},
+	})
+}
+
+func (xw *xmlCdrWriter) Write(values []string, stopTime time.Time) error {
+	start := xml.StartElement{
+		Name: xml.Name{Local: "IPDR"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "time"}, Value: stopTime.Format(time.RFC3339)}},
+	}
+	if err := xw.enc.EncodeToken(start); err != nil {
+		return err
+	}
+	for i, col := range xw.columns {
+		if err := xw.enc.EncodeElement(values[i], xml.StartElement{Name: xml.Name{Local: col.Name}}); err != nil {
+			return err
+		}
+	}
+	return xw.enc.EncodeToken(start.End())
+}
+
+func (xw *xmlCdrWriter) Close(count int64) error {
+	end := xml.StartElement{
+		Name: xml.Name{Local: "IPDRDoc.End"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: "count"}, Value: strconv.FormatInt(count, 10)},
+			{Name: xml.Name{Local: "endTime"}, Value: xw.now.Format(time.RFC3339)},
+		},
+	}
+	if err := xw.enc.EncodeToken(end); err != nil {
+		return err
+	}
+	if err := xw.enc.EncodeToken(end.End()); err != nil {
+		return err
+	}
+	if err := xw.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: "IPDRDoc"}}); err != nil {
+		return err
+	}
+	return xw.enc.Flush()
+}
+
+// cdrOutput
+// A format file written to a temp file, renamed when the export is complete
+type cdrOutput struct {
+	format string
+	name   string
+	tmp    string
+	file   *os.File
+	buf    *bufio.Writer
+	writer cdrWriter
+}
+
+func newCdrOutput(dir, base, format string, columns []CdrColumn, now time.Time) (*cdrOutput, error) {
+	out := &cdrOutput{format: format, name: base + "." + format}
+	out.tmp = path.Join(dir, out.name+".tmp")
+	file, err := os.Create(out.tmp)
+	if err != nil {
+		return nil, err
+	}
+	out.file = file
+	out.buf = bufio.NewWriter(file)
+	switch format {
+	case CdrFormatCsv:
+		out.writer, err = newCsvCdrWriter(out.buf, columns)
+	case CdrFormatXml:
+		out.writer, err = newXmlCdrWriter(out.buf, columns, common.UUID(), now)
+	}
+	if err != nil {
+		out.discard()
+		return nil, err
+	}
+	return out, nil
+}
+
+func (out *cdrOutput) close(count int64) error {
+	err := out.writer.Close(count)
+	if err == nil {
+		err = out.buf.Flush()
+	}
+	if cerr := out.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (out *cdrOutput) discard() {
+	_ = out.file.Close()
+	_ = os.Remove(out.tmp)
+}
+
+// fileSha256
+func fileSha256(filename string) (string, int64, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// CdrManager
+// Daily accounting record files for billing and compliance
+type CdrManager struct{ *ModelManager }
+
+func (m *ModelManager) GetCdrManager() *CdrManager {
+	store, _ := m.ManagerMap.Get("CdrManager")
+	return store.(*CdrManager)
+}
+
+// GetCdrDir
+// <workdir>/data/cdr
+func (m *CdrManager) GetCdrDir() string {
+	return path.Join(m.Config.GetDataDir(), "cdr")
+}
+
+// ExportCdr
+// Write the accounting records stopped in the day to the configured formats, followed by the
+// sha256 checksum file and the manifest. The files of the day are replaced.
+func (m *CdrManager) ExportCdr(day time.Time) (*CdrManifest, error) {
+	cm := m.GetConfigManager()
+	columns, err := ParseCdrColumns(cm.GetRadiusConfigStringValue(constant.RadiusCdrColumns, DefaultCdrColumns))
+	if err != nil {
+		return nil, err
+	}
+	formats, err := ParseCdrFormats(cm.GetRadiusConfigStringValue(constant.RadiusCdrFormats, "csv,xml"))
+	if err != nil {
+		return nil, err
+	}
+	day = day.In(m.Location)
+	now := time.Now().In(m.Location)
+	manifest := &CdrManifest{
+		Begin:   time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, m.Location),
+		Columns: columns,
+		Files:   make([]CdrFile, 0),
+	}
+	manifest.End = manifest.Begin.AddDate(0, 0, 1)
+	manifest.Date = manifest.Begin.Format("2006-01-02")
+	if manifest.End.After(now) {
+		return nil, fmt.Errorf("cdr day %s not ended", manifest.Date)
+	}
+	dir := m.GetCdrDir()
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	base := "cdr-" + manifest.Begin.Format("20060102")
+
+	outputs := make([]*cdrOutput, 0, len(formats))
+	defer func() {
+		// temp files left on failure
+		for _, out := range outputs {
+			_ = os.Remove(out.tmp)
+		}
+	}()
+	for _, format := range formats {
+		out, err := newCdrOutput(dir, base, format, columns, now)
+		if err != nil {
+			for _, o := range outputs {
+				o.discard()
+			}
+			return nil, err
+		}
+		outputs = append(outputs, out)
+	}
+
+	err = func() error {
+		filter := bson.M{"acct_stop_time": bson.M{"$gte": manifest.Begin, "$lt": manifest.End}}
+		opts := options.Find().SetSort(bson.M{"acct_stop_time": 1})
+		cur, err := m.GetTeamsAcsCollection(TeamsacsAccounting).Find(context.TODO(), filter, opts)
+		if err != nil {
+			return err
+		}
+		defer cur.Close(context.TODO())
+		for cur.Next(context.TODO()) {
+			var record bson.M
+			if err = cur.Decode(&record); err != nil {
+				return err
+			}
+			var stopTime time.Time
+			if v, ok := record["acct_stop_time"].(primitive.DateTime); ok {
+				stopTime = v.Time().In(m.Location)
+			}
+			values := CdrValues(record, columns, m.Location)
+			for _, out := range outputs {
+				if err = out.writer.Write(values, stopTime); err != nil {
+					return err
+				}
+			}
+			manifest.Records++
+		}
+		return cur.Err()
+	}()
+	for _, out := range outputs {
+		if cerr := out.close(manifest.Records); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sums strings.Builder
+	for _, out := range outputs {
+		sum, size, err := fileSha256(out.tmp)
+		if err != nil {
+			return nil, err
+		}
+		if err = os.Rename(out.tmp, path.Join(dir, out.name)); err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, CdrFile{Name: out.name, Format: out.format, Size: size, Sha256: sum})
+		sums.WriteString(fmt.Sprintf("%s  %s\n", sum, out.name))
+	}
+	// sha256sum -c compatible
+	if err = ioutil.WriteFile(path.Join(dir, base+".sha256"), []byte(sums.String()), 0644); err != nil {
+		return nil, err
+	}
+	manifest.CreateTime = time.Now().In(m.Location)
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err = ioutil.WriteFile(path.Join(dir, base+".manifest.json"), data, 0644); err != nil {
+		return nil, err
+	}
+	log.Infof("export cdr %s, %d records", manifest.Date, manifest.Records)
+	return manifest, nil
+}
+
+// ExportPendingCdr
+// Export the ended days without manifest in the last days if RadiusCdrExport is enabled
+func (m *CdrManager) ExportPendingCdr() {
+	if m.GetConfigManager().GetRadiusConfigStringValue(constant.RadiusCdrExport, constant.DISABLED) != constant.ENABLED {
+		return
+	}
+	now := time.Now().In(m.Location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, m.Location)
+	for i := cdrCatchupDays; i >= 1; i-- {
+		day := today.AddDate(0, 0, -i)
+		if day.AddDate(0, 0, 1).Add(cdrExportDelay).After(now) {
+			continue
+		}
+		manifest := path.Join(m.GetCdrDir(), "cdr-"+day.Format("20060102")+".manifest.json")
+		if _, err := os.Stat(manifest); err == nil {
+			continue
+		}
+		if _, err := m.ExportCdr(day); err != nil {
+			log.Errorf("export cdr %s error, %s", day.Format("2006-01-02"), err.Error())
+		}
+	}
+}
+
+// ListCdrManifests
+// The exported days, the latest first
+func (m *CdrManager) ListCdrManifests() ([]*CdrManifest, error) {
+	items := make([]*CdrManifest, 0)
+	entries, err := ioutil.ReadDir(m.GetCdrDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return items, nil
+		}
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".manifest.json") || !cdrFileNameRegexp.MatchString(entry.Name()) {
+			continue
+		}
+		data, err := ioutil.ReadFile(path.Join(m.GetCdrDir(), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var manifest = new(CdrManifest)
+		if err = json.Unmarshal(data, manifest); err != nil {
+			log.Errorf("cdr manifest %s error, %s", entry.Name(), err.Error())
+			continue
+		}
+		items = append(items, manifest)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Date > items[j].Date
+	})
+	return items, nil
+}
+
+// GetCdrFile
+// The path of an exported file by name, names out of the cdr files are refused
+func (m *CdrManager) GetCdrFile(name string) (string, error) {
+	if !cdrFileNameRegexp.MatchString(name) {
+		return "", fmt.Errorf("invalid cdr file %s", name)
+	}
+	filename := path.Join(m.GetCdrDir(), name)
+	if _, err := os.Stat(filename); err != nil {
+		return "", fmt.Errorf("cdr file %s not exists", name)
+	}
+	return filename, nil
+}
@@ -0,0 +1,172 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/ca17/teamsacs/config"
+)
+
+func TestParseCdrColumns(t *testing.T) {
+	columns, err := ParseCdrColumns("username, acct_session_id:SessionId ,vendor_attrs.huawei_domain:domain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []CdrColumn{{"username", "username"}, {"acct_session_id", "SessionId"}, {"vendor_attrs.huawei_domain", "domain"}}
+	if len(columns) != len(expect) {
+		t.Fatalf("columns error %+v", columns)
+	}
+	for i := range expect {
+		if columns[i] != expect[i] {
+			t.Fatalf("column %d error %+v", i, columns[i])
+		}
+	}
+	if columns, err = ParseCdrColumns(""); err != nil || columns[0].Field != "acct_session_id" {
+		t.Fatal("default columns error")
+	}
+	for _, text := range []string{"username:1st", ":name", "username:user name"} {
+		if _, err = ParseCdrColumns(text); err == nil {
+			t.Errorf("%s must be invalid", text)
+		}
+	}
+}
+
+func TestParseCdrFormats(t *testing.T) {
+	formats, err := ParseCdrFormats("CSV, xml,csv")
+	if err != nil || len(formats) != 2 || formats[0] != CdrFormatCsv || formats[1] != CdrFormatXml {
+		t.Fatalf("formats error %v %v", formats, err)
+	}
+	if _, err = ParseCdrFormats("json"); err == nil {
+		t.Fatal("json must be invalid")
+	}
+	if _, err = ParseCdrFormats(" , "); err == nil {
+		t.Fatal("empty formats must be invalid")
+	}
+}
+
+func testCdrRecord(stop time.Time) bson.M {
+	return bson.M{
+		"username":          "tim",
+		"acct_session_id":   "s1",
+		"acct_stop_time":    primitive.NewDateTimeFromTime(stop),
+		"acct_input_total":  int64(5368709120),
+		"acct_session_time": int32(3600),
+		"vendor_attrs":      bson.M{"huawei_domain": "isp<1>"},
+	}
+}
+
+func TestCdrValues(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	stop := time.Date(2020, 10, 18, 15, 4, 5, 0, loc)
+	columns, _ := ParseCdrColumns("username,acct_stop_time,acct_input_total,acct_session_time,vendor_attrs.huawei_domain,framed_ipaddr,username.x")
+	values := CdrValues(testCdrRecord(stop), columns, loc)
+	expect := []string{"tim", "2020-10-18T15:04:05+08:00", "5368709120", "3600", "isp<1>", "", ""}
+	for i := range expect {
+		if values[i] != expect[i] {
+			t.Errorf("value %s = %q, expect %q", columns[i].Field, values[i], expect[i])
+		}
+	}
+}
+
+func TestCdrWriters(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	stop := time.Date(2020, 10, 18, 15, 4, 5, 0, loc)
+	columns, _ := ParseCdrColumns("username:user,vendor_attrs.huawei_domain:domain")
+	values := CdrValues(testCdrRecord(stop), columns, loc)
+
+	var cbuf bytes.Buffer
+	cw, err := newCsvCdrWriter(&cbuf, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cw.Write(values, stop); err != nil {
+		t.Fatal(err)
+	}
+	if err = cw.Close(1); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&cbuf).ReadAll()
+	if err != nil || len(rows) != 2 || rows[0][0] != "user" || rows[1][1] != "isp<1>" {
+		t.Fatalf("csv error %v %v", rows, err)
+	}
+
+	var xbuf bytes.Buffer
+	xw, err := newXmlCdrWriter(&xbuf, columns, "doc1", stop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = xw.Write(values, stop); err != nil {
+		t.Fatal(err)
+	}
+	if err = xw.Close(1); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		DocId   string `xml:"docId,attr"`
+		Records []struct {
+			Time   string `xml:"time,attr"`
+			User   string `xml:"user"`
+			Domain string `xml:"domain"`
+		} `xml:"IPDR"`
+		End struct {
+			Count int `xml:"count,attr"`
+		} `xml:"IPDRDoc.End"`
+	}
+	if err = xml.Unmarshal(xbuf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.DocId != "doc1" || len(doc.Records) != 1 || doc.End.Count != 1 {
+		t.Fatalf("xml error %s", xbuf.String())
+	}
+	if r := doc.Records[0]; r.User != "tim" || r.Domain != "isp<1>" || r.Time != "2020-10-18T15:04:05+08:00" {
+		t.Fatalf("xml record error %+v", r)
+	}
+}
+
+func TestGetCdrFile(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "cdr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(workdir)
+	m := &CdrManager{&ModelManager{Config: &config.AppConfig{System: config.SysConfig{Workdir: workdir}}}}
+	if err = os.MkdirAll(m.GetCdrDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(path.Join(m.GetCdrDir(), "cdr-20201018.csv"), []byte("user\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.GetCdrFile("cdr-20201018.csv"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"cdr-20201019.csv", "../cdr-20201018.csv", "cdr-20201018.csv.tmp"} {
+		if _, err = m.GetCdrFile(name); err == nil {
+			t.Errorf("%s must be refused", name)
+		}
+	}
+}
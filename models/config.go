@@ -21,6 +21,8 @@ import (
 	"strconv"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/ca17/teamsacs/common/web"
 )
@@ -57,16 +59,35 @@ func (m *ConfigManager) GetConfigValue(ctype, name string) string {
 	return result.Value
 }
 
+// GetRadiusConfigValue
+// Radius configs are read on every request, missing values are cached too
 func (m *ConfigManager) GetRadiusConfigValue(name string) string {
+	return m.GetCachedConfigValue("radius", name)
+}
+
+// GetCachedConfigValue
+// Config value by the document cache, empty if not exists
+func (m *ConfigManager) GetCachedConfigValue(ctype, name string) string {
+	key := ctype + ":" + name
+	if v, ok := m.Cache.Config.Get(key); ok {
+		return v.(string)
+	}
 	coll := m.GetTeamsAcsCollection(TeamsacsConfig)
-	doc := coll.FindOne(context.TODO(), bson.M{"type": "radius", "name": name})
+	doc := coll.FindOne(context.TODO(), bson.M{"type": ctype, "name": name})
 	err := doc.Err()
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			m.Cache.Config.Set(key, "", "")
+		}
 		return ""
 	}
-	var result = ""
-	err = doc.Decode(&result)
-	return result
+	var result = new(Config)
+	err = doc.Decode(result)
+	if err != nil {
+		return ""
+	}
+	m.Cache.Config.Set(key, result.ID, result.Value)
+	return result.Value
 }
 
 func (m *ConfigManager) GetRadiusConfigStringValue(name string, defval string) string {
@@ -94,5 +115,27 @@ func (m *ConfigManager) UpdateConfigValue(ctype, name, value string) error {
 	query := bson.M{"type": ctype, "name": name}
 	update := bson.M{"$set": bson.M{"value": value}}
 	_, err := coll.UpdateOne(context.TODO(), query, update)
+	m.InvalidateCache(TeamsacsConfig)
+	return err
+}
+
+// SaveConfigValue
+// Update the config value, the config is added if not exists
+func (m *ConfigManager) SaveConfigValue(ctype, name, value string) error {
+	coll := m.GetTeamsAcsCollection(TeamsacsConfig)
+	query := bson.M{"type": ctype, "name": name}
+	update := bson.M{
+		"$set":         bson.M{"value": value},
+		"$setOnInsert": bson.M{"_id": ctype + ":" + name},
+	}
+	_, err := coll.UpdateOne(context.TODO(), query, update, options.Update().SetUpsert(true))
+	m.InvalidateCache(TeamsacsConfig)
+	return err
+}
+
+func (m *ConfigManager) DeleteConfigValue(ctype, name string) error {
+	coll := m.GetTeamsAcsCollection(TeamsacsConfig)
+	_, err := coll.DeleteOne(context.TODO(), bson.M{"type": ctype, "name": name})
+	m.InvalidateCache(TeamsacsConfig)
 	return err
 }
@@ -22,8 +22,10 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/common/log"
 	"github.com/ca17/teamsacs/common/web"
 )
 
@@ -84,8 +86,12 @@ func (m *DataManager) AddData(params web.RequestParams) error {
 		data["_id"] = common.UUID()
 	}
 	data["update_time"] = time.Now().Format("2006-01-02 15:04:05 Z0700 MST")
-	coll := m.GetTeamsAcsCollection(params.GetMustString("collname"))
-	_, err := coll.InsertOne(context.TODO(), data)
+	collname := params.GetMustString("collname")
+	_, err := m.GetTeamsAcsCollection(collname).InsertOne(context.TODO(), data)
+	m.InvalidateCache(collname, cacheId(data["_id"]))
+	if err == nil && collname == TeamsacsSubscribe {
+		m.GetSubscribeManager().PublishSubscribeAdd(map[string]interface{}(data))
+	}
 	return err
 }
 
@@ -93,6 +99,12 @@ func (m *DataManager) AddData(params web.RequestParams) error {
 func (m *DataManager) AddBatchData(collname string, datas []interface{}) error {
 	coll := m.GetTeamsAcsCollection(collname)
 	_, err := coll.InsertMany(context.TODO(), datas)
+	m.InvalidateCache(collname)
+	if err == nil && collname == TeamsacsSubscribe {
+		for _, data := range datas {
+			m.GetSubscribeManager().PublishSubscribeAdd(data)
+		}
+	}
 	return err
 }
 
@@ -103,7 +115,12 @@ func (m *DataManager) UpdateData(params web.RequestParams) error {
 	_id := data.GetMustString("_id")
 	query := bson.M{"_id": _id}
 	update := bson.M{"$set": data}
-	_, err := m.GetTeamsAcsCollection(params.GetMustString("collname")).UpdateOne(context.TODO(), query, update)
+	collname := params.GetMustString("collname")
+	_, err := m.GetTeamsAcsCollection(collname).UpdateOne(context.TODO(), query, update)
+	m.InvalidateCache(collname, _id)
+	if err == nil && collname == TeamsacsSubscribe {
+		m.GetSubscribeManager().PublishSubscribeUpdate(data.GetString("username"), data)
+	}
 	return err
 }
 
@@ -116,7 +133,40 @@ func (m *DataManager) DeleteData(params web.RequestParams) error {
 	}
 	collname := params.GetMustString("collname")
 	filter := bson.M{"_id": bson.M{"$in":idarray}}
+	var usernames []string
+	if collname == TeamsacsSubscribe {
+		usernames = m.findSubscribeUsernames(filter)
+	}
 	_, err := m.GetTeamsAcsCollection(collname).DeleteMany(context.TODO(), filter)
+	m.InvalidateCache(collname, strings.Split(ids, ",")...)
+	if err == nil && collname == TeamsacsSubscribe {
+		for _, username := range usernames {
+			m.PublishEvent(EventSubscribeDelete, map[string]interface{}{"username": username})
+		}
+	}
 	return err
 }
 
+// findSubscribeUsernames
+// The usernames of the subscribers to be deleted, for the subscribe.delete events
+func (m *DataManager) findSubscribeUsernames(filter bson.M) []string {
+	usernames := make([]string, 0)
+	if m.Events == nil {
+		return usernames
+	}
+	opts := options.Find().SetProjection(bson.M{"username": 1})
+	cur, err := m.GetTeamsAcsCollection(TeamsacsSubscribe).Find(context.TODO(), filter, opts)
+	if err != nil {
+		log.Error(err)
+		return usernames
+	}
+	defer cur.Close(context.TODO())
+	for cur.Next(context.TODO()) {
+		var user Subscribe
+		if err = cur.Decode(&user); err == nil {
+			usernames = append(usernames, user.GetUsername())
+		}
+	}
+	return usernames
+}
+
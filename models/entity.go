@@ -25,6 +25,16 @@ import (
 
 type DataObject map[string]interface{}
 
+// Copy
+// A shallow copy, cached objects are copied before returned to the caller
+func (d DataObject) Copy() *DataObject {
+	c := make(DataObject, len(d))
+	for k, v := range d {
+		c[k] = v
+	}
+	return &c
+}
+
 func (d DataObject) GetStringValue(key string, defval string) string {
 	val, ok := d[key]
 	if !ok || val == nil || val == "" {
@@ -0,0 +1,167 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/common/log"
+)
+
+// Event types, a filter is a type, a prefix ending with * (radius.*) or * for all
+const (
+	EventAuthAccept      = "radius.auth.accept"
+	EventAuthReject      = "radius.auth.reject"
+	EventAcctStart       = "radius.acct.start"
+	EventAcctStop        = "radius.acct.stop"
+	EventSubscribeAdd    = "subscribe.add"
+	EventSubscribeUpdate = "subscribe.update"
+	EventSubscribeDelete = "subscribe.delete"
+	EventSubscribeExpire = "subscribe.expire"
+	EventSyslogMessage   = "syslog.message"
+	EventWebhookPing     = "webhook.ping"
+
+	EventQueueSize = 10000
+	EventWorkers   = 4
+)
+
+// Event
+type Event struct {
+	ID        string                 `bson:"id" json:"id"`
+	Type      string                 `bson:"type" json:"type"`
+	Timestamp time.Time              `bson:"timestamp" json:"timestamp"`
+	Data      map[string]interface{} `bson:"data" json:"data"`
+}
+
+func NewEvent(etype string, data map[string]interface{}) *Event {
+	return &Event{ID: common.UUID(), Type: etype, Timestamp: time.Now(), Data: data}
+}
+
+// MatchEventFilters
+// Whether the event type matches one of the filters, empty filters match all
+func MatchEventFilters(filters []string, etype string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, filter := range filters {
+		filter = strings.TrimSpace(filter)
+		if filter == "*" || filter == etype {
+			return true
+		}
+		if strings.HasSuffix(filter, "*") && strings.HasPrefix(etype, strings.TrimSuffix(filter, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// EventHandler
+// Consumer of the event bus
+type EventHandler interface {
+	MatchEvent(etype string) bool
+	HandleEvent(event *Event)
+}
+
+// EventBus
+// Events are queued only if a handler matches the type and handled by the workers,
+// the producers are never blocked, events are dropped when the queue is full.
+type EventBus struct {
+	queue    chan *Event
+	lock     sync.RWMutex
+	handlers []EventHandler
+	drops    int64
+}
+
+func NewEventBus(size int) *EventBus {
+	return &EventBus{queue: make(chan *Event, size)}
+}
+
+func (b *EventBus) AddHandler(h EventHandler) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+func (b *EventBus) matchHandlers(etype string) []EventHandler {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	var handlers []EventHandler
+	for _, h := range b.handlers {
+		if h.MatchEvent(etype) {
+			handlers = append(handlers, h)
+		}
+	}
+	return handlers
+}
+
+// Publish
+func (b *EventBus) Publish(event *Event) {
+	if len(b.matchHandlers(event.Type)) == 0 {
+		return
+	}
+	select {
+	case b.queue <- event:
+	default:
+		if n := atomic.AddInt64(&b.drops, 1); n%1000 == 1 {
+			log.Errorf("event queue is full, %d events dropped", n)
+		}
+	}
+}
+
+// Drops
+// The number of events dropped by the full queue
+func (b *EventBus) Drops() int64 {
+	return atomic.LoadInt64(&b.drops)
+}
+
+// Start
+// Handle the queued events by workers goroutines
+func (b *EventBus) Start(workers int) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for event := range b.queue {
+				b.dispatch(event)
+			}
+		}()
+	}
+}
+
+func (b *EventBus) dispatch(event *Event) {
+	for _, h := range b.matchHandlers(event.Type) {
+		func() {
+			defer func() {
+				if ret := recover(); ret != nil {
+					log.Errorf("handle event %s error, %v", event.Type, ret)
+				}
+			}()
+			h.HandleEvent(event)
+		}()
+	}
+}
+
+// PublishEvent
+// Publish to the event bus of the manager, nothing is done without a bus
+func (m *ModelManager) PublishEvent(etype string, data map[string]interface{}) {
+	if m.Events == nil {
+		return
+	}
+	m.Events.Publish(NewEvent(etype, data))
+}
@@ -0,0 +1,103 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMatchEventFilters(t *testing.T) {
+	tests := []struct {
+		filters []string
+		etype   string
+		expect  bool
+	}{
+		{nil, EventAuthAccept, true},
+		{[]string{"*"}, EventSyslogMessage, true},
+		{[]string{EventAuthAccept}, EventAuthAccept, true},
+		{[]string{EventAuthAccept}, EventAuthReject, false},
+		{[]string{"radius.*"}, EventAcctStop, true},
+		{[]string{"radius.auth.*", "subscribe.expire"}, EventSubscribeExpire, true},
+		{[]string{"radius.auth.*"}, EventAcctStart, false},
+	}
+	for _, tt := range tests {
+		if MatchEventFilters(tt.filters, tt.etype) != tt.expect {
+			t.Errorf("MatchEventFilters(%v, %s) expect %v", tt.filters, tt.etype, tt.expect)
+		}
+	}
+}
+
+type testEventHandler struct {
+	filters []string
+	wg      sync.WaitGroup
+	lock    sync.Mutex
+	events  []*Event
+}
+
+func (h *testEventHandler) MatchEvent(etype string) bool {
+	return MatchEventFilters(h.filters, etype)
+}
+
+func (h *testEventHandler) HandleEvent(event *Event) {
+	h.lock.Lock()
+	h.events = append(h.events, event)
+	h.lock.Unlock()
+	h.wg.Done()
+}
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus(10)
+	h := &testEventHandler{filters: []string{"radius.*"}}
+	bus.AddHandler(h)
+	bus.Start(2)
+	h.wg.Add(2)
+	bus.Publish(NewEvent(EventAuthAccept, map[string]interface{}{"username": "tim"}))
+	bus.Publish(NewEvent(EventSyslogMessage, nil))
+	bus.Publish(NewEvent(EventAcctStop, map[string]interface{}{"username": "tim"}))
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 3):
+		t.Fatal("events not handled")
+	}
+	if len(h.events) != 2 {
+		t.Fatalf("handled %d events", len(h.events))
+	}
+	for _, e := range h.events {
+		if e.Type == EventSyslogMessage || e.ID == "" || e.Data["username"] != "tim" {
+			t.Fatalf("event error %+v", e)
+		}
+	}
+}
+
+func TestEventBusFull(t *testing.T) {
+	bus := NewEventBus(1)
+	bus.AddHandler(&testEventHandler{})
+	// not started, the second event is dropped
+	bus.Publish(NewEvent(EventAuthAccept, nil))
+	bus.Publish(NewEvent(EventAuthAccept, nil))
+	if bus.Drops() != 1 {
+		t.Fatalf("drops %d", bus.Drops())
+	}
+}
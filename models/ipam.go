@@ -0,0 +1,677 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/common/log"
+	"github.com/ca17/teamsacs/common/web"
+	"github.com/ca17/teamsacs/constant"
+)
+
+const (
+	IpLeaseOffered  = "offered"
+	IpLeaseActive   = "active"
+	IpLeaseConflict = "conflict"
+
+	IpLeaseActionAllocate = "allocate"
+	IpLeaseActionBind     = "bind"
+	IpLeaseActionRelease  = "release"
+	IpLeaseActionConflict = "conflict"
+
+	// an offered address is released if the session is not started in time
+	IpLeaseOfferTimeout = time.Second * 300
+	IpPoolMaxSize       = 1 << 20
+)
+
+// IpPool
+// Address pool managed by TeamsACS, the subscriber addr_pool names the pool,
+// addresses are leased at Access-Accept by Framed-IP-Address.
+type IpPool struct {
+	ID       string   `bson:"_id,omitempty" json:"id,omitempty"`
+	Name     string   `bson:"name" json:"name"`
+	StartIp  string   `bson:"start_ip" json:"start_ip"`
+	EndIp    string   `bson:"end_ip" json:"end_ip"`
+	Excludes []string `bson:"excludes" json:"excludes"`
+	Status   string   `bson:"status" json:"status"`
+	Remark   string   `bson:"remark" json:"remark"`
+}
+
+// IpLease
+// One leased address, _id is pool:ip so an address is never leased twice
+type IpLease struct {
+	ID            string    `bson:"_id,omitempty" json:"id,omitempty"`
+	Pool          string    `bson:"pool" json:"pool"`
+	Ipaddr        string    `bson:"ipaddr" json:"ipaddr"`
+	Username      string    `bson:"username" json:"username"`
+	AcctSessionId string    `bson:"acct_session_id" json:"acct_session_id"`
+	NasAddr       string    `bson:"nas_addr" json:"nas_addr"`
+	MacAddr       string    `bson:"mac_addr" json:"mac_addr"`
+	Status        string    `bson:"status" json:"status"`
+	LeaseTime     time.Time `bson:"lease_time" json:"lease_time"`
+	ExpireTime    time.Time `bson:"expire_time,omitempty" json:"expire_time,omitempty"`
+	UpdateTime    time.Time `bson:"update_time" json:"update_time"`
+}
+
+// IpLeaseLog
+// Lease history
+type IpLeaseLog struct {
+	ID            string    `bson:"_id,omitempty" json:"id,omitempty"`
+	Pool          string    `bson:"pool" json:"pool"`
+	Ipaddr        string    `bson:"ipaddr" json:"ipaddr"`
+	Username      string    `bson:"username" json:"username"`
+	AcctSessionId string    `bson:"acct_session_id,omitempty" json:"acct_session_id,omitempty"`
+	NasAddr       string    `bson:"nas_addr,omitempty" json:"nas_addr,omitempty"`
+	Action        string    `bson:"action" json:"action"`
+	Reason        string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	Timestamp     time.Time `bson:"timestamp" json:"timestamp"`
+}
+
+// IpPoolUsage
+// Pool utilization
+type IpPoolUsage struct {
+	Name     string  `json:"name"`
+	Total    int64   `json:"total"`
+	Used     int64   `json:"used"`
+	Offered  int64   `json:"offered"`
+	Active   int64   `json:"active"`
+	Conflict int64   `json:"conflict"`
+	Ratio    float64 `json:"ratio"`
+}
+
+func ipv4ToUint(ip string) (uint32, error) {
+	addr := net.ParseIP(ip).To4()
+	if addr == nil {
+		return 0, fmt.Errorf("invalid ipv4 address %s", ip)
+	}
+	return binary.BigEndian.Uint32(addr), nil
+}
+
+func uintToIpv4(n uint32) string {
+	addr := make(net.IP, 4)
+	binary.BigEndian.PutUint32(addr, n)
+	return addr.String()
+}
+
+func (a *IpPool) Validate() error {
+	if common.IsEmptyOrNA(a.Name) {
+		return fmt.Errorf("invalid pool name")
+	}
+	start, err := ipv4ToUint(a.StartIp)
+	if err != nil {
+		return err
+	}
+	end, err := ipv4ToUint(a.EndIp)
+	if err != nil {
+		return err
+	}
+	if start > end || end-start >= IpPoolMaxSize {
+		return fmt.Errorf("invalid pool range %s - %s", a.StartIp, a.EndIp)
+	}
+	for _, ip := range a.Excludes {
+		if _, err := ipv4ToUint(ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Overlaps
+// Whether the ranges of two pools overlap, an address must belong to one pool only
+func (a *IpPool) Overlaps(b *IpPool) bool {
+	return a.Contains(b.StartIp) || a.Contains(b.EndIp) || b.Contains(a.StartIp)
+}
+
+// Contains
+// Whether ip is in the pool range
+func (a *IpPool) Contains(ip string) bool {
+	n, err := ipv4ToUint(ip)
+	if err != nil {
+		return false
+	}
+	start, _ := ipv4ToUint(a.StartIp)
+	end, _ := ipv4ToUint(a.EndIp)
+	return n >= start && n <= end
+}
+
+// Size
+// Number of leasable addresses
+func (a *IpPool) Size() int64 {
+	start, _ := ipv4ToUint(a.StartIp)
+	end, _ := ipv4ToUint(a.EndIp)
+	size := int64(end) - int64(start) + 1
+	for _, ip := range a.Excludes {
+		if a.Contains(ip) {
+			size--
+		}
+	}
+	return size
+}
+
+// nextFree
+// The first address of the pool neither excluded nor in used, empty if the pool is exhausted
+func (a *IpPool) nextFree(used map[string]struct{}) string {
+	start, _ := ipv4ToUint(a.StartIp)
+	end, _ := ipv4ToUint(a.EndIp)
+	excludes := make(map[string]struct{}, len(a.Excludes))
+	for _, ip := range a.Excludes {
+		excludes[ip] = struct{}{}
+	}
+	for n := uint64(start); n <= uint64(end); n++ {
+		ip := uintToIpv4(uint32(n))
+		if _, ok := excludes[ip]; ok {
+			continue
+		}
+		if _, ok := used[ip]; ok {
+			continue
+		}
+		return ip
+	}
+	return ""
+}
+
+func ipLeaseId(pool, ip string) string {
+	return pool + ":" + ip
+}
+
+func isDuplicateKeyError(err error) bool {
+	if we, ok := err.(mongo.WriteException); ok {
+		for _, e := range we.WriteErrors {
+			if e.Code == 11000 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IpamManager
+type IpamManager struct{ *ModelManager }
+
+func (m *ModelManager) GetIpamManager() *IpamManager {
+	store, _ := m.ManagerMap.Get("IpamManager")
+	return store.(*IpamManager)
+}
+
+func (m *IpamManager) QueryIpPools(params web.RequestParams) (*web.PageResult, error) {
+	return m.QueryPagerItems(params, TeamsacsIppool)
+}
+
+func (m *IpamManager) QueryIpLeases(params web.RequestParams) (*web.PageResult, error) {
+	return m.QueryPagerItems(params, TeamsacsIplease)
+}
+
+func (m *IpamManager) QueryIpLeaseLogs(params web.RequestParams) (*web.PageResult, error) {
+	return m.QueryPagerItems(params, TeamsacsIpleaselog)
+}
+
+// GetIpPool
+func (m *IpamManager) GetIpPool(name string) (*IpPool, error) {
+	doc := m.GetTeamsAcsCollection(TeamsacsIppool).FindOne(context.TODO(), bson.M{"name": name})
+	if err := doc.Err(); err != nil {
+		return nil, err
+	}
+	var result = new(IpPool)
+	return result, doc.Decode(result)
+}
+
+func (m *IpamManager) getIpPools(filter bson.M) ([]IpPool, error) {
+	cur, err := m.GetTeamsAcsCollection(TeamsacsIppool).Find(context.TODO(), filter)
+	if err != nil {
+		return nil, err
+	}
+	var pools = make([]IpPool, 0)
+	err = cur.All(context.TODO(), &pools)
+	return pools, err
+}
+
+func (m *IpamManager) checkIpPoolOverlap(pool *IpPool) error {
+	pools, err := m.getIpPools(bson.M{"name": bson.M{"$ne": pool.Name}})
+	if err != nil {
+		return err
+	}
+	for i := range pools {
+		if pool.Overlaps(&pools[i]) {
+			return fmt.Errorf("ip pool range overlaps with pool %s", pools[i].Name)
+		}
+	}
+	return nil
+}
+
+// AddIpPool
+func (m *IpamManager) AddIpPool(pool *IpPool) error {
+	if err := pool.Validate(); err != nil {
+		return err
+	}
+	if _, err := m.GetIpPool(pool.Name); err == nil {
+		return fmt.Errorf("ip pool exists")
+	}
+	if err := m.checkIpPoolOverlap(pool); err != nil {
+		return err
+	}
+	pool.ID = common.UUID()
+	if pool.Status == "" {
+		pool.Status = constant.ENABLED
+	}
+	// the unique index of name rejects the pool added concurrently
+	_, err := m.GetTeamsAcsCollection(TeamsacsIppool).InsertOne(context.TODO(), pool)
+	if isDuplicateKeyError(err) {
+		return fmt.Errorf("ip pool exists")
+	}
+	return err
+}
+
+// SetupIpamIndexes
+// Pool names are unique
+func (m *IpamManager) SetupIpamIndexes() {
+	_, err := m.GetTeamsAcsCollection(TeamsacsIppool).Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Errorf("create ip pool name index error, %s", err.Error())
+	}
+}
+
+// UpdateIpPool
+// Leases out of the new range are kept until they are released
+func (m *IpamManager) UpdateIpPool(pool *IpPool) error {
+	if err := pool.Validate(); err != nil {
+		return err
+	}
+	if err := m.checkIpPoolOverlap(pool); err != nil {
+		return err
+	}
+	data := bson.M{
+		"start_ip": pool.StartIp,
+		"end_ip":   pool.EndIp,
+		"excludes": pool.Excludes,
+		"remark":   pool.Remark,
+	}
+	if common.InSlice(pool.Status, []string{constant.ENABLED, constant.DISABLED}) {
+		data["status"] = pool.Status
+	}
+	_, err := m.GetTeamsAcsCollection(TeamsacsIppool).UpdateOne(context.TODO(), bson.M{"name": pool.Name}, bson.M{"$set": data})
+	return err
+}
+
+// DeleteIpPool
+// A pool with leases can not be deleted
+func (m *IpamManager) DeleteIpPool(name string) error {
+	if common.IsEmptyOrNA(name) {
+		return fmt.Errorf("pool name is empty or NA")
+	}
+	count, err := m.GetTeamsAcsCollection(TeamsacsIplease).CountDocuments(context.TODO(), bson.M{"pool": name})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("ip pool %s has %d leases", name, count)
+	}
+	_, err = m.GetTeamsAcsCollection(TeamsacsIppool).DeleteOne(context.TODO(), bson.M{"name": name})
+	return err
+}
+
+func (m *IpamManager) addLeaseLog(lease *IpLease, action, reason string) {
+	_, err := m.GetTeamsAcsCollection(TeamsacsIpleaselog).InsertOne(context.TODO(), IpLeaseLog{
+		ID:            common.UUID(),
+		Pool:          lease.Pool,
+		Ipaddr:        lease.Ipaddr,
+		Username:      lease.Username,
+		AcctSessionId: lease.AcctSessionId,
+		NasAddr:       lease.NasAddr,
+		Action:        action,
+		Reason:        reason,
+		Timestamp:     time.Now(),
+	})
+	if err != nil {
+		log.Errorf("add ip lease log error, %s", err.Error())
+	}
+}
+
+// AllocateLease
+// Lease a free address of the pool, the insert of pool:ip is the atomic allocation,
+// an address taken concurrently fails with duplicate key and the next one is tried.
+// An offered lease of the user not started yet is reused (e.g. Access-Request retransmission)
+// only if it was offered to the same device, the mac and the NAS must match.
+func (m *IpamManager) AllocateLease(pool *IpPool, username, nasaddr, macaddr string) (*IpLease, error) {
+	coll := m.GetTeamsAcsCollection(TeamsacsIplease)
+	now := time.Now()
+	offered := coll.FindOneAndUpdate(context.TODO(),
+		offeredLeaseFilter(pool.Name, username, nasaddr, macaddr),
+		bson.M{"$set": bson.M{"expire_time": now.Add(IpLeaseOfferTimeout), "update_time": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if offered.Err() == nil {
+		var lease IpLease
+		if err := offered.Decode(&lease); err == nil {
+			return &lease, nil
+		}
+	}
+
+	used, err := m.getLeasedIps(pool.Name)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		ip := pool.nextFree(used)
+		if ip == "" {
+			return nil, fmt.Errorf("ip pool %s exhausted", pool.Name)
+		}
+		used[ip] = struct{}{}
+		// the address is used by an online session without lease, e.g. configured statically
+		if online, err := m.getOnlineByIp(ip); err == nil {
+			m.addConflictLease(pool, online, "address in use without lease")
+			continue
+		}
+		lease := &IpLease{
+			ID:         ipLeaseId(pool.Name, ip),
+			Pool:       pool.Name,
+			Ipaddr:     ip,
+			Username:   username,
+			NasAddr:    nasaddr,
+			MacAddr:    macaddr,
+			Status:     IpLeaseOffered,
+			LeaseTime:  now,
+			ExpireTime: now.Add(IpLeaseOfferTimeout),
+			UpdateTime: now,
+		}
+		_, err := coll.InsertOne(context.TODO(), lease)
+		if isDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		m.addLeaseLog(lease, IpLeaseActionAllocate, "")
+		return lease, nil
+	}
+}
+
+// offeredLeaseFilter
+// The offered lease of the user on the same NAS and mac
+func offeredLeaseFilter(pool, username, nasaddr, macaddr string) bson.M {
+	return bson.M{"pool": pool, "username": username, "nas_addr": nasaddr, "mac_addr": macaddr, "status": IpLeaseOffered}
+}
+
+func (m *IpamManager) getLeasedIps(pool string) (map[string]struct{}, error) {
+	cur, err := m.GetTeamsAcsCollection(TeamsacsIplease).Find(context.TODO(), bson.M{"pool": pool},
+		options.Find().SetProjection(bson.M{"ipaddr": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+	used := make(map[string]struct{})
+	for cur.Next(context.TODO()) {
+		var lease IpLease
+		if err := cur.Decode(&lease); err == nil {
+			used[lease.Ipaddr] = struct{}{}
+		}
+	}
+	return used, cur.Err()
+}
+
+func (m *IpamManager) getOnlineByIp(ip string) (*Accounting, error) {
+	doc := m.GetTeamsAcsCollection(TeamsacsOnline).FindOne(context.TODO(), bson.M{"framed_ipaddr": ip})
+	if err := doc.Err(); err != nil {
+		return nil, err
+	}
+	var online = new(Accounting)
+	return online, doc.Decode(online)
+}
+
+// addConflictLease
+// Record the address used by a session without lease, the address is not leased
+// to others until the session is stopped.
+func (m *IpamManager) addConflictLease(pool *IpPool, online *Accounting, reason string) {
+	now := time.Now()
+	lease := &IpLease{
+		ID:            ipLeaseId(pool.Name, online.FramedIpaddr),
+		Pool:          pool.Name,
+		Ipaddr:        online.FramedIpaddr,
+		Username:      online.Username,
+		AcctSessionId: online.AcctSessionId,
+		NasAddr:       online.NasAddr,
+		MacAddr:       online.MacAddr,
+		Status:        IpLeaseConflict,
+		LeaseTime:     now,
+		UpdateTime:    now,
+	}
+	log.Warningf("ip pool %s address %s conflict, user:%s, %s", pool.Name, lease.Ipaddr, lease.Username, reason)
+	if _, err := m.GetTeamsAcsCollection(TeamsacsIplease).InsertOne(context.TODO(), lease); err != nil && !isDuplicateKeyError(err) {
+		log.Errorf("add conflict lease error, %s", err.Error())
+	}
+	m.addLeaseLog(lease, IpLeaseActionConflict, reason)
+}
+
+// BindLease
+// Accounting of a session, the offered lease of the framed address becomes active.
+// The address leased to another user, or used without lease in a managed pool, is a conflict.
+func (m *IpamManager) BindLease(online *Accounting) {
+	if ip := net.ParseIP(online.FramedIpaddr); ip == nil || ip.IsUnspecified() {
+		return
+	}
+	coll := m.GetTeamsAcsCollection(TeamsacsIplease)
+	doc := coll.FindOne(context.TODO(), bson.M{"ipaddr": online.FramedIpaddr})
+	if doc.Err() == mongo.ErrNoDocuments {
+		pools, err := m.getIpPools(bson.M{"status": constant.ENABLED})
+		if err != nil {
+			log.Errorf("query ip pools error, %s", err.Error())
+			return
+		}
+		for i := range pools {
+			if pools[i].Contains(online.FramedIpaddr) {
+				m.addConflictLease(&pools[i], online, "address in use without lease")
+				return
+			}
+		}
+		return
+	}
+	var lease IpLease
+	if err := doc.Decode(&lease); err != nil {
+		log.Errorf("query ip lease %s error, %s", online.FramedIpaddr, err.Error())
+		return
+	}
+	if lease.Status == IpLeaseConflict {
+		if lease.AcctSessionId == online.AcctSessionId {
+			_, _ = coll.UpdateOne(context.TODO(), bson.M{"_id": lease.ID}, bson.M{"$set": bson.M{"update_time": time.Now()}})
+		}
+		return
+	}
+	if lease.Username != online.Username {
+		if lease.AcctSessionId != online.AcctSessionId {
+			pool := &IpPool{Name: lease.Pool}
+			m.addConflictLease(pool, online, fmt.Sprintf("address leased to user:%s", lease.Username))
+		}
+		return
+	}
+	if lease.Status == IpLeaseActive && lease.AcctSessionId == online.AcctSessionId {
+		_, _ = coll.UpdateOne(context.TODO(), bson.M{"_id": lease.ID}, bson.M{"$set": bson.M{"update_time": time.Now()}})
+		return
+	}
+	lease.AcctSessionId = online.AcctSessionId
+	lease.NasAddr = online.NasAddr
+	_, err := coll.UpdateOne(context.TODO(), bson.M{"_id": lease.ID}, bson.M{
+		"$set":   bson.M{"status": IpLeaseActive, "acct_session_id": online.AcctSessionId, "nas_addr": online.NasAddr, "update_time": time.Now()},
+		"$unset": bson.M{"expire_time": ""},
+	})
+	if err != nil {
+		log.Errorf("bind ip lease %s error, %s", lease.ID, err.Error())
+		return
+	}
+	m.addLeaseLog(&lease, IpLeaseActionBind, "")
+}
+
+// releaseLeases
+// Remove the leases matched by filter and record the history
+func (m *IpamManager) releaseLeases(filter bson.M, reason string) int {
+	coll := m.GetTeamsAcsCollection(TeamsacsIplease)
+	cur, err := coll.Find(context.TODO(), filter)
+	if err != nil {
+		log.Errorf("query ip leases error, %s", err.Error())
+		return 0
+	}
+	var leases []IpLease
+	if err = cur.All(context.TODO(), &leases); err != nil {
+		log.Errorf("decode ip leases error, %s", err.Error())
+		return 0
+	}
+	var count = 0
+	for i := range leases {
+		r, err := coll.DeleteOne(context.TODO(), bson.M{"_id": leases[i].ID, "update_time": leases[i].UpdateTime})
+		if err != nil {
+			log.Errorf("release ip lease %s error, %s", leases[i].ID, err.Error())
+			continue
+		}
+		if r.DeletedCount == 0 {
+			// changed by a concurrent request
+			continue
+		}
+		m.addLeaseLog(&leases[i], IpLeaseActionRelease, reason)
+		count++
+	}
+	return count
+}
+
+// ReleaseSessionLease
+// Accounting-Stop or a lost session, the lease of the session is released,
+// the offered lease of the user and address is also released if the session never started.
+func (m *IpamManager) ReleaseSessionLease(online *Accounting, reason string) {
+	filters := bson.A{bson.M{"acct_session_id": online.AcctSessionId}}
+	if online.FramedIpaddr != "" {
+		filters = append(filters, bson.M{"ipaddr": online.FramedIpaddr, "username": online.Username, "status": IpLeaseOffered})
+	}
+	if online.AcctSessionId == "" {
+		filters = filters[1:]
+	}
+	if len(filters) == 0 {
+		return
+	}
+	m.releaseLeases(bson.M{"$or": filters}, reason)
+}
+
+// ReleaseNasLeases
+// The NAS restarted (Accounting-On/Off), all its leases are released
+func (m *IpamManager) ReleaseNasLeases(nasaddr, reason string) {
+	if nasaddr == "" {
+		return
+	}
+	if n := m.releaseLeases(bson.M{"nas_addr": nasaddr}, reason); n > 0 {
+		log.Infof("release %d ip leases of nas %s, %s", n, nasaddr, reason)
+	}
+}
+
+// ReleaseLease
+// Release the address manually
+func (m *IpamManager) ReleaseLease(pool, ip string) error {
+	if m.releaseLeases(bson.M{"_id": ipLeaseId(pool, ip)}, "released by operator") == 0 {
+		return fmt.Errorf("ip lease %s not found", ipLeaseId(pool, ip))
+	}
+	return nil
+}
+
+// ClearExpireLeases
+// Offered addresses without Accounting-Start and leases of sessions no longer online are released
+func (m *IpamManager) ClearExpireLeases() {
+	now := time.Now()
+	count := m.releaseLeases(bson.M{"status": IpLeaseOffered, "expire_time": bson.M{"$lt": now}}, "offer expired")
+
+	cur, err := m.GetTeamsAcsCollection(TeamsacsIplease).Find(context.TODO(), bson.M{
+		"status":      bson.M{"$in": bson.A{IpLeaseActive, IpLeaseConflict}},
+		"update_time": bson.M{"$lt": now.Add(-IpLeaseOfferTimeout)},
+	})
+	if err != nil {
+		log.Errorf("query ip leases error, %s", err.Error())
+		return
+	}
+	var leases []IpLease
+	if err = cur.All(context.TODO(), &leases); err != nil {
+		log.Errorf("decode ip leases error, %s", err.Error())
+		return
+	}
+	rm := m.GetRadiusManager()
+	for _, lease := range leases {
+		if n, err := rm.GetOnlineCountBySessionid(lease.AcctSessionId); err != nil || n > 0 {
+			continue
+		}
+		count += m.releaseLeases(bson.M{"_id": lease.ID, "acct_session_id": lease.AcctSessionId}, "session lost")
+	}
+	if count > 0 {
+		log.Infof("release %d expire ip leases", count)
+	}
+}
+
+// GetIpPoolUsages
+// Utilization of all pools
+func (m *IpamManager) GetIpPoolUsages() ([]IpPoolUsage, error) {
+	pools, err := m.getIpPools(bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"pool": "$pool", "status": "$status"},
+			"count": bson.M{"$sum": 1},
+		}}},
+	}
+	cur, err := m.GetTeamsAcsCollection(TeamsacsIplease).Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var counts []struct {
+		ID struct {
+			Pool   string `bson:"pool"`
+			Status string `bson:"status"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+	}
+	if err = cur.All(context.TODO(), &counts); err != nil {
+		return nil, err
+	}
+	var usages = make([]IpPoolUsage, 0, len(pools))
+	for i := range pools {
+		usage := IpPoolUsage{Name: pools[i].Name, Total: pools[i].Size()}
+		for _, c := range counts {
+			if c.ID.Pool != usage.Name {
+				continue
+			}
+			switch c.ID.Status {
+			case IpLeaseOffered:
+				usage.Offered = c.Count
+			case IpLeaseActive:
+				usage.Active = c.Count
+			case IpLeaseConflict:
+				usage.Conflict = c.Count
+			}
+			usage.Used += c.Count
+		}
+		if usage.Total > 0 {
+			usage.Ratio = float64(usage.Used) / float64(usage.Total)
+		}
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
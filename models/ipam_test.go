@@ -0,0 +1,81 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"testing"
+)
+
+func TestIpPoolRange(t *testing.T) {
+	pool := &IpPool{Name: "pool1", StartIp: "10.0.0.254", EndIp: "10.0.1.2", Excludes: []string{"10.0.0.255", "10.0.9.1"}}
+	if err := pool.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if size := pool.Size(); size != 4 {
+		t.Fatalf("pool size %d, expect 4", size)
+	}
+	used := map[string]struct{}{"10.0.0.254": {}}
+	var ips []string
+	for ip := pool.nextFree(used); ip != ""; ip = pool.nextFree(used) {
+		used[ip] = struct{}{}
+		ips = append(ips, ip)
+	}
+	expect := []string{"10.0.1.0", "10.0.1.1", "10.0.1.2"}
+	if len(ips) != len(expect) {
+		t.Fatalf("allocated %v, expect %v", ips, expect)
+	}
+	for i := range expect {
+		if ips[i] != expect[i] {
+			t.Fatalf("allocated %v, expect %v", ips, expect)
+		}
+	}
+}
+
+func TestIpPoolValidate(t *testing.T) {
+	tests := []IpPool{
+		{Name: "", StartIp: "10.0.0.1", EndIp: "10.0.0.9"},
+		{Name: "p", StartIp: "10.0.0.9", EndIp: "10.0.0.1"},
+		{Name: "p", StartIp: "10.0.0.1", EndIp: "fe80::1"},
+		{Name: "p", StartIp: "10.0.0.0", EndIp: "10.255.255.255"},
+		{Name: "p", StartIp: "10.0.0.1", EndIp: "10.0.0.9", Excludes: []string{"x"}},
+	}
+	for _, pool := range tests {
+		if err := pool.Validate(); err == nil {
+			t.Errorf("invalid pool %+v passed", pool)
+		}
+	}
+}
+
+func TestIpPoolOverlaps(t *testing.T) {
+	a := &IpPool{StartIp: "10.0.0.10", EndIp: "10.0.0.20"}
+	tests := []struct {
+		start, end string
+		overlaps   bool
+	}{
+		{"10.0.0.1", "10.0.0.9", false},
+		{"10.0.0.1", "10.0.0.10", true},
+		{"10.0.0.12", "10.0.0.15", true},
+		{"10.0.0.1", "10.0.0.30", true},
+		{"10.0.0.21", "10.0.0.30", false},
+	}
+	for _, tt := range tests {
+		b := &IpPool{StartIp: tt.start, EndIp: tt.end}
+		if a.Overlaps(b) != tt.overlaps || b.Overlaps(a) != tt.overlaps {
+			t.Errorf("%s-%s overlaps %v", tt.start, tt.end, !tt.overlaps)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Ipv6Range
+// First and last address of a prefix, as fixed length hex the string order is the address order.
+type Ipv6Range struct {
+	Start string `bson:"start" json:"start"`
+	End   string `bson:"end" json:"end"`
+}
+
+// ParseIpv6Range
+// The range of an ipv6 prefix, an address is a /128 prefix
+func ParseIpv6Range(prefix string) (*Ipv6Range, error) {
+	if !strings.Contains(prefix, "/") {
+		prefix += "/128"
+	}
+	ip, ipnet, err := net.ParseCIDR(prefix)
+	if err != nil || ip.To4() != nil {
+		return nil, fmt.Errorf("invalid ipv6 prefix %s", prefix)
+	}
+	last := make(net.IP, net.IPv6len)
+	for i := range ipnet.IP {
+		last[i] = ipnet.IP[i] | ^ipnet.Mask[i]
+	}
+	return &Ipv6Range{Start: hex.EncodeToString(ipnet.IP), End: hex.EncodeToString(last)}, nil
+}
+
+// SetIpv6Ranges
+// Ranges of the framed address and the framed and delegated prefixes
+func (a *Accounting) SetIpv6Ranges() {
+	a.Ipv6Ranges = nil
+	for _, prefix := range []string{a.FramedIpv6Prefix, a.DelegatedIpv6Prefix, a.FramedIpv6Address} {
+		if prefix == "" {
+			continue
+		}
+		if r, err := ParseIpv6Range(prefix); err == nil {
+			a.Ipv6Ranges = append(a.Ipv6Ranges, *r)
+		}
+	}
+}
+
+// HasIpv6
+func (a *Accounting) HasIpv6() bool {
+	return a.FramedIpv6Prefix != "" || a.FramedIpv6Address != "" || a.FramedInterfaceId != "" || a.DelegatedIpv6Prefix != ""
+}
+
+// getIpv6PrefixFilter
+// Sessions with a prefix overlapping the searched prefix, i.e. prefixes in it or the prefix containing the address
+func getIpv6PrefixFilter(prefix string) (bson.D, error) {
+	r, err := ParseIpv6Range(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return bson.D{{Key: "ipv6_ranges", Value: bson.M{"$elemMatch": bson.M{
+		"start": bson.M{"$lte": r.End},
+		"end":   bson.M{"$gte": r.Start},
+	}}}}, nil
+}
+
+// UpdateRadiusOnlineIpv6
+// IPv6 attributes may be reported by interim after DHCPv6 or SLAAC is completed
+func (m *RadiusManager) UpdateRadiusOnlineIpv6(acct Accounting) error {
+	if !acct.HasIpv6() {
+		return nil
+	}
+	acct.SetIpv6Ranges()
+	data := bson.M{
+		"framed_ipv6_prefix":    acct.FramedIpv6Prefix,
+		"framed_ipv6_address":   acct.FramedIpv6Address,
+		"framed_interface_id":   acct.FramedInterfaceId,
+		"delegated_ipv6_prefix": acct.DelegatedIpv6Prefix,
+		"ipv6_ranges":           acct.Ipv6Ranges,
+	}
+	_, err := m.GetTeamsAcsCollection(TeamsacsOnline).UpdateOne(context.TODO(),
+		bson.M{"acct_session_id": acct.AcctSessionId}, bson.M{"$set": data})
+	return err
+}
@@ -0,0 +1,60 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"testing"
+)
+
+func TestParseIpv6Range(t *testing.T) {
+	r, err := ParseIpv6Range("2001:db8:1::/48")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Start != "20010db8000100000000000000000000" || r.End != "20010db80001ffffffffffffffffffff" {
+		t.Fatalf("range %+v", r)
+	}
+	if _, err = ParseIpv6Range("10.0.0.0/8"); err == nil {
+		t.Fatal("ipv4 prefix parsed")
+	}
+}
+
+func TestAccountingIpv6Ranges(t *testing.T) {
+	acct := Accounting{FramedIpv6Prefix: "2001:db8:1:2::/64", DelegatedIpv6Prefix: "2001:db8:100::/56"}
+	acct.SetIpv6Ranges()
+	overlaps := func(prefix string) bool {
+		q, _ := ParseIpv6Range(prefix)
+		for _, r := range acct.Ipv6Ranges {
+			if r.Start <= q.End && r.End >= q.Start {
+				return true
+			}
+		}
+		return false
+	}
+	tests := map[string]bool{
+		"2001:db8::/32":        true,
+		"2001:db8:1:2::abcd":   true,
+		"2001:db8:100:ff::/64": true,
+		"2001:db8:1:3::/64":    false,
+		"2001:db8:200::1":      false,
+	}
+	for prefix, expect := range tests {
+		if overlaps(prefix) != expect {
+			t.Errorf("%s matched %v", prefix, !expect)
+		}
+	}
+}
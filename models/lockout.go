@@ -0,0 +1,232 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ca17/teamsacs/constant"
+)
+
+const (
+	LockoutTypeUsername = "username"
+	LockoutTypeMac      = "mac"
+
+	LockoutDefaultFailures = 5
+	LockoutDefaultWindow   = 300
+	LockoutDefaultTime     = 600
+
+	// retransmits of a rejected request within the window are counted once
+	LockoutRetransmitWindow = time.Second * 30
+)
+
+// LockoutItem
+// Authentication failures of a username or calling station mac
+type LockoutItem struct {
+	Type         string    `json:"type"`
+	Value        string    `json:"value"`
+	Failures     int       `json:"failures"`
+	FirstFailure time.Time `json:"first_failure"`
+	LastFailure  time.Time `json:"last_failure"`
+	LockedUntil  time.Time `json:"locked_until"`
+}
+
+func (a *LockoutItem) IsLocked(now time.Time) bool {
+	return a.LockedUntil.After(now)
+}
+
+// LockoutStore
+// In-memory failure counters, a key is locked for lockTime after maxFailures
+// failures within window, the counter restarts when the window is passed.
+// The rejected requests are remembered to skip the failures of retransmits.
+type LockoutStore struct {
+	lock     sync.Mutex
+	items    map[string]*LockoutItem
+	requests map[string]time.Time
+}
+
+func NewLockoutStore() *LockoutStore {
+	return &LockoutStore{items: make(map[string]*LockoutItem), requests: make(map[string]time.Time)}
+}
+
+func lockoutKey(ltype, value string) string {
+	return ltype + ":" + strings.ToLower(value)
+}
+
+// LockedUntil
+// The lockout end time and whether the key is locked now
+func (s *LockoutStore) LockedUntil(ltype, value string, now time.Time) (time.Time, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	item, ok := s.items[lockoutKey(ltype, value)]
+	if !ok || !item.IsLocked(now) {
+		return time.Time{}, false
+	}
+	return item.LockedUntil, true
+}
+
+// AddFailure
+// Count a failure, returns true if the key is locked by this failure
+func (s *LockoutStore) AddFailure(ltype, value string, now time.Time, maxFailures int, window, lockTime time.Duration) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := lockoutKey(ltype, value)
+	item, ok := s.items[key]
+	if !ok || (!item.IsLocked(now) && now.Sub(item.FirstFailure) > window) {
+		item = &LockoutItem{Type: ltype, Value: value, FirstFailure: now}
+		s.items[key] = item
+	}
+	if item.IsLocked(now) {
+		item.LastFailure = now
+		return false
+	}
+	item.Failures++
+	item.LastFailure = now
+	if item.Failures >= maxFailures {
+		item.LockedUntil = now.Add(lockTime)
+		return true
+	}
+	return false
+}
+
+// IsRetransmit
+// Returns true if the request of key was already counted within LockoutRetransmitWindow,
+// otherwise the request is remembered.
+func (s *LockoutStore) IsRetransmit(key string, now time.Time) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if last, ok := s.requests[key]; ok && now.Sub(last) <= LockoutRetransmitWindow {
+		return true
+	}
+	s.requests[key] = now
+	return false
+}
+
+// Clear
+// Remove the counter of the key, all counters are removed if ltype is empty
+func (s *LockoutStore) Clear(ltype, value string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if ltype == "" {
+		s.items = make(map[string]*LockoutItem)
+		return
+	}
+	delete(s.items, lockoutKey(ltype, value))
+}
+
+// ClearExpire
+// Remove counters neither locked nor in the failure window
+func (s *LockoutStore) ClearExpire(now time.Time, window time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for key, item := range s.items {
+		if !item.IsLocked(now) && now.Sub(item.LastFailure) > window {
+			delete(s.items, key)
+		}
+	}
+	for key, last := range s.requests {
+		if now.Sub(last) > LockoutRetransmitWindow {
+			delete(s.requests, key)
+		}
+	}
+}
+
+// Items
+// Copy of the counters, only locked ones if lockedOnly
+func (s *LockoutStore) Items(now time.Time, lockedOnly bool) []LockoutItem {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var items = make([]LockoutItem, 0)
+	for _, item := range s.items {
+		if lockedOnly && !item.IsLocked(now) {
+			continue
+		}
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].LastFailure.After(items[j].LastFailure)
+	})
+	return items
+}
+
+// LockoutManager
+// Brute force protection of radius authentication, counters are kept in memory
+type LockoutManager struct {
+	*ModelManager
+	store *LockoutStore
+}
+
+func (m *ModelManager) GetLockoutManager() *LockoutManager {
+	store, _ := m.ManagerMap.Get("LockoutManager")
+	return store.(*LockoutManager)
+}
+
+// GetLockoutConfig
+// Max failures, failure window and lock time, lockout is disabled if max failures is 0
+func (m *LockoutManager) GetLockoutConfig() (int, time.Duration, time.Duration) {
+	cm := m.GetConfigManager()
+	failures := cm.GetRadiusConfigIntValue(constant.RadiusLockoutFailures, LockoutDefaultFailures)
+	window := cm.GetRadiusConfigIntValue(constant.RadiusLockoutWindow, LockoutDefaultWindow)
+	locktime := cm.GetRadiusConfigIntValue(constant.RadiusLockoutTime, LockoutDefaultTime)
+	return int(failures), time.Duration(window) * time.Second, time.Duration(locktime) * time.Second
+}
+
+func (m *LockoutManager) IsEnabled() bool {
+	failures, _, _ := m.GetLockoutConfig()
+	return failures > 0
+}
+
+// GetLockedUntil
+// The lockout end time if username or mac is locked
+func (m *LockoutManager) GetLockedUntil(ltype, value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	return m.store.LockedUntil(ltype, value, time.Now())
+}
+
+// AddFailure
+// Returns true if the username or mac is locked by this failure
+func (m *LockoutManager) AddFailure(ltype, value string) bool {
+	failures, window, locktime := m.GetLockoutConfig()
+	if failures <= 0 || value == "" {
+		return false
+	}
+	return m.store.AddFailure(ltype, value, time.Now(), failures, window, locktime)
+}
+
+// IsRetransmit
+// Returns true if the failure of the request of key is already counted
+func (m *LockoutManager) IsRetransmit(key string) bool {
+	return m.store.IsRetransmit(key, time.Now())
+}
+
+func (m *LockoutManager) ClearLockout(ltype, value string) {
+	m.store.Clear(ltype, value)
+}
+
+func (m *LockoutManager) QueryLockouts(lockedOnly bool) []LockoutItem {
+	return m.store.Items(time.Now(), lockedOnly)
+}
+
+func (m *LockoutManager) ClearExpireLockouts() {
+	_, window, _ := m.GetLockoutConfig()
+	m.store.ClearExpire(time.Now(), window)
+}
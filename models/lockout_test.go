@@ -0,0 +1,90 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutStore(t *testing.T) {
+	s := NewLockoutStore()
+	now := time.Date(2020, 10, 18, 15, 0, 0, 0, time.UTC)
+	window, locktime := time.Minute*5, time.Minute*10
+
+	for i := 1; i < 3; i++ {
+		if s.AddFailure(LockoutTypeUsername, "Test01", now, 3, window, locktime) {
+			t.Fatalf("locked after %d failures", i)
+		}
+	}
+	if !s.AddFailure(LockoutTypeUsername, "test01", now.Add(time.Minute), 3, window, locktime) {
+		t.Fatal("not locked after 3 failures")
+	}
+	until, ok := s.LockedUntil(LockoutTypeUsername, "test01", now.Add(time.Minute*2))
+	if !ok || !until.Equal(now.Add(time.Minute*11)) {
+		t.Fatalf("locked until %s, %v", until, ok)
+	}
+	// failures during lockout do not lock again
+	if s.AddFailure(LockoutTypeUsername, "test01", now.Add(time.Minute*3), 3, window, locktime) {
+		t.Fatal("locked again during lockout")
+	}
+	if _, ok := s.LockedUntil(LockoutTypeUsername, "test01", now.Add(time.Minute*12)); ok {
+		t.Fatal("still locked after lock time")
+	}
+	if n := len(s.Items(now.Add(time.Minute*2), true)); n != 1 {
+		t.Fatalf("%d locked items", n)
+	}
+	s.Clear(LockoutTypeUsername, "test01")
+	if n := len(s.Items(now, false)); n != 0 {
+		t.Fatalf("%d items after clear", n)
+	}
+}
+
+func TestLockoutWindow(t *testing.T) {
+	s := NewLockoutStore()
+	now := time.Date(2020, 10, 18, 15, 0, 0, 0, time.UTC)
+	window, locktime := time.Minute*5, time.Minute*10
+
+	s.AddFailure(LockoutTypeMac, "00:11:22:33:44:55", now, 2, window, locktime)
+	// the window is passed, counter restarts
+	if s.AddFailure(LockoutTypeMac, "00:11:22:33:44:55", now.Add(time.Minute*6), 2, window, locktime) {
+		t.Fatal("locked by failures out of window")
+	}
+	s.ClearExpire(now.Add(time.Minute*12), window)
+	if n := len(s.Items(now, false)); n != 0 {
+		t.Fatalf("%d items after clear expire", n)
+	}
+}
+
+func TestLockoutRetransmit(t *testing.T) {
+	s := NewLockoutStore()
+	now := time.Date(2020, 10, 18, 15, 0, 0, 0, time.UTC)
+	key := "10.0.0.1:1645/12/00112233445566778899aabbccddeeff"
+	if s.IsRetransmit(key, now) {
+		t.Fatal("first request is a retransmit")
+	}
+	if !s.IsRetransmit(key, now.Add(time.Second*5)) {
+		t.Fatal("retransmit not detected")
+	}
+	if s.IsRetransmit("10.0.0.1:1645/13/00112233445566778899aabbccddeeff", now.Add(time.Second*5)) {
+		t.Fatal("new identifier is a retransmit")
+	}
+	s.ClearExpire(now.Add(LockoutRetransmitWindow*2), time.Minute)
+	if s.IsRetransmit(key, now.Add(LockoutRetransmitWindow*2)) {
+		t.Fatal("retransmit after the window")
+	}
+}
@@ -28,23 +28,38 @@ import (
 
 	"github.com/ca17/teamsacs/common"
 	"github.com/ca17/teamsacs/common/gmail"
+	"github.com/ca17/teamsacs/common/metrics"
 	"github.com/ca17/teamsacs/common/mongodb"
 	"github.com/ca17/teamsacs/common/tpl"
 	"github.com/ca17/teamsacs/config"
 )
 
 const (
-	MDBTeamsacs        = "teamsacs"
-	MDBGenieacs        = "genieacs"
-	TeamsacsConfig     = "config"
-	TeamsacsOperator   = "operator"
-	TeamsacsSubscribe  = "subscribe"
-	TeamsacsVpe        = "vpe"
-	TeamsacsCpe        = "cpe"
-	TeamsacsOnline     = "online"
-	TeamsacsAccounting = "accounting"
-	TeamsacsAuthlog    = "authlog"
-	TeamsacsSyslog     = "syslog"
+	MDBTeamsacs          = "teamsacs"
+	MDBGenieacs          = "genieacs"
+	TeamsacsConfig       = "config"
+	TeamsacsOperator     = "operator"
+	TeamsacsSubscribe    = "subscribe"
+	TeamsacsVpe          = "vpe"
+	TeamsacsCpe          = "cpe"
+	TeamsacsOnline       = "online"
+	TeamsacsAccounting   = "accounting"
+	TeamsacsAuthlog      = "authlog"
+	TeamsacsSyslog       = "syslog"
+	TeamsacsCoalog       = "coalog"
+	TeamsacsRealm        = "realm"
+	TeamsacsQuota        = "quota"
+	TeamsacsPurgelog     = "purgelog"
+	TeamsacsIppool       = "ippool"
+	TeamsacsIplease      = "iplease"
+	TeamsacsIpleaselog   = "ipleaselog"
+	TeamsacsSchedule     = "schedule"
+	TeamsacsProduct      = "product"
+	TeamsacsVoucher      = "voucher"
+	TeamsacsVoucherBatch = "voucherbatch"
+	TeamsacsAcctTimeline = "accttimeline"
+	TeamsacsWebhook      = "webhook"
+	TeamsacsWebhookDead  = "webhookdead"
 
 	GenieacsDevices = "devices"
 	GenieacsFaults  = "faults"
@@ -70,21 +85,31 @@ type ModelManager struct {
 	WebJwtConfig *middleware.JWTConfig
 	MailSender   *gmail.MailSender
 	ManagerMap   cmap.ConcurrentMap
+	Cache        *CacheManager
+	Events       *EventBus
 	Dev          bool
 }
 
 func NewModelManager(appconfig *config.AppConfig, dev bool) *ModelManager {
 	m := &ModelManager{Config: appconfig, Dev: dev}
 	m.ManagerMap = cmap.New()
-	_mongodb, err := mongodb.GetMongodbClient(appconfig.Mongodb)
+	_mongodb, err := mongodb.GetMongodbClient(appconfig.Mongodb, metrics.NewMongoMonitor())
 	common.Must(err)
 	m.Mongo = _mongodb
 	loc, err := time.LoadLocation(appconfig.System.Location)
 	common.Must(err)
 	m.Location = loc
 	m.registerManagers()
+	metrics.RegisterOnlineSessions(m.GetRadiusManager().GetOnlineSessions)
+	m.Cache = NewCacheManager(CacheDefaultTTL)
+	go m.WatchCacheChanges()
+	m.Events = NewEventBus(EventQueueSize)
+	m.Events.AddHandler(m.GetWebhookManager())
+	m.Events.Start(EventWorkers)
 	m.TplRender = tpl.NewCommonTemplate([]string{"/resources/templates"}, m.Dev, m.GetTemplateFuncMap())
 	m.SetupSyslogDB()
+	m.GetIpamManager().SetupIpamIndexes()
+	m.GetRadiusManager().SetupRadiusIndexes()
 	go m.StartScheduler()
 	return m
 }
@@ -101,7 +126,7 @@ func (m *ModelManager) SetupSyslogDB() {
 }
 
 func (m *ModelManager) registerManagers() {
-	m.ManagerMap.Set("SubscribeManager", &SubscribeManager{m})
+	m.ManagerMap.Set("SubscribeManager", &SubscribeManager{ModelManager: m})
 	m.ManagerMap.Set("RadiusManager", &RadiusManager{m})
 	m.ManagerMap.Set("VpeManager", &VpeManager{m})
 	m.ManagerMap.Set("OperatorManager", &OperatorManager{m})
@@ -109,6 +134,15 @@ func (m *ModelManager) registerManagers() {
 	m.ManagerMap.Set("ConfigManager", &ConfigManager{m})
 	m.ManagerMap.Set("GenieacsManager", &GenieacsManager{m})
 	m.ManagerMap.Set("DataManager", &DataManager{m})
+	m.ManagerMap.Set("RealmManager", &RealmManager{m})
+	m.ManagerMap.Set("QuotaManager", &QuotaManager{m})
+	m.ManagerMap.Set("LockoutManager", &LockoutManager{m, NewLockoutStore()})
+	m.ManagerMap.Set("IpamManager", &IpamManager{m})
+	m.ManagerMap.Set("ScheduleManager", &ScheduleManager{m})
+	m.ManagerMap.Set("ProductManager", &ProductManager{m})
+	m.ManagerMap.Set("VoucherManager", &VoucherManager{m})
+	m.ManagerMap.Set("CdrManager", &CdrManager{m})
+	m.ManagerMap.Set("WebhookManager", newWebhookManager(m))
 }
 
 func (m *ModelManager) GetTeamsAcsCollection(coll string) *mongo.Collection {
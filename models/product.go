@@ -0,0 +1,318 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/common/web"
+	"github.com/ca17/teamsacs/constant"
+)
+
+// Product
+// Service plan, the subscriber product_id names the product. Fields of the product are
+// the defaults of its subscribers, zero values are not set by the product.
+type Product struct {
+	ID              string `bson:"_id,omitempty" json:"id,omitempty"`
+	Name            string `bson:"name" json:"name"`
+	UpRate          int    `bson:"up_rate" json:"up_rate"`
+	DownRate        int    `bson:"down_rate" json:"down_rate"`
+	AddrPool        string `bson:"addr_pool" json:"addr_pool"`
+	Ipv6AddrPool    string `bson:"ipv6_addr_pool" json:"ipv6_addr_pool"`
+	Domain          string `bson:"domain" json:"domain"`
+	LimitPolicy     string `bson:"limit_policy" json:"limit_policy"`
+	UpLimitPolicy   string `bson:"up_limit_policy" json:"up_limit_policy"`
+	DownLimitPolicy string `bson:"down_limit_policy" json:"down_limit_policy"`
+	InterimInterval int    `bson:"interim_interval" json:"interim_interval"`
+	ActiveNum       int    `bson:"active_num" json:"active_num"`
+	QuotaBytes      int64  `bson:"quota_bytes" json:"quota_bytes"`
+	QuotaSeconds    int64  `bson:"quota_seconds" json:"quota_seconds"`
+	QuotaCycle      string `bson:"quota_cycle" json:"quota_cycle"`
+	QuotaAction     string `bson:"quota_action" json:"quota_action"`
+	SchedulePolicy  string `bson:"schedule_policy" json:"schedule_policy"`
+	ValidDays       int    `bson:"valid_days" json:"valid_days"`
+	Status          string `bson:"status" json:"status"`
+	Remark          string `bson:"remark" json:"remark"`
+}
+
+func (a *Product) Validate() error {
+	switch {
+	case common.IsEmptyOrNA(a.Name):
+		return fmt.Errorf("invalid product name")
+	case a.UpRate < 0 || a.DownRate < 0 || a.InterimInterval < 0 || a.ActiveNum < 0 || a.ValidDays < 0:
+		return fmt.Errorf("product values must not be negative")
+	case a.QuotaBytes < 0 || a.QuotaSeconds < 0:
+		return fmt.Errorf("product quota must not be negative")
+	case a.QuotaCycle != "" && !common.InSlice(a.QuotaCycle, []string{QuotaCycleDaily, QuotaCycleMonthly, QuotaCycleLifetime}):
+		return fmt.Errorf("invalid quota cycle %s", a.QuotaCycle)
+	case a.QuotaAction != "" && !common.InSlice(a.QuotaAction, []string{QuotaActionDisconnect, QuotaActionThrottle}):
+		return fmt.Errorf("invalid quota action %s", a.QuotaAction)
+	}
+	return nil
+}
+
+// Attributes
+// The subscriber fields set by the product
+func (a *Product) Attributes() map[string]interface{} {
+	attrs := make(map[string]interface{})
+	setInt := func(name string, v int64) {
+		if v != 0 {
+			attrs[name] = v
+		}
+	}
+	setString := func(name string, v string) {
+		if common.IsNotEmptyAndNA(v) {
+			attrs[name] = v
+		}
+	}
+	setInt("up_rate", int64(a.UpRate))
+	setInt("down_rate", int64(a.DownRate))
+	setString("addr_pool", a.AddrPool)
+	setString("ipv6_addr_pool", a.Ipv6AddrPool)
+	setString("domain", a.Domain)
+	setString("limit_policy", a.LimitPolicy)
+	setString("up_limit_policy", a.UpLimitPolicy)
+	setString("down_limit_policy", a.DownLimitPolicy)
+	setInt("interim_interval", int64(a.InterimInterval))
+	setInt("active_num", int64(a.ActiveNum))
+	setInt("quota_bytes", a.QuotaBytes)
+	setInt("quota_seconds", a.QuotaSeconds)
+	setString("quota_cycle", a.QuotaCycle)
+	setString("quota_action", a.QuotaAction)
+	setString("schedule_policy", a.SchedulePolicy)
+	return attrs
+}
+
+// productFields
+// The subscriber fields a product may set, cleared from the subscribers to follow a new product
+var productFields = []string{
+	"up_rate", "down_rate", "addr_pool", "ipv6_addr_pool", "domain",
+	"limit_policy", "up_limit_policy", "down_limit_policy", "interim_interval", "active_num",
+	"quota_bytes", "quota_seconds", "quota_cycle", "quota_action", "schedule_policy",
+}
+
+// Merge
+// The subscriber with the product defaults, a subscriber field overrides the product
+// unless it is missing, empty or N/A.
+func (a *Product) Merge(user *Subscribe) *Subscribe {
+	result := user.Copy()
+	for name, value := range a.Attributes() {
+		switch v := (*user)[name].(type) {
+		case nil:
+		case string:
+			if common.IsNotEmptyAndNA(v) {
+				continue
+			}
+		default:
+			continue
+		}
+		(*result)[name] = value
+	}
+	return result
+}
+
+func (a Subscribe) GetProductId() string {
+	return a.GetStringValue("product_id", constant.NA)
+}
+
+// ProductManager
+type ProductManager struct{ *ModelManager }
+
+func (m *ModelManager) GetProductManager() *ProductManager {
+	store, _ := m.ManagerMap.Get("ProductManager")
+	return store.(*ProductManager)
+}
+
+func (m *ProductManager) QueryProducts(params web.RequestParams) (*web.PageResult, error) {
+	return m.QueryPagerItems(params, TeamsacsProduct)
+}
+
+// GetProduct
+// Product lookup on every request of the product subscribers, missing products are cached too
+func (m *ProductManager) GetProduct(id string) (*Product, error) {
+	if v, ok := m.Cache.Product.Get(id); ok {
+		if v.(*Product) == nil {
+			return nil, mongo.ErrNoDocuments
+		}
+		return v.(*Product), nil
+	}
+	doc := m.GetTeamsAcsCollection(TeamsacsProduct).FindOne(context.TODO(), bson.M{"_id": id})
+	err := doc.Err()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			m.Cache.Product.Set(id, "", (*Product)(nil))
+		}
+		return nil, err
+	}
+	var result = new(Product)
+	err = doc.Decode(result)
+	if err != nil {
+		return nil, err
+	}
+	m.Cache.Product.Set(id, result.ID, result)
+	return result, nil
+}
+
+// GetEffectiveSubscribe
+// The subscriber merged with the defaults of its product, a missing or disabled product is an error
+func (m *ProductManager) GetEffectiveSubscribe(user *Subscribe) (*Subscribe, error) {
+	id := user.GetProductId()
+	if common.IsEmptyOrNA(id) {
+		return user, nil
+	}
+	product, err := m.GetProduct(id)
+	if err != nil {
+		return nil, fmt.Errorf("user:%s product %s error, %s", user.GetUsername(), id, err.Error())
+	}
+	if product.Status == constant.DISABLED {
+		return nil, fmt.Errorf("user:%s product %s is disabled", user.GetUsername(), product.Name)
+	}
+	return product.Merge(user), nil
+}
+
+// AddProduct
+func (m *ProductManager) AddProduct(product *Product) error {
+	if err := product.Validate(); err != nil {
+		return err
+	}
+	coll := m.GetTeamsAcsCollection(TeamsacsProduct)
+	count, err := coll.CountDocuments(context.TODO(), bson.M{"name": product.Name})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("product exists")
+	}
+	if common.IsEmptyOrNA(product.ID) {
+		product.ID = common.UUID()
+	}
+	if product.Status == "" {
+		product.Status = constant.ENABLED
+	}
+	_, err = coll.InsertOne(context.TODO(), product)
+	m.InvalidateCache(TeamsacsProduct)
+	return err
+}
+
+// UpdateProduct
+// The subscribers follow the updated product at the next request
+func (m *ProductManager) UpdateProduct(product *Product) error {
+	if err := product.Validate(); err != nil {
+		return err
+	}
+	if common.IsEmptyOrNA(product.ID) {
+		return fmt.Errorf("product id is empty or NA")
+	}
+	data := bson.M{
+		"name":              product.Name,
+		"up_rate":           product.UpRate,
+		"down_rate":         product.DownRate,
+		"addr_pool":         product.AddrPool,
+		"ipv6_addr_pool":    product.Ipv6AddrPool,
+		"domain":            product.Domain,
+		"limit_policy":      product.LimitPolicy,
+		"up_limit_policy":   product.UpLimitPolicy,
+		"down_limit_policy": product.DownLimitPolicy,
+		"interim_interval":  product.InterimInterval,
+		"active_num":        product.ActiveNum,
+		"quota_bytes":       product.QuotaBytes,
+		"quota_seconds":     product.QuotaSeconds,
+		"quota_cycle":       product.QuotaCycle,
+		"quota_action":      product.QuotaAction,
+		"schedule_policy":   product.SchedulePolicy,
+		"valid_days":        product.ValidDays,
+		"remark":            product.Remark,
+	}
+	if common.InSlice(product.Status, []string{constant.ENABLED, constant.DISABLED}) {
+		data["status"] = product.Status
+	}
+	_, err := m.GetTeamsAcsCollection(TeamsacsProduct).UpdateOne(context.TODO(), bson.M{"_id": product.ID}, bson.M{"$set": data})
+	m.InvalidateCache(TeamsacsProduct)
+	return err
+}
+
+// DeleteProduct
+// Products with subscribers are not deleted, migrate the subscribers first
+func (m *ProductManager) DeleteProduct(id string) error {
+	if common.IsEmptyOrNA(id) {
+		return fmt.Errorf("product id is empty or NA")
+	}
+	count, err := m.GetTeamsAcsCollection(TeamsacsSubscribe).CountDocuments(context.TODO(), bson.M{"product_id": id})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("product has %d subscribers", count)
+	}
+	_, err = m.GetTeamsAcsCollection(TeamsacsProduct).DeleteOne(context.TODO(), bson.M{"_id": id})
+	m.InvalidateCache(TeamsacsProduct)
+	return err
+}
+
+// ProductMigration
+// Move the subscribers of a product or the listed subscribers to another product
+type ProductMigration struct {
+	FromProductId string   `json:"from_product_id"`
+	ToProductId   string   `json:"to_product_id"`
+	Usernames     []string `json:"usernames"`
+	// remove the subscriber overrides of the product fields
+	ClearOverrides bool `json:"clear_overrides"`
+	// set the expire time to now plus the valid days of the new product
+	Renew bool `json:"renew"`
+}
+
+// MigrateSubscribes
+// Returns the number of the migrated subscribers, online sessions get the new product at the next login or CoA
+func (m *ProductManager) MigrateSubscribes(mig *ProductMigration) (int64, error) {
+	product, err := m.GetProduct(mig.ToProductId)
+	if err != nil {
+		return 0, fmt.Errorf("product %s error, %s", mig.ToProductId, err.Error())
+	}
+	var filter bson.M
+	switch {
+	case len(mig.Usernames) > 0:
+		filter = bson.M{"username": bson.M{"$in": mig.Usernames}}
+	case common.IsNotEmptyAndNA(mig.FromProductId):
+		filter = bson.M{"product_id": mig.FromProductId}
+	default:
+		return 0, fmt.Errorf("from product or usernames is required")
+	}
+	set := bson.M{"product_id": product.ID, "update_time": time.Now()}
+	if mig.Renew && product.ValidDays > 0 {
+		set["expire_time"] = time.Now().Add(time.Hour * 24 * time.Duration(product.ValidDays))
+	}
+	update := bson.M{"$set": set}
+	if mig.ClearOverrides {
+		unset := bson.M{}
+		for _, name := range productFields {
+			unset[name] = ""
+		}
+		update["$unset"] = unset
+	}
+	result, err := m.GetTeamsAcsCollection(TeamsacsSubscribe).UpdateMany(context.TODO(), filter, update)
+	m.InvalidateCache(TeamsacsSubscribe)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,84 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"testing"
+)
+
+func TestProductMerge(t *testing.T) {
+	product := &Product{
+		Name:        "100m",
+		UpRate:      10240,
+		DownRate:    102400,
+		AddrPool:    "pool1",
+		Domain:      "isp",
+		QuotaBytes:  1 << 30,
+		QuotaCycle:  QuotaCycleMonthly,
+		LimitPolicy: "N/A",
+	}
+	user := &Subscribe{
+		"username":   "tim",
+		"product_id": "p1",
+		"down_rate":  int32(204800),
+		"addr_pool":  "N/A",
+		"domain":     "",
+		"ipaddr":     "10.0.0.2",
+	}
+	merged := product.Merge(user)
+	tests := []struct {
+		name   string
+		value  interface{}
+		expect interface{}
+	}{
+		{"product up rate", merged.GetUpRateKbps(), 10240},
+		{"user down rate", merged.GetDownRateKbps(), 204800},
+		{"N/A user pool", merged.GetAddrPool(), "pool1"},
+		{"empty user domain", merged.GetDomain(), "isp"},
+		{"user ipaddr", merged.GetIpaddr(), "10.0.0.2"},
+		{"product quota", merged.GetQuotaBytes(), int64(1 << 30)},
+		{"product quota cycle", merged.GetQuotaCycle(), QuotaCycleMonthly},
+		{"N/A product policy", merged.GetLimitPolicy(), "N/A"},
+	}
+	for _, tt := range tests {
+		if tt.value != tt.expect {
+			t.Errorf("%s %v, expect %v", tt.name, tt.value, tt.expect)
+		}
+	}
+	if _, ok := (*user)["up_rate"]; ok {
+		t.Error("merge must not change the subscriber")
+	}
+}
+
+func TestProductValidate(t *testing.T) {
+	tests := []struct {
+		product Product
+		valid   bool
+	}{
+		{Product{Name: "10m", UpRate: 1024, DownRate: 10240}, true},
+		{Product{Name: "quota", QuotaBytes: 1 << 30, QuotaCycle: QuotaCycleDaily, QuotaAction: QuotaActionThrottle}, true},
+		{Product{Name: ""}, false},
+		{Product{Name: "neg", UpRate: -1}, false},
+		{Product{Name: "cycle", QuotaCycle: "weekly"}, false},
+		{Product{Name: "action", QuotaAction: "block"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.product.Validate(); (err == nil) != tt.valid {
+			t.Errorf("product %s valid %v, %v", tt.product.Name, tt.valid, err)
+		}
+	}
+}
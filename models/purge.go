@@ -0,0 +1,186 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/common/log"
+	"github.com/ca17/teamsacs/common/web"
+	"github.com/ca17/teamsacs/constant"
+)
+
+// PurgeLog
+// Report of a history purge
+type PurgeLog struct {
+	ID        string    `bson:"_id,omitempty" json:"id,omitempty"`
+	Collname  string    `bson:"collname" json:"collname"`
+	Before    time.Time `bson:"before" json:"before"`
+	Exported  int64     `bson:"exported" json:"exported"`
+	Deleted   int64     `bson:"deleted" json:"deleted"`
+	Archive   string    `bson:"archive,omitempty" json:"archive,omitempty"`
+	Error     string    `bson:"error,omitempty" json:"error,omitempty"`
+	Cast      int       `bson:"cast" json:"cast"`
+	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
+}
+
+func (m *ModelManager) QueryPurgeLogs(params web.RequestParams) (*web.PageResult, error) {
+	return m.QueryPagerItems(params, TeamsacsPurgelog)
+}
+
+// PurgeRadiusHistory
+// Delete accounting, authlog, accounting timeline and syslog records older than the configured days,
+// records are exported to gzip JSON Lines archives in the backup dir first if RadiusPurgeBackup is enabled.
+// syslog is a capped collection, mongodb before 5.0 refuses the delete and the error is saved to purgelog.
+func (m *ModelManager) PurgeRadiusHistory() []*PurgeLog {
+	cm := m.GetConfigManager()
+	backup := cm.GetRadiusConfigStringValue(constant.RadiusPurgeBackup, constant.ENABLED) == constant.ENABLED
+	return []*PurgeLog{
+		m.PurgeHistory(TeamsacsAccounting, "acct_stop_time", cm.GetRadiusConfigIntValue(constant.RadiuslogHistoryDays, 180), backup),
+		m.PurgeHistory(TeamsacsAuthlog, "timestamp", cm.GetRadiusConfigIntValue(constant.RadiusAuthlogHistoryDays, 30), backup),
+		m.PurgeHistory(TeamsacsAcctTimeline, "timestamp", cm.GetRadiusConfigIntValue(constant.RadiusTimelineHistoryDays, 7), backup),
+		m.PurgeHistory(TeamsacsSyslog, "timestamp", cm.GetRadiusConfigIntValue(constant.SyslogHistoryDays, 90), backup),
+	}
+}
+
+// historyStore
+// The records of a history collection, the purge is tested without mongodb
+type historyStore interface {
+	Each(filter bson.M, fn func(doc bson.Raw) error) error
+	DeleteMany(filter bson.M) (int64, error)
+}
+
+type mongoHistoryStore struct {
+	coll *mongo.Collection
+}
+
+func (s mongoHistoryStore) Each(filter bson.M, fn func(doc bson.Raw) error) error {
+	cur, err := s.coll.Find(context.TODO(), filter)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(context.TODO())
+	for cur.Next(context.TODO()) {
+		if err = fn(cur.Current); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+func (s mongoHistoryStore) DeleteMany(filter bson.M) (int64, error) {
+	r, err := s.coll.DeleteMany(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return r.DeletedCount, nil
+}
+
+// PurgeHistory
+// Delete the records of collname whose timeField is older than days, the report is saved to purgelog.
+func (m *ModelManager) PurgeHistory(collname, timeField string, days int64, backup bool) *PurgeLog {
+	plog := m.purgeHistory(mongoHistoryStore{m.GetTeamsAcsCollection(collname)}, collname, timeField, days, backup)
+	if _, err := m.GetTeamsAcsCollection(TeamsacsPurgelog).InsertOne(context.TODO(), plog); err != nil {
+		log.Error(err)
+	}
+	return plog
+}
+
+func (m *ModelManager) purgeHistory(store historyStore, collname, timeField string, days int64, backup bool) *PurgeLog {
+	var start = time.Now()
+	plog := &PurgeLog{
+		ID:       common.UUID(),
+		Collname: collname,
+		Before:   start.Add(-time.Hour * 24 * time.Duration(days)),
+	}
+	filter := bson.M{timeField: bson.M{"$lt": plog.Before}}
+	err := func() error {
+		if days <= 0 {
+			return fmt.Errorf("history days %d invalid, skip", days)
+		}
+		if backup {
+			archive, count, err := m.exportHistory(store, collname, filter, start)
+			if err != nil {
+				return err
+			}
+			plog.Archive = archive
+			plog.Exported = count
+		}
+		deleted, err := store.DeleteMany(filter)
+		if err != nil {
+			return err
+		}
+		plog.Deleted = deleted
+		return nil
+	}()
+	if err != nil {
+		plog.Error = err.Error()
+		log.Errorf("purge %s history error, %s", collname, err.Error())
+	} else {
+		log.Infof("purge %s history before %s, exported %d, deleted %d", collname, plog.Before.Format(time.RFC3339), plog.Exported, plog.Deleted)
+	}
+	plog.Cast = int(time.Since(start).Milliseconds())
+	plog.Timestamp = time.Now()
+	return plog
+}
+
+// exportHistory
+// Write the matched records as relaxed extended JSON, one record per line.
+// No archive is left if nothing matched.
+func (m *ModelManager) exportHistory(store historyStore, collname string, filter bson.M, now time.Time) (string, int64, error) {
+	backupDir := m.Config.GetBackupDir()
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		return "", 0, err
+	}
+	archive := path.Join(backupDir, fmt.Sprintf("%s-%s.jsonl.gz", collname, now.Format("20060102150405")))
+	f, err := os.Create(archive)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+
+	var count int64
+	err = store.Each(filter, func(doc bson.Raw) error {
+		line, err := bson.MarshalExtJSON(doc, false, false)
+		if err != nil {
+			return err
+		}
+		if _, err = gw.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err == nil {
+		err = gw.Close()
+	}
+	if err != nil || count == 0 {
+		_ = os.Remove(archive)
+		return "", 0, err
+	}
+	return archive, count, nil
+}
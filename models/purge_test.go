@@ -0,0 +1,127 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/ca17/teamsacs/config"
+)
+
+// memHistoryStore
+// The records are matched by the $lt condition of the time field like the purge filter
+type memHistoryStore struct {
+	timeField string
+	docs      []bson.M
+}
+
+func (s *memHistoryStore) match(filter bson.M, doc bson.M) bool {
+	before := filter[s.timeField].(bson.M)["$lt"].(time.Time)
+	return doc[s.timeField].(time.Time).Before(before)
+}
+
+func (s *memHistoryStore) Each(filter bson.M, fn func(doc bson.Raw) error) error {
+	for _, doc := range s.docs {
+		if !s.match(filter, doc) {
+			continue
+		}
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		if err = fn(raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memHistoryStore) DeleteMany(filter bson.M) (int64, error) {
+	var deleted int64
+	var docs []bson.M
+	for _, doc := range s.docs {
+		if s.match(filter, doc) {
+			deleted++
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	s.docs = docs
+	return deleted, nil
+}
+
+func TestPurgeHistory(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "teamsacs-purge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(workdir)
+	cfg := *config.DefaultAppConfig
+	cfg.System.Workdir = workdir
+	m := &ModelManager{Config: &cfg}
+
+	now := time.Now()
+	store := &memHistoryStore{timeField: "timestamp", docs: []bson.M{
+		{"_id": "1", "logtype": "radius", "timestamp": now.Add(-time.Hour * 24 * 100)},
+		{"_id": "2", "logtype": "radius", "timestamp": now.Add(-time.Hour)},
+	}}
+	plog := m.purgeHistory(store, TeamsacsSyslog, "timestamp", 90, true)
+	if plog.Error != "" || plog.Exported != 1 || plog.Deleted != 1 || plog.Archive == "" {
+		t.Fatalf("purge log %+v", plog)
+	}
+	if len(store.docs) != 1 || store.docs[0]["_id"] != "2" {
+		t.Fatalf("remain records %v", store.docs)
+	}
+
+	f, err := os.Open(plog.Archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(gr)
+	var lines []bson.M
+	for scanner.Scan() {
+		var doc bson.M
+		if err = bson.UnmarshalExtJSON(scanner.Bytes(), false, &doc); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, doc)
+	}
+	if len(lines) != 1 || lines[0]["_id"] != "1" {
+		t.Fatalf("archive records %v", lines)
+	}
+
+	// nothing to purge, no archive is left
+	plog = m.purgeHistory(store, TeamsacsSyslog, "timestamp", 90, true)
+	if plog.Error != "" || plog.Exported != 0 || plog.Deleted != 0 || plog.Archive != "" {
+		t.Fatalf("empty purge log %+v", plog)
+	}
+	if plog = m.purgeHistory(store, TeamsacsSyslog, "timestamp", 0, true); plog.Error == "" {
+		t.Fatal("days 0 must be refused")
+	}
+}
@@ -153,12 +153,18 @@ func (m *ModelManager) QueryItemOptions(params web.RequestParams, collatiion str
 }
 
 func (m *ModelManager) QueryPagerItems(params web.RequestParams, collatiion string) (*web.PageResult, error) {
+	return m.QueryPagerItemsWithFilter(params, collatiion, nil)
+}
+
+// QueryPagerItemsWithFilter
+// QueryPagerItems with conditions not expressible by the request params
+func (m *ModelManager) QueryPagerItemsWithFilter(params web.RequestParams, collatiion string, filter bson.D) (*web.PageResult, error) {
 	var findOptions = options.Find()
 	var pos = params.GetInt64WithDefval("start", 0)
 	findOptions.SetSkip(pos)
 	findOptions.SetLimit(params.GetInt64WithDefval("count", 40))
 	coll := m.GetTeamsAcsCollection(collatiion)
-	q := processQueryParams(params, findOptions)
+	q := append(processQueryParams(params, findOptions), filter...)
 	logQueryParams(q)
 	cur, err := coll.Find(context.TODO(), q, findOptions)
 	if err != nil {
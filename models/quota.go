@@ -0,0 +1,353 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/ca17/teamsacs/common/web"
+)
+
+const (
+	QuotaCycleDaily    = "daily"
+	QuotaCycleMonthly  = "monthly"
+	QuotaCycleLifetime = "lifetime"
+
+	QuotaActionDisconnect = "disconnect"
+	QuotaActionThrottle   = "throttle"
+
+	quotaUpdateRetries = 5
+	// a stopped session is kept to ignore the retransmitted or late stop
+	quotaStoppedSessionKeep = time.Hour * 24
+)
+
+// ErrQuotaUsageConflict
+// The usage was changed by another request since it was read
+var ErrQuotaUsageConflict = errors.New("quota usage changed by another request")
+
+// Subscribe quota attributes, 0 means unlimited
+
+func (a Subscribe) GetQuotaBytes() int64 {
+	return a.GetInt64Value("quota_bytes", 0)
+}
+
+func (a Subscribe) GetQuotaSeconds() int64 {
+	return a.GetInt64Value("quota_seconds", 0)
+}
+
+func (a Subscribe) GetQuotaCycle() string {
+	return a.GetStringValue("quota_cycle", QuotaCycleLifetime)
+}
+
+func (a Subscribe) GetQuotaAction() string {
+	return a.GetStringValue("quota_action", QuotaActionDisconnect)
+}
+
+// GetQuotaThrottleRate
+// The up and down rate (kbps) applied when the quota is exhausted and the action is throttle
+func (a Subscribe) GetQuotaThrottleRate() (int, int) {
+	return a.GetIntValue("quota_throttle_up_rate", 64), a.GetIntValue("quota_throttle_down_rate", 64)
+}
+
+func (a Subscribe) HasQuota() bool {
+	return a.GetQuotaBytes() > 0 || a.GetQuotaSeconds() > 0
+}
+
+// QuotaSession
+// The last reported counters of an online session, usage is accumulated by the delta.
+// A stopped session keeps the final counters and is not counted again.
+type QuotaSession struct {
+	InputTotal  int64     `bson:"input_total" json:"input_total"`
+	OutputTotal int64     `bson:"output_total" json:"output_total"`
+	SessionTime int64     `bson:"session_time" json:"session_time"`
+	Exhausted   bool      `bson:"exhausted" json:"exhausted"`
+	Stopped     bool      `bson:"stopped,omitempty" json:"stopped,omitempty"`
+	StopTime    time.Time `bson:"stop_time,omitempty" json:"stop_time,omitempty"`
+}
+
+// QuotaUsage
+// Usage of the current cycle, one document per subscriber, Version is increased by every
+// update to detect concurrent updates of the same subscriber.
+type QuotaUsage struct {
+	ID          string                  `bson:"_id" json:"username"`
+	Cycle       string                  `bson:"cycle" json:"cycle"`
+	CycleStart  time.Time               `bson:"cycle_start" json:"cycle_start"`
+	UsedBytes   int64                   `bson:"used_bytes" json:"used_bytes"`
+	UsedSeconds int64                   `bson:"used_seconds" json:"used_seconds"`
+	Sessions    map[string]QuotaSession `bson:"sessions" json:"sessions"`
+	UpdateTime  time.Time               `bson:"update_time" json:"update_time"`
+	Version     int64                   `bson:"version" json:"version"`
+
+	// the document exists, loaded by GetQuotaUsage or saved
+	exists bool
+}
+
+// GetQuotaCycleStart
+// Start time of the cycle now belongs to, in the location of now
+func GetQuotaCycleStart(cycle string, now time.Time) time.Time {
+	switch cycle {
+	case QuotaCycleDaily:
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	case QuotaCycleMonthly:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	}
+	return time.Time{}
+}
+
+// RemainBytes
+// -1 means unlimited
+func (u *QuotaUsage) RemainBytes(user *Subscribe) int64 {
+	quota := user.GetQuotaBytes()
+	if quota <= 0 {
+		return -1
+	}
+	if u.UsedBytes >= quota {
+		return 0
+	}
+	return quota - u.UsedBytes
+}
+
+// RemainSeconds
+// -1 means unlimited
+func (u *QuotaUsage) RemainSeconds(user *Subscribe) int64 {
+	quota := user.GetQuotaSeconds()
+	if quota <= 0 {
+		return -1
+	}
+	if u.UsedSeconds >= quota {
+		return 0
+	}
+	return quota - u.UsedSeconds
+}
+
+func (u *QuotaUsage) IsExhausted(user *Subscribe) bool {
+	return u.RemainBytes(user) == 0 || u.RemainSeconds(user) == 0
+}
+
+// resetCycle
+// Usage is cleared when a new cycle begins, the counters of the online sessions are kept to
+// continue the delta, the stopped sessions are removed.
+func (u *QuotaUsage) resetCycle(cycle string, now time.Time) {
+	start := GetQuotaCycleStart(cycle, now)
+	if u.Cycle != cycle || !u.CycleStart.Equal(start) {
+		u.Cycle = cycle
+		u.CycleStart = start
+		u.UsedBytes = 0
+		u.UsedSeconds = 0
+		for sid, session := range u.Sessions {
+			if session.Stopped {
+				delete(u.Sessions, sid)
+				continue
+			}
+			session.Exhausted = false
+			u.Sessions[sid] = session
+		}
+	}
+}
+
+// pruneStopped
+// The lifetime cycle never resets, the stopped sessions are removed after quotaStoppedSessionKeep
+func (u *QuotaUsage) pruneStopped(now time.Time) {
+	for sid, session := range u.Sessions {
+		if session.Stopped && now.Sub(session.StopTime) > quotaStoppedSessionKeep {
+			delete(u.Sessions, sid)
+		}
+	}
+}
+
+// Add
+// Accumulate the delta of the session counters, see AcctCounterDelta for wraps and restarts.
+// A stopped session is not counted.
+func (u *QuotaUsage) Add(sessionid string, inputTotal, outputTotal, sessionTime int64) {
+	last := u.Sessions[sessionid]
+	if last.Stopped {
+		return
+	}
+	u.UsedBytes += AcctCounterDelta(last.InputTotal, inputTotal) + AcctCounterDelta(last.OutputTotal, outputTotal)
+	u.UsedSeconds += AcctCounterDelta(last.SessionTime, sessionTime)
+	last.InputTotal = inputTotal
+	last.OutputTotal = outputTotal
+	last.SessionTime = sessionTime
+	u.Sessions[sessionid] = last
+}
+
+// Stop
+// Accumulate the final counters and mark the session stopped, false if it was stopped already
+func (u *QuotaUsage) Stop(sessionid string, inputTotal, outputTotal, sessionTime int64, now time.Time) bool {
+	if u.Sessions[sessionid].Stopped {
+		return false
+	}
+	u.Add(sessionid, inputTotal, outputTotal, sessionTime)
+	session := u.Sessions[sessionid]
+	session.Stopped = true
+	session.StopTime = now
+	u.Sessions[sessionid] = session
+	return true
+}
+
+// QuotaManager
+type QuotaManager struct{ *ModelManager }
+
+func (m *ModelManager) GetQuotaManager() *QuotaManager {
+	store, _ := m.ManagerMap.Get("QuotaManager")
+	return store.(*QuotaManager)
+}
+
+func (m *QuotaManager) QueryQuotaUsages(params web.RequestParams) (*web.PageResult, error) {
+	return m.QueryPagerItems(params, TeamsacsQuota)
+}
+
+// GetQuotaUsage
+// Usage of the current cycle, an empty usage is returned if not exists
+func (m *QuotaManager) GetQuotaUsage(user *Subscribe) (*QuotaUsage, error) {
+	username := user.GetUsername()
+	usage := &QuotaUsage{ID: username, Sessions: make(map[string]QuotaSession)}
+	doc := m.GetTeamsAcsCollection(TeamsacsQuota).FindOne(context.TODO(), bson.M{"_id": username})
+	if err := doc.Err(); err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	} else if err == nil {
+		if err = doc.Decode(usage); err != nil {
+			return nil, err
+		}
+		if usage.Sessions == nil {
+			usage.Sessions = make(map[string]QuotaSession)
+		}
+		usage.exists = true
+	}
+	now := time.Now().In(m.Location)
+	usage.resetCycle(user.GetQuotaCycle(), now)
+	usage.pruneStopped(now)
+	return usage, nil
+}
+
+// UpdateQuotaUsage
+// Save the usage read by GetQuotaUsage, ErrQuotaUsageConflict is returned if the usage
+// was created or changed by another request since it was read.
+func (m *QuotaManager) UpdateQuotaUsage(usage *QuotaUsage) error {
+	coll := m.GetTeamsAcsCollection(TeamsacsQuota)
+	version := usage.Version
+	usage.UpdateTime = time.Now()
+	usage.Version = version + 1
+	if !usage.exists {
+		_, err := coll.InsertOne(context.TODO(), usage)
+		switch {
+		case isDuplicateKeyError(err):
+			usage.Version = version
+			return ErrQuotaUsageConflict
+		case err != nil:
+			usage.Version = version
+			return err
+		}
+		usage.exists = true
+		return nil
+	}
+	result, err := coll.ReplaceOne(context.TODO(), bson.M{"_id": usage.ID, "version": version}, usage)
+	if err != nil {
+		usage.Version = version
+		return err
+	}
+	if result.MatchedCount == 0 {
+		usage.Version = version
+		return ErrQuotaUsageConflict
+	}
+	return nil
+}
+
+// ModifyQuotaUsage
+// Apply modify to the usage of the current cycle and save it, modify is applied again
+// to the latest usage if the usage was changed by another request meanwhile.
+func (m *QuotaManager) ModifyQuotaUsage(user *Subscribe, modify func(usage *QuotaUsage)) (*QuotaUsage, error) {
+	for i := 1; ; i++ {
+		usage, err := m.GetQuotaUsage(user)
+		if err != nil {
+			return nil, err
+		}
+		modify(usage)
+		err = m.UpdateQuotaUsage(usage)
+		if err != ErrQuotaUsageConflict || i >= quotaUpdateRetries {
+			return usage, err
+		}
+	}
+}
+
+// ResetQuotaUsage
+// Clear the usage of the current cycle, the online sessions are counted from now on
+func (m *QuotaManager) ResetQuotaUsage(username string) error {
+	return m.updateQuotaUsageById(username, func(usage *QuotaUsage) bool {
+		usage.UsedBytes = 0
+		usage.UsedSeconds = 0
+		for sid, session := range usage.Sessions {
+			session.Exhausted = false
+			usage.Sessions[sid] = session
+		}
+		return true
+	})
+}
+
+// StopQuotaSessions
+// Mark the sessions removed without accounting stop (stale or cleared by Accounting-On) stopped,
+// they are pruned like the stopped sessions and a late stop is not counted.
+func (m *QuotaManager) StopQuotaSessions(username string, sessionids ...string) error {
+	err := m.updateQuotaUsageById(username, func(usage *QuotaUsage) bool {
+		now := time.Now()
+		changed := false
+		for _, sid := range sessionids {
+			if session, ok := usage.Sessions[sid]; ok && !session.Stopped {
+				session.Stopped = true
+				session.StopTime = now
+				usage.Sessions[sid] = session
+				changed = true
+			}
+		}
+		return changed
+	})
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	return err
+}
+
+// updateQuotaUsageById
+// Apply modify to the saved usage without the cycle check, the usage is saved if modify returns true
+func (m *QuotaManager) updateQuotaUsageById(username string, modify func(usage *QuotaUsage) bool) error {
+	for i := 1; ; i++ {
+		doc := m.GetTeamsAcsCollection(TeamsacsQuota).FindOne(context.TODO(), bson.M{"_id": username})
+		if err := doc.Err(); err != nil {
+			return err
+		}
+		var usage = new(QuotaUsage)
+		if err := doc.Decode(usage); err != nil {
+			return err
+		}
+		usage.exists = true
+		if usage.Sessions == nil {
+			usage.Sessions = make(map[string]QuotaSession)
+		}
+		if !modify(usage) {
+			return nil
+		}
+		err := m.UpdateQuotaUsage(usage)
+		if err != ErrQuotaUsageConflict || i >= quotaUpdateRetries {
+			return err
+		}
+	}
+}
@@ -0,0 +1,92 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuotaCycleStart(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	now := time.Date(2020, 10, 18, 15, 4, 5, 0, loc)
+	tests := []struct {
+		cycle  string
+		expect time.Time
+	}{
+		{QuotaCycleDaily, time.Date(2020, 10, 18, 0, 0, 0, 0, loc)},
+		{QuotaCycleMonthly, time.Date(2020, 10, 1, 0, 0, 0, 0, loc)},
+		{QuotaCycleLifetime, time.Time{}},
+	}
+	for _, tt := range tests {
+		if start := GetQuotaCycleStart(tt.cycle, now); !start.Equal(tt.expect) {
+			t.Errorf("%s cycle start %s, expect %s", tt.cycle, start, tt.expect)
+		}
+	}
+}
+
+func TestQuotaUsageAdd(t *testing.T) {
+	user := &Subscribe{"username": "tim", "quota_bytes": int64(1000), "quota_seconds": int64(60)}
+	usage := &QuotaUsage{ID: "tim", Sessions: make(map[string]QuotaSession)}
+	usage.Add("s1", 100, 200, 10)
+	usage.Add("s1", 300, 300, 30)
+	// the counter restarted
+	usage.Add("s1", 50, 0, 40)
+	if usage.UsedBytes != 650 || usage.UsedSeconds != 40 {
+		t.Fatalf("usage error bytes=%d seconds=%d", usage.UsedBytes, usage.UsedSeconds)
+	}
+	if usage.RemainBytes(user) != 350 || usage.RemainSeconds(user) != 20 || usage.IsExhausted(user) {
+		t.Fatal("remain error")
+	}
+	usage.Add("s2", 400, 0, 0)
+	if usage.RemainBytes(user) != 0 || !usage.IsExhausted(user) {
+		t.Fatal("quota must be exhausted")
+	}
+}
+
+func TestQuotaUsageStop(t *testing.T) {
+	now := time.Now()
+	usage := &QuotaUsage{ID: "tim", Sessions: make(map[string]QuotaSession)}
+	usage.Add("s1", 100, 200, 10)
+	if !usage.Stop("s1", 300, 300, 30, now) {
+		t.Fatal("the first stop must be counted")
+	}
+	// the retransmitted stop and a late interim are not counted again
+	if usage.Stop("s1", 300, 300, 30, now) {
+		t.Fatal("the retransmitted stop must be ignored")
+	}
+	usage.Add("s1", 400, 400, 40)
+	if usage.UsedBytes != 600 || usage.UsedSeconds != 30 {
+		t.Fatalf("usage error bytes=%d seconds=%d", usage.UsedBytes, usage.UsedSeconds)
+	}
+
+	usage.Add("s2", 100, 0, 10)
+	usage.pruneStopped(now.Add(quotaStoppedSessionKeep))
+	if _, ok := usage.Sessions["s1"]; !ok {
+		t.Fatal("the stopped session must be kept")
+	}
+	usage.pruneStopped(now.Add(quotaStoppedSessionKeep + time.Second))
+	if _, ok := usage.Sessions["s1"]; ok {
+		t.Fatal("the stopped session must be pruned")
+	}
+
+	usage.Stop("s3", 100, 0, 10, now)
+	usage.resetCycle(QuotaCycleDaily, now)
+	if _, ok := usage.Sessions["s3"]; ok || usage.Sessions["s2"].InputTotal != 100 || usage.UsedBytes != 0 {
+		t.Fatalf("cycle reset error %+v", usage)
+	}
+}
@@ -21,13 +21,22 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/ca17/teamsacs/common"
 	"github.com/ca17/teamsacs/common/log"
 	"github.com/ca17/teamsacs/common/web"
+	"github.com/ca17/teamsacs/constant"
 )
 
+// AcctTerminateCauseStale
+// Terminate cause of the stop records written for lost sessions
+const AcctTerminateCauseStale = "Stale-Session"
+
+// the stop records saved within the window are replaced by the stop of the same session
+const acctStopRepeatWindow = time.Hour * 24
+
 type Authlog struct {
 	ID        string    `bson:"_id,omitempty" json:"id,omitempty"`
 	Username  string    `bson:"username,omitempty" json:"username,omitempty"`
@@ -41,31 +50,80 @@ type Authlog struct {
 // Accounting
 // Radius Accounting Recode
 type Accounting struct {
-	ID                string    `bson:"_id,omitempty" json:"id,omitempty"`
-	Username          string    `bson:"username,omitempty" json:"username,omitempty"`
-	NasId             string    `bson:"nas_id,omitempty" json:"nas_id,omitempty"`
-	NasAddr           string    `bson:"nas_addr,omitempty" json:"nas_addr,omitempty"`
-	NasPaddr          string    `bson:"nas_paddr,omitempty" json:"nas_paddr,omitempty"`
-	SessionTimeout    int       `bson:"session_timeout,omitempty" json:"session_timeout,omitempty"`
-	FramedIpaddr      string    `bson:"framed_ipaddr,omitempty" json:"framed_ipaddr,omitempty"`
-	FramedNetmask     string    `bson:"framed_netmask,omitempty" json:"framed_netmask,omitempty"`
-	MacAddr           string    `bson:"mac_addr,omitempty" json:"mac_addr,omitempty"`
-	NasPort           int64     `bson:"nas_port,omitempty" json:"nas_port,omitempty,string"`
-	NasClass          string    `bson:"nas_class,omitempty" json:"nas_class,omitempty"`
-	NasPortId         string    `bson:"nas_port_id,omitempty" json:"nas_port_id,omitempty"`
-	NasPortType       int       `bson:"nas_port_type,omitempty" json:"nas_port_type,omitempty"`
-	ServiceType       int       `bson:"service_type,omitempty" json:"service_type,omitempty"`
-	AcctSessionId     string    `bson:"acct_session_id,omitempty" json:"acct_session_id,omitempty"`
-	AcctSessionTime   int       `bson:"acct_session_time,omitempty" json:"acct_session_time,omitempty"`
-	AcctInputTotal    int64     `bson:"acct_input_total,omitempty" json:"acct_input_total,omitempty,string"`
-	AcctOutputTotal   int64     `bson:"acct_output_total,omitempty" json:"acct_output_total,omitempty,string"`
-	AcctInputPackets  int       `bson:"acct_input_packets,omitempty" json:"acct_input_packets,omitempty"`
-	AcctOutputPackets int       `bson:"acct_output_packets,omitempty" json:"acct_output_packets,omitempty"`
-	AcctStartTime     time.Time `bson:"acct_start_time,omitempty" json:"acct_start_time,omitempty"`
-	LastUpdate        time.Time `bson:"last_update,omitempty" json:"last_update,omitempty"`
-	AcctStopTime      time.Time `bson:"acct_stop_time,omitempty" json:"acct_stop_time,omitempty"`
+	ID                  string            `bson:"_id,omitempty" json:"id,omitempty"`
+	Username            string            `bson:"username,omitempty" json:"username,omitempty"`
+	NasId               string            `bson:"nas_id,omitempty" json:"nas_id,omitempty"`
+	NasAddr             string            `bson:"nas_addr,omitempty" json:"nas_addr,omitempty"`
+	NasPaddr            string            `bson:"nas_paddr,omitempty" json:"nas_paddr,omitempty"`
+	SessionTimeout      int               `bson:"session_timeout,omitempty" json:"session_timeout,omitempty"`
+	FramedIpaddr        string            `bson:"framed_ipaddr,omitempty" json:"framed_ipaddr,omitempty"`
+	FramedNetmask       string            `bson:"framed_netmask,omitempty" json:"framed_netmask,omitempty"`
+	FramedIpv6Prefix    string            `bson:"framed_ipv6_prefix,omitempty" json:"framed_ipv6_prefix,omitempty"`
+	FramedIpv6Address   string            `bson:"framed_ipv6_address,omitempty" json:"framed_ipv6_address,omitempty"`
+	FramedInterfaceId   string            `bson:"framed_interface_id,omitempty" json:"framed_interface_id,omitempty"`
+	DelegatedIpv6Prefix string            `bson:"delegated_ipv6_prefix,omitempty" json:"delegated_ipv6_prefix,omitempty"`
+	Ipv6Ranges          []Ipv6Range       `bson:"ipv6_ranges,omitempty" json:"-"`
+	MacAddr             string            `bson:"mac_addr,omitempty" json:"mac_addr,omitempty"`
+	NasPort             int64             `bson:"nas_port,omitempty" json:"nas_port,omitempty,string"`
+	NasClass            string            `bson:"nas_class,omitempty" json:"nas_class,omitempty"`
+	NasPortId           string            `bson:"nas_port_id,omitempty" json:"nas_port_id,omitempty"`
+	NasPortType         int               `bson:"nas_port_type,omitempty" json:"nas_port_type,omitempty"`
+	ServiceType         int               `bson:"service_type,omitempty" json:"service_type,omitempty"`
+	AcctSessionId       string            `bson:"acct_session_id,omitempty" json:"acct_session_id,omitempty"`
+	AcctSessionTime     int               `bson:"acct_session_time,omitempty" json:"acct_session_time,omitempty"`
+	AcctInputTotal      int64             `bson:"acct_input_total,omitempty" json:"acct_input_total,omitempty,string"`
+	AcctOutputTotal     int64             `bson:"acct_output_total,omitempty" json:"acct_output_total,omitempty,string"`
+	AcctInputCounter    int64             `bson:"acct_input_counter,omitempty" json:"acct_input_counter,omitempty,string"`
+	AcctOutputCounter   int64             `bson:"acct_output_counter,omitempty" json:"acct_output_counter,omitempty,string"`
+	AcctInputPackets    int               `bson:"acct_input_packets,omitempty" json:"acct_input_packets,omitempty"`
+	AcctOutputPackets   int               `bson:"acct_output_packets,omitempty" json:"acct_output_packets,omitempty"`
+	AcctStartTime       time.Time         `bson:"acct_start_time,omitempty" json:"acct_start_time,omitempty"`
+	LastUpdate          time.Time         `bson:"last_update,omitempty" json:"last_update,omitempty"`
+	InterimInterval     int               `bson:"interim_interval,omitempty" json:"interim_interval,omitempty"`
+	AcctStopTime        time.Time         `bson:"acct_stop_time,omitempty" json:"acct_stop_time,omitempty"`
+	AcctTerminateCause  string            `bson:"acct_terminate_cause,omitempty" json:"acct_terminate_cause,omitempty"`
+	VendorAttrs         map[string]string `bson:"vendor_attrs,omitempty" json:"vendor_attrs,omitempty"`
 }
 
+// EventData
+// The session fields of the radius.acct.* events
+func (a *Accounting) EventData() map[string]interface{} {
+	data := map[string]interface{}{
+		"username":          a.Username,
+		"acct_session_id":   a.AcctSessionId,
+		"nas_id":            a.NasId,
+		"nas_addr":          a.NasAddr,
+		"nas_port_id":       a.NasPortId,
+		"mac_addr":          a.MacAddr,
+		"framed_ipaddr":     a.FramedIpaddr,
+		"acct_start_time":   a.AcctStartTime,
+		"acct_session_time": a.AcctSessionTime,
+		"acct_input_total":  a.AcctInputTotal,
+		"acct_output_total": a.AcctOutputTotal,
+	}
+	if !a.AcctStopTime.IsZero() {
+		data["acct_stop_time"] = a.AcctStopTime
+		data["acct_terminate_cause"] = a.AcctTerminateCause
+	}
+	return data
+}
+
+// CoaLog
+// Radius Disconnect-Request and CoA-Request recode
+type CoaLog struct {
+	ID            string    `bson:"_id,omitempty" json:"id,omitempty"`
+	Type          string    `bson:"type,omitempty" json:"type,omitempty"`
+	Username      string    `bson:"username,omitempty" json:"username,omitempty"`
+	AcctSessionId string    `bson:"acct_session_id,omitempty" json:"acct_session_id,omitempty"`
+	NasAddr       string    `bson:"nas_addr,omitempty" json:"nas_addr,omitempty"`
+	CoaPort       int       `bson:"coa_port,omitempty" json:"coa_port,omitempty"`
+	Result        string    `bson:"result,omitempty" json:"result,omitempty"`
+	ErrorCause    string    `bson:"error_cause,omitempty" json:"error_cause,omitempty"`
+	Reason        string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	Operator      string    `bson:"operator,omitempty" json:"operator,omitempty"`
+	Cast          int       `bson:"cast,omitempty" json:"cast,omitempty"`
+	Timestamp     time.Time `bson:"timestamp,omitempty" json:"timestamp,omitempty"`
+}
 
 type RadiusManager struct{ *ModelManager }
 
@@ -81,6 +139,19 @@ func (m *RadiusManager) GetOnlineCount(username string) (int64, error) {
 }
 
 
+// GetOnlineSessions
+// The number of online sessions from the collection metadata, for the metrics scrape
+func (m *RadiusManager) GetOnlineSessions() float64 {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	count, err := m.GetTeamsAcsCollection(TeamsacsOnline).EstimatedDocumentCount(ctx)
+	if err != nil {
+		log.Errorf("count online sessions error, %s", err.Error())
+		return 0
+	}
+	return float64(count)
+}
+
 func (m *RadiusManager) GetOnlineCountBySessionid(acct_session_id string) (int64, error) {
 	coll := m.GetTeamsAcsCollection(TeamsacsOnline)
 	return coll.CountDocuments(context.TODO(), bson.M{"acct_session_id": acct_session_id})
@@ -95,8 +166,42 @@ func (m *RadiusManager) QueryAuthlogs(params web.RequestParams) (*web.PageResult
 	return m.QueryPagerItems(params, TeamsacsAuthlog)
 }
 
+// QueryOnlines
+// querymap.ipv6_prefix searches the sessions whose framed or delegated prefix is in the prefix,
+// an address matches the session prefix it belongs to.
 func (m *RadiusManager) QueryOnlines(params web.RequestParams) (*web.PageResult, error) {
-	return m.QueryPagerItems(params, TeamsacsOnline)
+	prefix := params.GetQueryMap().GetString("ipv6_prefix")
+	if prefix == "" {
+		return m.QueryPagerItems(params, TeamsacsOnline)
+	}
+	filter, err := getIpv6PrefixFilter(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return m.QueryPagerItemsWithFilter(params, TeamsacsOnline, filter)
+}
+
+func (m *RadiusManager) QueryCoaLogs(params web.RequestParams) (*web.PageResult, error) {
+	return m.QueryPagerItems(params, TeamsacsCoalog)
+}
+
+// GetRadiusOnline
+func (m *RadiusManager) GetRadiusOnline(sessionid string) (*Accounting, error) {
+	doc := m.GetTeamsAcsCollection(TeamsacsOnline).FindOne(context.TODO(), bson.M{"acct_session_id": sessionid})
+	err := doc.Err()
+	if err != nil {
+		return nil, err
+	}
+	var result = new(Accounting)
+	err = doc.Decode(result)
+	return result, err
+}
+
+func (m *RadiusManager) AddRadiusCoaLog(coalog *CoaLog) error {
+	coalog.ID = common.UUID()
+	coalog.Timestamp = time.Now()
+	_, err := m.GetTeamsAcsCollection(TeamsacsCoalog).InsertOne(context.TODO(), coalog)
+	return err
 }
 
 func (m *RadiusManager) AddRadiusAuthLog(username string, nasip string, result string, reason string, cast int64) error {
@@ -114,6 +219,8 @@ func (m *RadiusManager) AddRadiusAuthLog(username string, nasip string, result s
 	return err
 }
 
+// BatchClearRadiusOnlineDataByNas
+// Remove the online sessions of the NAS, the quota sessions of them are stopped
 func (m *RadiusManager) BatchClearRadiusOnlineDataByNas(nasip, nasid string) error {
 	coll := m.GetTeamsAcsCollection(TeamsacsOnline)
 	filter := bson.D{
@@ -123,17 +230,45 @@ func (m *RadiusManager) BatchClearRadiusOnlineDataByNas(nasip, nasid string) err
 				bson.D{{"nas_id", nasid}},
 			}},
 	}
-	_, err := coll.DeleteMany(context.TODO(), filter)
-	return err
+	var onlines []Accounting
+	cur, err := coll.Find(context.TODO(), filter, options.Find().SetProjection(bson.M{"username": 1, "acct_session_id": 1}))
+	if err != nil {
+		return err
+	}
+	if err = cur.All(context.TODO(), &onlines); err != nil {
+		return err
+	}
+	if _, err = coll.DeleteMany(context.TODO(), filter); err != nil {
+		return err
+	}
+	sessions := make(map[string][]string)
+	for _, online := range onlines {
+		sessions[online.Username] = append(sessions[online.Username], online.AcctSessionId)
+	}
+	for username, sessionids := range sessions {
+		if err = m.GetQuotaManager().StopQuotaSessions(username, sessionids...); err != nil {
+			log.Errorf("stop quota sessions user:%s error, %s", username, err.Error())
+		}
+	}
+	return nil
 }
 
 func (m *RadiusManager) AddRadiusOnline(ol Accounting) error {
+	if ol.ID == "" {
+		ol.ID = common.UUID()
+	}
+	ol.SetIpv6Ranges()
 	_, err := m.GetTeamsAcsCollection(TeamsacsOnline).InsertOne(context.TODO(), ol)
 	return err
 }
 
+// AddRadiusAccounting
+// The stop time is now unless already set
 func (m *RadiusManager) AddRadiusAccounting(acct Accounting) error {
-	acct.AcctStopTime = time.Now()
+	acct.ID = common.UUID()
+	if acct.AcctStopTime.IsZero() {
+		acct.AcctStopTime = time.Now()
+	}
 	_, err := m.GetTeamsAcsCollection(TeamsacsAccounting).InsertOne(context.TODO(), acct)
 	return err
 }
@@ -144,27 +279,139 @@ func (m *RadiusManager) DeleteRadiusOnline(sessionid string) error {
 }
 
 
+// UpdateRadiusOnlineData
+// Interim update of an online session, the absolute counters of the request are saved and
+// the totals are accumulated by the delta, the sample is appended to the session timeline.
+// The session is added if not online (the start request was lost).
 func (m *RadiusManager) UpdateRadiusOnlineData(acct Accounting) error {
-	data := bson.D{
-		{"$inc", bson.D{
-			{"acct_input_total", acct.AcctInputTotal},
-			{"acct_output_total", acct.AcctOutputTotal},
-			{"acct_input_packets", acct.AcctInputPackets},
-			{"acct_output_packets", acct.AcctOutputPackets},
-			{"acct_input_total", acct.AcctSessionTime},
-		}},
-		{"last_update", primitive.NewDateTimeFromTime(time.Now())},
+	last, err := m.GetRadiusOnline(acct.AcctSessionId)
+	if err == mongo.ErrNoDocuments {
+		sample := acct.ApplyCounters(&Accounting{})
+		if err = m.AddRadiusOnline(acct); err != nil {
+			return err
+		}
+		return m.AddAcctSample(sample)
+	} else if err != nil {
+		return err
 	}
-	query := bson.M{"acct_session_id": acct.AcctSessionId}
-	r := m.GetTeamsAcsCollection(TeamsacsOnline).FindOne(context.TODO(), query)
-	if r.Err() == nil {
-		return m.AddRadiusAccounting(acct)
+	sample := acct.ApplyCounters(last)
+	data := bson.M{"$set": bson.M{
+		"acct_session_time":   acct.AcctSessionTime,
+		"acct_input_counter":  acct.AcctInputCounter,
+		"acct_output_counter": acct.AcctOutputCounter,
+		"acct_input_total":    acct.AcctInputTotal,
+		"acct_output_total":   acct.AcctOutputTotal,
+		"acct_input_packets":  acct.AcctInputPackets,
+		"acct_output_packets": acct.AcctOutputPackets,
+		"last_update":         acct.LastUpdate,
+	}}
+	if acct.InterimInterval > 0 {
+		data["$set"].(bson.M)["interim_interval"] = acct.InterimInterval
+	}
+	_, err = m.GetTeamsAcsCollection(TeamsacsOnline).UpdateOne(context.TODO(), bson.M{"_id": last.ID}, data)
+	if err != nil {
+		return err
+	}
+	return m.AddAcctSample(sample)
+}
+
+// UpsertRadiusAccounting
+// Save the stop record by acct_session_id, a retransmitted or late stop (the session was closed
+// as stale) replaces the record of the day instead of adding another one, the session ids
+// reused by the NAS later are new records.
+func (m *RadiusManager) UpsertRadiusAccounting(acct Accounting) error {
+	if acct.AcctStopTime.IsZero() {
+		acct.AcctStopTime = time.Now()
+	}
+	acct.ID = ""
+	b, err := bson.Marshal(acct)
+	if err != nil {
+		return err
+	}
+	var data bson.M
+	if err = bson.Unmarshal(b, &data); err != nil {
+		return err
+	}
+	_, err = m.GetTeamsAcsCollection(TeamsacsAccounting).UpdateOne(context.TODO(),
+		acctStopFilter(&acct),
+		bson.M{"$set": data, "$setOnInsert": bson.M{"_id": common.UUID()}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// acctStopFilter
+// The stop record of the session saved within acctStopRepeatWindow
+func acctStopFilter(acct *Accounting) bson.M {
+	return bson.M{
+		"acct_session_id": acct.AcctSessionId,
+		"username":        acct.Username,
+		"acct_stop_time":  bson.M{"$gte": acct.AcctStopTime.Add(-acctStopRepeatWindow)},
+	}
+}
+
+// StopRadiusOnline
+// Move the session to accounting with the totals of the stop request, the last sample is appended to the timeline.
+// The online session is kept to be cleared as stale if the accounting record failed. False is returned if the
+// session was not online (a retransmitted or late stop), the record is saved but no sample is added.
+func (m *RadiusManager) StopRadiusOnline(acct *Accounting) (bool, error) {
+	last, err := m.GetRadiusOnline(acct.AcctSessionId)
+	online := err == nil
+	if err == mongo.ErrNoDocuments {
+		last = &Accounting{}
+	} else if err != nil {
+		return false, err
+	}
+	sample := acct.ApplyCounters(last)
+	if acct.AcctStopTime.IsZero() {
+		acct.AcctStopTime = time.Now()
+	}
+	if err = m.UpsertRadiusAccounting(*acct); err != nil {
+		return false, err
+	}
+	if !online {
+		return false, nil
+	}
+	if err = m.DeleteRadiusOnline(acct.AcctSessionId); err != nil {
+		return false, err
+	}
+	return true, m.AddAcctSample(sample)
+}
+
+// SetupRadiusIndexes
+// The stop records are saved by acct_session_id
+func (m *RadiusManager) SetupRadiusIndexes() {
+	_, err := m.GetTeamsAcsCollection(TeamsacsAccounting).Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "acct_session_id", Value: 1}},
+	})
+	if err != nil {
+		log.Errorf("create accounting session index error, %s", err.Error())
 	}
-	_, err := m.GetTeamsAcsCollection(TeamsacsOnline).UpdateOne(context.TODO(), query, data)
+}
+
+// UpdateRadiusOnlineVendorAttrs
+// The vendor attributes of the last accounting request
+func (m *RadiusManager) UpdateRadiusOnlineVendorAttrs(acct Accounting) error {
+	if len(acct.VendorAttrs) == 0 {
+		return nil
+	}
+	query := bson.M{"acct_session_id": acct.AcctSessionId}
+	update := bson.M{"$set": bson.M{"vendor_attrs": acct.VendorAttrs}}
+	_, err := m.GetTeamsAcsCollection(TeamsacsOnline).UpdateOne(context.TODO(), query, update)
 	return err
 }
 
 
+// staleOnlineFilter
+// Online sessions whose last update is older than times of the session interim interval
+func staleOnlineFilter(now time.Time, interim, times int64) bson.M {
+	return bson.M{"$expr": bson.M{"$lt": bson.A{
+		"$last_update",
+		bson.M{"$subtract": bson.A{now, bson.M{"$multiply": bson.A{
+			bson.M{"$ifNull": bson.A{"$interim_interval", interim}}, times * 1000,
+		}}}},
+	}}}
+}
+
 func getAcctStartTime(sessionTime string) time.Time {
 	m, _ := time.ParseDuration("-" + sessionTime + "s")
 	return time.Now().Add(m)
@@ -172,7 +419,7 @@ func getAcctStartTime(sessionTime string) time.Time {
 
 func getInputTotal(form *web.WebForm) int64 {
 	var acctInputOctets = form.GetInt64Val("acctInputOctets", 0)
-	var acctInputGigawords = form.GetInt64Val("acctInputGigaword", 0)
+	var acctInputGigawords = form.GetInt64Val("acctInputGigawords", 0)
 	return acctInputOctets + acctInputGigawords*4*1024*1024*1024
 }
 
@@ -205,6 +452,8 @@ func (m *RadiusManager) UpdateRadiusOnline(form *web.WebForm) error {
 		AcctSessionTime:   form.GetIntVal("acctSessionTime", 0),
 		AcctInputTotal:    getInputTotal(form),
 		AcctOutputTotal:   getOutputTotal(form),
+		AcctInputCounter:  getInputTotal(form),
+		AcctOutputCounter: getOutputTotal(form),
 		AcctInputPackets:  form.GetIntVal("acctInputPackets", 0),
 		AcctOutputPackets: form.GetIntVal("acctOutputPackets", 0),
 		AcctStartTime:     getAcctStartTime(form.GetVal2("acctSessionTime", "0")),
@@ -222,9 +471,55 @@ func (m *RadiusManager) UpdateRadiusOnline(form *web.WebForm) error {
 		}
 	case "Stop":
 		log.Infof("Update radius cdr %+v", radOnline)
-		_ = m.AddRadiusAccounting(radOnline)
-		return m.DeleteRadiusOnline(sessionId)
+		_, err := m.StopRadiusOnline(&radOnline)
+		return err
 	}
 
 	return nil
 }
+
+// ClearExpireOnlines
+// Sessions without accounting update for a long time are considered lost (e.g. the NAS rebooted
+// without Accounting-Off), a stop record is written to accounting, the online entry is removed
+// and radius.acct.stop is published with the Stale-Session cause.
+// A session is stale after RadiusOnlineExpireTimes of its own interim interval, sessions
+// without the interval use the AcctInterimInterval config.
+func (m *RadiusManager) ClearExpireOnlines() {
+	interim := m.GetConfigManager().GetRadiusConfigIntValue(constant.AcctInterimInterval, 120)
+	times := m.GetConfigManager().GetRadiusConfigIntValue(constant.RadiusOnlineExpireTimes, 3)
+	now := time.Now()
+	coll := m.GetTeamsAcsCollection(TeamsacsOnline)
+	cur, err := coll.Find(context.TODO(), staleOnlineFilter(now, interim, times))
+	if err != nil {
+		log.Errorf("query expire online error, %s", err.Error())
+		return
+	}
+	defer cur.Close(context.TODO())
+	var count = 0
+	for cur.Next(context.TODO()) {
+		var online Accounting
+		if err := cur.Decode(&online); err != nil {
+			log.Errorf("decode expire online error, %s", err.Error())
+			continue
+		}
+		online.AcctStopTime = online.LastUpdate
+		online.AcctTerminateCause = AcctTerminateCauseStale
+		if err := m.AddRadiusAccounting(online); err != nil {
+			log.Errorf("add stale accounting user:%s error, %s", online.Username, err.Error())
+			continue
+		}
+		if _, err := coll.DeleteOne(context.TODO(), bson.M{"_id": online.ID}); err != nil {
+			log.Errorf("delete expire online user:%s error, %s", online.Username, err.Error())
+			continue
+		}
+		m.GetIpamManager().ReleaseSessionLease(&online, "stale session")
+		m.PublishEvent(EventAcctStop, online.EventData())
+		if err := m.GetQuotaManager().StopQuotaSessions(online.Username, online.AcctSessionId); err != nil {
+			log.Errorf("stop quota session user:%s error, %s", online.Username, err.Error())
+		}
+		count++
+	}
+	if count > 0 {
+		log.Infof("clear %d expire online sessions, %d times of the interim interval", count, times)
+	}
+}
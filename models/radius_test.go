@@ -0,0 +1,52 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestStaleOnlineFilter(t *testing.T) {
+	now := time.Now()
+	filter := staleOnlineFilter(now, 120, 3)
+	expect := bson.M{"$expr": bson.M{"$lt": bson.A{
+		"$last_update",
+		bson.M{"$subtract": bson.A{now, bson.M{"$multiply": bson.A{
+			bson.M{"$ifNull": bson.A{"$interim_interval", int64(120)}}, int64(3000),
+		}}}},
+	}}}
+	if !reflect.DeepEqual(filter, expect) {
+		t.Errorf("filter %v", filter)
+	}
+}
+
+func TestAcctStopFilter(t *testing.T) {
+	stop := time.Now()
+	filter := acctStopFilter(&Accounting{Username: "tim", AcctSessionId: "s1", AcctStopTime: stop})
+	expect := bson.M{
+		"acct_session_id": "s1",
+		"username":        "tim",
+		"acct_stop_time":  bson.M{"$gte": stop.Add(-acctStopRepeatWindow)},
+	}
+	if !reflect.DeepEqual(filter, expect) {
+		t.Errorf("filter %v", filter)
+	}
+}
@@ -0,0 +1,153 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/common/web"
+	"github.com/ca17/teamsacs/constant"
+)
+
+// RealmUpstream
+// Upstream radius server of a realm, tried in order
+type RealmUpstream struct {
+	Addr     string `bson:"addr" json:"addr"`
+	AuthPort int    `bson:"auth_port" json:"auth_port"`
+	AcctPort int    `bson:"acct_port" json:"acct_port"`
+	Secret   string `bson:"secret" json:"secret"`
+}
+
+// Realm
+// Users log in as user@realm are proxied to the upstream servers of the realm
+type Realm struct {
+	ID         string          `bson:"_id,omitempty" json:"id,omitempty"`
+	Realm      string          `bson:"realm" json:"realm"`
+	StripRealm bool            `bson:"strip_realm" json:"strip_realm"`
+	Timeout    int             `bson:"timeout" json:"timeout"`
+	Upstreams  []RealmUpstream `bson:"upstreams" json:"upstreams"`
+	Status     string          `bson:"status" json:"status"`
+	Remark     string          `bson:"remark" json:"remark"`
+}
+
+func (a *Realm) Validate() error {
+	switch {
+	case common.IsEmptyOrNA(a.Realm):
+		return fmt.Errorf("invalid realm")
+	case len(a.Upstreams) == 0:
+		return fmt.Errorf("realm upstreams is empty")
+	}
+	for _, u := range a.Upstreams {
+		if common.IsEmptyOrNA(u.Addr) || u.Secret == "" {
+			return fmt.Errorf("invalid realm upstream %s", u.Addr)
+		}
+	}
+	return nil
+}
+
+// RealmManager
+type RealmManager struct{ *ModelManager }
+
+func (m *ModelManager) GetRealmManager() *RealmManager {
+	store, _ := m.ManagerMap.Get("RealmManager")
+	return store.(*RealmManager)
+}
+
+func (m *RealmManager) QueryRealms(params web.RequestParams) (*web.PageResult, error) {
+	return m.QueryPagerItems(params, TeamsacsRealm)
+}
+
+// GetRealm
+// Realm lookup on every proxied request, missing realms are cached too
+func (m *RealmManager) GetRealm(realm string) (*Realm, error) {
+	realm = strings.ToLower(realm)
+	if v, ok := m.Cache.Realm.Get(realm); ok {
+		if v.(*Realm) == nil {
+			return nil, mongo.ErrNoDocuments
+		}
+		return v.(*Realm), nil
+	}
+	doc := m.GetTeamsAcsCollection(TeamsacsRealm).FindOne(context.TODO(), bson.M{"realm": realm})
+	err := doc.Err()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			m.Cache.Realm.Set(realm, "", (*Realm)(nil))
+		}
+		return nil, err
+	}
+	var result = new(Realm)
+	err = doc.Decode(result)
+	if err != nil {
+		return nil, err
+	}
+	m.Cache.Realm.Set(realm, result.ID, result)
+	return result, nil
+}
+
+// AddRealm
+func (m *RealmManager) AddRealm(realm *Realm) error {
+	if err := realm.Validate(); err != nil {
+		return err
+	}
+	realm.Realm = strings.ToLower(realm.Realm)
+	if _, err := m.GetRealm(realm.Realm); err == nil {
+		return fmt.Errorf("realm exists")
+	}
+	realm.ID = common.UUID()
+	if realm.Status == "" {
+		realm.Status = constant.ENABLED
+	}
+	_, err := m.GetTeamsAcsCollection(TeamsacsRealm).InsertOne(context.TODO(), realm)
+	m.InvalidateCache(TeamsacsRealm)
+	return err
+}
+
+// UpdateRealm
+func (m *RealmManager) UpdateRealm(realm *Realm) error {
+	if err := realm.Validate(); err != nil {
+		return err
+	}
+	realm.Realm = strings.ToLower(realm.Realm)
+	data := bson.M{
+		"strip_realm": realm.StripRealm,
+		"timeout":     realm.Timeout,
+		"upstreams":   realm.Upstreams,
+		"remark":      realm.Remark,
+	}
+	if common.InSlice(realm.Status, []string{constant.ENABLED, constant.DISABLED}) {
+		data["status"] = realm.Status
+	}
+	_, err := m.GetTeamsAcsCollection(TeamsacsRealm).UpdateOne(context.TODO(), bson.M{"realm": realm.Realm}, bson.M{"$set": data})
+	m.InvalidateCache(TeamsacsRealm)
+	return err
+}
+
+// DeleteRealm
+func (m *RealmManager) DeleteRealm(realm string) error {
+	if common.IsEmptyOrNA(realm) {
+		return fmt.Errorf("realm is empty or NA")
+	}
+	_, err := m.GetTeamsAcsCollection(TeamsacsRealm).DeleteOne(context.TODO(), bson.M{"realm": strings.ToLower(realm)})
+	m.InvalidateCache(TeamsacsRealm)
+	return err
+}
@@ -0,0 +1,309 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/common/web"
+	"github.com/ca17/teamsacs/constant"
+)
+
+// windows are resolved over this horizon, a window longer than it is unlimited
+const scheduleHorizon = time.Hour * 24 * 8
+
+// ScheduleRange
+// Login time range on the weekdays (0 is Sunday, empty is every day), times are HH:MM in
+// the System.Location time zone, end 24:00 is midnight and an end before the start crosses midnight.
+type ScheduleRange struct {
+	Weekdays []int  `bson:"weekdays" json:"weekdays"`
+	Start    string `bson:"start" json:"start"`
+	End      string `bson:"end" json:"end"`
+}
+
+// Schedule
+// Login windows, the union of the ranges
+type Schedule []ScheduleRange
+
+// SchedulePolicy
+// Reusable schedule, the subscriber schedule_policy names the policy
+type SchedulePolicy struct {
+	ID     string   `bson:"_id,omitempty" json:"id,omitempty"`
+	Name   string   `bson:"name" json:"name"`
+	Ranges Schedule `bson:"ranges" json:"ranges"`
+	Status string   `bson:"status" json:"status"`
+	Remark string   `bson:"remark" json:"remark"`
+}
+
+func parseScheduleTime(s string) (int, int, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid schedule time %s", s)
+	}
+	hour, herr := strconv.Atoi(parts[0])
+	minute, merr := strconv.Atoi(parts[1])
+	if herr != nil || merr != nil || hour < 0 || hour > 24 || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, 0, fmt.Errorf("invalid schedule time %s", s)
+	}
+	return hour, minute, nil
+}
+
+func (r ScheduleRange) Validate() error {
+	for _, day := range r.Weekdays {
+		if day < 0 || day > 6 {
+			return fmt.Errorf("invalid schedule weekday %d", day)
+		}
+	}
+	if _, _, err := parseScheduleTime(r.Start); err != nil {
+		return err
+	}
+	_, _, err := parseScheduleTime(r.End)
+	return err
+}
+
+func (r ScheduleRange) onWeekday(day time.Weekday) bool {
+	if len(r.Weekdays) == 0 {
+		return true
+	}
+	for _, d := range r.Weekdays {
+		if time.Weekday(d) == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Schedule) Validate() error {
+	if len(s) == 0 {
+		return fmt.Errorf("schedule ranges is empty")
+	}
+	for _, r := range s {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type scheduleInterval struct {
+	start, end time.Time
+}
+
+// intervals
+// The ranges as time intervals from the day before now to the horizon, sorted and merged
+func (s Schedule) intervals(now time.Time) []scheduleInterval {
+	var items []scheduleInterval
+	loc := now.Location()
+	for day := -1; day <= int(scheduleHorizon/(time.Hour*24)); day++ {
+		date := time.Date(now.Year(), now.Month(), now.Day()+day, 0, 0, 0, 0, loc)
+		for _, r := range s {
+			if !r.onWeekday(date.Weekday()) {
+				continue
+			}
+			sh, sm, err := parseScheduleTime(r.Start)
+			if err != nil {
+				continue
+			}
+			eh, em, err := parseScheduleTime(r.End)
+			if err != nil {
+				continue
+			}
+			start := time.Date(date.Year(), date.Month(), date.Day(), sh, sm, 0, 0, loc)
+			endDay := date.Day()
+			if eh*60+em <= sh*60+sm {
+				endDay++
+			}
+			end := time.Date(date.Year(), date.Month(), endDay, eh, em, 0, 0, loc)
+			items = append(items, scheduleInterval{start, end})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].start.Before(items[j].start) })
+	var merged []scheduleInterval
+	for _, item := range items {
+		if n := len(merged); n > 0 && !item.start.After(merged[n-1].end) {
+			if item.end.After(merged[n-1].end) {
+				merged[n-1].end = item.end
+			}
+			continue
+		}
+		merged = append(merged, item)
+	}
+	return merged
+}
+
+// Window
+// Whether now is in a login window and the seconds to the end of the window,
+// -1 if the window does not end within the horizon. now is in the schedule time zone.
+func (s Schedule) Window(now time.Time) (bool, int64) {
+	for _, item := range s.intervals(now) {
+		if now.Before(item.start) || !now.Before(item.end) {
+			continue
+		}
+		remain := item.end.Sub(now)
+		if remain >= scheduleHorizon-time.Hour*24 {
+			return true, -1
+		}
+		return true, int64((remain + time.Second - 1) / time.Second)
+	}
+	return false, 0
+}
+
+// GetSchedulePolicy
+// Name of the schedule policy of the subscriber, the inline schedule overrides the policy
+func (a Subscribe) GetSchedulePolicy() string {
+	return a.GetStringValue("schedule_policy", constant.NA)
+}
+
+// GetSchedule
+// The inline schedule ranges of the subscriber, nil if not set
+func (a Subscribe) GetSchedule() (Schedule, error) {
+	v, ok := a["schedule"]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	data, err := bson.Marshal(bson.M{"ranges": v})
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Ranges Schedule `bson:"ranges"`
+	}
+	if err = bson.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid subscribe schedule, %s", err.Error())
+	}
+	if len(result.Ranges) == 0 {
+		return nil, nil
+	}
+	return result.Ranges, result.Ranges.Validate()
+}
+
+// ScheduleManager
+type ScheduleManager struct{ *ModelManager }
+
+func (m *ModelManager) GetScheduleManager() *ScheduleManager {
+	store, _ := m.ManagerMap.Get("ScheduleManager")
+	return store.(*ScheduleManager)
+}
+
+func (m *ScheduleManager) QuerySchedulePolicies(params web.RequestParams) (*web.PageResult, error) {
+	return m.QueryPagerItems(params, TeamsacsSchedule)
+}
+
+// GetSchedulePolicy
+// Policy lookup on every login of the scheduled subscribers, missing policies are cached too
+func (m *ScheduleManager) GetSchedulePolicy(name string) (*SchedulePolicy, error) {
+	if v, ok := m.Cache.Schedule.Get(name); ok {
+		if v.(*SchedulePolicy) == nil {
+			return nil, mongo.ErrNoDocuments
+		}
+		return v.(*SchedulePolicy), nil
+	}
+	doc := m.GetTeamsAcsCollection(TeamsacsSchedule).FindOne(context.TODO(), bson.M{"name": name})
+	err := doc.Err()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			m.Cache.Schedule.Set(name, "", (*SchedulePolicy)(nil))
+		}
+		return nil, err
+	}
+	var result = new(SchedulePolicy)
+	err = doc.Decode(result)
+	if err != nil {
+		return nil, err
+	}
+	m.Cache.Schedule.Set(name, result.ID, result)
+	return result, nil
+}
+
+// GetSubscribeSchedule
+// The login schedule of the subscriber, nil if the subscriber is not restricted,
+// a disabled policy does not restrict the subscribers.
+func (m *ScheduleManager) GetSubscribeSchedule(user *Subscribe) (Schedule, error) {
+	schedule, err := user.GetSchedule()
+	if err != nil || schedule != nil {
+		return schedule, err
+	}
+	name := user.GetSchedulePolicy()
+	if common.IsEmptyOrNA(name) {
+		return nil, nil
+	}
+	policy, err := m.GetSchedulePolicy(name)
+	if err != nil {
+		return nil, fmt.Errorf("schedule policy %s error, %s", name, err.Error())
+	}
+	if policy.Status == constant.DISABLED {
+		return nil, nil
+	}
+	return policy.Ranges, nil
+}
+
+// AddSchedulePolicy
+func (m *ScheduleManager) AddSchedulePolicy(policy *SchedulePolicy) error {
+	if common.IsEmptyOrNA(policy.Name) {
+		return fmt.Errorf("invalid schedule policy name")
+	}
+	if err := policy.Ranges.Validate(); err != nil {
+		return err
+	}
+	if _, err := m.GetSchedulePolicy(policy.Name); err == nil {
+		return fmt.Errorf("schedule policy exists")
+	}
+	policy.ID = common.UUID()
+	if policy.Status == "" {
+		policy.Status = constant.ENABLED
+	}
+	_, err := m.GetTeamsAcsCollection(TeamsacsSchedule).InsertOne(context.TODO(), policy)
+	m.InvalidateCache(TeamsacsSchedule)
+	return err
+}
+
+// UpdateSchedulePolicy
+func (m *ScheduleManager) UpdateSchedulePolicy(policy *SchedulePolicy) error {
+	if err := policy.Ranges.Validate(); err != nil {
+		return err
+	}
+	data := bson.M{
+		"ranges": policy.Ranges,
+		"remark": policy.Remark,
+	}
+	if common.InSlice(policy.Status, []string{constant.ENABLED, constant.DISABLED}) {
+		data["status"] = policy.Status
+	}
+	_, err := m.GetTeamsAcsCollection(TeamsacsSchedule).UpdateOne(context.TODO(), bson.M{"name": policy.Name}, bson.M{"$set": data})
+	m.InvalidateCache(TeamsacsSchedule)
+	return err
+}
+
+// DeleteSchedulePolicy
+// Subscribers of a deleted policy are rejected until the policy is added again or unset
+func (m *ScheduleManager) DeleteSchedulePolicy(name string) error {
+	if common.IsEmptyOrNA(name) {
+		return fmt.Errorf("schedule policy name is empty or NA")
+	}
+	_, err := m.GetTeamsAcsCollection(TeamsacsSchedule).DeleteOne(context.TODO(), bson.M{"name": name})
+	m.InvalidateCache(TeamsacsSchedule)
+	return err
+}
@@ -0,0 +1,101 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestScheduleWindow(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	// 2020-10-19 is Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2020, 10, day, hour, minute, 0, 0, loc)
+	}
+	school := Schedule{{Weekdays: []int{1, 2, 3, 4, 5}, Start: "08:00", End: "17:30"}}
+	hotel := Schedule{{Start: "22:00", End: "06:00"}}
+	weekend := Schedule{{Weekdays: []int{6}, Start: "00:00", End: "24:00"}, {Weekdays: []int{0}, Start: "00:00", End: "24:00"}}
+	friday := Schedule{{Weekdays: []int{5}, Start: "20:00", End: "02:00"}}
+	always := Schedule{{Start: "00:00", End: "24:00"}}
+	tests := []struct {
+		name     string
+		schedule Schedule
+		now      time.Time
+		allowed  bool
+		remain   int64
+	}{
+		{"school monday", school, at(19, 9, 0), true, 30600},
+		{"school before start", school, at(19, 7, 59), false, 0},
+		{"school at end", school, at(19, 17, 30), false, 0},
+		{"school sunday", school, at(18, 10, 0), false, 0},
+		{"hotel night", hotel, at(20, 23, 0), true, 25200},
+		{"hotel morning", hotel, at(21, 5, 0), true, 3600},
+		{"hotel noon", hotel, at(21, 12, 0), false, 0},
+		{"weekend merged", weekend, at(24, 10, 0), true, 136800},
+		{"friday overnight", friday, at(24, 1, 0), true, 3600},
+		{"friday overnight end", friday, at(24, 2, 0), false, 0},
+		{"always", always, at(19, 12, 0), true, -1},
+	}
+	for _, tt := range tests {
+		allowed, remain := tt.schedule.Window(tt.now)
+		if allowed != tt.allowed || remain != tt.remain {
+			t.Errorf("%s window %v %d, expect %v %d", tt.name, allowed, remain, tt.allowed, tt.remain)
+		}
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	tests := []struct {
+		schedule Schedule
+		valid    bool
+	}{
+		{Schedule{{Weekdays: []int{0, 6}, Start: "08:00", End: "24:00"}}, true},
+		{Schedule{{Start: "22:00", End: "06:00"}}, true},
+		{Schedule{{Start: "25:00", End: "06:00"}}, false},
+		{Schedule{{Start: "24:30", End: "06:00"}}, false},
+		{Schedule{{Weekdays: []int{7}, Start: "08:00", End: "17:00"}}, false},
+		{Schedule{{Start: "8", End: "17:00"}}, false},
+		{Schedule{}, false},
+	}
+	for i, tt := range tests {
+		if err := tt.schedule.Validate(); (err == nil) != tt.valid {
+			t.Errorf("case %d valid %v, %v", i, tt.valid, err)
+		}
+	}
+}
+
+func TestSubscribeSchedule(t *testing.T) {
+	user := Subscribe{"username": "tim", "schedule": bson.A{
+		bson.M{"weekdays": bson.A{int32(1), int32(2)}, "start": "08:00", "end": "17:00"},
+	}}
+	schedule, err := user.GetSchedule()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedule) != 1 || len(schedule[0].Weekdays) != 2 || schedule[0].End != "17:00" {
+		t.Fatalf("schedule %+v", schedule)
+	}
+	if schedule, err = (Subscribe{"username": "tim"}).GetSchedule(); schedule != nil || err != nil {
+		t.Fatalf("schedule %+v, %v", schedule, err)
+	}
+	if _, err = (Subscribe{"schedule": bson.A{bson.M{"start": "08:00", "end": "99:00"}}}).GetSchedule(); err == nil {
+		t.Fatal("invalid schedule must be rejected")
+	}
+}
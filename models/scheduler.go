@@ -16,10 +16,42 @@
 
 package models
 
-import "github.com/go-co-op/gocron"
+import (
+	"github.com/go-co-op/gocron"
+
+	"github.com/ca17/teamsacs/common/log"
+)
 
 func (m *ModelManager) StartScheduler()  {
 	m.Sched = gocron.NewScheduler(m.Location)
+	// lost radius online sessions
+	if _, err := m.Sched.Every(60).Seconds().Do(m.GetRadiusManager().ClearExpireOnlines); err != nil {
+		log.Error(err)
+	}
+	// ip leases of sessions not started or lost
+	if _, err := m.Sched.Every(60).Seconds().Do(m.GetIpamManager().ClearExpireLeases); err != nil {
+		log.Error(err)
+	}
+	// radius auth lockout counters
+	if _, err := m.Sched.Every(60).Seconds().Do(m.GetLockoutManager().ClearExpireLockouts); err != nil {
+		log.Error(err)
+	}
+	// vouchers over the first use deadline or the validity
+	if _, err := m.Sched.Every(60).Seconds().Do(m.GetVoucherManager().ClearExpireVouchers); err != nil {
+		log.Error(err)
+	}
+	// radius history retention
+	if _, err := m.Sched.Every(1).Day().At("03:00").Do(m.PurgeRadiusHistory); err != nil {
+		log.Error(err)
+	}
+	// subscribe.expire events
+	if _, err := m.Sched.Every(60).Seconds().Do(m.GetSubscribeManager().PublishExpiredSubscribes); err != nil {
+		log.Error(err)
+	}
+	// daily cdr files of the ended days
+	if _, err := m.Sched.Every(10).Minutes().Do(m.GetCdrManager().ExportPendingCdr); err != nil {
+		log.Error(err)
+	}
 	<-m.Sched.Start()
 }
 
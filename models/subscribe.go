@@ -18,10 +18,17 @@ package models
 
 import (
 	"context"
+	"sort"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"github.com/ca17/teamsacs/common/aes"
+	"github.com/ca17/teamsacs/common/log"
+	"github.com/ca17/teamsacs/common/mfa"
 	"github.com/ca17/teamsacs/common/web"
 	"github.com/ca17/teamsacs/constant"
 )
@@ -53,6 +60,22 @@ func (a Subscribe) GetDownRateKbps() int {
 	return a.GetIntValue("down_rate", 0)
 }
 
+func (a Subscribe) GetIpv6AddrPool() string {
+	return a.GetStringValue("ipv6_addr_pool", constant.NA)
+}
+
+func (a Subscribe) GetIpv6Prefix() string {
+	return a.GetStringValue("ipv6_prefix", constant.NA)
+}
+
+func (a Subscribe) GetIpv6InterfaceId() string {
+	return a.GetStringValue("ipv6_interface_id", constant.NA)
+}
+
+func (a Subscribe) GetDelegatedIpv6Prefix() string {
+	return a.GetStringValue("delegated_ipv6_prefix", constant.NA)
+}
+
 func (a Subscribe) GetDomain() string {
 	return a.GetStringValue("domain", constant.NA)
 }
@@ -90,11 +113,35 @@ func (a Subscribe) GetStatus() string {
 	return a.GetStringValue("status", constant.DISABLED)
 }
 
+// GetMappingFields
+// Subscribe fields used by the vendor attribute mapping templates, secrets are excluded
+func (a Subscribe) GetMappingFields() map[string]interface{} {
+	fields := make(map[string]interface{}, len(a))
+	for k, v := range a {
+		switch k {
+		case "_id", "password", "mfa_secret":
+			continue
+		}
+		fields[k] = v
+	}
+	return fields
+}
+
+// GetMfaSecret
+// TOTP secret encrypted by the system aes key, empty if mfa is not enrolled
+func (a Subscribe) GetMfaSecret() string {
+	return a.GetStringValue("mfa_secret", "")
+}
+
 
 
 
 // SubscribeManager
-type SubscribeManager struct{ *ModelManager }
+type SubscribeManager struct {
+	*ModelManager
+	expireLock  sync.Mutex
+	expireCheck time.Time
+}
 
 func (m *ModelManager) GetSubscribeManager() *SubscribeManager {
 	store, _ := m.ManagerMap.Get("SubscribeManager")
@@ -108,33 +155,151 @@ func (m *SubscribeManager) QuerySubscribes(params web.RequestParams) (*web.PageR
 
 // GetSubscribeByUser
 func (m *SubscribeManager) GetSubscribeByUser(username string) (*Subscribe, error) {
-	coll := m.GetTeamsAcsCollection(TeamsacsSubscribe)
-	doc := coll.FindOne(context.TODO(), bson.M{"username": username})
-	err := doc.Err()
-	if err != nil {
-		return nil, err
-	}
-	var result = new(Subscribe)
-	err = doc.Decode(result)
-	return result, err
+	return m.getCachedSubscribe("user:"+username, bson.M{"username": username})
 }
 
 // GetSubscribeByMac
 func (m *SubscribeManager) GetSubscribeByMac(mac string) (*Subscribe, error) {
+	return m.getCachedSubscribe("mac:"+mac, bson.M{"macaddr": mac})
+}
+
+func (m *SubscribeManager) getCachedSubscribe(key string, filter bson.M) (*Subscribe, error) {
+	if v, ok := m.Cache.Subscribe.Get(key); ok {
+		return v.(Subscribe).Copy(), nil
+	}
 	coll := m.GetTeamsAcsCollection(TeamsacsSubscribe)
-	doc := coll.FindOne(context.TODO(), bson.M{"macaddr": mac})
+	doc := coll.FindOne(context.TODO(), filter)
 	err := doc.Err()
 	if err != nil {
 		return nil, err
 	}
 	var result = new(Subscribe)
 	err = doc.Decode(result)
-	return result, err
+	if err != nil {
+		return nil, err
+	}
+	m.Cache.Subscribe.Set(key, cacheId((*result)["_id"]), *result)
+	return result.Copy(), nil
 }
 
 // UpdateSubscribeByUsername
+// The cached documents of the subscribe are removed by id, so both the username and the mac key are invalidated
 func (m *SubscribeManager) UpdateSubscribeByUsername(username string, valmap map[string]interface{}) error {
 	coll := m.GetTeamsAcsCollection(TeamsacsSubscribe)
-	_, err := coll.UpdateOne(context.TODO(), bson.M{"username": username}, valmap)
-	return err
+	var doc bson.M
+	err := coll.FindOneAndUpdate(context.TODO(), bson.M{"username": username}, bson.M{"$set": valmap},
+		options.FindOneAndUpdate().SetProjection(bson.M{"_id": 1})).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	m.InvalidateCache(TeamsacsSubscribe, cacheId(doc["_id"]))
+	m.PublishSubscribeUpdate(username, valmap)
+	return nil
+}
+
+// EnrollSubscribeMfa
+// Create a new TOTP secret for the user, returns the secret and the provisioning uri for authenticator apps
+func (m *SubscribeManager) EnrollSubscribeMfa(username string) (string, string, error) {
+	if _, err := m.GetSubscribeByUser(username); err != nil {
+		return "", "", err
+	}
+	ga := mfa.NewGoogleAuth()
+	secret := ga.GetSecret()
+	encsecret, err := aes.EncryptToB64(secret, m.Config.System.Aeskey)
+	if err != nil {
+		return "", "", err
+	}
+	if err = m.UpdateSubscribeByUsername(username, map[string]interface{}{"mfa_secret": encsecret}); err != nil {
+		return "", "", err
+	}
+	return secret, ga.GetQrcode(username, secret, m.Config.System.Appid), nil
+}
+
+// RemoveSubscribeMfa
+func (m *SubscribeManager) RemoveSubscribeMfa(username string) error {
+	if _, err := m.GetSubscribeByUser(username); err != nil {
+		return err
+	}
+	return m.UpdateSubscribeByUsername(username, map[string]interface{}{"mfa_secret": ""})
+}
+
+// PublishSubscribeAdd
+// subscribe.add event of a new subscriber document
+func (m *SubscribeManager) PublishSubscribeAdd(item interface{}) {
+	var id, username interface{}
+	switch doc := item.(type) {
+	case map[string]interface{}:
+		id, username = doc["_id"], doc["username"]
+	case map[string]string:
+		id, username = doc["_id"], doc["username"]
+	case Subscribe:
+		id, username = doc["_id"], doc["username"]
+	default:
+		return
+	}
+	m.PublishEvent(EventSubscribeAdd, map[string]interface{}{"id": id, "username": username})
+}
+
+// PublishSubscribeUpdate
+// subscribe.update event with the names of the changed fields, the values may be secrets
+func (m *SubscribeManager) PublishSubscribeUpdate(username string, valmap map[string]interface{}) {
+	data := map[string]interface{}{"username": username}
+	fields := make([]string, 0, len(valmap))
+	for k := range valmap {
+		switch k {
+		case "_id":
+			data["id"] = valmap[k]
+		case "update_time":
+		default:
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	data["fields"] = fields
+	m.PublishEvent(EventSubscribeUpdate, data)
+}
+
+// expiredSubscribeFilter
+// The subscribers expired in (from, to], expire_time is a date or a "2006-01-02 15:04:05" string
+// in UTC like GetDateValue parses it, mongodb compares the values of the same type only.
+func expiredSubscribeFilter(from, to time.Time) bson.M {
+	const layout = "2006-01-02 15:04:05"
+	return bson.M{"$or": bson.A{
+		bson.M{"expire_time": bson.M{"$gt": from, "$lte": to}},
+		bson.M{"expire_time": bson.M{"$gt": from.UTC().Format(layout), "$lte": to.UTC().Format(layout)}},
+	}}
+}
+
+// PublishExpiredSubscribes
+// subscribe.expire events of the subscribers expired since the last check, the first check
+// starts from a minute ago, expirations while the server is down are not published.
+func (m *SubscribeManager) PublishExpiredSubscribes() {
+	m.expireLock.Lock()
+	defer m.expireLock.Unlock()
+	now := time.Now()
+	if m.expireCheck.IsZero() {
+		m.expireCheck = now.Add(-time.Minute)
+	}
+	cur, err := m.GetTeamsAcsCollection(TeamsacsSubscribe).Find(context.TODO(), expiredSubscribeFilter(m.expireCheck, now))
+	if err != nil {
+		log.Errorf("query expired subscribes error, %s", err.Error())
+		return
+	}
+	defer cur.Close(context.TODO())
+	for cur.Next(context.TODO()) {
+		var user Subscribe
+		if err = cur.Decode(&user); err != nil {
+			log.Errorf("decode expired subscribe error, %s", err.Error())
+			continue
+		}
+		m.PublishEvent(EventSubscribeExpire, map[string]interface{}{
+			"username":    user.GetUsername(),
+			"expire_time": user.GetExpireTime(),
+			"product_id":  user.GetStringValue("product_id", ""),
+		})
+	}
+	m.expireCheck = now
 }
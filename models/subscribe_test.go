@@ -0,0 +1,73 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// matchExpireRange evaluates a {"$gt", "$lte"} range like mongodb, values of another type never match
+func matchExpireRange(cond bson.M, val interface{}) bool {
+	switch v := val.(type) {
+	case time.Time:
+		gt, ok1 := cond["$gt"].(time.Time)
+		lte, ok2 := cond["$lte"].(time.Time)
+		return ok1 && ok2 && v.After(gt) && !v.After(lte)
+	case string:
+		gt, ok1 := cond["$gt"].(string)
+		lte, ok2 := cond["$lte"].(string)
+		return ok1 && ok2 && v > gt && v <= lte
+	}
+	return false
+}
+
+func matchExpiredSubscribe(filter bson.M, user Subscribe) bool {
+	for _, item := range filter["$or"].(bson.A) {
+		if matchExpireRange(item.(bson.M)["expire_time"].(bson.M), user["expire_time"]) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestExpiredSubscribeFilter(t *testing.T) {
+	to := time.Now()
+	from := to.Add(-time.Minute)
+	filter := expiredSubscribeFilter(from, to)
+	tests := []struct {
+		user   Subscribe
+		expire bool
+	}{
+		{Subscribe{"expire_time": to.Add(-time.Second * 30)}, true},
+		{Subscribe{"expire_time": to.Add(-time.Hour)}, false},
+		{Subscribe{"expire_time": to.Add(-time.Second * 30).UTC().Format("2006-01-02 15:04:05")}, true},
+		{Subscribe{"expire_time": to.Add(time.Hour).UTC().Format("2006-01-02 15:04:05")}, false},
+		{Subscribe{"expire_time": to.Add(-time.Hour).UTC().Format("2006-01-02 15:04:05")}, false},
+	}
+	for _, tt := range tests {
+		if matchExpiredSubscribe(filter, tt.user) != tt.expire {
+			t.Errorf("expire_time %v expire %v", tt.user["expire_time"], !tt.expire)
+		}
+		// the expire time of the event is parsed back from the string
+		if tt.expire && tt.user.GetExpireTime().Before(from.Add(-time.Second)) {
+			t.Errorf("expire_time %v parsed %v", tt.user["expire_time"], tt.user.GetExpireTime())
+		}
+	}
+}
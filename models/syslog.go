@@ -34,12 +34,10 @@ type Syslog struct {
 }
 
 
-func (m *OperatorManager) AddSyslog(item *Syslog)  {
+func (m *OperatorManager) AddSyslog(item *Syslog) error {
 	coll := m.GetTeamsAcsCollection(TeamsacsSyslog)
 	_, err := coll.InsertOne(context.TODO(), item)
-	if err != nil {
-		log.Error(err)
-	}
+	return err
 }
 
 
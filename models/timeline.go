@@ -0,0 +1,111 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ca17/teamsacs/common"
+)
+
+// The octets counters without Gigawords wrap at 2^32, a lower value after
+// a last value above the limit is taken as a wrap instead of a counter restart
+const (
+	acctCounterWrap      = int64(1) << 32
+	acctCounterWrapLimit = acctCounterWrap / 4 * 3
+)
+
+// AcctSample
+// Usage of a session between two accounting requests, the per-session timeline is used for usage graphs
+type AcctSample struct {
+	ID              string    `bson:"_id,omitempty" json:"id,omitempty"`
+	AcctSessionId   string    `bson:"acct_session_id" json:"acct_session_id"`
+	Username        string    `bson:"username" json:"username"`
+	AcctSessionTime int       `bson:"acct_session_time" json:"acct_session_time"`
+	Interval        int       `bson:"interval" json:"interval"`
+	InputTotal      int64     `bson:"input_total" json:"input_total,string"`
+	OutputTotal     int64     `bson:"output_total" json:"output_total,string"`
+	InputDelta      int64     `bson:"input_delta" json:"input_delta,string"`
+	OutputDelta     int64     `bson:"output_delta" json:"output_delta,string"`
+	Timestamp       time.Time `bson:"timestamp" json:"timestamp"`
+}
+
+// AcctCounterDelta
+// The increase of an absolute accounting counter since last. A counter lower than the last one
+// wrapped at 32 bits if the last value was close to it (the NAS sends no Gigawords),
+// otherwise the NAS restarted the counter and the new value is the delta.
+func AcctCounterDelta(last, cur int64) int64 {
+	if cur >= last {
+		return cur - last
+	}
+	if last >= acctCounterWrapLimit && last < acctCounterWrap {
+		return cur + acctCounterWrap - last
+	}
+	return cur
+}
+
+// ApplyCounters
+// Set the session totals from the absolute counters of the request and the last online record,
+// the returned sample is the usage between the two requests.
+func (a *Accounting) ApplyCounters(last *Accounting) *AcctSample {
+	if a.LastUpdate.IsZero() {
+		a.LastUpdate = time.Now()
+	}
+	inputDelta := AcctCounterDelta(last.AcctInputCounter, a.AcctInputCounter)
+	outputDelta := AcctCounterDelta(last.AcctOutputCounter, a.AcctOutputCounter)
+	a.AcctInputTotal = last.AcctInputTotal + inputDelta
+	a.AcctOutputTotal = last.AcctOutputTotal + outputDelta
+	interval := a.AcctSessionTime - last.AcctSessionTime
+	if interval < 0 {
+		interval = a.AcctSessionTime
+	}
+	return &AcctSample{
+		AcctSessionId:   a.AcctSessionId,
+		Username:        a.Username,
+		AcctSessionTime: a.AcctSessionTime,
+		Interval:        interval,
+		InputTotal:      a.AcctInputTotal,
+		OutputTotal:     a.AcctOutputTotal,
+		InputDelta:      inputDelta,
+		OutputDelta:     outputDelta,
+		Timestamp:       a.LastUpdate,
+	}
+}
+
+// AddAcctSample
+func (m *RadiusManager) AddAcctSample(sample *AcctSample) error {
+	sample.ID = common.UUID()
+	_, err := m.GetTeamsAcsCollection(TeamsacsAcctTimeline).InsertOne(context.TODO(), sample)
+	return err
+}
+
+// GetAcctTimeline
+// The samples of the session in time order
+func (m *RadiusManager) GetAcctTimeline(sessionid string) ([]AcctSample, error) {
+	opts := options.Find().SetSort(bson.M{"timestamp": 1})
+	cur, err := m.GetTeamsAcsCollection(TeamsacsAcctTimeline).Find(context.TODO(), bson.M{"acct_session_id": sessionid}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var items = make([]AcctSample, 0)
+	err = cur.All(context.TODO(), &items)
+	return items, err
+}
@@ -0,0 +1,71 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"testing"
+)
+
+func TestAcctCounterDelta(t *testing.T) {
+	tests := []struct {
+		last, cur, expect int64
+	}{
+		{0, 100, 100},
+		{100, 100, 0},
+		{100, 300, 200},
+		// the NAS restarted the counter
+		{300, 50, 50},
+		// 32 bits counter wrap without Gigawords
+		{acctCounterWrap - 100, 50, 150},
+		// Gigawords counters do not wrap
+		{acctCounterWrap + 100, 50, 50},
+	}
+	for _, tt := range tests {
+		if delta := AcctCounterDelta(tt.last, tt.cur); delta != tt.expect {
+			t.Errorf("AcctCounterDelta(%d, %d) = %d, expect %d", tt.last, tt.cur, delta, tt.expect)
+		}
+	}
+}
+
+func TestAccountingApplyCounters(t *testing.T) {
+	online := &Accounting{AcctSessionId: "s1", Username: "tim", AcctSessionTime: 0, AcctInputCounter: 100, AcctOutputCounter: 200}
+	first := online.ApplyCounters(&Accounting{})
+	if online.AcctInputTotal != 100 || online.AcctOutputTotal != 200 || first.InputDelta != 100 {
+		t.Fatalf("first sample error %+v", first)
+	}
+
+	interim := &Accounting{AcctSessionId: "s1", Username: "tim", AcctSessionTime: 120,
+		AcctInputCounter: acctCounterWrap - 100, AcctOutputCounter: 1200}
+	sample := interim.ApplyCounters(online)
+	if interim.AcctInputTotal != acctCounterWrap-100 || interim.AcctOutputTotal != 1200 || sample.Interval != 120 {
+		t.Fatalf("interim sample error %+v", sample)
+	}
+
+	// the input counter wrapped, the output counter restarted
+	stop := &Accounting{AcctSessionId: "s1", Username: "tim", AcctSessionTime: 240,
+		AcctInputCounter: 400, AcctOutputCounter: 300}
+	sample = stop.ApplyCounters(interim)
+	if sample.InputDelta != 500 || sample.OutputDelta != 300 {
+		t.Fatalf("stop sample delta error %+v", sample)
+	}
+	if stop.AcctInputTotal != acctCounterWrap+400 || stop.AcctOutputTotal != 1500 {
+		t.Fatalf("stop totals error input=%d output=%d", stop.AcctInputTotal, stop.AcctOutputTotal)
+	}
+	if sample.Timestamp.IsZero() || sample.InputTotal != stop.AcctInputTotal {
+		t.Fatalf("stop sample error %+v", sample)
+	}
+}
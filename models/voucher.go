@@ -0,0 +1,513 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/common/aes"
+	"github.com/ca17/teamsacs/common/log"
+	"github.com/ca17/teamsacs/common/web"
+	"github.com/ca17/teamsacs/constant"
+)
+
+// Voucher status, unused vouchers become active at the first use,
+// unused and active vouchers may be revoked, expired and revoked vouchers are final.
+const (
+	VoucherUnused  = "unused"
+	VoucherActive  = "active"
+	VoucherExpired = "expired"
+	VoucherRevoked = "revoked"
+
+	voucherMaxBatchCount  = 100000
+	voucherInsertRetries  = 5
+	voucherDefaultCodeLen = 12
+	voucherDefaultPinLen  = 6
+)
+
+// voucherTransitions
+// The states a voucher may leave for the target state
+var voucherTransitions = map[string][]string{
+	VoucherActive:  {VoucherUnused},
+	VoucherExpired: {VoucherUnused, VoucherActive},
+	VoucherRevoked: {VoucherUnused, VoucherActive},
+}
+
+// CanVoucherTransit
+func CanVoucherTransit(from, to string) bool {
+	return common.InSlice(from, voucherTransitions[to])
+}
+
+// VoucherBatch
+// Prepaid cards of a product, the subscriber created by a voucher gets the product
+// and is valid for ValidDays from the first use, the product valid days if zero.
+type VoucherBatch struct {
+	ID         string    `bson:"_id,omitempty" json:"id,omitempty"`
+	Name       string    `bson:"name" json:"name"`
+	ProductId  string    `bson:"product_id" json:"product_id"`
+	Count      int       `bson:"count" json:"count"`
+	Prefix     string    `bson:"prefix" json:"prefix"`
+	CodeLength int       `bson:"code_length" json:"code_length"`
+	PinLength  int       `bson:"pin_length" json:"pin_length"`
+	ValidDays  int       `bson:"valid_days" json:"valid_days"`
+	ExpireTime time.Time `bson:"expire_time" json:"expire_time"`
+	CreateTime time.Time `bson:"create_time" json:"create_time"`
+	Remark     string    `bson:"remark" json:"remark"`
+}
+
+func (a *VoucherBatch) Validate() error {
+	switch {
+	case common.IsEmptyOrNA(a.Name):
+		return fmt.Errorf("invalid voucher batch name")
+	case common.IsEmptyOrNA(a.ProductId):
+		return fmt.Errorf("voucher batch product is required")
+	case a.Count <= 0 || a.Count > voucherMaxBatchCount:
+		return fmt.Errorf("voucher count must be 1-%d", voucherMaxBatchCount)
+	case a.CodeLength < 6 || a.CodeLength > 32:
+		return fmt.Errorf("voucher code length must be 6-32")
+	case a.PinLength < 4 || a.PinLength > 16:
+		return fmt.Errorf("voucher pin length must be 4-16")
+	case a.ValidDays < 0:
+		return fmt.Errorf("voucher valid days must not be negative")
+	case strings.ContainsAny(a.Prefix, " @"):
+		return fmt.Errorf("invalid voucher prefix %s", a.Prefix)
+	}
+	return nil
+}
+
+// Voucher
+// The code is the username of the created subscriber and the pin its password,
+// the pin is encrypted by the system aes key. ExpireTime is the deadline of the first use,
+// EndTime is the end of the validity started by the first use.
+type Voucher struct {
+	Code       string    `bson:"_id" json:"code"`
+	Pin        string    `bson:"pin" json:"-"`
+	BatchId    string    `bson:"batch_id" json:"batch_id"`
+	ProductId  string    `bson:"product_id" json:"product_id"`
+	Status     string    `bson:"status" json:"status"`
+	ValidDays  int       `bson:"valid_days" json:"valid_days"`
+	ExpireTime time.Time `bson:"expire_time" json:"expire_time"`
+	ActiveTime time.Time `bson:"active_time" json:"active_time"`
+	EndTime    time.Time `bson:"end_time" json:"end_time"`
+	Username   string    `bson:"username" json:"username"`
+	CreateTime time.Time `bson:"create_time" json:"create_time"`
+	UpdateTime time.Time `bson:"update_time" json:"update_time"`
+}
+
+// IsAvailable
+// Unused and not over the deadline of the first use
+func (a *Voucher) IsAvailable(now time.Time) bool {
+	return a.Status == VoucherUnused && now.Before(a.ExpireTime)
+}
+
+// RandomDigits
+// A random decimal string of n digits
+func RandomDigits(n int) (string, error) {
+	var buff strings.Builder
+	ten := big.NewInt(10)
+	for i := 0; i < n; i++ {
+		d, err := rand.Int(rand.Reader, ten)
+		if err != nil {
+			return "", err
+		}
+		buff.WriteByte(byte('0' + d.Int64()))
+	}
+	return buff.String(), nil
+}
+
+// voucherEndTime
+// The validity of a voucher used at now extends the subscriber from its expire time if not yet expired
+func voucherEndTime(now, expire time.Time, validDays int) time.Time {
+	if expire.Before(now) {
+		expire = now
+	}
+	return expire.Add(time.Hour * 24 * time.Duration(validDays))
+}
+
+// VoucherManager
+type VoucherManager struct{ *ModelManager }
+
+func (m *ModelManager) GetVoucherManager() *VoucherManager {
+	store, _ := m.ManagerMap.Get("VoucherManager")
+	return store.(*VoucherManager)
+}
+
+func (m *VoucherManager) QueryVoucherBatches(params web.RequestParams) (*web.PageResult, error) {
+	return m.QueryPagerItems(params, TeamsacsVoucherBatch)
+}
+
+func (m *VoucherManager) QueryVouchers(params web.RequestParams) (*web.PageResult, error) {
+	return m.QueryPagerItems(params, TeamsacsVoucher)
+}
+
+// GetVoucherBatch
+func (m *VoucherManager) GetVoucherBatch(id string) (*VoucherBatch, error) {
+	var result = new(VoucherBatch)
+	err := m.GetTeamsAcsCollection(TeamsacsVoucherBatch).FindOne(context.TODO(), bson.M{"_id": id}).Decode(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetVoucher
+func (m *VoucherManager) GetVoucher(code string) (*Voucher, error) {
+	var result = new(Voucher)
+	err := m.GetTeamsAcsCollection(TeamsacsVoucher).FindOne(context.TODO(), bson.M{"_id": code}).Decode(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetVoucherPin
+// The plain pin of the voucher for printing the cards
+func (m *VoucherManager) GetVoucherPin(v *Voucher) (string, error) {
+	return aes.DecryptFromB64(v.Pin, m.Config.System.Aeskey)
+}
+
+// GetBatchVouchers
+func (m *VoucherManager) GetBatchVouchers(batchId string) ([]Voucher, error) {
+	opts := options.Find().SetSort(bson.M{"_id": 1})
+	cur, err := m.GetTeamsAcsCollection(TeamsacsVoucher).Find(context.TODO(), bson.M{"batch_id": batchId}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var result []Voucher
+	if err = cur.All(context.TODO(), &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// AddVoucherBatch
+// Generate the vouchers of the batch, the codes already used by other vouchers are generated again
+func (m *VoucherManager) AddVoucherBatch(batch *VoucherBatch) error {
+	if batch.CodeLength == 0 {
+		batch.CodeLength = voucherDefaultCodeLen
+	}
+	if batch.PinLength == 0 {
+		batch.PinLength = voucherDefaultPinLen
+	}
+	if err := batch.Validate(); err != nil {
+		return err
+	}
+	product, err := m.GetProductManager().GetProduct(batch.ProductId)
+	if err != nil {
+		return fmt.Errorf("product %s error, %s", batch.ProductId, err.Error())
+	}
+	if batch.ValidDays == 0 && product.ValidDays == 0 {
+		return fmt.Errorf("product %s has no valid days, voucher valid days is required", product.Name)
+	}
+	now := time.Now()
+	if batch.ExpireTime.IsZero() {
+		batch.ExpireTime = now.AddDate(1, 0, 0)
+	}
+	if !batch.ExpireTime.After(now) {
+		return fmt.Errorf("voucher batch expire time is over")
+	}
+	if common.IsEmptyOrNA(batch.ID) {
+		batch.ID = common.UUID()
+	}
+	batch.CreateTime = now
+
+	pending := batch.Count
+	for retry := 0; pending > 0; retry++ {
+		if retry > voucherInsertRetries {
+			return fmt.Errorf("voucher batch %s only %d of %d vouchers created", batch.Name, batch.Count-pending, batch.Count)
+		}
+		vouchers := make([]interface{}, 0, pending)
+		for i := 0; i < pending; i++ {
+			v, err := m.newVoucher(batch, now)
+			if err != nil {
+				return err
+			}
+			vouchers = append(vouchers, v)
+		}
+		_, err = m.GetTeamsAcsCollection(TeamsacsVoucher).InsertMany(context.TODO(), vouchers, options.InsertMany().SetOrdered(false))
+		if pending, err = countDuplicateVouchers(err); err != nil {
+			return err
+		}
+	}
+	_, err = m.GetTeamsAcsCollection(TeamsacsVoucherBatch).InsertOne(context.TODO(), batch)
+	return err
+}
+
+func (m *VoucherManager) newVoucher(batch *VoucherBatch, now time.Time) (*Voucher, error) {
+	code, err := RandomDigits(batch.CodeLength)
+	if err != nil {
+		return nil, err
+	}
+	pin, err := RandomDigits(batch.PinLength)
+	if err != nil {
+		return nil, err
+	}
+	encpin, err := aes.EncryptToB64(pin, m.Config.System.Aeskey)
+	if err != nil {
+		return nil, err
+	}
+	return &Voucher{
+		Code:       batch.Prefix + code,
+		Pin:        encpin,
+		BatchId:    batch.ID,
+		ProductId:  batch.ProductId,
+		Status:     VoucherUnused,
+		ValidDays:  batch.ValidDays,
+		ExpireTime: batch.ExpireTime,
+		CreateTime: now,
+		UpdateTime: now,
+	}, nil
+}
+
+// countDuplicateVouchers
+// The number of vouchers not inserted for duplicate codes, other errors are returned
+func countDuplicateVouchers(err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
+	bwe, ok := err.(mongo.BulkWriteException)
+	if !ok {
+		return 0, err
+	}
+	for _, e := range bwe.WriteErrors {
+		if e.Code != 11000 {
+			return 0, err
+		}
+	}
+	return len(bwe.WriteErrors), nil
+}
+
+// DeleteVoucherBatch
+// Batches with used vouchers are kept for the records, revoke them instead
+func (m *VoucherManager) DeleteVoucherBatch(id string) error {
+	if common.IsEmptyOrNA(id) {
+		return fmt.Errorf("voucher batch id is empty or NA")
+	}
+	coll := m.GetTeamsAcsCollection(TeamsacsVoucher)
+	count, err := coll.CountDocuments(context.TODO(), bson.M{"batch_id": id, "status": bson.M{"$ne": VoucherUnused}})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("voucher batch has %d used vouchers", count)
+	}
+	if _, err = coll.DeleteMany(context.TODO(), bson.M{"batch_id": id}); err != nil {
+		return err
+	}
+	_, err = m.GetTeamsAcsCollection(TeamsacsVoucherBatch).DeleteOne(context.TODO(), bson.M{"_id": id})
+	return err
+}
+
+// transitVouchers
+// Move the vouchers matched by filter to the status, only from the states allowed by the state machine
+func (m *VoucherManager) transitVouchers(filter bson.M, status string, set bson.M) (int64, error) {
+	filter["status"] = bson.M{"$in": voucherTransitions[status]}
+	if set == nil {
+		set = bson.M{}
+	}
+	set["status"] = status
+	set["update_time"] = time.Now()
+	result, err := m.GetTeamsAcsCollection(TeamsacsVoucher).UpdateMany(context.TODO(), filter, bson.M{"$set": set})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// RevokeVouchers
+// Revoke a voucher or all vouchers of a batch, the subscribers created by active vouchers are not changed
+func (m *VoucherManager) RevokeVouchers(code, batchId string) (int64, error) {
+	switch {
+	case common.IsNotEmptyAndNA(code):
+		return m.transitVouchers(bson.M{"_id": code}, VoucherRevoked, nil)
+	case common.IsNotEmptyAndNA(batchId):
+		return m.transitVouchers(bson.M{"batch_id": batchId}, VoucherRevoked, nil)
+	}
+	return 0, fmt.Errorf("voucher code or batch id is required")
+}
+
+// GetVoucherSubscribe
+// The subscriber a voucher would create at the first login, nil if code is not an available voucher.
+// The subscriber is not saved until ActivateVoucher.
+func (m *VoucherManager) GetVoucherSubscribe(code string) (*Subscribe, *Voucher) {
+	voucher, err := m.GetVoucher(code)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Errorf("query voucher %s error, %s", code, err.Error())
+		}
+		return nil, nil
+	}
+	now := time.Now()
+	if !voucher.IsAvailable(now) {
+		return nil, nil
+	}
+	validDays, err := m.getValidDays(voucher)
+	if err != nil {
+		log.Error(err)
+		return nil, nil
+	}
+	return m.newVoucherSubscribe(voucher, code, voucherEndTime(now, now, validDays)), voucher
+}
+
+func (m *VoucherManager) newVoucherSubscribe(voucher *Voucher, username string, expire time.Time) *Subscribe {
+	now := time.Now()
+	return &Subscribe{
+		"_id":          common.UUID(),
+		"username":     username,
+		"password":     voucher.Pin,
+		"product_id":   voucher.ProductId,
+		"voucher_code": voucher.Code,
+		"status":       constant.ENABLED,
+		"expire_time":  expire,
+		"create_time":  now,
+		"update_time":  now,
+		"remark":       "prepaid voucher " + voucher.Code,
+	}
+}
+
+func (m *VoucherManager) getValidDays(voucher *Voucher) (int, error) {
+	if voucher.ValidDays > 0 {
+		return voucher.ValidDays, nil
+	}
+	product, err := m.GetProductManager().GetProduct(voucher.ProductId)
+	if err != nil {
+		return 0, fmt.Errorf("voucher %s product %s error, %s", voucher.Code, voucher.ProductId, err.Error())
+	}
+	if product.ValidDays <= 0 {
+		return 0, fmt.Errorf("voucher %s product %s has no valid days", voucher.Code, product.Name)
+	}
+	return product.ValidDays, nil
+}
+
+// RedeemVoucher
+// Check the pin and activate the voucher for username, the voucher code if username is empty
+func (m *VoucherManager) RedeemVoucher(code, pin, username string) (*Voucher, error) {
+	voucher, err := m.GetVoucher(code)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("voucher %s not exists", code)
+		}
+		return nil, err
+	}
+	vpin, err := m.GetVoucherPin(voucher)
+	if err != nil || vpin != pin {
+		return nil, fmt.Errorf("voucher %s pin is invalid", code)
+	}
+	if common.IsEmptyOrNA(username) {
+		username = code
+	}
+	return voucher, m.ActivateVoucher(voucher, username)
+}
+
+// ActivateVoucher
+// Start the validity of the voucher, the subscriber username is created with the product of the voucher,
+// an existing subscriber is moved to the product and its expire time is extended by the valid days.
+func (m *VoucherManager) ActivateVoucher(voucher *Voucher, username string) error {
+	now := time.Now()
+	if !voucher.IsAvailable(now) {
+		return fmt.Errorf("voucher %s is %s", voucher.Code, voucher.Status)
+	}
+	validDays, err := m.getValidDays(voucher)
+	if err != nil {
+		return err
+	}
+	sm := m.GetSubscribeManager()
+	user, err := sm.GetSubscribeByUser(username)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	expire := now
+	if user != nil {
+		expire = user.GetExpireTime()
+	}
+	endTime := voucherEndTime(now, expire, validDays)
+
+	// the conditional transition makes sure a voucher is used only once
+	count, err := m.transitVouchers(bson.M{"_id": voucher.Code, "expire_time": bson.M{"$gt": now}}, VoucherActive, bson.M{
+		"active_time": now,
+		"end_time":    endTime,
+		"username":    username,
+	})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("voucher %s is not available", voucher.Code)
+	}
+
+	coll := m.GetTeamsAcsCollection(TeamsacsSubscribe)
+	if user == nil {
+		newuser := m.newVoucherSubscribe(voucher, username, endTime)
+		if _, err = coll.InsertOne(context.TODO(), newuser); err == nil {
+			m.GetSubscribeManager().PublishSubscribeAdd(*newuser)
+		}
+	} else {
+		valmap := map[string]interface{}{
+			"product_id":   voucher.ProductId,
+			"voucher_code": voucher.Code,
+			"expire_time":  endTime,
+			"update_time":  now,
+		}
+		if _, err = coll.UpdateOne(context.TODO(), bson.M{"username": username}, bson.M{"$set": valmap}); err == nil {
+			m.GetSubscribeManager().PublishSubscribeUpdate(username, valmap)
+		}
+	}
+	m.InvalidateCache(TeamsacsSubscribe)
+	if err != nil {
+		// give the voucher back, the subscriber is not changed
+		_, rerr := m.GetTeamsAcsCollection(TeamsacsVoucher).UpdateOne(context.TODO(),
+			bson.M{"_id": voucher.Code, "status": VoucherActive},
+			bson.M{"$set": bson.M{"status": VoucherUnused, "username": "", "update_time": time.Now()},
+				"$unset": bson.M{"active_time": "", "end_time": ""}})
+		if rerr != nil {
+			log.Errorf("voucher %s rollback error, %s", voucher.Code, rerr.Error())
+		}
+		return fmt.Errorf("voucher %s redeem for user:%s error, %s", voucher.Code, username, err.Error())
+	}
+	voucher.Status = VoucherActive
+	voucher.ActiveTime = now
+	voucher.EndTime = endTime
+	voucher.Username = username
+	return nil
+}
+
+// ClearExpireVouchers
+// Unused vouchers over the deadline of the first use and active vouchers over the validity are expired
+func (m *VoucherManager) ClearExpireVouchers() {
+	now := time.Now()
+	count, err := m.transitVouchers(bson.M{"$or": bson.A{
+		bson.M{"status": VoucherUnused, "expire_time": bson.M{"$lt": now}},
+		bson.M{"status": VoucherActive, "end_time": bson.M{"$lt": now}},
+	}}, VoucherExpired, nil)
+	if err != nil {
+		log.Errorf("expire vouchers error, %s", err.Error())
+		return
+	}
+	if count > 0 {
+		log.Infof("%d vouchers expired", count)
+	}
+}
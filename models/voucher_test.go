@@ -0,0 +1,143 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestRandomDigits(t *testing.T) {
+	codes := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		code, err := RandomDigits(12)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != 12 {
+			t.Fatalf("code %s length %d, expect 12", code, len(code))
+		}
+		for _, c := range code {
+			if c < '0' || c > '9' {
+				t.Fatalf("code %s is not digits", code)
+			}
+		}
+		codes[code] = true
+	}
+	if len(codes) < 100 {
+		t.Errorf("%d duplicate codes", 100-len(codes))
+	}
+}
+
+func TestVoucherTransit(t *testing.T) {
+	tests := []struct {
+		from, to string
+		expect   bool
+	}{
+		{VoucherUnused, VoucherActive, true},
+		{VoucherUnused, VoucherExpired, true},
+		{VoucherUnused, VoucherRevoked, true},
+		{VoucherActive, VoucherExpired, true},
+		{VoucherActive, VoucherRevoked, true},
+		{VoucherActive, VoucherActive, false},
+		{VoucherActive, VoucherUnused, false},
+		{VoucherExpired, VoucherActive, false},
+		{VoucherRevoked, VoucherActive, false},
+		{VoucherRevoked, VoucherExpired, false},
+	}
+	for _, tt := range tests {
+		if CanVoucherTransit(tt.from, tt.to) != tt.expect {
+			t.Errorf("%s => %s expect %v", tt.from, tt.to, tt.expect)
+		}
+	}
+}
+
+func TestVoucherEndTime(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	if end := voucherEndTime(now, now, 30); !end.Equal(now.AddDate(0, 0, 30)) {
+		t.Errorf("new subscriber end %s", end)
+	}
+	expired := now.AddDate(0, 0, -5)
+	if end := voucherEndTime(now, expired, 30); !end.Equal(now.AddDate(0, 0, 30)) {
+		t.Errorf("expired subscriber end %s", end)
+	}
+	valid := now.AddDate(0, 0, 5)
+	if end := voucherEndTime(now, valid, 30); !end.Equal(now.AddDate(0, 0, 35)) {
+		t.Errorf("valid subscriber end %s", end)
+	}
+}
+
+func TestVoucherAvailable(t *testing.T) {
+	now := time.Now()
+	v := &Voucher{Status: VoucherUnused, ExpireTime: now.Add(time.Hour)}
+	if !v.IsAvailable(now) {
+		t.Error("unused voucher not available")
+	}
+	if v.IsAvailable(now.Add(time.Hour * 2)) {
+		t.Error("voucher over the deadline available")
+	}
+	v.Status = VoucherActive
+	if v.IsAvailable(now) {
+		t.Error("active voucher available")
+	}
+}
+
+func TestVoucherBatchValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		batch VoucherBatch
+		valid bool
+	}{
+		{"valid", VoucherBatch{Name: "b1", ProductId: "p1", Count: 100, CodeLength: 12, PinLength: 6}, true},
+		{"no product", VoucherBatch{Name: "b1", Count: 100, CodeLength: 12, PinLength: 6}, false},
+		{"no count", VoucherBatch{Name: "b1", ProductId: "p1", CodeLength: 12, PinLength: 6}, false},
+		{"short code", VoucherBatch{Name: "b1", ProductId: "p1", Count: 100, CodeLength: 4, PinLength: 6}, false},
+		{"short pin", VoucherBatch{Name: "b1", ProductId: "p1", Count: 100, CodeLength: 12, PinLength: 2}, false},
+		{"invalid prefix", VoucherBatch{Name: "b1", ProductId: "p1", Count: 100, Prefix: "a@", CodeLength: 12, PinLength: 6}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.batch.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s validate %v, expect valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestCountDuplicateVouchers(t *testing.T) {
+	dup := mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{
+		{WriteError: mongo.WriteError{Code: 11000}},
+		{WriteError: mongo.WriteError{Code: 11000}},
+	}}
+	if n, err := countDuplicateVouchers(dup); n != 2 || err != nil {
+		t.Errorf("duplicate vouchers %d %v, expect 2", n, err)
+	}
+	other := mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{
+		{WriteError: mongo.WriteError{Code: 11000}},
+		{WriteError: mongo.WriteError{Code: 121}},
+	}}
+	if _, err := countDuplicateVouchers(other); err == nil {
+		t.Error("validation error not returned")
+	}
+	if _, err := countDuplicateVouchers(errors.New("timeout")); err == nil {
+		t.Error("error not returned")
+	}
+	if n, err := countDuplicateVouchers(nil); n != 0 || err != nil {
+		t.Errorf("no error %d %v", n, err)
+	}
+}
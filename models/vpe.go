@@ -38,6 +38,12 @@ func (v DataObject) GetVendorCode() string {
 	return v.GetStringValue("vendor_code","")
 }
 
+// GetVlanFormat
+// The NAS-Port-Id VLAN formats of the VPE, e.g. std,colon, empty for the vendor formats
+func (v DataObject) GetVlanFormat() string {
+	return v.GetStringValue("vlan_format", "")
+}
+
 // VpeManager
 type VpeManager struct{ *ModelManager }
 
@@ -54,28 +60,31 @@ func (m *VpeManager) QueryVpes(params web.RequestParams) (*web.PageResult, error
 
 // GetVpeByIpaddr
 func (m *VpeManager) GetVpeByIpaddr(ip string) (*Vpe, error) {
-	coll := m.GetTeamsAcsCollection(TeamsacsVpe)
-	doc := coll.FindOne(context.TODO(), bson.M{"ipaddr": ip})
-	err := doc.Err()
-	if err != nil {
-		return nil, err
-	}
-	var result = new(Vpe)
-	err = doc.Decode(result)
-	return result, err
+	return m.getCachedVpe("ip:"+ip, bson.M{"ipaddr": ip})
 }
 
 // GetVpeByIdentifier
 func (m *VpeManager) GetVpeByIdentifier(identifier string) (*Vpe, error) {
+	return m.getCachedVpe("id:"+identifier, bson.M{"identifier": identifier})
+}
+
+func (m *VpeManager) getCachedVpe(key string, filter bson.M) (*Vpe, error) {
+	if v, ok := m.Cache.Vpe.Get(key); ok {
+		return v.(Vpe).Copy(), nil
+	}
 	coll := m.GetTeamsAcsCollection(TeamsacsVpe)
-	doc := coll.FindOne(context.TODO(), bson.M{"identifier": identifier})
+	doc := coll.FindOne(context.TODO(), filter)
 	err := doc.Err()
 	if err != nil {
 		return nil, err
 	}
 	var result = new(Vpe)
 	err = doc.Decode(result)
-	return result, err
+	if err != nil {
+		return nil, err
+	}
+	m.Cache.Vpe.Set(key, cacheId((*result)["_id"]), *result)
+	return result.Copy(), nil
 }
 
 
@@ -107,5 +116,6 @@ func (m *VpeManager) AddVpeData(params web.RequestParams) error {
 		return err
 	}
 	_, err = coll.InsertOne(context.TODO(), data)
+	m.InvalidateCache(TeamsacsVpe, cacheId(data["_id"]))
 	return err
 }
@@ -0,0 +1,531 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/common/aes"
+	"github.com/ca17/teamsacs/common/log"
+	"github.com/ca17/teamsacs/common/web"
+	"github.com/ca17/teamsacs/constant"
+)
+
+const (
+	WebhookDefaultRetries = 5
+	WebhookDefaultTimeout = 10
+	WebhookMaxRetries     = 10
+	WebhookMaxTimeout     = 60
+
+	WebhookHeaderEvent     = "X-Teamsacs-Event"
+	WebhookHeaderDelivery  = "X-Teamsacs-Delivery"
+	WebhookHeaderTimestamp = "X-Teamsacs-Timestamp"
+	WebhookHeaderSignature = "X-Teamsacs-Signature"
+
+	// concurrent deliveries of a webhook, a slow webhook does not hold the others
+	webhookWorkers = 4
+	// pending deliveries of a webhook, the events over are saved as dead letters
+	webhookQueueSize  = 1024
+	webhookMaxBackoff = time.Minute * 5
+	webhookCacheKey   = "enabled"
+)
+
+var webhookClient = &http.Client{}
+
+// Webhook
+// Subscription of the events matched by the filters, the payloads are signed by the secret
+type Webhook struct {
+	ID         string    `bson:"_id,omitempty" json:"id,omitempty"`
+	Name       string    `bson:"name" json:"name"`
+	Url        string    `bson:"url" json:"url"`
+	Secret     string    `bson:"secret" json:"secret,omitempty"`
+	Events     []string  `bson:"events" json:"events"`
+	Status     string    `bson:"status" json:"status"`
+	MaxRetries int       `bson:"max_retries" json:"max_retries"`
+	Timeout    int       `bson:"timeout" json:"timeout"`
+	Remark     string    `bson:"remark" json:"remark"`
+	CreateTime time.Time `bson:"create_time" json:"create_time"`
+	UpdateTime time.Time `bson:"update_time" json:"update_time"`
+}
+
+// WebhookDeadLetter
+// An event not delivered after all retries
+type WebhookDeadLetter struct {
+	ID          string    `bson:"_id,omitempty" json:"id,omitempty"`
+	WebhookId   string    `bson:"webhook_id" json:"webhook_id"`
+	WebhookName string    `bson:"webhook_name" json:"webhook_name"`
+	Url         string    `bson:"url" json:"url"`
+	Event       *Event    `bson:"event" json:"event"`
+	Attempts    int       `bson:"attempts" json:"attempts"`
+	Error       string    `bson:"error" json:"error"`
+	Timestamp   time.Time `bson:"timestamp" json:"timestamp"`
+}
+
+// Validate
+// Defaults are set for the retries and timeout not set
+func (w *Webhook) Validate() error {
+	if common.IsEmptyOrNA(w.Name) {
+		return fmt.Errorf("invalid webhook name")
+	}
+	u, err := url.Parse(w.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook url %s", w.Url)
+	}
+	for _, filter := range w.Events {
+		if filter == "" {
+			return fmt.Errorf("invalid webhook event filter")
+		}
+	}
+	if w.MaxRetries == 0 {
+		w.MaxRetries = WebhookDefaultRetries
+	}
+	if w.Timeout == 0 {
+		w.Timeout = WebhookDefaultTimeout
+	}
+	if w.MaxRetries < 0 || w.MaxRetries > WebhookMaxRetries {
+		return fmt.Errorf("webhook max_retries must be 1-%d", WebhookMaxRetries)
+	}
+	if w.Timeout < 0 || w.Timeout > WebhookMaxTimeout {
+		return fmt.Errorf("webhook timeout must be 1-%d", WebhookMaxTimeout)
+	}
+	return nil
+}
+
+// SignWebhookPayload
+// Hex HMAC-SHA256 of "timestamp.body", the receiver checks it with the timestamp header
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookBackoff
+// The wait before the retry after attempt (from 0), doubled from one second
+func WebhookBackoff(attempt int) time.Duration {
+	if attempt > 16 {
+		return webhookMaxBackoff
+	}
+	d := time.Second << uint(attempt)
+	if d > webhookMaxBackoff {
+		return webhookMaxBackoff
+	}
+	return d
+}
+
+// PostWebhook
+// Send the event to the url once, a response other than 2xx is an error
+func PostWebhook(hookurl, secret string, timeout int, event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hookurl, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookHeaderEvent, event.Type)
+	req.Header.Set(WebhookHeaderDelivery, event.ID)
+	req.Header.Set(WebhookHeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookHeaderSignature, "sha256="+SignWebhookPayload(secret, timestamp, body))
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// newWebhookSecret
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// webhookDelivery
+// An event to be sent to the webhook, attempts is the number of the failed posts,
+// hook is reloaded before every attempt
+type webhookDelivery struct {
+	hook     Webhook
+	event    *Event
+	attempts int
+}
+
+// WebhookManager
+// Delivers the events of the bus to the webhooks, an event failed after the retries is saved as dead letter
+type WebhookManager struct {
+	*ModelManager
+	queueLock sync.Mutex
+	queues    map[string]chan *webhookDelivery
+}
+
+func newWebhookManager(m *ModelManager) *WebhookManager {
+	return &WebhookManager{ModelManager: m, queues: make(map[string]chan *webhookDelivery)}
+}
+
+func (m *ModelManager) GetWebhookManager() *WebhookManager {
+	store, _ := m.ManagerMap.Get("WebhookManager")
+	return store.(*WebhookManager)
+}
+
+// QueryWebhooks
+// All webhooks by name, the secrets are not returned
+func (m *WebhookManager) QueryWebhooks() ([]Webhook, error) {
+	cur, err := m.GetTeamsAcsCollection(TeamsacsWebhook).Find(context.TODO(), bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var items = make([]Webhook, 0)
+	if err = cur.All(context.TODO(), &items); err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Secret = ""
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+	return items, nil
+}
+
+// GetWebhook
+// The secret is encrypted
+func (m *WebhookManager) GetWebhook(id string) (*Webhook, error) {
+	doc := m.GetTeamsAcsCollection(TeamsacsWebhook).FindOne(context.TODO(), bson.M{"_id": id})
+	if err := doc.Err(); err != nil {
+		return nil, err
+	}
+	var result = new(Webhook)
+	err := doc.Decode(result)
+	return result, err
+}
+
+// getEnabledWebhooks
+// Matched for every published event, the list is cached
+func (m *WebhookManager) getEnabledWebhooks() []Webhook {
+	if v, ok := m.Cache.Webhook.Get(webhookCacheKey); ok {
+		return v.([]Webhook)
+	}
+	var items = make([]Webhook, 0)
+	cur, err := m.GetTeamsAcsCollection(TeamsacsWebhook).Find(context.TODO(), bson.M{"status": constant.ENABLED})
+	if err == nil {
+		err = cur.All(context.TODO(), &items)
+	}
+	if err != nil {
+		log.Errorf("query webhooks error, %s", err.Error())
+		return items
+	}
+	m.Cache.Webhook.Set(webhookCacheKey, "", items)
+	return items
+}
+
+// AddWebhook
+// A secret is generated if not set, the plain secret is left in w to be returned once
+func (m *WebhookManager) AddWebhook(w *Webhook) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	if w.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return err
+		}
+		w.Secret = secret
+	}
+	encsecret, err := aes.EncryptToB64(w.Secret, m.Config.System.Aeskey)
+	if err != nil {
+		return err
+	}
+	if w.Status == "" {
+		w.Status = constant.ENABLED
+	}
+	if w.Events == nil {
+		w.Events = []string{}
+	}
+	item := *w
+	item.ID = common.UUID()
+	item.Secret = encsecret
+	item.CreateTime = time.Now()
+	item.UpdateTime = item.CreateTime
+	_, err = m.GetTeamsAcsCollection(TeamsacsWebhook).InsertOne(context.TODO(), item)
+	m.InvalidateCache(TeamsacsWebhook)
+	if err != nil {
+		return err
+	}
+	w.ID, w.CreateTime, w.UpdateTime = item.ID, item.CreateTime, item.UpdateTime
+	return nil
+}
+
+// UpdateWebhook
+// The secret is changed only if set
+func (m *WebhookManager) UpdateWebhook(w *Webhook) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	data := bson.M{
+		"name":        w.Name,
+		"url":         w.Url,
+		"events":      w.Events,
+		"max_retries": w.MaxRetries,
+		"timeout":     w.Timeout,
+		"remark":      w.Remark,
+		"update_time": time.Now(),
+	}
+	if w.Events == nil {
+		data["events"] = []string{}
+	}
+	if common.InSlice(w.Status, []string{constant.ENABLED, constant.DISABLED}) {
+		data["status"] = w.Status
+	}
+	if w.Secret != "" {
+		encsecret, err := aes.EncryptToB64(w.Secret, m.Config.System.Aeskey)
+		if err != nil {
+			return err
+		}
+		data["secret"] = encsecret
+	}
+	r, err := m.GetTeamsAcsCollection(TeamsacsWebhook).UpdateOne(context.TODO(), bson.M{"_id": w.ID}, bson.M{"$set": data})
+	m.InvalidateCache(TeamsacsWebhook)
+	m.dropQueue(w.ID)
+	if err != nil {
+		return err
+	}
+	if r.MatchedCount == 0 {
+		return fmt.Errorf("webhook %s not exists", w.ID)
+	}
+	return nil
+}
+
+// DeleteWebhook
+// The dead letters of the webhook are kept
+func (m *WebhookManager) DeleteWebhook(id string) error {
+	_, err := m.GetTeamsAcsCollection(TeamsacsWebhook).DeleteOne(context.TODO(), bson.M{"_id": id})
+	m.InvalidateCache(TeamsacsWebhook)
+	m.dropQueue(id)
+	return err
+}
+
+// MatchEvent
+// EventHandler, whether an enabled webhook wants the event
+func (m *WebhookManager) MatchEvent(etype string) bool {
+	for _, w := range m.getEnabledWebhooks() {
+		if MatchEventFilters(w.Events, etype) {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleEvent
+// EventHandler, the event is queued to the matched webhooks, the bus is never blocked
+func (m *WebhookManager) HandleEvent(event *Event) {
+	for _, w := range m.getEnabledWebhooks() {
+		if !MatchEventFilters(w.Events, event.Type) {
+			continue
+		}
+		m.enqueue(&webhookDelivery{hook: w, event: event})
+	}
+}
+
+// getEnabledWebhook
+// The current config of an enabled webhook, nil if deleted or disabled
+func (m *WebhookManager) getEnabledWebhook(id string) *Webhook {
+	for _, w := range m.getEnabledWebhooks() {
+		if w.ID == id {
+			return &w
+		}
+	}
+	return nil
+}
+
+// enqueue
+// The queue and its workers are started by the first delivery of the webhook,
+// the delivery is saved as dead letter if the queue is full.
+func (m *WebhookManager) enqueue(d *webhookDelivery) {
+	m.queueLock.Lock()
+	queue, ok := m.queues[d.hook.ID]
+	if !ok {
+		queue = make(chan *webhookDelivery, webhookQueueSize)
+		m.queues[d.hook.ID] = queue
+		for i := 0; i < webhookWorkers; i++ {
+			go func() {
+				for d := range queue {
+					m.deliver(d)
+				}
+			}()
+		}
+	}
+	var full bool
+	select {
+	case queue <- d:
+	default:
+		full = true
+	}
+	m.queueLock.Unlock()
+	if full {
+		m.addDeadLetter(d, fmt.Errorf("webhook queue is full"))
+	}
+}
+
+// dropQueue
+// The workers exit after the queued deliveries, the webhook changed or deleted gets a new queue
+// on the next event. The queued deliveries are sent by the reloaded config or dropped if it is gone.
+func (m *WebhookManager) dropQueue(id string) {
+	m.queueLock.Lock()
+	defer m.queueLock.Unlock()
+	if queue, ok := m.queues[id]; ok {
+		delete(m.queues, id)
+		close(queue)
+	}
+}
+
+// deliver
+// Post the event once by the current config of the webhook, the retry is queued again after
+// the backoff until MaxRetries, then the dead letter is saved. The worker does not wait for the backoff.
+func (m *WebhookManager) deliver(d *webhookDelivery) {
+	w := m.getEnabledWebhook(d.hook.ID)
+	if w == nil {
+		log.Infof("webhook %s is removed or disabled, event %s %s dropped", d.hook.Name, d.event.Type, d.event.ID)
+		m.dropQueue(d.hook.ID)
+		return
+	}
+	if !MatchEventFilters(w.Events, d.event.Type) {
+		return
+	}
+	d.hook = *w
+	err := m.postWebhook(&d.hook, d.event)
+	if err == nil {
+		return
+	}
+	d.attempts++
+	if d.attempts > d.hook.MaxRetries {
+		m.addDeadLetter(d, err)
+		return
+	}
+	time.AfterFunc(WebhookBackoff(d.attempts-1), func() {
+		if m.getEnabledWebhook(d.hook.ID) == nil {
+			log.Infof("webhook %s is removed or disabled, event %s %s retry dropped", d.hook.Name, d.event.Type, d.event.ID)
+			return
+		}
+		m.enqueue(d)
+	})
+}
+
+// addDeadLetter
+func (m *WebhookManager) addDeadLetter(d *webhookDelivery, err error) {
+	log.Errorf("webhook %s event %s delivery failure, %s", d.hook.Name, d.event.Type, err.Error())
+	dead := &WebhookDeadLetter{
+		ID:          common.UUID(),
+		WebhookId:   d.hook.ID,
+		WebhookName: d.hook.Name,
+		Url:         d.hook.Url,
+		Event:       d.event,
+		Attempts:    d.attempts,
+		Error:       err.Error(),
+		Timestamp:   time.Now(),
+	}
+	if _, err = m.GetTeamsAcsCollection(TeamsacsWebhookDead).InsertOne(context.TODO(), dead); err != nil {
+		log.Errorf("add webhook dead letter error, %s", err.Error())
+	}
+}
+
+// postWebhook
+// Send the event to the webhook once
+func (m *WebhookManager) postWebhook(w *Webhook, event *Event) error {
+	secret, err := aes.DecryptFromB64(w.Secret, m.Config.System.Aeskey)
+	if err != nil {
+		return err
+	}
+	return PostWebhook(w.Url, secret, w.Timeout, event)
+}
+
+// PingWebhook
+// Send a webhook.ping event to check the receiver, it is not retried
+func (m *WebhookManager) PingWebhook(id string) error {
+	w, err := m.GetWebhook(id)
+	if err != nil {
+		return err
+	}
+	return m.postWebhook(w, NewEvent(EventWebhookPing, map[string]interface{}{"webhook_id": w.ID, "name": w.Name}))
+}
+
+func (m *WebhookManager) QueryDeadLetters(params web.RequestParams) (*web.PageResult, error) {
+	return m.QueryPagerItems(params, TeamsacsWebhookDead)
+}
+
+// RetryDeadLetter
+// Send the event again to the current url of the webhook, the dead letter is removed if delivered
+func (m *WebhookManager) RetryDeadLetter(id string) error {
+	coll := m.GetTeamsAcsCollection(TeamsacsWebhookDead)
+	doc := coll.FindOne(context.TODO(), bson.M{"_id": id})
+	if err := doc.Err(); err != nil {
+		return err
+	}
+	var dead = new(WebhookDeadLetter)
+	if err := doc.Decode(dead); err != nil {
+		return err
+	}
+	w, err := m.GetWebhook(dead.WebhookId)
+	if err != nil {
+		return fmt.Errorf("webhook %s not exists", dead.WebhookName)
+	}
+	if err = m.postWebhook(w, dead.Event); err != nil {
+		_, _ = coll.UpdateOne(context.TODO(), bson.M{"_id": id}, bson.M{
+			"$set": bson.M{"error": err.Error(), "timestamp": time.Now()},
+			"$inc": bson.M{"attempts": 1},
+		})
+		return err
+	}
+	_, err = coll.DeleteOne(context.TODO(), bson.M{"_id": id})
+	return err
+}
+
+// DeleteDeadLetters
+func (m *WebhookManager) DeleteDeadLetters(ids []string) error {
+	_, err := m.GetTeamsAcsCollection(TeamsacsWebhookDead).DeleteMany(context.TODO(), bson.M{"_id": bson.M{"$in": ids}})
+	return err
+}
@@ -0,0 +1,210 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package models
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ca17/teamsacs/common/aes"
+	"github.com/ca17/teamsacs/config"
+)
+
+func TestWebhookValidate(t *testing.T) {
+	w := &Webhook{Name: "crm", Url: "https://crm.example.com/hook", Events: []string{"radius.*"}}
+	if err := w.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if w.MaxRetries != WebhookDefaultRetries || w.Timeout != WebhookDefaultTimeout {
+		t.Fatalf("defaults error %+v", w)
+	}
+	invalids := []*Webhook{
+		{Name: "", Url: "https://crm.example.com/hook"},
+		{Name: "crm", Url: "ftp://crm.example.com/hook"},
+		{Name: "crm", Url: "http:///hook"},
+		{Name: "crm", Url: "https://crm.example.com/hook", Events: []string{""}},
+		{Name: "crm", Url: "https://crm.example.com/hook", MaxRetries: WebhookMaxRetries + 1},
+		{Name: "crm", Url: "https://crm.example.com/hook", Timeout: -1},
+	}
+	for _, w := range invalids {
+		if err := w.Validate(); err == nil {
+			t.Errorf("%+v must be invalid", w)
+		}
+	}
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	// echo -n '1603000000.{}' | openssl dgst -sha256 -hmac secret
+	sign := SignWebhookPayload("secret", 1603000000, []byte("{}"))
+	if sign != "0092bff159da13fca6940ea1671d39994d2d55ef1fc2e7e541f7523c8f07bffc" {
+		t.Fatalf("sign error %s", sign)
+	}
+	if sign == SignWebhookPayload("secret2", 1603000000, []byte("{}")) ||
+		sign == SignWebhookPayload("secret", 1603000001, []byte("{}")) {
+		t.Fatal("sign must depend on the secret and timestamp")
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	if WebhookBackoff(0) != time.Second || WebhookBackoff(3) != time.Second*8 {
+		t.Fatal("backoff error")
+	}
+	if WebhookBackoff(12) != webhookMaxBackoff || WebhookBackoff(100) != webhookMaxBackoff {
+		t.Fatal("backoff must be limited")
+	}
+}
+
+func TestPostWebhook(t *testing.T) {
+	var status = http.StatusNoContent
+	var received *Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(WebhookHeaderTimestamp), 10, 64)
+		if r.Header.Get(WebhookHeaderSignature) != "sha256="+SignWebhookPayload("secret", timestamp, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received = new(Event)
+		if err := json.Unmarshal(body, received); err != nil || r.Header.Get(WebhookHeaderEvent) != received.Type ||
+			r.Header.Get(WebhookHeaderDelivery) != received.ID {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	event := NewEvent(EventAuthReject, map[string]interface{}{"username": "tim", "reason": "password error"})
+	if err := PostWebhook(server.URL, "secret", 3, event); err != nil {
+		t.Fatal(err)
+	}
+	if received == nil || received.ID != event.ID || received.Data["reason"] != "password error" {
+		t.Fatalf("received error %+v", received)
+	}
+	if err := PostWebhook(server.URL, "wrong", 3, event); err == nil {
+		t.Fatal("wrong signature must be refused")
+	}
+	status = http.StatusInternalServerError
+	if err := PostWebhook(server.URL, "secret", 3, event); err == nil {
+		t.Fatal("status 500 must be an error")
+	}
+}
+
+func TestWebhookSlowDelivery(t *testing.T) {
+	release := make(chan struct{})
+	var slowReceived, received int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		atomic.AddInt32(&slowReceived, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer fast.Close()
+
+	secret, err := aes.EncryptToB64("secret", config.DefaultAppConfig.System.Aeskey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newWebhookManager(&ModelManager{Config: config.DefaultAppConfig, Cache: NewCacheManager(CacheDefaultTTL)})
+	m.Cache.Webhook.Set(webhookCacheKey, "", []Webhook{
+		{ID: "slow", Name: "slow", Url: slow.URL, Secret: secret, Events: []string{"*"}, MaxRetries: 1, Timeout: 30},
+		{ID: "fast", Name: "fast", Url: fast.URL, Secret: secret, Events: []string{"*"}, MaxRetries: 1, Timeout: 30},
+	})
+
+	// the slow webhook holds all its workers, the events are still published and delivered to the other
+	// more than the workers of a webhook and less than its queue
+	const count = 100
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < count; i++ {
+			m.HandleEvent(NewEvent(EventAuthReject, map[string]interface{}{"username": "tim"}))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("the bus is blocked by the slow webhook")
+	}
+	waitReceived(t, "fast", &received, count)
+	close(release)
+	waitReceived(t, "slow", &slowReceived, count)
+}
+
+func waitReceived(t *testing.T, name string, received *int32, count int32) {
+	deadline := time.Now().Add(time.Second * 5)
+	for atomic.LoadInt32(received) < count && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if n := atomic.LoadInt32(received); n != count {
+		t.Fatalf("%s webhook received %d of %d", name, n, count)
+	}
+}
+
+func TestWebhookReload(t *testing.T) {
+	newServer := func(status int, received *int32) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(received, 1)
+			w.WriteHeader(status)
+		}))
+	}
+	var failed, deleted, received int32
+	failServer := newServer(http.StatusInternalServerError, &failed)
+	defer failServer.Close()
+	deletedServer := newServer(http.StatusInternalServerError, &deleted)
+	defer deletedServer.Close()
+	okServer := newServer(http.StatusNoContent, &received)
+	defer okServer.Close()
+
+	secret, err := aes.EncryptToB64("secret", config.DefaultAppConfig.System.Aeskey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newWebhookManager(&ModelManager{Config: config.DefaultAppConfig, Cache: NewCacheManager(CacheDefaultTTL)})
+	hook := Webhook{ID: "crm", Name: "crm", Url: failServer.URL, Secret: secret, Events: []string{"*"}, MaxRetries: 1, Timeout: 30}
+	m.Cache.Webhook.Set(webhookCacheKey, "", []Webhook{hook,
+		{ID: "old", Name: "old", Url: deletedServer.URL, Secret: secret, Events: []string{"*"}, MaxRetries: 1, Timeout: 30},
+	})
+	m.HandleEvent(NewEvent(EventAuthReject, map[string]interface{}{"username": "tim"}))
+	waitReceived(t, "failed", &failed, 1)
+	waitReceived(t, "deleted", &deleted, 1)
+
+	// the url of crm is changed and old is deleted before the retry
+	hook.Url = okServer.URL
+	m.Cache.Webhook.Set(webhookCacheKey, "", []Webhook{hook})
+	m.dropQueue("old")
+	waitReceived(t, "changed", &received, 1)
+	time.Sleep(time.Millisecond * 100)
+	if f, d := atomic.LoadInt32(&failed), atomic.LoadInt32(&deleted); f != 1 || d != 1 {
+		t.Fatalf("the retry must not be sent to the old url, failed %d deleted %d", f, d)
+	}
+	m.queueLock.Lock()
+	defer m.queueLock.Unlock()
+	if _, ok := m.queues["old"]; ok {
+		t.Fatal("the queue of the deleted webhook must be dropped")
+	}
+}
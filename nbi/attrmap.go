@@ -0,0 +1,107 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package nbi
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/constant"
+	"github.com/ca17/teamsacs/radiusd"
+	"github.com/ca17/teamsacs/radiusd/authorization"
+)
+
+// GetVendorMapping
+// The attribute mapping yaml of the vendor and where it comes from (config, file, builtin or none)
+func (h *HttpHandler) GetVendorMapping(c echo.Context) error {
+	params := h.RequestParse(c)
+	vendorCode := params.GetMustString("vendor_code")
+	service := radiusd.NewRadiusService(h.GetManager())
+	text, origin := service.GetVendorMappingSource(vendorCode)
+	return c.JSON(http.StatusOK, h.RestResult(map[string]interface{}{
+		"vendor_code": vendorCode,
+		"mapping":     origin,
+		"value":       text,
+	}))
+}
+
+// UpdateVendorMapping
+// Save the attribute mapping yaml of the vendor to the config collection, the mapping is
+// checked against the dictionary first.
+func (h *HttpHandler) UpdateVendorMapping(c echo.Context) error {
+	if h.GetUserLevel(c) != constant.NBIAdminLevel {
+		return c.NoContent(http.StatusForbidden)
+	}
+	params := h.RequestParse(c)
+	vendorCode := params.GetMustString("vendor_code")
+	value := params.GetMustString("value")
+	service := radiusd.NewRadiusService(h.GetManager())
+	if _, err := authorization.ParseVendorMapping(value, service.GetDictionary()); err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	err := h.GetManager().GetConfigManager().SaveConfigValue(constant.ConfigTypeAttrmap, vendorCode, value)
+	common.Must(err)
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
+
+// DeleteVendorMapping
+// Remove the mapping of the config collection, the mapping file or builtin mapping is used again
+func (h *HttpHandler) DeleteVendorMapping(c echo.Context) error {
+	if h.GetUserLevel(c) != constant.NBIAdminLevel {
+		return c.NoContent(http.StatusForbidden)
+	}
+	params := h.RequestParse(c)
+	vendorCode := params.GetMustString("vendor_code")
+	err := h.GetManager().GetConfigManager().DeleteConfigValue(constant.ConfigTypeAttrmap, vendorCode)
+	common.Must(err)
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
+
+// PreviewVendorMapping
+// The Access-Accept attributes username would receive from the VPE of ip or identifier
+func (h *HttpHandler) PreviewVendorMapping(c echo.Context) error {
+	params := h.RequestParse(c)
+	username := params.GetMustString("username")
+	vpe := params.GetMustString("vpe")
+	preview, err := radiusd.NewRadiusService(h.GetManager()).PreviewAccept(username, vpe)
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestResult(preview))
+}
+
+// GetRadiusDictionary
+// The statistics of the loaded radius dictionary
+func (h *HttpHandler) GetRadiusDictionary(c echo.Context) error {
+	service := radiusd.NewRadiusService(h.GetManager())
+	return c.JSON(http.StatusOK, h.RestResult(service.GetDictionary().Stats()))
+}
+
+// ReloadRadiusDictionary
+// Load the dictionary files of the radius workdir again, the mappings are parsed again at next use
+func (h *HttpHandler) ReloadRadiusDictionary(c echo.Context) error {
+	if h.GetUserLevel(c) != constant.NBIAdminLevel {
+		return c.NoContent(http.StatusForbidden)
+	}
+	stats, err := radiusd.LoadDictionary(h.GetManager())
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestResult(stats))
+}
@@ -0,0 +1,66 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package nbi
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/constant"
+)
+
+// QueryCdrFiles
+// The manifests of the exported days, the latest first
+func (h *HttpHandler) QueryCdrFiles(c echo.Context) error {
+	data, err := h.GetManager().GetCdrManager().ListCdrManifests()
+	common.Must(err)
+	return c.JSON(http.StatusOK, h.RestResult(data))
+}
+
+// DownloadCdrFile
+// Send an exported file by name as attachment
+func (h *HttpHandler) DownloadCdrFile(c echo.Context) error {
+	params := h.RequestParse(c)
+	name := params.GetMustString("name")
+	filename, err := h.GetManager().GetCdrManager().GetCdrFile(name)
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.Attachment(filename, name)
+}
+
+// ExportCdr
+// Export the files of an ended day (yyyy-mm-dd) again, the existing files are replaced
+func (h *HttpHandler) ExportCdr(c echo.Context) error {
+	if h.GetUserLevel(c) != constant.NBIAdminLevel {
+		return c.NoContent(http.StatusForbidden)
+	}
+	params, err := h.ParseJsonBody(c)
+	common.Must(err)
+	day, err := time.ParseInLocation("2006-01-02", params.GetMustString("date"), h.GetManager().Location)
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError("invalid date, "+err.Error()))
+	}
+	manifest, err := h.GetManager().GetCdrManager().ExportCdr(day)
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestResult(manifest))
+}
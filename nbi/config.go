@@ -53,3 +53,14 @@ func (h *HttpHandler) UpdateRadiusConfigs(c echo.Context) error {
 	}
 	return c.JSON(http.StatusOK, h.RestSucc("Success"))
 }
+
+// QueryCacheStats
+// Hit/miss counters of the radius document caches
+func (h *HttpHandler) QueryCacheStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.RestResult(h.GetManager().Cache.Stats()))
+}
+
+func (h *HttpHandler) ClearCache(c echo.Context) error {
+	h.GetManager().Cache.ClearAll()
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
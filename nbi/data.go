@@ -21,9 +21,11 @@ import (
 	"net/http"
 	"path"
 	"sort"
+	"time"
 
 	"github.com/360EntSecGroup-Skylar/excelize"
 	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/ca17/teamsacs/common"
 	"github.com/ca17/teamsacs/common/log"
@@ -132,30 +134,35 @@ func (h *HttpHandler) ExportData(c echo.Context) error {
 	collname := params.GetMustString("collname")
 	data, err := h.GetManager().GetDataManager().QueryItems(params, collname)
 	common.Must(err)
-	sheet := collname
+	names := make([]string, 0)
+	if len(*data) > 0 {
+		for k, _ := range (*data)[0] {
+			names = append(names, k)
+		}
+		sort.Slice(names, func(i, j int)bool{
+			return names[i] == "_id"
+		})
+	}
+	return h.ExportExcel(c, collname, names, *data)
+}
+
+// ExportExcel
+// Save the rows to a xlsx file in the data dir and send it as attachment, the first row is the names
+func (h *HttpHandler) ExportExcel(c echo.Context, sheet string, names []string, rows []map[string]interface{}) error {
 	filename := fmt.Sprintf("%s-%d.xlsx", sheet, common.UUIDint64())
 	filepath := path.Join(h.GetConfig().GetDataDir(), filename)
 	xlsx := excelize.NewFile()
 	index := xlsx.NewSheet(sheet)
-	names := make([]string, 0)
-	for i, item := range *data {
-		if i == 0 {
-			for k, _ := range item {
-				names = append(names, k)
-			}
-			sort.Slice(names, func(i, j int)bool{
-				return names[i] == "_id"
-			})
-			for j, name := range names {
-				xlsx.SetCellValue(sheet, fmt.Sprintf("%s%d", COLNAMES[j], 1), name)
-			}
-		}
+	for j, name := range names {
+		xlsx.SetCellValue(sheet, fmt.Sprintf("%s%d", COLNAMES[j], 1), name)
+	}
+	for i, item := range rows {
 		for j, name := range names {
-			xlsx.SetCellValue(sheet, fmt.Sprintf("%s%d", COLNAMES[j], i+2), item[name].(string))
+			xlsx.SetCellValue(sheet, fmt.Sprintf("%s%d", COLNAMES[j], i+2), excelValue(item[name]))
 		}
 	}
 	xlsx.SetActiveSheet(index)
-	err = xlsx.SaveAs(filepath)
+	err := xlsx.SaveAs(filepath)
 	if err != nil {
 		log.Error(err)
 		return h.GetInternalError(err)
@@ -163,3 +170,20 @@ func (h *HttpHandler) ExportData(c echo.Context) error {
 	c.Response().Header().Set("Content-Disposition", fmt.Sprintf("attachment;filename=%s.xlsx", sheet))
 	return c.File(filepath)
 }
+
+// excelValue
+// Dates are written as local time text, values excelize does not support as text
+func excelValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string, bool, int, int32, int64, float32, float64:
+		return val
+	case time.Time:
+		return val.Format("2006-01-02 15:04:05")
+	case primitive.DateTime:
+		return val.Time().Format("2006-01-02 15:04:05")
+	default:
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package nbi
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/models"
+)
+
+// QueryIpPools
+func (h *HttpHandler) QueryIpPools(c echo.Context) error {
+	params := h.RequestParse(c)
+	data, err := h.GetManager().GetIpamManager().QueryIpPools(params)
+	common.Must(err)
+	return c.JSON(http.StatusOK, data)
+}
+
+// AddIpPool
+func (h *HttpHandler) AddIpPool(c echo.Context) error {
+	item := new(models.IpPool)
+	common.Must(c.Bind(item))
+	err := h.GetManager().GetIpamManager().AddIpPool(item)
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
+
+// UpdateIpPool
+func (h *HttpHandler) UpdateIpPool(c echo.Context) error {
+	item := new(models.IpPool)
+	common.Must(c.Bind(item))
+	err := h.GetManager().GetIpamManager().UpdateIpPool(item)
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
+
+// DeleteIpPool
+func (h *HttpHandler) DeleteIpPool(c echo.Context) error {
+	params := h.RequestParse(c)
+	name := params.GetMustString("name")
+	err := h.GetManager().GetIpamManager().DeleteIpPool(name)
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
+
+// QueryIpPoolUsages
+// Total, used, offered, active and conflict addresses of each pool
+func (h *HttpHandler) QueryIpPoolUsages(c echo.Context) error {
+	data, err := h.GetManager().GetIpamManager().GetIpPoolUsages()
+	common.Must(err)
+	return c.JSON(http.StatusOK, h.RestResult(data))
+}
+
+// QueryIpLeases
+func (h *HttpHandler) QueryIpLeases(c echo.Context) error {
+	params := h.RequestParse(c)
+	data, err := h.GetManager().GetIpamManager().QueryIpLeases(params)
+	common.Must(err)
+	return c.JSON(http.StatusOK, data)
+}
+
+// ReleaseIpLease
+func (h *HttpHandler) ReleaseIpLease(c echo.Context) error {
+	params := h.RequestParse(c)
+	pool := params.GetMustString("pool")
+	ipaddr := params.GetMustString("ipaddr")
+	err := h.GetManager().GetIpamManager().ReleaseLease(pool, ipaddr)
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
+
+// QueryIpLeaseLogs
+// Lease history, allocate, bind, release and conflict
+func (h *HttpHandler) QueryIpLeaseLogs(c echo.Context) error {
+	params := h.RequestParse(c)
+	params.GetSortMap()["timestamp"] = "desc"
+	data, err := h.GetManager().GetIpamManager().QueryIpLeaseLogs(params)
+	common.Must(err)
+	return c.JSON(http.StatusOK, data)
+}
@@ -0,0 +1,79 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package nbi
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/models"
+)
+
+// QueryProducts
+func (h *HttpHandler) QueryProducts(c echo.Context) error {
+	params := h.RequestParse(c)
+	data, err := h.GetManager().GetProductManager().QueryProducts(params)
+	common.Must(err)
+	return c.JSON(http.StatusOK, data)
+}
+
+// AddProduct
+func (h *HttpHandler) AddProduct(c echo.Context) error {
+	item := new(models.Product)
+	common.Must(c.Bind(item))
+	err := h.GetManager().GetProductManager().AddProduct(item)
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestResult(item))
+}
+
+// UpdateProduct
+func (h *HttpHandler) UpdateProduct(c echo.Context) error {
+	item := new(models.Product)
+	common.Must(c.Bind(item))
+	err := h.GetManager().GetProductManager().UpdateProduct(item)
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
+
+// DeleteProduct
+func (h *HttpHandler) DeleteProduct(c echo.Context) error {
+	params := h.RequestParse(c)
+	id := params.GetMustString("id")
+	err := h.GetManager().GetProductManager().DeleteProduct(id)
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
+
+// MigrateProduct
+// Move the subscribers of a product, or the listed subscribers, to another product
+func (h *HttpHandler) MigrateProduct(c echo.Context) error {
+	item := new(models.ProductMigration)
+	common.Must(c.Bind(item))
+	count, err := h.GetManager().GetProductManager().MigrateSubscribes(item)
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestResult(map[string]interface{}{"migrated": count}))
+}
@@ -22,6 +22,9 @@ import (
 	"github.com/labstack/echo/v4"
 
 	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/constant"
+	"github.com/ca17/teamsacs/models"
+	"github.com/ca17/teamsacs/radiusd"
 )
 
 func (h *HttpHandler) QueryRadiusAccounting(c echo.Context) error {
@@ -45,3 +48,97 @@ func (h *HttpHandler) QueryRadiusOnline(c echo.Context) error {
 	return c.JSON(http.StatusOK, data)
 }
 
+// QueryRadiusTimeline
+// The usage samples of a session in time order
+func (h *HttpHandler) QueryRadiusTimeline(c echo.Context) error {
+	params := h.RequestParse(c)
+	data, err := h.GetManager().GetRadiusManager().GetAcctTimeline(params.GetMustString("acct_session_id"))
+	common.Must(err)
+	return c.JSON(http.StatusOK, data)
+}
+
+func (h *HttpHandler) QueryRadiusCoalog(c echo.Context) error {
+	params := h.RequestParse(c)
+	data, err := h.GetManager().GetRadiusManager().QueryCoaLogs(params)
+	common.Must(err)
+	return c.JSON(http.StatusOK, data)
+}
+
+// DisconnectRadiusOnline
+// Send Disconnect-Request to the NAS of an online session
+func (h *HttpHandler) DisconnectRadiusOnline(c echo.Context) error {
+	params, err := h.ParseJsonBody(c)
+	common.Must(err)
+	sessionid := params.GetMustString("acct_session_id")
+	coaService := radiusd.NewCoaService(radiusd.NewRadiusService(h.GetManager()))
+	coalog, err := coaService.DisconnectOnline(sessionid, h.GetUsername(c), params.GetString("reason"))
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	if coalog.Result != radiusd.CoaResultAck {
+		return c.JSON(http.StatusOK, h.RestError("disconnect failure "+coalog.Result+" "+coalog.ErrorCause))
+	}
+	return c.JSON(http.StatusOK, h.RestResult(coalog))
+}
+
+// CoaRadiusOnline
+// Send CoA-Request with new up_rate/down_rate to the NAS of an online session
+func (h *HttpHandler) CoaRadiusOnline(c echo.Context) error {
+	params, err := h.ParseJsonBody(c)
+	common.Must(err)
+	sessionid := params.GetMustString("acct_session_id")
+	upRate := int(params.GetInt64("up_rate"))
+	downRate := int(params.GetInt64("down_rate"))
+	coaService := radiusd.NewCoaService(radiusd.NewRadiusService(h.GetManager()))
+	coalog, err := coaService.CoaOnline(sessionid, upRate, downRate, h.GetUsername(c), params.GetString("reason"))
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	if coalog.Result != radiusd.CoaResultAck {
+		return c.JSON(http.StatusOK, h.RestError("coa failure "+coalog.Result+" "+coalog.ErrorCause))
+	}
+	return c.JSON(http.StatusOK, h.RestResult(coalog))
+}
+
+func (h *HttpHandler) QueryRadiusPurgelog(c echo.Context) error {
+	params := h.RequestParse(c)
+	data, err := h.GetManager().QueryPurgeLogs(params)
+	common.Must(err)
+	return c.JSON(http.StatusOK, data)
+}
+
+// PurgeRadiusHistory
+// Run the history purge now, returns the purge reports
+func (h *HttpHandler) PurgeRadiusHistory(c echo.Context) error {
+	if h.GetUserLevel(c) != constant.NBIAdminLevel {
+		return c.NoContent(http.StatusForbidden)
+	}
+	return c.JSON(http.StatusOK, h.RestResult(h.GetManager().PurgeRadiusHistory()))
+}
+
+// QueryRadiusLockout
+// Authentication lockout counters, only locked ones unless all=1
+func (h *HttpHandler) QueryRadiusLockout(c echo.Context) error {
+	params := h.RequestParse(c)
+	lockedOnly := params.GetStringWithDefval("all", "0") != "1"
+	return c.JSON(http.StatusOK, h.RestResult(h.GetManager().GetLockoutManager().QueryLockouts(lockedOnly)))
+}
+
+// ClearRadiusLockout
+// Unlock a username or mac, all lockouts are cleared if type is empty
+func (h *HttpHandler) ClearRadiusLockout(c echo.Context) error {
+	params := h.RequestParse(c)
+	ltype := params.GetString("type")
+	value := params.GetString("value")
+	switch ltype {
+	case "":
+	case models.LockoutTypeUsername, models.LockoutTypeMac:
+		if value == "" {
+			return c.JSON(http.StatusOK, h.RestError("lockout value is empty"))
+		}
+	default:
+		return c.JSON(http.StatusOK, h.RestError("invalid lockout type "+ltype))
+	}
+	h.GetManager().GetLockoutManager().ClearLockout(ltype, value)
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
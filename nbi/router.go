@@ -49,16 +49,92 @@ func (h *HttpHandler) InitAllRouter(e *echo.Echo) {
 	e.Any("/nbi/radius/accounting/query", h.QueryRadiusAccounting)
 	e.Any("/nbi/radius/authlog/query", h.QueryRadiusAuthlog)
 	e.Any("/nbi/radius/online/query", h.QueryRadiusOnline)
+	e.Any("/nbi/radius/timeline/query", h.QueryRadiusTimeline)
+	e.POST("/nbi/radius/online/disconnect", h.DisconnectRadiusOnline)
+	e.POST("/nbi/radius/online/coa", h.CoaRadiusOnline)
+	e.Any("/nbi/radius/coalog/query", h.QueryRadiusCoalog)
+	e.Any("/nbi/radius/purgelog/query", h.QueryRadiusPurgelog)
+	e.POST("/nbi/radius/history/purge", h.PurgeRadiusHistory)
+	e.Any("/nbi/radius/lockout/query", h.QueryRadiusLockout)
+	e.POST("/nbi/radius/lockout/clear", h.ClearRadiusLockout)
+
+	// vendor attribute mappings
+	e.Any("/nbi/radius/attrmap/get", h.GetVendorMapping)
+	e.POST("/nbi/radius/attrmap/update", h.UpdateVendorMapping)
+	e.POST("/nbi/radius/attrmap/delete", h.DeleteVendorMapping)
+	e.Any("/nbi/radius/attrmap/preview", h.PreviewVendorMapping)
+	e.Any("/nbi/radius/dictionary/get", h.GetRadiusDictionary)
+	e.POST("/nbi/radius/dictionary/reload", h.ReloadRadiusDictionary)
+
+	// radius cdr files
+	e.Any("/nbi/radius/cdr/query", h.QueryCdrFiles)
+	e.GET("/nbi/radius/cdr/download", h.DownloadCdrFile)
+	e.POST("/nbi/radius/cdr/export", h.ExportCdr)
+
+	// radius realm proxy
+	e.Any("/nbi/radius/realm/query", h.QueryRealms)
+	e.POST("/nbi/radius/realm/add", h.AddRealm)
+	e.POST("/nbi/radius/realm/update", h.UpdateRealm)
+	e.POST("/nbi/radius/realm/delete", h.DeleteRealm)
+
+	// login schedule policy
+	e.Any("/nbi/schedule/query", h.QuerySchedulePolicies)
+	e.POST("/nbi/schedule/add", h.AddSchedulePolicy)
+	e.POST("/nbi/schedule/update", h.UpdateSchedulePolicy)
+	e.POST("/nbi/schedule/delete", h.DeleteSchedulePolicy)
+
+	// ip address pools
+	e.Any("/nbi/ippool/query", h.QueryIpPools)
+	e.POST("/nbi/ippool/add", h.AddIpPool)
+	e.POST("/nbi/ippool/update", h.UpdateIpPool)
+	e.POST("/nbi/ippool/delete", h.DeleteIpPool)
+	e.Any("/nbi/ippool/usage", h.QueryIpPoolUsages)
+	e.Any("/nbi/ippool/lease/query", h.QueryIpLeases)
+	e.POST("/nbi/ippool/lease/release", h.ReleaseIpLease)
+	e.Any("/nbi/ippool/leaselog/query", h.QueryIpLeaseLogs)
 
 	// config apis
 	e.POST("/nbi/config/radius/update", h.UpdateRadiusConfigs)
+	e.GET("/nbi/cache/stats", h.QueryCacheStats)
+	e.POST("/nbi/cache/clear", h.ClearCache)
 	e.POST("/nbi/config/update", h.UpdateConfig)
 	e.Any("/nbi/config/query", h.QueryConfig)
 	e.Any("/nbi/syslog/query", h.QuerySyslog)
 
 	e.Any("/nbi/cpe/query", h.QueryCpes)
 	e.Any("/nbi/vpe/query", h.QueryVpes)
+
+	// service plans
+	e.Any("/nbi/product/query", h.QueryProducts)
+	e.POST("/nbi/product/add", h.AddProduct)
+	e.POST("/nbi/product/update", h.UpdateProduct)
+	e.POST("/nbi/product/delete", h.DeleteProduct)
+	e.POST("/nbi/product/migrate", h.MigrateProduct)
+
+	// prepaid vouchers
+	e.Any("/nbi/voucher/batch/query", h.QueryVoucherBatches)
+	e.POST("/nbi/voucher/batch/add", h.AddVoucherBatch)
+	e.POST("/nbi/voucher/batch/delete", h.DeleteVoucherBatch)
+	e.Any("/nbi/voucher/batch/export", h.ExportVoucherBatch)
+	e.Any("/nbi/voucher/query", h.QueryVouchers)
+	e.POST("/nbi/voucher/redeem", h.RedeemVoucher)
+	e.POST("/nbi/voucher/revoke", h.RevokeVoucher)
+
+	// event webhooks
+	e.Any("/nbi/webhook/query", h.QueryWebhooks)
+	e.POST("/nbi/webhook/add", h.AddWebhook)
+	e.POST("/nbi/webhook/update", h.UpdateWebhook)
+	e.POST("/nbi/webhook/delete", h.DeleteWebhook)
+	e.POST("/nbi/webhook/ping", h.PingWebhook)
+	e.Any("/nbi/webhook/dead/query", h.QueryWebhookDeadLetters)
+	e.POST("/nbi/webhook/dead/retry", h.RetryWebhookDeadLetter)
+	e.POST("/nbi/webhook/dead/delete", h.DeleteWebhookDeadLetters)
+
 	e.Any("/nbi/subscribe/query", h.QuerySubscribes)
+	e.Any("/nbi/subscribe/quota/query", h.QuerySubscribeQuotas)
+	e.POST("/nbi/subscribe/quota/reset", h.ResetSubscribeQuota)
+	e.POST("/nbi/subscribe/mfa/enroll", h.EnrollSubscribeMfa)
+	e.POST("/nbi/subscribe/mfa/remove", h.RemoveSubscribeMfa)
 
 	// token
 	e.POST( "/nbi/token", h.RequestToken)
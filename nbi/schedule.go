@@ -0,0 +1,67 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package nbi
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/models"
+)
+
+// QuerySchedulePolicies
+func (h *HttpHandler) QuerySchedulePolicies(c echo.Context) error {
+	params := h.RequestParse(c)
+	data, err := h.GetManager().GetScheduleManager().QuerySchedulePolicies(params)
+	common.Must(err)
+	return c.JSON(http.StatusOK, data)
+}
+
+// AddSchedulePolicy
+func (h *HttpHandler) AddSchedulePolicy(c echo.Context) error {
+	item := new(models.SchedulePolicy)
+	common.Must(c.Bind(item))
+	err := h.GetManager().GetScheduleManager().AddSchedulePolicy(item)
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
+
+// UpdateSchedulePolicy
+func (h *HttpHandler) UpdateSchedulePolicy(c echo.Context) error {
+	item := new(models.SchedulePolicy)
+	common.Must(c.Bind(item))
+	err := h.GetManager().GetScheduleManager().UpdateSchedulePolicy(item)
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
+
+// DeleteSchedulePolicy
+func (h *HttpHandler) DeleteSchedulePolicy(c echo.Context) error {
+	params := h.RequestParse(c)
+	name := params.GetMustString("name")
+	err := h.GetManager().GetScheduleManager().DeleteSchedulePolicy(name)
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
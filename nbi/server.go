@@ -22,6 +22,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -30,6 +31,7 @@ import (
 
 	"github.com/ca17/teamsacs/common"
 	"github.com/ca17/teamsacs/common/log"
+	"github.com/ca17/teamsacs/common/metrics"
 	"github.com/ca17/teamsacs/common/tpl"
 	"github.com/ca17/teamsacs/models"
 )
@@ -38,6 +40,7 @@ import (
 func ListenNBIServer(manager *models.ModelManager) error {
 	e := echo.New()
 	e.Pre(middleware.RemoveTrailingSlash())
+	e.Use(MetricsMiddleware())
 	e.Use(middleware.GzipWithConfig(middleware.GzipConfig{
 		Level: 5,
 	}))
@@ -72,6 +75,11 @@ func ListenNBIServer(manager *models.ModelManager) error {
 		Config:  manager.Config,
 	})
 	httphandler.InitAllRouter(e)
+	if manager.Config.NBI.MetricsAddr != "" {
+		go ListenMetricsServer(manager.Config.NBI.MetricsAddr)
+	} else {
+		e.GET("/metrics", echo.WrapHandler(metrics.Handler()))
+	}
 
 	manager.TplRender = tpl.NewCommonTemplate([]string{"/resources/templates"}, manager.Dev, manager.GetTemplateFuncMap())
 	e.Renderer = manager.TplRender
@@ -89,6 +97,41 @@ func ListenNBIServer(manager *models.ModelManager) error {
 	return err
 }
 
+// ListenMetricsServer
+// /metrics without auth on its own address, see NBIConfig.MetricsAddr
+func ListenMetricsServer(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	log.Infof("start metrics server %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Errorf("metrics server error, %s", err.Error())
+	}
+}
+
+// MetricsMiddleware
+// Observe the request latency by the route template, errors not handled yet are counted by their status
+func MetricsMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			status := c.Response().Status
+			if err != nil {
+				switch he := err.(type) {
+				case *echo.HTTPError:
+					status = he.Code
+				case *HTTPError:
+					status = he.Code
+				default:
+					status = http.StatusInternalServerError
+				}
+			}
+			metrics.ObserveNbiRequest(start, c.Request().Method, c.Path(), status)
+			return err
+		}
+	}
+}
+
 func ServerRecover(debug bool) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -41,3 +41,42 @@ func (h *HttpHandler) QuerySubscribes(c echo.Context) error {
 }
 
 
+
+// QuerySubscribeQuotas
+// Quota usage of the current cycle
+func (h *HttpHandler) QuerySubscribeQuotas(c echo.Context) error {
+	params := h.RequestParse(c)
+	data, err := h.GetManager().GetQuotaManager().QueryQuotaUsages(params)
+	common.Must(err)
+	return c.JSON(http.StatusOK, data)
+}
+
+// ResetSubscribeQuota
+func (h *HttpHandler) ResetSubscribeQuota(c echo.Context) error {
+	params := h.RequestParse(c)
+	username := params.GetMustString("username")
+	common.Must(h.GetManager().GetQuotaManager().ResetQuotaUsage(username))
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
+
+// EnrollSubscribeMfa
+// Create a TOTP secret, the provisioning uri is shown as QR code to the user
+func (h *HttpHandler) EnrollSubscribeMfa(c echo.Context) error {
+	params := h.RequestParse(c)
+	username := params.GetMustString("username")
+	secret, uri, err := h.GetManager().GetSubscribeManager().EnrollSubscribeMfa(username)
+	common.Must(err)
+	return c.JSON(http.StatusOK, h.RestResult(map[string]interface{}{
+		"username":         username,
+		"secret":           secret,
+		"provisioning_uri": uri,
+	}))
+}
+
+// RemoveSubscribeMfa
+func (h *HttpHandler) RemoveSubscribeMfa(c echo.Context) error {
+	params := h.RequestParse(c)
+	username := params.GetMustString("username")
+	common.Must(h.GetManager().GetSubscribeManager().RemoveSubscribeMfa(username))
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
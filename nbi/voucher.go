@@ -0,0 +1,122 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package nbi
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/constant"
+	"github.com/ca17/teamsacs/models"
+)
+
+// QueryVoucherBatches
+func (h *HttpHandler) QueryVoucherBatches(c echo.Context) error {
+	params := h.RequestParse(c)
+	data, err := h.GetManager().GetVoucherManager().QueryVoucherBatches(params)
+	common.Must(err)
+	return c.JSON(http.StatusOK, data)
+}
+
+// AddVoucherBatch
+// Generate the vouchers of a product
+func (h *HttpHandler) AddVoucherBatch(c echo.Context) error {
+	item := new(models.VoucherBatch)
+	common.Must(c.Bind(item))
+	err := h.GetManager().GetVoucherManager().AddVoucherBatch(item)
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestResult(item))
+}
+
+// DeleteVoucherBatch
+func (h *HttpHandler) DeleteVoucherBatch(c echo.Context) error {
+	params := h.RequestParse(c)
+	id := params.GetMustString("id")
+	err := h.GetManager().GetVoucherManager().DeleteVoucherBatch(id)
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
+
+// ExportVoucherBatch
+// The codes and pins of the batch for printing the cards
+func (h *HttpHandler) ExportVoucherBatch(c echo.Context) error {
+	if h.GetUserLevel(c) != constant.NBIAdminLevel {
+		return c.NoContent(http.StatusForbidden)
+	}
+	params := h.RequestParse(c)
+	id := params.GetMustString("id")
+	vm := h.GetManager().GetVoucherManager()
+	batch, err := vm.GetVoucherBatch(id)
+	common.Must(err)
+	vouchers, err := vm.GetBatchVouchers(id)
+	common.Must(err)
+	rows := make([]map[string]interface{}, 0, len(vouchers))
+	for _, v := range vouchers {
+		pin, err := vm.GetVoucherPin(&v)
+		common.Must(err)
+		rows = append(rows, map[string]interface{}{
+			"code":        v.Code,
+			"pin":         pin,
+			"status":      v.Status,
+			"valid_days":  v.ValidDays,
+			"expire_time": v.ExpireTime,
+			"username":    v.Username,
+			"end_time":    v.EndTime,
+		})
+	}
+	names := []string{"code", "pin", "status", "valid_days", "expire_time", "username", "end_time"}
+	return h.ExportExcel(c, "voucher-"+batch.Name, names, rows)
+}
+
+// QueryVouchers
+func (h *HttpHandler) QueryVouchers(c echo.Context) error {
+	params := h.RequestParse(c)
+	data, err := h.GetManager().GetVoucherManager().QueryVouchers(params)
+	common.Must(err)
+	return c.JSON(http.StatusOK, data)
+}
+
+// RedeemVoucher
+// Create the subscriber of the voucher, or extend the subscriber username
+func (h *HttpHandler) RedeemVoucher(c echo.Context) error {
+	params := h.RequestParse(c)
+	code := params.GetMustString("code")
+	pin := params.GetMustString("pin")
+	username := params.GetString("username")
+	voucher, err := h.GetManager().GetVoucherManager().RedeemVoucher(code, pin, username)
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestResult(voucher))
+}
+
+// RevokeVoucher
+// Revoke a voucher by code or all vouchers of a batch by batch_id
+func (h *HttpHandler) RevokeVoucher(c echo.Context) error {
+	params := h.RequestParse(c)
+	count, err := h.GetManager().GetVoucherManager().RevokeVouchers(params.GetString("code"), params.GetString("batch_id"))
+	if err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestResult(map[string]interface{}{"revoked": count}))
+}
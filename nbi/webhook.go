@@ -0,0 +1,123 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package nbi
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/constant"
+	"github.com/ca17/teamsacs/models"
+)
+
+// QueryWebhooks
+// The secrets are not returned
+func (h *HttpHandler) QueryWebhooks(c echo.Context) error {
+	data, err := h.GetManager().GetWebhookManager().QueryWebhooks()
+	common.Must(err)
+	return c.JSON(http.StatusOK, h.RestResult(data))
+}
+
+// AddWebhook
+// The result includes the secret, generated if not set, it is not returned again
+func (h *HttpHandler) AddWebhook(c echo.Context) error {
+	if h.GetUserLevel(c) != constant.NBIAdminLevel {
+		return c.NoContent(http.StatusForbidden)
+	}
+	item := new(models.Webhook)
+	common.Must(c.Bind(item))
+	if err := h.GetManager().GetWebhookManager().AddWebhook(item); err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestResult(item))
+}
+
+// UpdateWebhook
+func (h *HttpHandler) UpdateWebhook(c echo.Context) error {
+	if h.GetUserLevel(c) != constant.NBIAdminLevel {
+		return c.NoContent(http.StatusForbidden)
+	}
+	item := new(models.Webhook)
+	common.Must(c.Bind(item))
+	if err := h.GetManager().GetWebhookManager().UpdateWebhook(item); err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
+
+// DeleteWebhook
+func (h *HttpHandler) DeleteWebhook(c echo.Context) error {
+	if h.GetUserLevel(c) != constant.NBIAdminLevel {
+		return c.NoContent(http.StatusForbidden)
+	}
+	params := h.RequestParse(c)
+	if err := h.GetManager().GetWebhookManager().DeleteWebhook(params.GetMustString("id")); err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
+
+// PingWebhook
+// Send a webhook.ping event to check the receiver and the signature
+func (h *HttpHandler) PingWebhook(c echo.Context) error {
+	if h.GetUserLevel(c) != constant.NBIAdminLevel {
+		return c.NoContent(http.StatusForbidden)
+	}
+	params := h.RequestParse(c)
+	if err := h.GetManager().GetWebhookManager().PingWebhook(params.GetMustString("id")); err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
+
+// QueryWebhookDeadLetters
+func (h *HttpHandler) QueryWebhookDeadLetters(c echo.Context) error {
+	params := h.RequestParse(c)
+	data, err := h.GetManager().GetWebhookManager().QueryDeadLetters(params)
+	common.Must(err)
+	return c.JSON(http.StatusOK, data)
+}
+
+// RetryWebhookDeadLetter
+// Deliver the event again, the dead letter is removed if delivered
+func (h *HttpHandler) RetryWebhookDeadLetter(c echo.Context) error {
+	if h.GetUserLevel(c) != constant.NBIAdminLevel {
+		return c.NoContent(http.StatusForbidden)
+	}
+	params := h.RequestParse(c)
+	if err := h.GetManager().GetWebhookManager().RetryDeadLetter(params.GetMustString("id")); err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
+
+// DeleteWebhookDeadLetters
+// ids is separated by comma
+func (h *HttpHandler) DeleteWebhookDeadLetters(c echo.Context) error {
+	if h.GetUserLevel(c) != constant.NBIAdminLevel {
+		return c.NoContent(http.StatusForbidden)
+	}
+	params := h.RequestParse(c)
+	ids := strings.Split(params.GetMustString("ids"), ",")
+	if err := h.GetManager().GetWebhookManager().DeleteDeadLetters(ids); err != nil {
+		return c.JSON(http.StatusOK, h.RestError(err.Error()))
+	}
+	return c.JSON(http.StatusOK, h.RestSucc("Success"))
+}
@@ -1,8 +1,6 @@
 package radiusd
 
 import (
-	"context"
-	"fmt"
 	"time"
 
 	"layeh.com/radius"
@@ -11,17 +9,20 @@ import (
 
 	"github.com/ca17/teamsacs/constant"
 	"github.com/ca17/teamsacs/models"
-	"github.com/ca17/teamsacs/radiusd/debug"
 	"github.com/ca17/teamsacs/radiusd/radlog"
 	"github.com/ca17/teamsacs/radiusd/radparser"
 )
 
-func (s *AcctService) processAcctStart(r *radius.Request, vr *radparser.VendorRequest,  username string, vpe *models.Vpe, nasrip string) {
+func (s *AcctService) processAcctStart(r *radius.Request, vr *radparser.VendorRequest,  user *models.Subscribe, vpe *models.Vpe, nasrip string) {
+	var username = user.GetUsername()
 	online := GetRadiusOnlineFromRequest(r, vr, vpe, nasrip)
+	online.InterimInterval = user.GetInterimInterval()
 	err := s.Manager.GetRadiusManager().AddRadiusOnline(online)
 	if err!= nil {
 		radlog.Errorf("AddRadiusOnline user:%s error %s", username, err.Error())
 	}
+	s.Manager.GetIpamManager().BindLease(&online)
+	s.Manager.PublishEvent(models.EventAcctStart, online.EventData())
 }
 
 
@@ -29,36 +30,51 @@ func (s *AcctService) processAcctUpdateBefore(r *radius.Request, vr *radparser.V
 	// 用户状态变更为停用后触发下线
 	var username = user.GetStringValue("username",constant.NA)
 	if user.GetStringValue("status", constant.DISABLED) == constant.DISABLED {
-		s.processAcctDisconnect(r, vpe, username, nasrip)
+		s.processAcctDisconnect(r, vpe, username, nasrip, "user disabled")
 	}
 
 	// 用户过期后触发下线
 	if user.GetExpireTime().Before(time.Now()) {
-		s.processAcctDisconnect(r, vpe, username, nasrip)
+		s.processAcctDisconnect(r, vpe, username, nasrip, "user expire")
 	}
 
-	s.processAcctUpdate(r, vr, username, vpe, nasrip)
+	s.processAcctUpdate(r, vr, user, vpe, nasrip)
 }
 
 
-func (s *AcctService) processAcctUpdate(r *radius.Request, vr *radparser.VendorRequest,  username string, vpe *models.Vpe, nasrip string) {
+func (s *AcctService) processAcctUpdate(r *radius.Request, vr *radparser.VendorRequest,  user *models.Subscribe, vpe *models.Vpe, nasrip string) {
+	var username = user.GetUsername()
 	online := GetRadiusOnlineFromRequest(r, vr, vpe, nasrip)
+	online.InterimInterval = user.GetInterimInterval()
 	// 更新在线信息
 	err := s.Manager.GetRadiusManager().UpdateRadiusOnlineData(online)
 	if err != nil {
 		radlog.Errorf("UpdateRadiusOnlineData user:%s error, %s", username, err.Error())
 	}
+	if err = s.Manager.GetRadiusManager().UpdateRadiusOnlineIpv6(online); err != nil {
+		radlog.Errorf("UpdateRadiusOnlineIpv6 user:%s error, %s", username, err.Error())
+	}
+	if err = s.Manager.GetRadiusManager().UpdateRadiusOnlineVendorAttrs(online); err != nil {
+		radlog.Errorf("UpdateRadiusOnlineVendorAttrs user:%s error, %s", username, err.Error())
+	}
+	s.Manager.GetIpamManager().BindLease(&online)
 
 }
 
 
 func (s *AcctService) processAcctStop(r *radius.Request, vr *radparser.VendorRequest,  username string, vpe *models.Vpe, nasrip string) {
 	online := GetRadiusOnlineFromRequest(r, vr, vpe, nasrip)
-	if err := s.Manager.GetRadiusManager().AddRadiusAccounting(online); err!=nil {
-		radlog.Errorf("AddRadiusAccounting user:%s error %s ", username, err.Error())
+	if cause, err := rfc2866.AcctTerminateCause_Lookup(r.Packet); err == nil {
+		online.AcctTerminateCause = cause.String()
+	}
+	removed, err := s.Manager.GetRadiusManager().StopRadiusOnline(&online)
+	if err != nil {
+		radlog.Errorf("StopRadiusOnline user:%s error %s ", username, err.Error())
 	}
-	if err := s.Manager.GetRadiusManager().DeleteRadiusOnline(online.AcctSessionId); err != nil {
-		radlog.Errorf("DeleteRadiusOnline user:%s error %s ", username, err.Error())
+	s.Manager.GetIpamManager().ReleaseSessionLease(&online, "accounting stop")
+	// a retransmitted stop or the stop of a session closed as stale is published once
+	if removed {
+		s.Manager.PublishEvent(models.EventAcctStop, online.EventData())
 	}
 }
 
@@ -71,6 +87,7 @@ func (s *AcctService) processAcctNasOn(r *radius.Request) {
 	if err != nil {
 		radlog.Errorf("BatchClearRadiusOnlineDataByNas error, %s", err.Error())
 	}
+	s.Manager.GetIpamManager().ReleaseNasLeases(rfc2865.NASIPAddress_Get(r.Packet).String(), "accounting on")
 }
 
 func (s *AcctService) processAcctNasOff(r *radius.Request) {
@@ -81,23 +98,15 @@ func (s *AcctService) processAcctNasOff(r *radius.Request) {
 	if err != nil {
 		radlog.Errorf("BatchClearRadiusOnlineDataByNas error, %s", err.Error())
 	}
+	s.Manager.GetIpamManager().ReleaseNasLeases(rfc2865.NASIPAddress_Get(r.Packet).String(), "accounting off")
 }
 
 
-func (s *AcctService) processAcctDisconnect(r *radius.Request, vpe *models.Vpe, username, nasrip string) {
-	packet := radius.New(radius.CodeDisconnectRequest, []byte(vpe.GetStringValue("secret",constant.NA)))
+func (s *AcctService) processAcctDisconnect(r *radius.Request, vpe *models.Vpe, username, nasrip, reason string) {
 	sessionid := rfc2866.AcctSessionID_GetString(r.Packet)
 	if sessionid == "" {
 		radlog.Errorf("radius disconnect user:%s, but sessionid is empty", username)
 		return
 	}
-	_ = rfc2865.UserName_SetString(packet, username)
-	_ = rfc2866.AcctSessionID_Set(packet, []byte(sessionid))
-	var coaPort = vpe.GetIntValue("coa_port", 3799)
-	radlog.Infof("disconnect user:%s => (%s:%d): %s", username, nasrip, coaPort, debug.FormatPacket(packet))
-	response, err := radius.Exchange(context.Background(), packet, fmt.Sprintf("%s:%d", nasrip, coaPort))
-	if err != nil {
-		radlog.Errorf("radius disconnect user:%s failure", username)
-	}
-	radlog.Info("radius disconnect resp from (%s:%s): %s ", nasrip, coaPort, debug.FormatPacket(response))
+	NewCoaService(s.RadiusService).Disconnect(username, sessionid, nasrip, vpe, CoaOperatorSystem, reason)
 }
@@ -0,0 +1,166 @@
+package radiusd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"layeh.com/radius"
+
+	"github.com/ca17/teamsacs/constant"
+	"github.com/ca17/teamsacs/radiusd/authorization"
+	"github.com/ca17/teamsacs/radiusd/dictionary"
+	"github.com/ca17/teamsacs/radiusd/radlog"
+)
+
+const (
+	VendorMappingConfig  = "config"
+	VendorMappingFile    = "file"
+	VendorMappingBuiltin = "builtin"
+	VendorMappingNone    = "none"
+
+	vendorMappingFileTTL = time.Second * 10
+)
+
+type vendorMappingItem struct {
+	source  string
+	dict    *dictionary.Dictionary
+	mapping *authorization.VendorMapping
+}
+
+type vendorMappingFileItem struct {
+	text   string
+	expire time.Time
+}
+
+var (
+	// vendor code => the mapping parsed from the current source
+	vendorMappings sync.Map
+	// file path => the file content, missing files are cached as empty
+	vendorMappingFiles sync.Map
+)
+
+// GetVendorMappingFile
+// The yaml mapping file of the vendor, <workdir>/radius/attrmap/<vendor code>.yaml
+func (s *RadiusService) GetVendorMappingFile(vendorCode string) string {
+	return path.Join(s.GetAppConfig().GetRadiusDir(), "attrmap", vendorCode+".yaml")
+}
+
+// GetVendorMappingSource
+// The mapping yaml of the vendor and where it comes from, the config collection (type attrmap, name is the
+// vendor code) overrides the mapping file, the mapping file overrides the builtin mapping.
+func (s *RadiusService) GetVendorMappingSource(vendorCode string) (string, string) {
+	if vendorCode == "" {
+		return "", VendorMappingNone
+	}
+	if text := s.Manager.GetConfigManager().GetCachedConfigValue(constant.ConfigTypeAttrmap, vendorCode); text != "" {
+		return text, VendorMappingConfig
+	}
+	if text := s.readVendorMappingFile(s.GetVendorMappingFile(vendorCode)); text != "" {
+		return text, VendorMappingFile
+	}
+	if text := authorization.BuiltinVendorMapping(vendorCode); text != "" {
+		return text, VendorMappingBuiltin
+	}
+	return "", VendorMappingNone
+}
+
+func (s *RadiusService) readVendorMappingFile(filename string) string {
+	if v, ok := vendorMappingFiles.Load(filename); ok && v.(*vendorMappingFileItem).expire.After(time.Now()) {
+		return v.(*vendorMappingFileItem).text
+	}
+	var text string
+	data, err := ioutil.ReadFile(filename)
+	if err == nil {
+		text = string(data)
+	} else if !os.IsNotExist(err) {
+		radlog.Errorf("read vendor mapping %s error, %s", filename, err.Error())
+	}
+	vendorMappingFiles.Store(filename, &vendorMappingFileItem{text: text, expire: time.Now().Add(vendorMappingFileTTL)})
+	return text
+}
+
+// GetVendorMapping
+// The attribute mapping of the vendor, nil if the vendor has no mapping,
+// the mapping is parsed again only when the source is changed.
+func (s *RadiusService) GetVendorMapping(vendorCode string) (*authorization.VendorMapping, string, error) {
+	text, origin := s.GetVendorMappingSource(vendorCode)
+	if text == "" {
+		return nil, origin, nil
+	}
+	dict := s.GetDictionary()
+	if v, ok := vendorMappings.Load(vendorCode); ok {
+		item := v.(*vendorMappingItem)
+		if item.source == text && item.dict == dict {
+			return item.mapping, origin, nil
+		}
+	}
+	mapping, err := authorization.ParseVendorMapping(text, dict)
+	if err != nil {
+		return nil, origin, fmt.Errorf("vendor %s %s mapping error, %s", vendorCode, origin, err.Error())
+	}
+	vendorMappings.Store(vendorCode, &vendorMappingItem{source: text, dict: dict, mapping: mapping})
+	return mapping, origin, nil
+}
+
+// UpdateVendorAuthorization
+// Setup the accept attributes of profile by the vendor mapping, an invalid mapping
+// or attribute is logged and the other attributes are still sent.
+func (s *RadiusService) UpdateVendorAuthorization(profile authorization.Profile, vendorCode string, accept *radius.Packet) {
+	mapping, _, err := s.GetVendorMapping(vendorCode)
+	if err != nil {
+		radlog.Error(err)
+	}
+	if err = authorization.UpdateAuthorization(profile, mapping, accept); err != nil {
+		radlog.Errorf("vendor %s authorization error, %s", vendorCode, err.Error())
+	}
+}
+
+// AcceptPreview
+// The Access-Accept attributes a user would receive from a VPE
+type AcceptPreview struct {
+	Username   string                      `json:"username"`
+	VendorCode string                      `json:"vendor_code"`
+	Mapping    string                      `json:"mapping"`
+	Attributes []dictionary.AttributeValue `json:"attributes"`
+	Errors     []string                    `json:"errors,omitempty"`
+}
+
+// PreviewAccept
+// Render the Access-Accept of username on the VPE of ip or identifier, the password is not checked
+// and no address is leased, the Framed-IP-Address of a managed pool is assigned at login.
+func (s *RadiusService) PreviewAccept(username, vpeKey string) (*AcceptPreview, error) {
+	vpe, err := s.GetNas(vpeKey, vpeKey)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.GetUserForAcct(username)
+	if err != nil {
+		return nil, fmt.Errorf("user:%s not exists", username)
+	}
+	profile, remainSeconds, err := s.GetQuotaProfile(user)
+	if err != nil {
+		return nil, err
+	}
+	preview := &AcceptPreview{Username: username, VendorCode: vpe.GetVendorCode()}
+	mapping, origin, err := s.GetVendorMapping(preview.VendorCode)
+	preview.Mapping = origin
+	if err != nil {
+		preview.Errors = append(preview.Errors, err.Error())
+	}
+	accept := radius.New(radius.CodeAccessAccept, []byte(vpe.GetSecret()))
+	if err = authorization.UpdateAuthorization(profile, mapping, accept); err != nil {
+		preview.Errors = append(preview.Errors, err.Error())
+	}
+	authorization.LimitSessionTimeout(accept, remainSeconds)
+	if scheduleSeconds, err := s.CheckSchedule(user); err != nil {
+		preview.Errors = append(preview.Errors, err.Error())
+	} else {
+		authorization.LimitSessionTimeout(accept, scheduleSeconds)
+	}
+	preview.Attributes = s.GetDictionary().FormatAttributes(accept)
+	return preview, nil
+}
@@ -60,10 +60,10 @@ func (s *AuthService) UpdateBind(user *models.Subscribe, vendorReq *radparser.Ve
 	}
 	reqvid1 := int(vendorReq.Vlanid1)
 	reqvid2 := int(vendorReq.Vlanid2)
-	if vlanid1 != reqvid1 {
-		s.UpdateUserVlanid2(username, reqvid1)
+	if reqvid1 != 0 && vlanid1 != reqvid1 {
+		s.UpdateUserVlanid1(username, reqvid1)
 	}
-	if vlanid2 != reqvid2 {
+	if reqvid2 != 0 && vlanid2 != reqvid2 {
 		s.UpdateUserVlanid2(username, reqvid2)
 	}
 }
@@ -0,0 +1,391 @@
+package radiusd
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+
+	"github.com/ca17/teamsacs/constant"
+	"github.com/ca17/teamsacs/models"
+	"github.com/ca17/teamsacs/radiusd/eap"
+	"github.com/ca17/teamsacs/radiusd/radlog"
+	"github.com/ca17/teamsacs/radiusd/vendors/microsoft"
+)
+
+const (
+	EapCertFile = "teamsacs-eap.tls.crt"
+	EapKeyFile  = "teamsacs-eap.tls.key"
+	EapCaFile   = "teamsacs-eap.ca.crt"
+
+	EapMethodMD5  = "md5"
+	EapMethodPEAP = "peap"
+	EapMethodTLS  = "tls"
+
+	// PEAPv0 inner method stages
+	peapStageHandshake     = 0
+	peapStageIdentity      = 1
+	peapStageMsChap        = 2
+	peapStageMsChapSuccess = 3
+	peapStageResult        = 4
+
+	peapVersion   = 0
+	eapServerName = "teamsacs"
+)
+
+var eapMethods = map[string]uint8{
+	EapMethodMD5:  eap.TypeMD5,
+	EapMethodPEAP: eap.TypePEAP,
+	EapMethodTLS:  eap.TypeTLS,
+}
+
+// getEapMethod
+// The method offered after EAP-Response/Identity, the supplicant may Nak it.
+func (s *AuthService) getEapMethod() uint8 {
+	method, ok := eapMethods[s.GetStringConfig(constant.RadiusEapMethod, EapMethodPEAP)]
+	if !ok {
+		return eap.TypePEAP
+	}
+	return method
+}
+
+// GetEapTlsConfig
+// Server certificate for PEAP and EAP-TLS, EAP-TLS also requires the client CA.
+// TLS 1.3 is disabled because the key derivation of RFC 5216 is TLS 1.2 only.
+func (s *AuthService) GetEapTlsConfig(method uint8) (*tls.Config, error) {
+	s.eapTlsOnce.Do(func() {
+		cfg := s.GetAppConfig()
+		cert, err := tls.LoadX509KeyPair(path.Join(cfg.GetPrivateDir(), EapCertFile), path.Join(cfg.GetPrivateDir(), EapKeyFile))
+		if err != nil {
+			s.eapTlsErr = err
+			return
+		}
+		s.eapTlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS10,
+			MaxVersion:   tls.VersionTLS12,
+		}
+		capem, err := ioutil.ReadFile(path.Join(cfg.GetPrivateDir(), EapCaFile))
+		if err != nil {
+			radlog.Warningf("eap client ca %s not loaded, EAP-TLS is unavailable", EapCaFile)
+			return
+		}
+		pool := x509.NewCertPool()
+		if pool.AppendCertsFromPEM(capem) {
+			s.eapTlsConfig.ClientCAs = pool
+		}
+	})
+	if s.eapTlsErr != nil {
+		return nil, s.eapTlsErr
+	}
+	config := s.eapTlsConfig.Clone()
+	if method == eap.TypeTLS {
+		if config.ClientCAs == nil {
+			return nil, fmt.Errorf("eap tls client ca %s is not available", EapCaFile)
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// ServeEAP
+// Process one EAP round trip. A non nil challenge must be sent to continue the
+// conversation, nil challenge and nil error means the user is authenticated and
+// the accept has been filled with EAP-Success and the MPPE keys.
+func (s *AuthService) ServeEAP(r *radius.Request, user *models.Subscribe, localpwd string, accept *radius.Packet) (*radius.Packet, error) {
+	if err := eap.VerifyMessageAuthenticator(r.Packet); err != nil {
+		return nil, err
+	}
+	msg, err := eap.Parse(eap.GetEapMessage(r.Packet))
+	if err != nil {
+		return nil, err
+	}
+	if msg.Code != eap.CodeResponse {
+		return nil, fmt.Errorf("eap code %d is not response", msg.Code)
+	}
+
+	username := user.GetUsername()
+	if msg.Type == eap.TypeIdentity {
+		state := s.EapStates.NewState(username, s.getEapMethod())
+		req, err := s.eapStartMethod(state)
+		if err != nil {
+			return nil, err
+		}
+		return s.eapChallenge(r, state, req), nil
+	}
+
+	state := s.EapStates.Get(rfc2865.State_GetString(r.Packet))
+	if state == nil {
+		return nil, fmt.Errorf("user:%s eap state not found or expired", username)
+	}
+	if state.Username == username && state.IsRetransmit(msg.Identifier, eap.GetEapMessage(r.Packet)) {
+		radlog.Infof("user:%s eap response %d repeated, resend the last challenge", username, msg.Identifier)
+		return s.eapChallenge(r, state, state.Request), nil
+	}
+	if state.Username != username || msg.Identifier != state.Identifier {
+		s.EapStates.Remove(state.Key)
+		return nil, fmt.Errorf("user:%s eap state not match", username)
+	}
+
+	var req *eap.Packet
+	switch {
+	case msg.Type == eap.TypeNak:
+		req, err = s.eapNak(state, msg)
+	case msg.Type != state.Method:
+		err = fmt.Errorf("eap type %d not match method %d", msg.Type, state.Method)
+	case state.Method == eap.TypeMD5:
+		err = s.eapMd5(state, msg, localpwd)
+	case state.Method == eap.TypePEAP:
+		req, err = s.eapPeap(state, msg, localpwd)
+	case state.Method == eap.TypeTLS:
+		req, err = s.eapTls(state, msg)
+	}
+	if err != nil {
+		s.EapStates.Remove(state.Key)
+		return nil, fmt.Errorf("user:%s eap auth failure, %s", username, err.Error())
+	}
+	if req != nil {
+		return s.eapChallenge(r, state, req), nil
+	}
+
+	// authenticated
+	if state.Tls != nil {
+		recvKey, sendKey, err := state.Tls.MppeKeys()
+		if err != nil {
+			radlog.Errorf("user:%s eap mppe keys error, %s", username, err.Error())
+		} else {
+			_ = microsoft.MSMPPERecvKey_Add(accept, recvKey)
+			_ = microsoft.MSMPPESendKey_Add(accept, sendKey)
+		}
+	}
+	eap.SetEapMessage(accept, eap.NewSuccess(msg.Identifier))
+	s.EapStates.Remove(state.Key)
+	radlog.Infof("user:%s eap access accept", username)
+	return nil, nil
+}
+
+// eapChallenge
+// The request is kept with the response it answers to be resent on a retransmit.
+func (s *AuthService) eapChallenge(r *radius.Request, state *eap.State, req *eap.Packet) *radius.Packet {
+	challenge := r.Response(radius.CodeAccessChallenge)
+	eap.SetEapMessage(challenge, req)
+	state.Request = req
+	state.Response = eap.GetEapMessage(r.Packet)
+	_ = rfc2865.State_SetString(challenge, state.Key)
+	s.EapStates.Put(state)
+	return challenge
+}
+
+func (s *AuthService) eapStartMethod(state *eap.State) (*eap.Packet, error) {
+	switch state.Method {
+	case eap.TypeMD5:
+		state.Challenge = make([]byte, 16)
+		_, _ = rand.Read(state.Challenge)
+		data := append([]byte{byte(len(state.Challenge))}, state.Challenge...)
+		return eap.NewRequest(state.NextIdentifier(), eap.TypeMD5, data), nil
+	case eap.TypePEAP, eap.TypeTLS:
+		config, err := s.GetEapTlsConfig(state.Method)
+		if err != nil {
+			return nil, err
+		}
+		state.Tls = eap.NewTlsSession(config)
+		var flags uint8 = eap.TlsFlagStart
+		if state.Method == eap.TypePEAP {
+			flags |= peapVersion
+		}
+		return eap.NewRequest(state.NextIdentifier(), state.Method, []byte{flags}), nil
+	}
+	return nil, fmt.Errorf("eap method %d not supported", state.Method)
+}
+
+// eapNak
+// The supplicant refused the offered method and proposes the ones it wants.
+func (s *AuthService) eapNak(state *eap.State, msg *eap.Packet) (*eap.Packet, error) {
+	if state.Stage != 0 {
+		return nil, errors.New("eap nak after method started")
+	}
+	if state.Tls != nil {
+		state.Tls.Close()
+		state.Tls = nil
+	}
+	for _, desired := range msg.Data {
+		for _, method := range eapMethods {
+			if desired == method {
+				state.Method = method
+				state.Challenge = nil
+				return s.eapStartMethod(state)
+			}
+		}
+	}
+	return nil, errors.New("no acceptable eap method")
+}
+
+// eapMd5
+// RFC 3748, response value is MD5(identifier + password + challenge).
+func (s *AuthService) eapMd5(state *eap.State, msg *eap.Packet, localpwd string) error {
+	if len(msg.Data) < 17 || msg.Data[0] != 16 {
+		return errors.New("eap md5 response invalid")
+	}
+	hash := md5.New()
+	hash.Write([]byte{msg.Identifier})
+	hash.Write([]byte(localpwd))
+	hash.Write(state.Challenge)
+	if !bytes.Equal(hash.Sum(nil), msg.Data[1:17]) {
+		return errors.New("eap md5 password error")
+	}
+	return nil
+}
+
+// eapTlsProcess
+// Fragmentation and handshake shared by PEAP and EAP-TLS. A non nil reply is the
+// next EAP-TLS payload to send, otherwise the handshake has finished and appdata
+// holds the decrypted application data, empty when the peer just acknowledged.
+func (s *AuthService) eapTlsProcess(state *eap.State, data []byte, version uint8) (reply []byte, appdata []byte, err error) {
+	session := state.Tls
+	if session.HasPending() {
+		if !eap.IsAck(data) {
+			return nil, nil, errors.New("eap tls fragment ack expected")
+		}
+		return session.NextFragment(version), nil, nil
+	}
+	complete, err := session.Receive(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !complete {
+		// ack the fragment
+		return []byte{version}, nil, nil
+	}
+	input := session.TakeInput()
+	if len(input) == 0 {
+		if !session.Handshake {
+			return nil, nil, errors.New("eap tls unexpected ack")
+		}
+		return nil, nil, nil
+	}
+	output, appdata, err := session.Exchange(input)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(output) > 0 {
+		session.Send(output)
+		return session.NextFragment(version), nil, nil
+	}
+	return nil, appdata, nil
+}
+
+// eapTls
+// EAP-TLS, the client certificate CommonName must be the username.
+func (s *AuthService) eapTls(state *eap.State, msg *eap.Packet) (*eap.Packet, error) {
+	reply, _, err := s.eapTlsProcess(state, msg.Data, 0)
+	if err != nil {
+		return nil, err
+	}
+	if reply != nil {
+		return eap.NewRequest(state.NextIdentifier(), eap.TypeTLS, reply), nil
+	}
+	certs := state.Tls.PeerCertificates()
+	if len(certs) == 0 {
+		return nil, errors.New("eap tls client certificate is missing")
+	}
+	if !strings.EqualFold(certs[0].Subject.CommonName, state.Username) {
+		return nil, fmt.Errorf("eap tls certificate CN=%s not match", certs[0].Subject.CommonName)
+	}
+	return nil, nil
+}
+
+// peapRequest
+// Encrypt an inner packet, PEAPv0 sends inner packets without the EAP header
+// except for the TLV packets.
+func (s *AuthService) peapRequest(state *eap.State, inner []byte) (*eap.Packet, error) {
+	records, err := state.Tls.WriteApp(inner)
+	if err != nil {
+		return nil, err
+	}
+	state.Tls.Send(records)
+	return eap.NewRequest(state.NextIdentifier(), eap.TypePEAP, state.Tls.NextFragment(peapVersion)), nil
+}
+
+// eapPeap
+// PEAPv0 with inner EAP-MSCHAPv2, the password check reuses CheckMsChapPassword.
+func (s *AuthService) eapPeap(state *eap.State, msg *eap.Packet, localpwd string) (*eap.Packet, error) {
+	reply, appdata, err := s.eapTlsProcess(state, msg.Data, peapVersion)
+	if err != nil {
+		return nil, err
+	}
+	if reply != nil {
+		return eap.NewRequest(state.NextIdentifier(), eap.TypePEAP, reply), nil
+	}
+
+	if state.Stage == peapStageHandshake {
+		state.Stage = peapStageIdentity
+		return s.peapRequest(state, []byte{eap.TypeIdentity})
+	}
+	if len(appdata) == 0 {
+		return nil, errors.New("peap inner response is empty")
+	}
+
+	switch state.Stage {
+	case peapStageIdentity:
+		if appdata[0] != eap.TypeIdentity {
+			return nil, errors.New("peap inner identity expected")
+		}
+		if identity := string(appdata[1:]); identity != state.Username {
+			return nil, fmt.Errorf("peap inner identity %s not match", identity)
+		}
+		state.Challenge = make([]byte, 16)
+		_, _ = rand.Read(state.Challenge)
+		state.Stage = peapStageMsChap
+		inner := append([]byte{eap.TypeMSCHAPv2}, eap.NewMsChapChallenge(state.Identifier+1, state.Challenge, eapServerName)...)
+		return s.peapRequest(state, inner)
+	case peapStageMsChap:
+		if appdata[0] != eap.TypeMSCHAPv2 {
+			return nil, errors.New("peap inner mschapv2 expected")
+		}
+		resp, err := eap.ParseMsChapResponse(appdata[1:])
+		if err != nil {
+			return nil, err
+		}
+		scratch := radius.New(radius.CodeAccessAccept, nil)
+		if err = s.CheckMsChapPassword(resp.Name, localpwd, state.Challenge, resp.RadiusResponse(), scratch); err != nil {
+			return nil, err
+		}
+		success := microsoft.MSCHAP2Success_Get(scratch)
+		if len(success) < 2 {
+			return nil, errors.New("peap mschapv2 authenticator response error")
+		}
+		state.Stage = peapStageMsChapSuccess
+		message := fmt.Sprintf("%s M=Authentication succeeded", success[1:])
+		inner := append([]byte{eap.TypeMSCHAPv2}, eap.NewMsChapSuccess(resp.Identifier, message)...)
+		return s.peapRequest(state, inner)
+	case peapStageMsChapSuccess:
+		if appdata[0] != eap.TypeMSCHAPv2 || len(appdata) < 2 || appdata[1] != eap.MsChapOpSuccess {
+			return nil, errors.New("peap inner mschapv2 success ack expected")
+		}
+		state.Stage = peapStageResult
+		return s.peapRequest(state, eap.NewTlvResult(state.Identifier+1, eap.TlvResultSuccess).Encode())
+	case peapStageResult:
+		inner, err := eap.Parse(appdata)
+		if err != nil {
+			return nil, err
+		}
+		result, err := eap.ParseTlvResult(inner.Data)
+		if err != nil {
+			return nil, err
+		}
+		if inner.Type != eap.TypeTLV || result != eap.TlvResultSuccess {
+			return nil, errors.New("peap result tlv failure")
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("peap stage %d invalid", state.Stage)
+}
@@ -0,0 +1,74 @@
+package radiusd
+
+import (
+	"bytes"
+	"crypto/md5"
+	"testing"
+	"time"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+
+	"github.com/ca17/teamsacs/models"
+	"github.com/ca17/teamsacs/radiusd/eap"
+)
+
+func newEapRequest(t *testing.T, state string, msg *eap.Packet) *radius.Request {
+	packet := radius.New(radius.CodeAccessRequest, []byte("secret"))
+	_ = rfc2865.UserName_SetString(packet, "tim")
+	_ = rfc2865.State_SetString(packet, state)
+	eap.SetEapMessage(packet, msg)
+	if err := eap.SetMessageAuthenticator(packet); err != nil {
+		t.Fatal(err)
+	}
+	return &radius.Request{Packet: packet}
+}
+
+func TestServeEAPRetransmit(t *testing.T) {
+	s := &AuthService{EapStates: eap.NewStateCache(time.Minute)}
+	user := &models.Subscribe{"username": "tim"}
+	state := s.EapStates.NewState("tim", eap.TypeMD5)
+	req, err := s.eapStartMethod(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.eapChallenge(newEapRequest(t, "", &eap.Packet{Code: eap.CodeResponse, Identifier: 0, Type: eap.TypeIdentity, Data: []byte("tim")}), state, req)
+
+	// the supplicant nak the offered method and gets a new md5 challenge
+	nak := &eap.Packet{Code: eap.CodeResponse, Identifier: req.Identifier, Type: eap.TypeNak, Data: []byte{eap.TypeMD5}}
+	challenge, err := s.ServeEAP(newEapRequest(t, state.Key, nak), user, "pass", nil)
+	if err != nil || challenge == nil {
+		t.Fatalf("nak challenge %v, %v", challenge, err)
+	}
+	// the challenge is lost, the nak is resent with the previous identifier
+	resent, err := s.ServeEAP(newEapRequest(t, state.Key, nak), user, "pass", nil)
+	if err != nil || resent == nil {
+		t.Fatalf("retransmit challenge %v, %v", resent, err)
+	}
+	if !bytes.Equal(eap.GetEapMessage(challenge), eap.GetEapMessage(resent)) {
+		t.Fatal("the last challenge must be resent")
+	}
+
+	md5req, _ := eap.Parse(eap.GetEapMessage(resent))
+	hash := md5.New()
+	hash.Write([]byte{md5req.Identifier})
+	hash.Write([]byte("pass"))
+	hash.Write(md5req.Data[1:])
+	resp := &eap.Packet{Code: eap.CodeResponse, Identifier: md5req.Identifier, Type: eap.TypeMD5, Data: append([]byte{16}, hash.Sum(nil)...)}
+	accept := radius.New(radius.CodeAccessAccept, []byte("secret"))
+	if challenge, err = s.ServeEAP(newEapRequest(t, state.Key, resp), user, "pass", accept); err != nil || challenge != nil {
+		t.Fatalf("md5 auth %v, %v", challenge, err)
+	}
+
+	// another response with the previous identifier still ends the conversation
+	state = s.EapStates.NewState("tim", eap.TypeMD5)
+	req, _ = s.eapStartMethod(state)
+	s.eapChallenge(newEapRequest(t, "", nak), state, req)
+	other := &eap.Packet{Code: eap.CodeResponse, Identifier: req.Identifier - 1, Type: eap.TypeNak, Data: []byte{eap.TypePEAP}}
+	if _, err = s.ServeEAP(newEapRequest(t, state.Key, other), user, "pass", nil); err == nil {
+		t.Fatal("a different response must not match")
+	}
+	if s.EapStates.Get(state.Key) != nil {
+		t.Fatal("the state must be removed")
+	}
+}
@@ -0,0 +1,95 @@
+package radiusd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+
+	"github.com/ca17/teamsacs/constant"
+	"github.com/ca17/teamsacs/models"
+	"github.com/ca17/teamsacs/radiusd/radlog"
+)
+
+// the reject delay is limited, the NAS retransmits the request if the reply is too late
+const RadiusMaxRejectDelay = 10
+
+// GetRejectDelay
+// RadiusRejectDelay in seconds, 0 means reply immediately
+func (s *RadiusService) GetRejectDelay() time.Duration {
+	delay := s.GetIntConfig(constant.RadiusRejectDelay, 0)
+	if delay <= 0 {
+		return 0
+	}
+	if delay > RadiusMaxRejectDelay {
+		delay = RadiusMaxRejectDelay
+	}
+	return time.Duration(delay) * time.Second
+}
+
+// GetCallingStationMac
+// Calling-Station-Id in the form of xx:xx:xx:xx:xx:xx, empty if it is not a mac address
+func GetCallingStationMac(r *radius.Request) string {
+	mac := strings.ToLower(rfc2865.CallingStationID_GetString(r.Packet))
+	mac = strings.NewReplacer("-", "", ":", "", ".", "").Replace(mac)
+	if _, err := hex.DecodeString(mac); err != nil || len(mac) != 12 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s:%s:%s:%s:%s", mac[0:2], mac[2:4], mac[4:6], mac[6:8], mac[8:10], mac[10:12])
+}
+
+// CheckLockout
+// Returns an error if the username or the calling station mac is locked
+func (s *RadiusService) CheckLockout(username, mac string) error {
+	lm := s.Manager.GetLockoutManager()
+	if !lm.IsEnabled() {
+		return nil
+	}
+	if until, ok := lm.GetLockedUntil(models.LockoutTypeUsername, username); ok {
+		return fmt.Errorf("user:%s is locked until %s", username, until.Format(time.RFC3339))
+	}
+	if until, ok := lm.GetLockedUntil(models.LockoutTypeMac, mac); ok {
+		return fmt.Errorf("mac:%s is locked until %s", mac, until.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// lockoutRequestKey
+// The client address, identifier and request authenticator, same for the retransmits of a request
+func lockoutRequestKey(r *radius.Request) string {
+	return fmt.Sprintf("%s/%d/%s", r.RemoteAddr, r.Identifier, hex.EncodeToString(r.Authenticator[:]))
+}
+
+// AddLockoutFailure
+// Count the rejected request for the username and the calling station mac, a new lockout is logged to authlog.
+// The NAS retransmits the request while the reject is delayed, the retransmits are not counted again.
+func (s *RadiusService) AddLockoutFailure(start time.Time, r *radius.Request) {
+	lm := s.Manager.GetLockoutManager()
+	if !lm.IsEnabled() || lm.IsRetransmit(lockoutRequestKey(r)) {
+		return
+	}
+	username := rfc2865.UserName_GetString(r.Packet)
+	mac := GetCallingStationMac(r)
+	nasip := addrIp(r.RemoteAddr)
+	if lm.AddFailure(models.LockoutTypeUsername, username) {
+		radlog.Warningf("user:%s is locked by too many authentication failures", username)
+		s.addAuthlog(start, username, nasip, RadiusAuthLockout, fmt.Sprintf("user:%s locked by too many authentication failures", username))
+	}
+	if lm.AddFailure(models.LockoutTypeMac, mac) {
+		radlog.Warningf("mac:%s is locked by too many authentication failures", mac)
+		s.addAuthlog(start, username, nasip, RadiusAuthLockout, fmt.Sprintf("mac:%s locked by too many authentication failures", mac))
+	}
+}
+
+// ClearLockout
+// Authentication succeeded, the failure counters are restarted
+func (s *RadiusService) ClearLockout(username, mac string) {
+	lm := s.Manager.GetLockoutManager()
+	lm.ClearLockout(models.LockoutTypeUsername, username)
+	if mac != "" {
+		lm.ClearLockout(models.LockoutTypeMac, mac)
+	}
+}
@@ -0,0 +1,95 @@
+package radiusd
+
+import (
+	"fmt"
+	"strings"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+
+	"github.com/ca17/teamsacs/common/aes"
+	"github.com/ca17/teamsacs/common/mfa"
+	"github.com/ca17/teamsacs/constant"
+	"github.com/ca17/teamsacs/models"
+	"github.com/ca17/teamsacs/radiusd/eap"
+)
+
+const (
+	MfaCodeLength       = 6
+	MfaChallengeMessage = "Please enter the one-time password"
+)
+
+// IsMfaRequired
+// TOTP is required if RadiusMfaStatus is enabled and the user has enrolled, mac authentication is excluded
+func (s *AuthService) IsMfaRequired(user *models.Subscribe, isMacAuth bool) bool {
+	if isMacAuth || user.GetMfaSecret() == "" {
+		return false
+	}
+	return s.GetStringConfig(constant.RadiusMfaStatus, constant.DISABLED) == constant.ENABLED
+}
+
+func (s *AuthService) getMfaSecret(user *models.Subscribe) (string, error) {
+	secret, err := aes.DecryptFromB64(user.GetMfaSecret(), s.GetAppConfig().System.Aeskey)
+	if err != nil {
+		return "", fmt.Errorf("user:%s mfa secret is invalid", user.GetUsername())
+	}
+	return secret, nil
+}
+
+func (s *AuthService) verifyMfaCode(user *models.Subscribe, code string) error {
+	secret, err := s.getMfaSecret(user)
+	if err != nil {
+		return err
+	}
+	ok, err := mfa.NewGoogleAuth().VerifyCode(secret, code)
+	if err != nil || !ok {
+		return fmt.Errorf("user:%s mfa code is not match", user.GetUsername())
+	}
+	return nil
+}
+
+// CheckMfaPassword
+// PAP only, the password is either the local password followed by the TOTP code,
+// or the local password alone, then an Access-Challenge is returned to prompt for the code.
+func (s *AuthService) CheckMfaPassword(r *radius.Request, user *models.Subscribe, localpwd string) (*radius.Packet, error) {
+	username := user.GetUsername()
+	password, err := rfc2865.UserPassword_LookupString(r.Packet)
+	if err != nil {
+		return nil, fmt.Errorf("user:%s mfa requires pap authentication", username)
+	}
+	password = strings.TrimSpace(password)
+	if password == localpwd {
+		state := s.MfaStates.NewState(username, 0)
+		s.MfaStates.Put(state)
+		challenge := r.Response(radius.CodeAccessChallenge)
+		_ = rfc2865.ReplyMessage_SetString(challenge, MfaChallengeMessage)
+		_ = rfc2865.State_SetString(challenge, state.Key)
+		return challenge, nil
+	}
+	if len(password) <= MfaCodeLength || password[:len(password)-MfaCodeLength] != localpwd {
+		return nil, fmt.Errorf("user:%s pap password is not match", username)
+	}
+	return nil, s.verifyMfaCode(user, password[len(password)-MfaCodeLength:])
+}
+
+// GetMfaState
+// The Access-Challenge conversation of the request, nil if it is not a reply of the mfa prompt
+func (s *AuthService) GetMfaState(r *radius.Request, username string) *eap.State {
+	key := rfc2865.State_GetString(r.Packet)
+	if key == "" {
+		return nil
+	}
+	state := s.MfaStates.Get(key)
+	if state == nil || state.Username != username {
+		return nil
+	}
+	return state
+}
+
+// CheckMfaChallenge
+// The User-Password of the reply is the TOTP code, the conversation is used only once
+func (s *AuthService) CheckMfaChallenge(r *radius.Request, user *models.Subscribe, state *eap.State) error {
+	s.MfaStates.Remove(state.Key)
+	code := strings.TrimSpace(rfc2865.UserPassword_GetString(r.Packet))
+	return s.verifyMfaCode(user, code)
+}
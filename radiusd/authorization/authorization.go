@@ -10,7 +10,6 @@ import (
 	"layeh.com/radius/rfc2869"
 
 	"github.com/ca17/teamsacs/common"
-	"github.com/ca17/teamsacs/radiusd/vendors"
 )
 
 type Profile interface {
@@ -18,6 +17,10 @@ type Profile interface {
 	GetInterimInterval() int
 	GetAddrPool() string
 	GetIpaddr() string
+	GetIpv6AddrPool() string
+	GetIpv6Prefix() string
+	GetIpv6InterfaceId() string
+	GetDelegatedIpv6Prefix() string
 	GetUpRateKbps() int
 	GetDownRateKbps() int
 	GetDomain() string
@@ -27,26 +30,34 @@ type Profile interface {
 }
 
 
-func UpdateAuthorization(profile Profile, vendorCode string, accept *radius.Packet) {
+// UpdateAuthorization
+// The standard attributes and the vendor attributes of mapping, mapping is nil if the vendor has none
+func UpdateAuthorization(profile Profile, mapping *VendorMapping, accept *radius.Packet) error {
 	DefaultAuthorization(profile, accept)
-	switch vendorCode {
-	case vendors.VendorHuawei:
-		HuaweiAuthorization(profile, accept)
-	case vendors.VendorH3c:
-		H3CAuthorization(profile, accept)
-	case vendors.VendorRadback:
-		RadbackAuthorization(profile, accept)
-	case vendors.VendorZte:
-		ZteAuthorization(profile, accept)
-	case vendors.VendorCisco:
-		CiscoAuthorization(profile, accept)
-	case vendors.VendorMikrotik:
-		MikrotikAuthorization(profile, accept)
-	case vendors.VendorIkuai:
-		IkuaiAuthorization(profile, accept)
-	}
+	return mapping.Apply(profile, accept, false)
+}
+
+// VendorAuthorization
+// Vendor rate and policy attributes only, used by CoA-Request
+func VendorAuthorization(profile Profile, mapping *VendorMapping, packet *radius.Packet) error {
+	return mapping.Apply(profile, packet, true)
 }
 
+// LimitSessionTimeout
+// Session-Timeout is lowered to timeout seconds if it is longer
+func LimitSessionTimeout(accept *radius.Packet, timeout int64) {
+	if timeout < 0 {
+		return
+	}
+	if timeout > math.MaxInt32 {
+		timeout = math.MaxInt32
+	}
+	current, err := rfc2865.SessionTimeout_Lookup(accept)
+	if err == nil && int64(current) <= timeout {
+		return
+	}
+	_ = rfc2865.SessionTimeout_Set(accept, rfc2865.SessionTimeout(timeout))
+}
 
 func DefaultAuthorization(prof Profile, accept *radius.Packet) {
 	var timeout = int64(prof.GetExpireTime().Sub(time.Now()).Seconds())
@@ -66,4 +77,5 @@ func DefaultAuthorization(prof Profile, accept *radius.Packet) {
 	if common.IsNotEmptyAndNA(ipaddr) {
 		rfc2865.FramedIPAddress_Set(accept, net.ParseIP(ipaddr))
 	}
+	Ipv6Authorization(prof, accept)
 }
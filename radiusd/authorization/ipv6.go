@@ -0,0 +1,78 @@
+package authorization
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc3162"
+	"layeh.com/radius/rfc4818"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/radiusd/radlog"
+)
+
+// Ipv6Authorization
+// Framed-IPv6-Prefix, Framed-Interface-Id, Framed-IPv6-Pool (RFC 3162) and Delegated-IPv6-Prefix (RFC 4818)
+func Ipv6Authorization(prof Profile, accept *radius.Packet) {
+	if prefix := prof.GetIpv6Prefix(); common.IsNotEmptyAndNA(prefix) {
+		if ipnet, err := ParseIpv6Prefix(prefix); err == nil {
+			_ = rfc3162.FramedIPv6Prefix_Set(accept, ipnet)
+		} else {
+			radlog.Error(err)
+		}
+	}
+	if ifid := prof.GetIpv6InterfaceId(); common.IsNotEmptyAndNA(ifid) {
+		if id, err := ParseInterfaceId(ifid); err == nil {
+			_ = rfc3162.FramedInterfaceID_Set(accept, id)
+		} else {
+			radlog.Error(err)
+		}
+	}
+	if prefix := prof.GetDelegatedIpv6Prefix(); common.IsNotEmptyAndNA(prefix) {
+		if ipnet, err := ParseIpv6Prefix(prefix); err == nil {
+			_ = rfc4818.DelegatedIPv6Prefix_Set(accept, ipnet)
+		} else {
+			radlog.Error(err)
+		}
+	}
+	if pool := prof.GetIpv6AddrPool(); common.IsNotEmptyAndNA(pool) {
+		_ = rfc3162.FramedIPv6Pool_SetString(accept, pool)
+	}
+}
+
+// ParseIpv6Prefix
+// The prefix must be ipv6, host bits are cleared
+func ParseIpv6Prefix(prefix string) (*net.IPNet, error) {
+	ip, ipnet, err := net.ParseCIDR(prefix)
+	if err != nil || ip.To4() != nil {
+		return nil, fmt.Errorf("invalid ipv6 prefix %s", prefix)
+	}
+	return ipnet, nil
+}
+
+// ParseInterfaceId
+// 64 bits interface identifier, as the low 64 bits of an address "0:0:0:1",
+// or as octets "00:00:00:00:00:00:00:01"
+func ParseInterfaceId(ifid string) (net.HardwareAddr, error) {
+	groups := strings.Split(ifid, ":")
+	if len(groups) == 4 {
+		id := make(net.HardwareAddr, 8)
+		for i, g := range groups {
+			v, err := strconv.ParseUint(g, 16, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid interface id %s", ifid)
+			}
+			binary.BigEndian.PutUint16(id[i*2:], uint16(v))
+		}
+		return id, nil
+	}
+	id, err := net.ParseMAC(ifid)
+	if err != nil || len(id) != 8 {
+		return nil, fmt.Errorf("invalid interface id %s", ifid)
+	}
+	return id, nil
+}
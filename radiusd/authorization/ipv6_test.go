@@ -0,0 +1,77 @@
+package authorization
+
+import (
+	"testing"
+	"time"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc3162"
+	"layeh.com/radius/rfc4818"
+)
+
+type testProfile struct {
+	prefix, ifid, delegated, pool string
+	up, down                      int
+	domain, policy, addrpool      string
+}
+
+func (p testProfile) GetExpireTime() time.Time       { return time.Now().Add(time.Hour) }
+func (p testProfile) GetInterimInterval() int        { return 120 }
+func (p testProfile) GetAddrPool() string            { return p.addrpool }
+func (p testProfile) GetIpaddr() string              { return "" }
+func (p testProfile) GetIpv6AddrPool() string        { return p.pool }
+func (p testProfile) GetIpv6Prefix() string          { return p.prefix }
+func (p testProfile) GetIpv6InterfaceId() string     { return p.ifid }
+func (p testProfile) GetDelegatedIpv6Prefix() string { return p.delegated }
+func (p testProfile) GetUpRateKbps() int             { return p.up }
+func (p testProfile) GetDownRateKbps() int           { return p.down }
+func (p testProfile) GetDomain() string              { return p.domain }
+func (p testProfile) GetLimitPolicy() string         { return p.policy }
+func (p testProfile) GetUpLimitPolicy() string       { return p.policy }
+func (p testProfile) GetDownLimitPolicy() string     { return p.policy }
+
+func TestParseInterfaceId(t *testing.T) {
+	tests := []struct {
+		ifid   string
+		expect string
+		valid  bool
+	}{
+		{"0:0:0:1", "00:00:00:00:00:00:00:01", true},
+		{"0211:22ff:fe33:4455", "02:11:22:ff:fe:33:44:55", true},
+		{"02:11:22:ff:fe:33:44:55", "02:11:22:ff:fe:33:44:55", true},
+		{"02:11:22:33:44:55", "", false},
+		{"0:0:0:1ffff", "", false},
+	}
+	for _, tt := range tests {
+		id, err := ParseInterfaceId(tt.ifid)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s valid %v, %v", tt.ifid, tt.valid, err)
+			continue
+		}
+		if tt.valid && id.String() != tt.expect {
+			t.Errorf("%s parsed %s, expect %s", tt.ifid, id, tt.expect)
+		}
+	}
+}
+
+func TestIpv6Authorization(t *testing.T) {
+	accept := radius.New(radius.CodeAccessAccept, []byte("secret"))
+	Ipv6Authorization(testProfile{
+		prefix:    "2001:db8:1:2::1/64",
+		ifid:      "0:0:0:1",
+		delegated: "2001:db8:100::/56",
+		pool:      "v6pool",
+	}, accept)
+	if prefix := rfc3162.FramedIPv6Prefix_Get(accept); prefix == nil || prefix.String() != "2001:db8:1:2::/64" {
+		t.Errorf("Framed-IPv6-Prefix %v", prefix)
+	}
+	if ifid := rfc3162.FramedInterfaceID_Get(accept); ifid.String() != "00:00:00:00:00:00:00:01" {
+		t.Errorf("Framed-Interface-Id %v", ifid)
+	}
+	if prefix := rfc4818.DelegatedIPv6Prefix_Get(accept); prefix == nil || prefix.String() != "2001:db8:100::/56" {
+		t.Errorf("Delegated-IPv6-Prefix %v", prefix)
+	}
+	if pool := rfc3162.FramedIPv6Pool_GetString(accept); pool != "v6pool" {
+		t.Errorf("Framed-IPv6-Pool %s", pool)
+	}
+}
@@ -0,0 +1,204 @@
+package authorization
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+	"layeh.com/radius"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/radiusd/dictionary"
+)
+
+const (
+	MappingOpAdd = "add"
+	MappingOpSet = "set"
+)
+
+// AttrMapping
+// A reply attribute of the vendor mapping, Value is a text/template rendered with the profile data,
+// the attribute is not sent if the value is empty or N/A. AddrPool marks the attribute naming the
+// address pool, it is removed when the address is leased from a pool managed by TeamsACS.
+type AttrMapping struct {
+	Name     string `yaml:"name" json:"name"`
+	Value    string `yaml:"value" json:"value"`
+	Op       string `yaml:"op,omitempty" json:"op,omitempty"`
+	AuthOnly bool   `yaml:"auth_only,omitempty" json:"auth_only,omitempty"`
+	AddrPool bool   `yaml:"addr_pool,omitempty" json:"addr_pool,omitempty"`
+}
+
+// VendorMapping
+// Vendor reply attributes mapped from the profile, e.g.
+//
+//	vendor: "2011"
+//	name: huawei
+//	attributes:
+//	  - name: Huawei-Input-Average-Rate
+//	    value: "{{int32 (mul .up_rate 1000)}}"
+//	  - name: Huawei-Domain-Name
+//	    value: "{{.domain}}"
+type VendorMapping struct {
+	Vendor     string        `yaml:"vendor" json:"vendor"`
+	Name       string        `yaml:"name" json:"name"`
+	Attributes []AttrMapping `yaml:"attributes" json:"attributes"`
+
+	dict      *dictionary.Dictionary
+	attrs     []*dictionary.Attribute
+	templates []*template.Template
+}
+
+var mappingFuncs = template.FuncMap{
+	"int": toInt64,
+	"add": func(a, b interface{}) int64 { return toInt64(a) + toInt64(b) },
+	"sub": func(a, b interface{}) int64 { return toInt64(a) - toInt64(b) },
+	"mul": func(a, b interface{}) int64 { return toInt64(a) * toInt64(b) },
+	"div": func(a, b interface{}) int64 {
+		if toInt64(b) == 0 {
+			return 0
+		}
+		return toInt64(a) / toInt64(b)
+	},
+	// limit to the range of radius integer values used by most devices
+	"int32": func(v interface{}) int64 {
+		i := toInt64(v)
+		if i > math.MaxInt32 {
+			return math.MaxInt32
+		}
+		if i < 0 {
+			return 0
+		}
+		return i
+	},
+	"na": func(v interface{}) bool {
+		return common.IsEmptyOrNA(fmt.Sprint(v))
+	},
+	"default": func(def, v interface{}) interface{} {
+		if v == nil || common.IsEmptyOrNA(fmt.Sprint(v)) {
+			return def
+		}
+		return v
+	},
+}
+
+func toInt64(v interface{}) int64 {
+	i, err := common.ParseInt64(v)
+	if err != nil {
+		return 0
+	}
+	return i
+}
+
+// ParseVendorMapping
+// Parse the yaml mapping, attribute names are resolved by the dictionary
+func ParseVendorMapping(text string, dict *dictionary.Dictionary) (*VendorMapping, error) {
+	mapping := new(VendorMapping)
+	if err := yaml.Unmarshal([]byte(text), mapping); err != nil {
+		return nil, fmt.Errorf("invalid vendor mapping, %s", err.Error())
+	}
+	mapping.dict = dict
+	for _, am := range mapping.Attributes {
+		attr, ok := dict.Lookup(am.Name)
+		if !ok {
+			return nil, fmt.Errorf("vendor mapping %s unknown attribute %s", mapping.Name, am.Name)
+		}
+		switch am.Op {
+		case "", MappingOpAdd, MappingOpSet:
+		default:
+			return nil, fmt.Errorf("vendor mapping %s attribute %s invalid op %s", mapping.Name, am.Name, am.Op)
+		}
+		tpl, err := template.New(am.Name).Funcs(mappingFuncs).Option("missingkey=zero").Parse(am.Value)
+		if err != nil {
+			return nil, fmt.Errorf("vendor mapping %s attribute %s invalid value, %s", mapping.Name, am.Name, err.Error())
+		}
+		mapping.attrs = append(mapping.attrs, attr)
+		mapping.templates = append(mapping.templates, tpl)
+	}
+	return mapping, nil
+}
+
+// Apply
+// Add the mapped attributes to p, auth only attributes are skipped for CoA-Request.
+// Attributes failed to render or encode are skipped and reported by the returned error.
+func (m *VendorMapping) Apply(prof Profile, p *radius.Packet, coa bool) error {
+	if m == nil {
+		return nil
+	}
+	data := ProfileData(prof)
+	var errs []string
+	for i, am := range m.Attributes {
+		if coa && am.AuthOnly {
+			continue
+		}
+		var buff strings.Builder
+		if err := m.templates[i].Execute(&buff, data); err != nil {
+			errs = append(errs, fmt.Sprintf("attribute %s %s", am.Name, err.Error()))
+			continue
+		}
+		value := strings.TrimSpace(buff.String())
+		if common.IsEmptyOrNA(value) || value == "<no value>" {
+			continue
+		}
+		var err error
+		if am.Op == MappingOpSet {
+			err = m.dict.Set(p, m.attrs[i].Name, value)
+		} else {
+			err = m.dict.Add(p, m.attrs[i].Name, value)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// DelAddrPoolAttributes
+// Remove the address pool attributes of the mapping from p
+func (m *VendorMapping) DelAddrPoolAttributes(p *radius.Packet) {
+	if m == nil {
+		return
+	}
+	for i, am := range m.Attributes {
+		if am.AddrPool {
+			m.dict.Del(p, m.attrs[i])
+		}
+	}
+}
+
+// FieldsProfile
+// Profiles exposing extra fields to the mapping templates, e.g. the subscribe document
+type FieldsProfile interface {
+	GetMappingFields() map[string]interface{}
+}
+
+// ProfileData
+// The template data of prof, the profile values override the extra fields of the same name
+func ProfileData(prof Profile) map[string]interface{} {
+	data := make(map[string]interface{})
+	if fp, ok := prof.(FieldsProfile); ok {
+		for k, v := range fp.GetMappingFields() {
+			data[k] = v
+		}
+	}
+	data["expire_time"] = prof.GetExpireTime()
+	data["interim_interval"] = prof.GetInterimInterval()
+	data["addr_pool"] = prof.GetAddrPool()
+	data["ipaddr"] = prof.GetIpaddr()
+	data["ipv6_addr_pool"] = prof.GetIpv6AddrPool()
+	data["ipv6_prefix"] = prof.GetIpv6Prefix()
+	data["ipv6_interface_id"] = prof.GetIpv6InterfaceId()
+	data["delegated_ipv6_prefix"] = prof.GetDelegatedIpv6Prefix()
+	data["up_rate"] = prof.GetUpRateKbps()
+	data["down_rate"] = prof.GetDownRateKbps()
+	data["domain"] = prof.GetDomain()
+	data["limit_policy"] = prof.GetLimitPolicy()
+	data["up_limit_policy"] = prof.GetUpLimitPolicy()
+	data["down_limit_policy"] = prof.GetDownLimitPolicy()
+	return data
+}
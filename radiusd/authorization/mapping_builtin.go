@@ -0,0 +1,153 @@
+package authorization
+
+import (
+	"github.com/ca17/teamsacs/radiusd/vendors"
+)
+
+// BuiltinVendorMapping
+// The default mapping of the vendor, empty if the vendor has no vendor attributes
+func BuiltinVendorMapping(vendorCode string) string {
+	return builtinMappings[vendorCode]
+}
+
+// Rates are kbps in the profile, the peak rate is 4 times of the average rate, pfSense rates are bps,
+// the ipv6 pool is used for prefix delegation unless a delegated prefix is assigned.
+var builtinMappings = map[string]string{
+	vendors.VendorHuawei: `
+vendor: "2011"
+name: huawei
+attributes:
+  - name: Huawei-Input-Average-Rate
+    value: "{{int32 (mul .up_rate 1000)}}"
+  - name: Huawei-Input-Peak-Rate
+    value: "{{int32 (mul .up_rate 4000)}}"
+  - name: Huawei-Output-Average-Rate
+    value: "{{int32 (mul .down_rate 1000)}}"
+  - name: Huawei-Output-Peak-Rate
+    value: "{{int32 (mul .down_rate 4000)}}"
+  - name: Huawei-Domain-Name
+    value: "{{.domain}}"
+  - name: Huawei-Delegated-IPv6-Prefix-Pool
+    value: "{{if na .delegated_ipv6_prefix}}{{.ipv6_addr_pool}}{{end}}"
+    auth_only: true
+`,
+	vendors.VendorH3c: `
+vendor: "25506"
+name: h3c
+attributes:
+  - name: H3C-Input-Average-Rate
+    value: "{{int32 (mul .up_rate 1000)}}"
+  - name: H3C-Input-Peak-Rate
+    value: "{{int32 (mul .up_rate 4000)}}"
+  - name: H3C-Output-Average-Rate
+    value: "{{int32 (mul .down_rate 1000)}}"
+  - name: H3C-Output-Peak-Rate
+    value: "{{int32 (mul .down_rate 4000)}}"
+`,
+	vendors.VendorRadback: `
+vendor: "2352"
+name: radback
+attributes:
+  - name: Subscriber-Profile-Name
+    value: "{{.limit_policy}}"
+  - name: Context-Name
+    value: "{{.domain}}"
+`,
+	vendors.VendorZte: `
+vendor: "3902"
+name: zte
+attributes:
+  - name: ZTE-Rate-Ctrl-SCR-Up
+    value: "{{int32 (mul .up_rate 1000)}}"
+  - name: ZTE-Rate-Ctrl-SCR-Down
+    value: "{{int32 (mul .down_rate 1000)}}"
+  - name: ZTE-Context-Name
+    value: "{{.domain}}"
+`,
+	vendors.VendorCisco: `
+vendor: "9"
+name: cisco
+attributes:
+  - name: Cisco-AVPair
+    value: "{{if not (na .up_limit_policy)}}sub-qos-policy-in={{.up_limit_policy}}{{end}}"
+  - name: Cisco-AVPair
+    value: "{{if not (na .down_limit_policy)}}sub-qos-policy-out={{.down_limit_policy}}{{end}}"
+`,
+	vendors.VendorMikrotik: `
+vendor: "14988"
+name: mikrotik
+attributes:
+  - name: Mikrotik-Rate-Limit
+    value: "{{.up_rate}}k/{{.down_rate}}k"
+  - name: Mikrotik-Delegated-IPv6-Pool
+    value: "{{if na .delegated_ipv6_prefix}}{{.ipv6_addr_pool}}{{end}}"
+    auth_only: true
+`,
+	vendors.VendorIkuai: `
+vendor: "10055"
+name: ikuai
+attributes:
+  - name: RP-Upstream-Speed-Limit
+    value: "{{int32 (mul .up_rate 8192)}}"
+  - name: RP-Downstream-Speed-Limit
+    value: "{{int32 (mul .down_rate 8192)}}"
+`,
+	vendors.VendorJuniper: `
+vendor: "2636"
+name: juniper
+attributes:
+  - name: Juniper-Ip-Pool-Name
+    value: "{{if na .ipaddr}}{{.addr_pool}}{{end}}"
+    auth_only: true
+    addr_pool: true
+  - name: Juniper-Firewall-Filter-Name
+    value: "{{.limit_policy}}"
+  - name: Juniper-CoS-Traffic-Control-Profile
+    value: "{{.down_limit_policy}}"
+`,
+	vendors.VendorAruba: `
+vendor: "14823"
+name: aruba
+attributes:
+  - name: Aruba-User-Role
+    value: "{{.limit_policy}}"
+`,
+	vendors.VendorAlcatel: `
+vendor: "3041"
+name: alcatel
+attributes:
+  - name: AAT-Filter
+    value: "{{.limit_policy}}"
+  - name: AAT-Vrouter-Name
+    value: "{{.domain}}"
+`,
+	vendors.VendorHillstone: `
+vendor: "28557"
+name: hillstone
+attributes:
+  - name: Hillstone-User-Role-Name
+    value: "{{.limit_policy}}"
+  - name: Hillstone-VPN-DHCP-Pool
+    value: "{{if na .ipaddr}}{{.addr_pool}}{{end}}"
+    auth_only: true
+    addr_pool: true
+`,
+	vendors.VendorF5: `
+vendor: "3375"
+name: f5
+attributes:
+  - name: F5-LTM-User-Info-1
+    value: "{{.limit_policy}}"
+  - name: F5-LTM-User-Partition
+    value: "{{.domain}}"
+`,
+	vendors.VendorPfSense: `
+vendor: "13644"
+name: pfsense
+attributes:
+  - name: pfSense-Bandwidth-Max-Up
+    value: "{{int32 (mul .up_rate 1000)}}"
+  - name: pfSense-Bandwidth-Max-Down
+    value: "{{int32 (mul .down_rate 1000)}}"
+`,
+}
@@ -0,0 +1,234 @@
+package authorization
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"layeh.com/radius"
+
+	"github.com/ca17/teamsacs/radiusd/dictionary"
+	"github.com/ca17/teamsacs/radiusd/vendors"
+	"github.com/ca17/teamsacs/radiusd/vendors/alcatel"
+	"github.com/ca17/teamsacs/radiusd/vendors/aruba"
+	"github.com/ca17/teamsacs/radiusd/vendors/cisco"
+	"github.com/ca17/teamsacs/radiusd/vendors/f5"
+	"github.com/ca17/teamsacs/radiusd/vendors/h3c"
+	"github.com/ca17/teamsacs/radiusd/vendors/hillstone"
+	"github.com/ca17/teamsacs/radiusd/vendors/huawei"
+	"github.com/ca17/teamsacs/radiusd/vendors/ikuai"
+	"github.com/ca17/teamsacs/radiusd/vendors/juniper"
+	"github.com/ca17/teamsacs/radiusd/vendors/mikrotik"
+	"github.com/ca17/teamsacs/radiusd/vendors/pfSense"
+	"github.com/ca17/teamsacs/radiusd/vendors/radback"
+	"github.com/ca17/teamsacs/radiusd/vendors/zte"
+)
+
+func applyBuiltinMapping(t *testing.T, vendorCode string, prof Profile, coa bool) *radius.Packet {
+	mapping, err := ParseVendorMapping(BuiltinVendorMapping(vendorCode), dictionary.Builtin())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := radius.New(radius.CodeAccessAccept, []byte("secret"))
+	if err = mapping.Apply(prof, p, coa); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestBuiltinMappings(t *testing.T) {
+	for code := range builtinMappings {
+		if _, err := ParseVendorMapping(BuiltinVendorMapping(code), dictionary.Builtin()); err != nil {
+			t.Errorf("vendor %s %v", code, err)
+		}
+	}
+}
+
+func TestHuaweiMapping(t *testing.T) {
+	prof := testProfile{up: 2048, down: 1024 * 1024, domain: "isp", pool: "v6pool"}
+	p := applyBuiltinMapping(t, vendors.VendorHuawei, prof, false)
+	if v := huawei.HuaweiInputAverageRate_Get(p); v != 2048000 {
+		t.Errorf("Huawei-Input-Average-Rate %d", v)
+	}
+	if v := huawei.HuaweiInputPeakRate_Get(p); v != 8192000 {
+		t.Errorf("Huawei-Input-Peak-Rate %d", v)
+	}
+	if v := huawei.HuaweiOutputAverageRate_Get(p); v != 1024*1024*1000 {
+		t.Errorf("Huawei-Output-Average-Rate %d", v)
+	}
+	if v := huawei.HuaweiOutputPeakRate_Get(p); v != math.MaxInt32 {
+		t.Errorf("Huawei-Output-Peak-Rate %d", v)
+	}
+	if v := huawei.HuaweiDomainName_GetString(p); v != "isp" {
+		t.Errorf("Huawei-Domain-Name %s", v)
+	}
+	if v := huawei.HuaweiDelegatedIPv6PrefixPool_GetString(p); v != "v6pool" {
+		t.Errorf("Huawei-Delegated-IPv6-Prefix-Pool %s", v)
+	}
+
+	// auth only and N/A values are not sent
+	p = applyBuiltinMapping(t, vendors.VendorHuawei, testProfile{up: 1, down: 1, domain: "N/A", pool: "v6pool"}, true)
+	if _, err := huawei.HuaweiDelegatedIPv6PrefixPool_Lookup(p); err == nil {
+		t.Error("Huawei-Delegated-IPv6-Prefix-Pool sent in coa")
+	}
+	if _, err := huawei.HuaweiDomainName_Lookup(p); err == nil {
+		t.Error("Huawei-Domain-Name sent for N/A")
+	}
+}
+
+func TestVendorMappings(t *testing.T) {
+	prof := testProfile{up: 1024, down: 2048, domain: "isp", policy: "qos10m", delegated: "2001:db8::/56", pool: "v6pool"}
+
+	p := applyBuiltinMapping(t, vendors.VendorH3c, prof, false)
+	if h3c.H3CInputAverageRate_Get(p) != 1024000 || h3c.H3CInputPeakRate_Get(p) != 4096000 ||
+		h3c.H3COutputAverageRate_Get(p) != 2048000 || h3c.H3COutputPeakRate_Get(p) != 8192000 {
+		t.Error("h3c rates")
+	}
+
+	p = applyBuiltinMapping(t, vendors.VendorZte, prof, false)
+	if zte.ZTERateCtrlSCRUp_Get(p) != 1024000 || zte.ZTERateCtrlSCRDown_Get(p) != 2048000 || zte.ZTEContextName_GetString(p) != "isp" {
+		t.Error("zte attributes")
+	}
+
+	p = applyBuiltinMapping(t, vendors.VendorRadback, prof, false)
+	if radback.SubscriberProfileName_GetString(p) != "qos10m" || radback.ContextName_GetString(p) != "isp" {
+		t.Error("radback attributes")
+	}
+
+	p = applyBuiltinMapping(t, vendors.VendorCisco, prof, false)
+	pairs, _ := cisco.CiscoAVPair_GetStrings(p)
+	if len(pairs) != 2 || pairs[0] != "sub-qos-policy-in=qos10m" || pairs[1] != "sub-qos-policy-out=qos10m" {
+		t.Errorf("Cisco-AVPair %v", pairs)
+	}
+
+	p = applyBuiltinMapping(t, vendors.VendorMikrotik, prof, false)
+	if v := mikrotik.MikrotikRateLimit_GetString(p); v != "1024k/2048k" {
+		t.Errorf("Mikrotik-Rate-Limit %s", v)
+	}
+	// the delegated prefix is assigned, the pool is not used for delegation
+	if _, err := mikrotik.MikrotikDelegatedIPv6Pool_Lookup(p); err == nil {
+		t.Error("Mikrotik-Delegated-IPv6-Pool sent with delegated prefix")
+	}
+
+	p = applyBuiltinMapping(t, vendors.VendorIkuai, prof, false)
+	if ikuai.RPUpstreamSpeedLimit_Get(p) != 1024*8192 || ikuai.RPDownstreamSpeedLimit_Get(p) != 2048*8192 {
+		t.Error("ikuai rates")
+	}
+}
+
+func TestMoreVendorMappings(t *testing.T) {
+	prof := testProfile{up: 1024, down: 2048, domain: "isp", policy: "qos10m", addrpool: "pool1"}
+	tests := []struct {
+		vendor string
+		attr   string
+		get    func(p *radius.Packet) string
+		expect string
+	}{
+		{vendors.VendorJuniper, "Juniper-Ip-Pool-Name", juniper.JuniperIPPoolName_GetString, "pool1"},
+		{vendors.VendorJuniper, "Juniper-Firewall-Filter-Name", juniper.JuniperFirewallFilterName_GetString, "qos10m"},
+		{vendors.VendorJuniper, "Juniper-CoS-Traffic-Control-Profile", juniper.JuniperCoSTrafficControlProfile_GetString, "qos10m"},
+		{vendors.VendorAruba, "Aruba-User-Role", aruba.ArubaUserRole_GetString, "qos10m"},
+		{vendors.VendorAlcatel, "AAT-Filter", alcatel.AATFilter_GetString, "qos10m"},
+		{vendors.VendorAlcatel, "AAT-Vrouter-Name", alcatel.AATVrouterName_GetString, "isp"},
+		{vendors.VendorHillstone, "Hillstone-User-Role-Name", hillstone.HillstoneUserRoleBame_GetString, "qos10m"},
+		{vendors.VendorHillstone, "Hillstone-VPN-DHCP-Pool", hillstone.HillstoneVPNDHCPPool_GetString, "pool1"},
+		{vendors.VendorF5, "F5-LTM-User-Info-1", f5.F5LTMUserInfo1_GetString, "qos10m"},
+		{vendors.VendorF5, "F5-LTM-User-Partition", f5.F5LTMUserPartition_GetString, "isp"},
+		{vendors.VendorPfSense, "pfSense-Bandwidth-Max-Up", func(p *radius.Packet) string {
+			return fmt.Sprint(uint32(pfSense.PfSenseBandwidthMaxUp_Get(p)))
+		}, "1024000"},
+		{vendors.VendorPfSense, "pfSense-Bandwidth-Max-Down", func(p *radius.Packet) string {
+			return fmt.Sprint(uint32(pfSense.PfSenseBandwidthMaxDown_Get(p)))
+		}, "2048000"},
+	}
+	for _, tt := range tests {
+		p := applyBuiltinMapping(t, tt.vendor, prof, false)
+		if v := tt.get(p); v != tt.expect {
+			t.Errorf("vendor %s %s %s, expect %s", tt.vendor, tt.attr, v, tt.expect)
+		}
+	}
+
+	// pools are auth only and not used for a fixed address
+	for _, vendor := range []string{vendors.VendorJuniper, vendors.VendorHillstone} {
+		p := applyBuiltinMapping(t, vendor, prof, true)
+		if juniper.JuniperIPPoolName_GetString(p) != "" || hillstone.HillstoneVPNDHCPPool_GetString(p) != "" {
+			t.Errorf("vendor %s pool sent in coa", vendor)
+		}
+	}
+
+	// the address is leased from a managed pool, the vendor pools are removed
+	for _, vendor := range []string{vendors.VendorJuniper, vendors.VendorHillstone} {
+		mapping, err := ParseVendorMapping(BuiltinVendorMapping(vendor), dictionary.Builtin())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := applyBuiltinMapping(t, vendor, prof, false)
+		mapping.DelAddrPoolAttributes(p)
+		if juniper.JuniperIPPoolName_GetString(p) != "" || hillstone.HillstoneVPNDHCPPool_GetString(p) != "" {
+			t.Errorf("vendor %s pool sent with leased address", vendor)
+		}
+		if juniper.JuniperFirewallFilterName_GetString(p) == "" && hillstone.HillstoneUserRoleBame_GetString(p) == "" {
+			t.Errorf("vendor %s other attributes removed", vendor)
+		}
+	}
+}
+
+func TestParseVendorMapping(t *testing.T) {
+	dict := dictionary.Builtin()
+	tests := []struct {
+		text  string
+		valid bool
+	}{
+		{"name: test\nattributes:\n  - name: Filter-Id\n    value: \"{{.limit_policy}}\"\n", true},
+		{"name: test\nattributes:\n  - name: Unknown-Attr\n    value: \"1\"\n", false},
+		{"name: test\nattributes:\n  - name: Filter-Id\n    value: \"{{.limit_policy\"\n", false},
+		{"name: test\nattributes:\n  - name: Filter-Id\n    value: \"1\"\n    op: replace\n", false},
+		{"name: [test", false},
+	}
+	for _, tt := range tests {
+		if _, err := ParseVendorMapping(tt.text, dict); (err == nil) != tt.valid {
+			t.Errorf("%q valid %v, %v", tt.text, tt.valid, err)
+		}
+	}
+}
+
+func TestMappingSetAndErrors(t *testing.T) {
+	mapping, err := ParseVendorMapping(`
+name: test
+attributes:
+  - name: Session-Timeout
+    value: "{{div .interim_interval 2}}"
+    op: set
+  - name: Framed-IP-Address
+    value: "{{.domain}}"
+  - name: Filter-Id
+    value: "{{default \"none\" .ipaddr}}"
+`, dictionary.Builtin())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := radius.New(radius.CodeAccessAccept, []byte("secret"))
+	DefaultAuthorization(testProfile{}, p)
+	// the invalid Framed-IP-Address is reported, the others are still added
+	if err = mapping.Apply(testProfile{domain: "isp"}, p, false); err == nil {
+		t.Error("invalid ipaddr not reported")
+	}
+	values := dictionary.Builtin().FormatAttributes(p)
+	var timeouts int
+	for _, v := range values {
+		switch v.Name {
+		case "Session-Timeout":
+			timeouts++
+			if v.Value != "60" {
+				t.Errorf("Session-Timeout %s", v.Value)
+			}
+		case "Filter-Id":
+			if v.Value != "none" {
+				t.Errorf("Filter-Id %s", v.Value)
+			}
+		}
+	}
+	if timeouts != 1 {
+		t.Errorf("Session-Timeout count %d", timeouts)
+	}
+}
@@ -0,0 +1,174 @@
+package radiusd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+	"layeh.com/radius/rfc2866"
+	"layeh.com/radius/rfc3576"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/models"
+	"github.com/ca17/teamsacs/radiusd/authorization"
+	"github.com/ca17/teamsacs/radiusd/debug"
+	"github.com/ca17/teamsacs/radiusd/radlog"
+)
+
+const (
+	CoaTypeDisconnect = "disconnect"
+	CoaTypeCoa        = "coa"
+
+	CoaResultAck   = "ack"
+	CoaResultNak   = "nak"
+	CoaResultError = "error"
+
+	CoaOperatorSystem = "system"
+	CoaDefaultPort    = 3799
+	CoaTimeout        = time.Second * 5
+)
+
+// CoaService
+// Dynamic authorization client (RFC 5176), sends Disconnect-Request
+// and CoA-Request to the NAS, every attempt is recorded to coalog.
+type CoaService struct {
+	*RadiusService
+}
+
+func NewCoaService(radiusService *RadiusService) *CoaService {
+	return &CoaService{RadiusService: radiusService}
+}
+
+// DisconnectOnline
+// Disconnect an online session by acct_session_id
+func (s *CoaService) DisconnectOnline(sessionid, operator, reason string) (*models.CoaLog, error) {
+	online, vpe, err := s.getOnlineNas(sessionid)
+	if err != nil {
+		return nil, err
+	}
+	return s.Disconnect(online.Username, online.AcctSessionId, getOnlineNasAddr(online), vpe, operator, reason), nil
+}
+
+// CoaOnline
+// Change the rate of an online session, the rate attributes are built by the
+// authorization package from the subscriber profile with the new rates applied.
+func (s *CoaService) CoaOnline(sessionid string, upRate, downRate int, operator, reason string) (*models.CoaLog, error) {
+	online, vpe, err := s.getOnlineNas(sessionid)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.GetUserForAcct(online.Username)
+	if err != nil {
+		return nil, err
+	}
+	profile := make(models.Subscribe)
+	for k, v := range *user {
+		profile[k] = v
+	}
+	if upRate > 0 {
+		profile["up_rate"] = upRate
+	}
+	if downRate > 0 {
+		profile["down_rate"] = downRate
+	}
+	return s.Coa(&profile, online.AcctSessionId, getOnlineNasAddr(online), vpe, operator, reason), nil
+}
+
+func (s *CoaService) getOnlineNas(sessionid string) (*models.Accounting, *models.Vpe, error) {
+	online, err := s.Manager.GetRadiusManager().GetRadiusOnline(sessionid)
+	if err != nil {
+		return nil, nil, fmt.Errorf("online session %s not exists, %s", sessionid, err.Error())
+	}
+	vpe, err := s.GetNas(online.NasAddr, online.NasId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return online, vpe, nil
+}
+
+// getOnlineNasAddr
+// The source address of the accounting packets, NAS behind NAT may differ from the VPE ipaddr
+func getOnlineNasAddr(online *models.Accounting) string {
+	if common.IsNotEmptyAndNA(online.NasPaddr) {
+		return online.NasPaddr
+	}
+	return online.NasAddr
+}
+
+// Disconnect
+// Send Disconnect-Request for the session
+func (s *CoaService) Disconnect(username, sessionid, nasip string, vpe *models.Vpe, operator, reason string) *models.CoaLog {
+	return s.exchange(CoaTypeDisconnect, newDisconnectPacket(username, sessionid, vpe), username, sessionid, nasip, vpe, operator, reason)
+}
+
+func newDisconnectPacket(username, sessionid string, vpe *models.Vpe) *radius.Packet {
+	packet := radius.New(radius.CodeDisconnectRequest, []byte(vpe.GetSecret()))
+	_ = rfc2865.UserName_SetString(packet, username)
+	_ = rfc2866.AcctSessionID_SetString(packet, sessionid)
+	return packet
+}
+
+// Coa
+// Send CoA-Request with the vendor rate attributes of profile
+func (s *CoaService) Coa(profile *models.Subscribe, sessionid, nasip string, vpe *models.Vpe, operator, reason string) *models.CoaLog {
+	packet := radius.New(radius.CodeCoARequest, []byte(vpe.GetSecret()))
+	_ = rfc2865.UserName_SetString(packet, profile.GetUsername())
+	_ = rfc2866.AcctSessionID_SetString(packet, sessionid)
+	mapping, _, err := s.GetVendorMapping(vpe.GetVendorCode())
+	if err != nil {
+		radlog.Error(err)
+	}
+	if err = authorization.VendorAuthorization(profile, mapping, packet); err != nil {
+		radlog.Errorf("vendor %s authorization error, %s", vpe.GetVendorCode(), err.Error())
+	}
+	return s.exchange(CoaTypeCoa, packet, profile.GetUsername(), sessionid, nasip, vpe, operator, reason)
+}
+
+func (s *CoaService) exchange(coatype string, packet *radius.Packet, username, sessionid, nasip string, vpe *models.Vpe, operator, reason string) *models.CoaLog {
+	coalog := exchangeCoa(coatype, packet, username, sessionid, nasip, vpe, operator, reason)
+	if err := s.Manager.GetRadiusManager().AddRadiusCoaLog(coalog); err != nil {
+		radlog.Errorf("AddRadiusCoaLog user:%s error %s", username, err.Error())
+	}
+	return coalog
+}
+
+// exchangeCoa
+// Send the request to the coa_port of the VPE, the result is returned as the coalog to be saved
+func exchangeCoa(coatype string, packet *radius.Packet, username, sessionid, nasip string, vpe *models.Vpe, operator, reason string) *models.CoaLog {
+	var start = time.Now()
+	var coaPort = vpe.GetIntValue("coa_port", CoaDefaultPort)
+	coalog := &models.CoaLog{
+		Type:          coatype,
+		Username:      username,
+		AcctSessionId: sessionid,
+		NasAddr:       nasip,
+		CoaPort:       coaPort,
+		Reason:        reason,
+		Operator:      operator,
+	}
+
+	radlog.Infof("radius %s user:%s => (%s:%d): %s", coatype, username, nasip, coaPort, debug.FormatPacket(packet))
+	ctx, cancel := context.WithTimeout(context.Background(), CoaTimeout)
+	defer cancel()
+	response, err := radius.Exchange(ctx, packet, fmt.Sprintf("%s:%d", nasip, coaPort))
+	coalog.Cast = int(time.Since(start).Milliseconds())
+	if err != nil {
+		radlog.Errorf("radius %s user:%s failure, %s", coatype, username, err.Error())
+		coalog.Result = CoaResultError
+		coalog.ErrorCause = err.Error()
+	} else {
+		radlog.Infof("radius %s resp from (%s:%d): %s", coatype, nasip, coaPort, debug.FormatPacket(response))
+		switch response.Code {
+		case radius.CodeDisconnectACK, radius.CodeCoAACK:
+			coalog.Result = CoaResultAck
+		default:
+			coalog.Result = CoaResultNak
+			if cause, err := rfc3576.ErrorCause_Lookup(response); err == nil {
+				coalog.ErrorCause = cause.String()
+			}
+		}
+	}
+	return coalog
+}
@@ -0,0 +1,57 @@
+package radiusd
+
+import (
+	"net"
+	"testing"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2866"
+	"layeh.com/radius/rfc3576"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/models"
+)
+
+// a NAS answering the first request with ACK and the others with NAK
+func startCoaServer() (int, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	common.Must(err)
+	var requests int
+	server := radius.PacketServer{
+		SecretSource: radius.StaticSecretSource([]byte("secret")),
+		Handler: radius.HandlerFunc(func(w radius.ResponseWriter, r *radius.Request) {
+			requests++
+			if rfc2866.AcctSessionID_GetString(r.Packet) != "s1" {
+				return
+			}
+			if requests == 1 {
+				_ = w.Write(r.Response(radius.CodeDisconnectACK))
+				return
+			}
+			resp := r.Response(radius.CodeDisconnectNAK)
+			_ = rfc3576.ErrorCause_Set(resp, rfc3576.ErrorCause_Value_SessionContextNotFound)
+			_ = w.Write(resp)
+		}),
+	}
+	go server.Serve(conn)
+	return conn.LocalAddr().(*net.UDPAddr).Port, func() { _ = conn.Close() }
+}
+
+func TestExchangeCoa(t *testing.T) {
+	port, stop := startCoaServer()
+	defer stop()
+	vpe := &models.Vpe{"secret": "secret", "coa_port": port}
+
+	coalog := exchangeCoa(CoaTypeDisconnect, newDisconnectPacket("tim", "s1", vpe), "tim", "s1", "127.0.0.1", vpe, CoaOperatorSystem, "test")
+	if coalog.Result != CoaResultAck || coalog.CoaPort != port || coalog.ErrorCause != "" {
+		t.Fatalf("ack coalog %+v", coalog)
+	}
+	if coalog.Type != CoaTypeDisconnect || coalog.Username != "tim" || coalog.AcctSessionId != "s1" || coalog.Operator != CoaOperatorSystem {
+		t.Fatalf("coalog fields %+v", coalog)
+	}
+
+	coalog = exchangeCoa(CoaTypeDisconnect, newDisconnectPacket("tim", "s1", vpe), "tim", "s1", "127.0.0.1", vpe, CoaOperatorSystem, "test")
+	if coalog.Result != CoaResultNak || coalog.ErrorCause != rfc3576.ErrorCause_Value_SessionContextNotFound.String() {
+		t.Fatalf("nak coalog %+v", coalog)
+	}
+}
@@ -0,0 +1,369 @@
+package dictionary
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	builtinOnce sync.Once
+	builtin     *Dictionary
+	current     atomic.Value
+)
+
+func newBuiltin() *Dictionary {
+	d := New()
+	if err := d.Parse("builtin", strings.NewReader(builtinDictionary)); err != nil || len(d.Warnings) > 0 {
+		panic(fmt.Sprintf("builtin dictionary error %v %v", err, d.Warnings))
+	}
+	return d
+}
+
+// Builtin
+// The standard attributes and the vendor attributes used by the builtin authorization mappings
+func Builtin() *Dictionary {
+	builtinOnce.Do(func() {
+		builtin = newBuiltin()
+	})
+	return builtin
+}
+
+// Default
+// The dictionary loaded by SetDefault, the builtin dictionary if none is loaded
+func Default() *Dictionary {
+	if d, ok := current.Load().(*Dictionary); ok {
+		return d
+	}
+	return Builtin()
+}
+
+func SetDefault(d *Dictionary) {
+	current.Store(d)
+}
+
+// RFC 2865, 2866, 2869, 3162, 3576, 4818, 6911
+const builtinDictionary = `
+ATTRIBUTE	User-Name				1	string
+ATTRIBUTE	User-Password				2	string encrypt=1
+ATTRIBUTE	CHAP-Password				3	octets
+ATTRIBUTE	NAS-IP-Address				4	ipaddr
+ATTRIBUTE	NAS-Port				5	integer
+ATTRIBUTE	Service-Type				6	integer
+ATTRIBUTE	Framed-Protocol				7	integer
+ATTRIBUTE	Framed-IP-Address			8	ipaddr
+ATTRIBUTE	Framed-IP-Netmask			9	ipaddr
+ATTRIBUTE	Framed-Routing				10	integer
+ATTRIBUTE	Filter-Id				11	string
+ATTRIBUTE	Framed-MTU				12	integer
+ATTRIBUTE	Framed-Compression			13	integer
+ATTRIBUTE	Login-IP-Host				14	ipaddr
+ATTRIBUTE	Login-Service				15	integer
+ATTRIBUTE	Login-TCP-Port				16	integer
+ATTRIBUTE	Reply-Message				18	string
+ATTRIBUTE	Callback-Number				19	string
+ATTRIBUTE	Callback-Id				20	string
+ATTRIBUTE	Framed-Route				22	string
+ATTRIBUTE	Framed-IPX-Network			23	ipaddr
+ATTRIBUTE	State					24	octets
+ATTRIBUTE	Class					25	octets
+ATTRIBUTE	Vendor-Specific				26	vsa
+ATTRIBUTE	Session-Timeout				27	integer
+ATTRIBUTE	Idle-Timeout				28	integer
+ATTRIBUTE	Termination-Action			29	integer
+ATTRIBUTE	Called-Station-Id			30	string
+ATTRIBUTE	Calling-Station-Id			31	string
+ATTRIBUTE	NAS-Identifier				32	string
+ATTRIBUTE	Proxy-State				33	octets
+ATTRIBUTE	Login-LAT-Service			34	string
+ATTRIBUTE	Login-LAT-Node				35	string
+ATTRIBUTE	Login-LAT-Group				36	octets
+ATTRIBUTE	Framed-AppleTalk-Link			37	integer
+ATTRIBUTE	Framed-AppleTalk-Network		38	integer
+ATTRIBUTE	Framed-AppleTalk-Zone			39	string
+ATTRIBUTE	CHAP-Challenge				60	octets
+ATTRIBUTE	NAS-Port-Type				61	integer
+ATTRIBUTE	Port-Limit				62	integer
+ATTRIBUTE	Login-LAT-Port				63	string
+VALUE	Service-Type			Login-User		1
+VALUE	Service-Type			Framed-User		2
+VALUE	Service-Type			Callback-Login-User	3
+VALUE	Service-Type			Callback-Framed-User	4
+VALUE	Service-Type			Outbound-User		5
+VALUE	Service-Type			Administrative-User	6
+VALUE	Service-Type			NAS-Prompt-User		7
+VALUE	Service-Type			Authenticate-Only	8
+VALUE	Service-Type			Callback-NAS-Prompt	9
+VALUE	Service-Type			Call-Check		10
+VALUE	Service-Type			Callback-Administrative	11
+VALUE	Framed-Protocol			PPP			1
+VALUE	Framed-Protocol			SLIP			2
+VALUE	Framed-Protocol			ARAP			3
+VALUE	Framed-Protocol			Gandalf-SLML		4
+VALUE	Framed-Protocol			Xylogics-IPX-SLIP	5
+VALUE	Framed-Protocol			X.75-Synchronous	6
+VALUE	Framed-Routing			None			0
+VALUE	Framed-Routing			Broadcast		1
+VALUE	Framed-Routing			Listen			2
+VALUE	Framed-Routing			Broadcast-Listen	3
+VALUE	Framed-Compression		None			0
+VALUE	Framed-Compression		Van-Jacobson-TCP-IP	1
+VALUE	Framed-Compression		IPX-Header-Compression	2
+VALUE	Framed-Compression		Stac-LZS		3
+VALUE	Login-Service			Telnet			0
+VALUE	Login-Service			Rlogin			1
+VALUE	Login-Service			TCP-Clear		2
+VALUE	Login-Service			PortMaster		3
+VALUE	Login-Service			LAT			4
+VALUE	Login-Service			X25-PAD			5
+VALUE	Login-Service			X25-T3POS		6
+VALUE	Login-Service			TCP-Clear-Quiet		8
+VALUE	Login-TCP-Port			Telnet			23
+VALUE	Login-TCP-Port			Rlogin			513
+VALUE	Login-TCP-Port			Rsh			514
+VALUE	Termination-Action		Default			0
+VALUE	Termination-Action		RADIUS-Request		1
+VALUE	NAS-Port-Type			Async			0
+VALUE	NAS-Port-Type			Sync			1
+VALUE	NAS-Port-Type			ISDN			2
+VALUE	NAS-Port-Type			ISDN-V120		3
+VALUE	NAS-Port-Type			ISDN-V110		4
+VALUE	NAS-Port-Type			Virtual			5
+VALUE	NAS-Port-Type			PIAFS			6
+VALUE	NAS-Port-Type			HDLC-Clear-Channel	7
+VALUE	NAS-Port-Type			X.25			8
+VALUE	NAS-Port-Type			X.75			9
+VALUE	NAS-Port-Type			G.3-Fax			10
+VALUE	NAS-Port-Type			SDSL			11
+VALUE	NAS-Port-Type			ADSL-CAP		12
+VALUE	NAS-Port-Type			ADSL-DMT		13
+VALUE	NAS-Port-Type			IDSL			14
+VALUE	NAS-Port-Type			Ethernet		15
+VALUE	NAS-Port-Type			xDSL			16
+VALUE	NAS-Port-Type			Cable			17
+VALUE	NAS-Port-Type			Wireless-Other		18
+VALUE	NAS-Port-Type			Wireless-802.11		19
+ATTRIBUTE	Acct-Status-Type			40	integer
+ATTRIBUTE	Acct-Delay-Time				41	integer
+ATTRIBUTE	Acct-Input-Octets			42	integer
+ATTRIBUTE	Acct-Output-Octets			43	integer
+ATTRIBUTE	Acct-Session-Id				44	string
+ATTRIBUTE	Acct-Authentic				45	integer
+ATTRIBUTE	Acct-Session-Time			46	integer
+ATTRIBUTE	Acct-Input-Packets			47	integer
+ATTRIBUTE	Acct-Output-Packets			48	integer
+ATTRIBUTE	Acct-Terminate-Cause			49	integer
+ATTRIBUTE	Acct-Multi-Session-Id			50	string
+ATTRIBUTE	Acct-Link-Count				51	integer
+VALUE	Acct-Status-Type		Start			1
+VALUE	Acct-Status-Type		Stop			2
+VALUE	Acct-Status-Type		Alive			3   # dup
+VALUE	Acct-Status-Type		Interim-Update		3
+VALUE	Acct-Status-Type		Accounting-On		7
+VALUE	Acct-Status-Type		Accounting-Off		8
+VALUE	Acct-Status-Type		Failed			15
+VALUE	Acct-Authentic			RADIUS			1
+VALUE	Acct-Authentic			Local			2
+VALUE	Acct-Authentic			Remote			3
+VALUE	Acct-Authentic			Diameter		4
+VALUE	Acct-Terminate-Cause		User-Request		1
+VALUE	Acct-Terminate-Cause		Lost-Carrier		2
+VALUE	Acct-Terminate-Cause		Lost-Service		3
+VALUE	Acct-Terminate-Cause		Idle-Timeout		4
+VALUE	Acct-Terminate-Cause		Session-Timeout		5
+VALUE	Acct-Terminate-Cause		Admin-Reset		6
+VALUE	Acct-Terminate-Cause		Admin-Reboot		7
+VALUE	Acct-Terminate-Cause		Port-Error		8
+VALUE	Acct-Terminate-Cause		NAS-Error		9
+VALUE	Acct-Terminate-Cause		NAS-Request		10
+VALUE	Acct-Terminate-Cause		NAS-Reboot		11
+VALUE	Acct-Terminate-Cause		Port-Unneeded		12
+VALUE	Acct-Terminate-Cause		Port-Preempted		13
+VALUE	Acct-Terminate-Cause		Port-Suspended		14
+VALUE	Acct-Terminate-Cause		Service-Unavailable	15
+VALUE	Acct-Terminate-Cause		Callback		16
+VALUE	Acct-Terminate-Cause		User-Error		17
+VALUE	Acct-Terminate-Cause		Host-Request		18
+ATTRIBUTE	Acct-Input-Gigawords			52	integer
+ATTRIBUTE	Acct-Output-Gigawords			53	integer
+ATTRIBUTE	Event-Timestamp				55	date
+ATTRIBUTE	ARAP-Password				70	octets[16]
+ATTRIBUTE	ARAP-Features				71	octets[14]
+ATTRIBUTE	ARAP-Zone-Access			72	integer
+ATTRIBUTE	ARAP-Security				73	integer
+ATTRIBUTE	ARAP-Security-Data			74	string
+ATTRIBUTE	Password-Retry				75	integer
+ATTRIBUTE	Prompt					76	integer
+ATTRIBUTE	Connect-Info				77	string
+ATTRIBUTE	Configuration-Token			78	string
+ATTRIBUTE	EAP-Message				79	octets concat
+ATTRIBUTE	Message-Authenticator			80	octets
+ATTRIBUTE	ARAP-Challenge-Response			84	octets[8]
+ATTRIBUTE	Acct-Interim-Interval			85	integer
+ATTRIBUTE	NAS-Port-Id				87	string
+ATTRIBUTE	Framed-Pool				88	string
+VALUE	ARAP-Zone-Access		Default-Zone		1
+VALUE	ARAP-Zone-Access		Zone-Filter-Inclusive	2
+VALUE	ARAP-Zone-Access		Zone-Filter-Exclusive	4
+VALUE	Prompt				No-Echo			0
+VALUE	Prompt				Echo			1
+ATTRIBUTE	NAS-IPv6-Address			95	ipv6addr
+ATTRIBUTE	Framed-Interface-Id			96	ifid
+ATTRIBUTE	Framed-IPv6-Prefix			97	ipv6prefix
+ATTRIBUTE	Login-IPv6-Host				98	ipv6addr
+ATTRIBUTE	Framed-IPv6-Route			99	string
+ATTRIBUTE	Framed-IPv6-Pool			100	string
+ATTRIBUTE	Error-Cause				101	integer
+VALUE	Service-Type			Authorize-Only		17
+VALUE	Error-Cause			Residual-Context-Removed 201
+VALUE	Error-Cause			Invalid-EAP-Packet	202
+VALUE	Error-Cause			Unsupported-Attribute	401
+VALUE	Error-Cause			Missing-Attribute	402
+VALUE	Error-Cause			NAS-Identification-Mismatch 403
+VALUE	Error-Cause			Invalid-Request		404
+VALUE	Error-Cause			Unsupported-Service	405
+VALUE	Error-Cause			Unsupported-Extension	406
+VALUE	Error-Cause			Administratively-Prohibited 501
+VALUE	Error-Cause			Proxy-Request-Not-Routable 502
+VALUE	Error-Cause			Session-Context-Not-Found 503
+VALUE	Error-Cause			Session-Context-Not-Removable 504
+VALUE	Error-Cause			Proxy-Processing-Error	505
+VALUE	Error-Cause			Resources-Unavailable	506
+VALUE	Error-Cause			Request-Initiated	507
+ATTRIBUTE	Delegated-IPv6-Prefix			123	ipv6prefix
+ATTRIBUTE	Framed-IPv6-Address			168	ipv6addr
+ATTRIBUTE	DNS-Server-IPv6-Address			169	ipv6addr
+ATTRIBUTE	Route-IPv6-Information			170	ipv6prefix
+ATTRIBUTE	Delegated-IPv6-Prefix-Pool		171	string
+ATTRIBUTE	Stateful-IPv6-Address-Pool		172	string
+
+VENDOR		Cisco				9
+BEGIN-VENDOR	Cisco
+ATTRIBUTE	Cisco-AVPair				1	string
+END-VENDOR	Cisco
+
+VENDOR		Huawei				2011
+BEGIN-VENDOR	Huawei
+ATTRIBUTE	Huawei-Input-Burst-Size			1	integer
+ATTRIBUTE	Huawei-Input-Average-Rate		2	integer
+ATTRIBUTE	Huawei-Input-Peak-Rate			3	integer
+ATTRIBUTE	Huawei-Output-Burst-Size		4	integer
+ATTRIBUTE	Huawei-Output-Average-Rate		5	integer
+ATTRIBUTE	Huawei-Output-Peak-Rate			6	integer
+ATTRIBUTE	Huawei-Qos-Profile-Name			31	string
+ATTRIBUTE	Huawei-Domain-Name			138	string
+ATTRIBUTE	Huawei-Delegated-IPv6-Prefix-Pool	191	string
+END-VENDOR	Huawei
+
+VENDOR		Redback				2352
+BEGIN-VENDOR	Redback
+ATTRIBUTE	Context-Name				4	string
+ATTRIBUTE	Rate-Limit-Rate				10	integer
+ATTRIBUTE	IP-Address-Pool-Name			36	string
+ATTRIBUTE	Subscriber-Profile-Name			91	string
+END-VENDOR	Redback
+
+VENDOR		Juniper				2636
+BEGIN-VENDOR	Juniper
+ATTRIBUTE	Juniper-Local-User-Name			1	string
+ATTRIBUTE	Juniper-Primary-Dns			31	ipaddr
+ATTRIBUTE	Juniper-Secondary-Dns			33	ipaddr
+ATTRIBUTE	Juniper-Ip-Pool-Name			36	string
+ATTRIBUTE	Juniper-CoS-Traffic-Control-Profile	38	string
+ATTRIBUTE	Juniper-Firewall-Filter-Name		44	string
+ATTRIBUTE	Juniper-Local-Group-Name		46	string
+ATTRIBUTE	Juniper-Switching-Filter		48	string
+END-VENDOR	Juniper
+
+VENDOR		Alcatel				3041
+BEGIN-VENDOR	Alcatel
+ATTRIBUTE	AAT-Filter				60	string
+ATTRIBUTE	AAT-Vrouter-Name			61	string
+ATTRIBUTE	AAT-IP-Pool-Definition			63	string
+ATTRIBUTE	AAT-Assign-IP-Pool			64	integer
+ATTRIBUTE	AAT-Data-Filter				65	string
+ATTRIBUTE	AAT-User-MAC-Address			132	string
+END-VENDOR	Alcatel
+
+VENDOR		F5				3375
+BEGIN-VENDOR	F5
+ATTRIBUTE	F5-LTM-User-Role			1	integer
+ATTRIBUTE	F5-LTM-User-Partition			3	string
+ATTRIBUTE	F5-LTM-User-Info-1			12	string
+ATTRIBUTE	F5-LTM-User-Info-2			13	string
+VALUE	F5-LTM-User-Role		Administrator		0
+VALUE	F5-LTM-User-Role		Resource-Admin		20
+VALUE	F5-LTM-User-Role		User-Manager		40
+VALUE	F5-LTM-User-Role		Manager			100
+VALUE	F5-LTM-User-Role		App-Editor		300
+VALUE	F5-LTM-User-Role		Operator		400
+VALUE	F5-LTM-User-Role		Guest			700
+VALUE	F5-LTM-User-Role		Policy-Editor		800
+VALUE	F5-LTM-User-Role		No-Access		900
+END-VENDOR	F5
+
+VENDOR		ZTE				3902
+BEGIN-VENDOR	ZTE
+ATTRIBUTE	ZTE-Context-Name			4	string
+ATTRIBUTE	ZTE-QoS-Profile-Down			82	string
+ATTRIBUTE	ZTE-Rate-Ctrl-SCR-Down			83	integer
+ATTRIBUTE	ZTE-Rate-Ctrl-SCR-Up			89	integer
+ATTRIBUTE	ZTE-QOS-Profile-Up			94	string
+END-VENDOR	ZTE
+
+VENDOR		iKuai				10055
+BEGIN-VENDOR	iKuai
+ATTRIBUTE	RP-Upstream-Speed-Limit			1	integer
+ATTRIBUTE	RP-Downstream-Speed-Limit		2	integer
+END-VENDOR	iKuai
+
+VENDOR		pfSense				13644
+BEGIN-VENDOR	pfSense
+ATTRIBUTE	pfSense-Bandwidth-Max-Up		1	integer
+ATTRIBUTE	pfSense-Bandwidth-Max-Down		2	integer
+ATTRIBUTE	pfSense-Max-Total-Octets		3	integer
+END-VENDOR	pfSense
+
+VENDOR		Aruba				14823
+BEGIN-VENDOR	Aruba
+ATTRIBUTE	Aruba-User-Role				1	string
+ATTRIBUTE	Aruba-User-Vlan				2	integer
+ATTRIBUTE	Aruba-Essid-Name			5	string
+ATTRIBUTE	Aruba-Location-Id			6	string
+ATTRIBUTE	Aruba-Port-Identifier			7	string
+ATTRIBUTE	Aruba-Named-User-Vlan			9	string
+ATTRIBUTE	Aruba-AP-Group				10	string
+ATTRIBUTE	Aruba-User-Group			36	string
+END-VENDOR	Aruba
+
+VENDOR		Mikrotik			14988
+BEGIN-VENDOR	Mikrotik
+ATTRIBUTE	Mikrotik-Recv-Limit			1	integer
+ATTRIBUTE	Mikrotik-Xmit-Limit			2	integer
+ATTRIBUTE	Mikrotik-Group				3	string
+ATTRIBUTE	Mikrotik-Rate-Limit			8	string
+ATTRIBUTE	Mikrotik-Delegated-IPv6-Pool		22	string
+END-VENDOR	Mikrotik
+
+VENDOR		H3C				25506
+BEGIN-VENDOR	H3C
+ATTRIBUTE	H3C-Input-Peak-Rate			1	integer
+ATTRIBUTE	H3C-Input-Average-Rate			2	integer
+ATTRIBUTE	H3C-Input-Basic-Rate			3	integer
+ATTRIBUTE	H3C-Output-Peak-Rate			4	integer
+ATTRIBUTE	H3C-Output-Average-Rate			5	integer
+ATTRIBUTE	H3C-User-Group				140	string
+END-VENDOR	H3C
+
+VENDOR		Hillstone			28557
+BEGIN-VENDOR	Hillstone
+ATTRIBUTE	Hillstone-User-Vsys-ID			1	integer
+ATTRIBUTE	Hillstone-User-Role-Name		9	string
+ATTRIBUTE	Hillstone-VPN-DHCP-Gateway		100	string
+ATTRIBUTE	Hillstone-VPN-DHCP-Mask			101	string
+ATTRIBUTE	Hillstone-VPN-DHCP-Pool			102	string
+ATTRIBUTE	Hillstone-VPN-DNS			104	string
+ATTRIBUTE	Hillstone-VPN-Split-Route		105	string
+END-VENDOR	Hillstone
+`
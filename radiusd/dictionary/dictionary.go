@@ -0,0 +1,501 @@
+package dictionary
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"layeh.com/radius"
+	dict "layeh.com/radius/dictionary"
+	"layeh.com/radius/rfc2865"
+)
+
+// Attribute
+// A dictionary attribute, Vendor is 0 for the standard attributes
+type Attribute struct {
+	Name     string
+	Vendor   uint32
+	Type     uint32
+	DataType dict.AttributeType
+	Encrypt  int
+	HasTag   bool
+	values   map[string]uint32
+	names    map[uint32]string
+}
+
+// Vendor
+// Vendor-Specific sub attribute format, the octets of type and length
+type Vendor struct {
+	Name         string
+	Number       uint32
+	TypeOctets   int
+	LengthOctets int
+}
+
+// Dictionary
+// Attributes indexed by name (case insensitive) and by vendor and type,
+// attributes added later override the earlier ones of the same name or code.
+type Dictionary struct {
+	Files    []string
+	Warnings []string
+
+	attrs       map[string]*Attribute
+	codes       map[uint64]*Attribute
+	vendors     map[uint32]*Vendor
+	vendorNames map[string]*Vendor
+}
+
+// Stats
+type Stats struct {
+	Files      []string `json:"files"`
+	Vendors    int      `json:"vendors"`
+	Attributes int      `json:"attributes"`
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+func New() *Dictionary {
+	return &Dictionary{
+		attrs:       make(map[string]*Attribute),
+		codes:       make(map[uint64]*Attribute),
+		vendors:     make(map[uint32]*Vendor),
+		vendorNames: make(map[string]*Vendor),
+	}
+}
+
+func attrCode(vendor, typ uint32) uint64 {
+	return uint64(vendor)<<32 | uint64(typ)
+}
+
+func (d *Dictionary) addVendor(vendor *Vendor) {
+	d.vendors[vendor.Number] = vendor
+	d.vendorNames[strings.ToLower(vendor.Name)] = vendor
+}
+
+func (d *Dictionary) addAttribute(attr *Attribute) {
+	attr.values = make(map[string]uint32)
+	attr.names = make(map[uint32]string)
+	d.attrs[strings.ToLower(attr.Name)] = attr
+	d.codes[attrCode(attr.Vendor, attr.Type)] = attr
+}
+
+func (d *Dictionary) addValue(attrName, name string, number uint32) bool {
+	attr, ok := d.attrs[strings.ToLower(attrName)]
+	if !ok {
+		return false
+	}
+	attr.values[strings.ToLower(name)] = number
+	attr.names[number] = name
+	return true
+}
+
+// Stats
+// The loaded files and the number of vendors and attributes
+func (d *Dictionary) Stats() Stats {
+	return Stats{Files: d.Files, Vendors: len(d.vendors), Attributes: len(d.codes), Warnings: d.Warnings}
+}
+
+// Lookup
+// The attribute of name
+func (d *Dictionary) Lookup(name string) (*Attribute, bool) {
+	attr, ok := d.attrs[strings.ToLower(name)]
+	return attr, ok
+}
+
+// LookupType
+// The attribute of vendor and type, vendor is 0 for the standard attributes
+func (d *Dictionary) LookupType(vendor, typ uint32) (*Attribute, bool) {
+	attr, ok := d.codes[attrCode(vendor, typ)]
+	return attr, ok
+}
+
+func (d *Dictionary) GetVendor(number uint32) *Vendor {
+	if v, ok := d.vendors[number]; ok {
+		return v
+	}
+	return &Vendor{Number: number, TypeOctets: 1, LengthOctets: 1}
+}
+
+// Add
+// Encode value as the attribute of name and append it to p
+func (d *Dictionary) Add(p *radius.Packet, name, value string) error {
+	attr, ok := d.Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown attribute %s", name)
+	}
+	data, err := attr.Encode(value)
+	if err != nil {
+		return err
+	}
+	if attr.Vendor == 0 {
+		p.Add(radius.Type(attr.Type), data)
+		return nil
+	}
+	vsa, err := d.GetVendor(attr.Vendor).encode(attr.Type, data)
+	if err != nil {
+		return fmt.Errorf("attribute %s %s", attr.Name, err.Error())
+	}
+	p.Add(rfc2865.VendorSpecific_Type, vsa)
+	return nil
+}
+
+// Set
+// Replace the attributes of name in p with value
+func (d *Dictionary) Set(p *radius.Packet, name, value string) error {
+	attr, ok := d.Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown attribute %s", name)
+	}
+	d.Del(p, attr)
+	return d.Add(p, name, value)
+}
+
+// Del
+// Remove the attributes of attr from p, a Vendor-Specific attribute carrying other
+// sub attributes of the vendor is kept without the removed ones.
+func (d *Dictionary) Del(p *radius.Packet, attr *Attribute) {
+	if attr.Vendor == 0 {
+		p.Del(radius.Type(attr.Type))
+		return
+	}
+	vendor := d.GetVendor(attr.Vendor)
+	var attrs []*radius.AVP
+	for _, avp := range p.Attributes {
+		if avp.Type != rfc2865.VendorSpecific_Type {
+			attrs = append(attrs, avp)
+			continue
+		}
+		vendorId, value, err := radius.VendorSpecific(avp.Attribute)
+		if err != nil || vendorId != attr.Vendor {
+			attrs = append(attrs, avp)
+			continue
+		}
+		subs, err := vendor.decode(value)
+		if err != nil {
+			attrs = append(attrs, avp)
+			continue
+		}
+		var kept []VendorAttribute
+		for _, sub := range subs {
+			if sub.Type != attr.Type {
+				kept = append(kept, sub)
+			}
+		}
+		if len(kept) == len(subs) {
+			attrs = append(attrs, avp)
+			continue
+		}
+		for _, sub := range kept {
+			if vsa, err := vendor.encode(sub.Type, sub.Value); err == nil {
+				attrs = append(attrs, &radius.AVP{Type: rfc2865.VendorSpecific_Type, Attribute: vsa})
+			}
+		}
+	}
+	p.Attributes = attrs
+}
+
+// VendorAttribute
+// A sub attribute of Vendor-Specific
+type VendorAttribute struct {
+	Type  uint32
+	Value radius.Attribute
+}
+
+// DecodeVendorSpecific
+// The vendor id and sub attributes of a Vendor-Specific attribute
+func (d *Dictionary) DecodeVendorSpecific(attr radius.Attribute) (uint32, []VendorAttribute, error) {
+	vendorId, value, err := radius.VendorSpecific(attr)
+	if err != nil {
+		return 0, nil, err
+	}
+	subs, err := d.GetVendor(vendorId).decode(value)
+	return vendorId, subs, err
+}
+
+func (v *Vendor) encode(typ uint32, data radius.Attribute) (radius.Attribute, error) {
+	hlen := v.TypeOctets + v.LengthOctets
+	size := hlen + len(data)
+	// the Vendor-Specific value is at most 253 octets with the 4 octets vendor id
+	if size > 249 {
+		return nil, errors.New("value too long")
+	}
+	value := make(radius.Attribute, size)
+	putUint(value[:v.TypeOctets], typ)
+	putUint(value[v.TypeOctets:hlen], uint32(size))
+	copy(value[hlen:], data)
+	return radius.NewVendorSpecific(v.Number, value)
+}
+
+func (v *Vendor) decode(value radius.Attribute) ([]VendorAttribute, error) {
+	hlen := v.TypeOctets + v.LengthOctets
+	var subs []VendorAttribute
+	for len(value) > 0 {
+		if len(value) < hlen {
+			return nil, errors.New("invalid vendor attribute length")
+		}
+		typ := getUint(value[:v.TypeOctets])
+		size := len(value)
+		if v.LengthOctets > 0 {
+			size = int(getUint(value[v.TypeOctets:hlen]))
+		}
+		if size < hlen || size > len(value) {
+			return nil, errors.New("invalid vendor attribute length")
+		}
+		subs = append(subs, VendorAttribute{Type: typ, Value: value[hlen:size]})
+		value = value[size:]
+	}
+	return subs, nil
+}
+
+func putUint(b []byte, v uint32) {
+	switch len(b) {
+	case 1:
+		b[0] = byte(v)
+	case 2:
+		binary.BigEndian.PutUint16(b, uint16(v))
+	case 4:
+		binary.BigEndian.PutUint32(b, v)
+	}
+}
+
+func getUint(b []byte) uint32 {
+	switch len(b) {
+	case 1:
+		return uint32(b[0])
+	case 2:
+		return uint32(binary.BigEndian.Uint16(b))
+	case 4:
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// Encode
+// Encode the text value by the attribute data type, integer values may be
+// the VALUE names, octets may be hex with the 0x prefix.
+func (a *Attribute) Encode(value string) (radius.Attribute, error) {
+	if a.Encrypt != 0 || a.HasTag {
+		return nil, fmt.Errorf("attribute %s encrypted or tagged value is not supported", a.Name)
+	}
+	value = strings.TrimSpace(value)
+	switch a.DataType {
+	case dict.AttributeString:
+		return radius.NewString(value)
+	case dict.AttributeOctets, dict.AttributeABinary:
+		if strings.HasPrefix(value, "0x") {
+			b, err := hex.DecodeString(value[2:])
+			if err != nil {
+				return nil, fmt.Errorf("attribute %s invalid hex value %s", a.Name, value)
+			}
+			return radius.NewBytes(b)
+		}
+		return radius.NewBytes([]byte(value))
+	case dict.AttributeIPAddr:
+		ip := net.ParseIP(value)
+		if ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("attribute %s invalid ipv4 address %s", a.Name, value)
+		}
+		return radius.NewIPAddr(ip)
+	case dict.AttributeIPv6Addr:
+		ip := net.ParseIP(value)
+		if ip == nil || ip.To4() != nil {
+			return nil, fmt.Errorf("attribute %s invalid ipv6 address %s", a.Name, value)
+		}
+		return radius.NewIPv6Addr(ip)
+	case dict.AttributeIPv6Prefix:
+		ip, ipnet, err := net.ParseCIDR(value)
+		if err != nil || ip.To4() != nil {
+			return nil, fmt.Errorf("attribute %s invalid ipv6 prefix %s", a.Name, value)
+		}
+		return radius.NewIPv6Prefix(ipnet)
+	case dict.AttributeIFID:
+		id, err := net.ParseMAC(value)
+		if err != nil || len(id) != 8 {
+			return nil, fmt.Errorf("attribute %s invalid interface id %s", a.Name, value)
+		}
+		return radius.NewIFID(id)
+	case dict.AttributeEther:
+		mac, err := net.ParseMAC(value)
+		if err != nil || len(mac) != 6 {
+			return nil, fmt.Errorf("attribute %s invalid mac address %s", a.Name, value)
+		}
+		return radius.NewBytes(mac)
+	case dict.AttributeDate:
+		v, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s invalid date %s", a.Name, value)
+		}
+		return radius.NewDate(time.Unix(int64(v), 0))
+	case dict.AttributeInteger64:
+		v, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s invalid integer %s", a.Name, value)
+		}
+		return radius.NewInteger64(v), nil
+	case dict.AttributeInteger, dict.AttributeByte, dict.AttributeShort, dict.AttributeSigned:
+		v, err := a.parseInteger(value)
+		if err != nil {
+			return nil, err
+		}
+		switch a.DataType {
+		case dict.AttributeByte:
+			return radius.Attribute{byte(v)}, nil
+		case dict.AttributeShort:
+			return radius.NewShort(uint16(v)), nil
+		}
+		return radius.NewInteger(v), nil
+	}
+	return nil, fmt.Errorf("attribute %s type %s is not supported", a.Name, a.DataType)
+}
+
+func (a *Attribute) parseInteger(value string) (uint32, error) {
+	if v, ok := a.values[strings.ToLower(value)]; ok {
+		return v, nil
+	}
+	bits := 32
+	switch a.DataType {
+	case dict.AttributeByte:
+		bits = 8
+	case dict.AttributeShort:
+		bits = 16
+	case dict.AttributeSigned:
+		v, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("attribute %s invalid integer %s", a.Name, value)
+		}
+		return uint32(v), nil
+	}
+	v, err := strconv.ParseUint(value, 10, bits)
+	if err != nil {
+		return 0, fmt.Errorf("attribute %s invalid integer %s", a.Name, value)
+	}
+	return uint32(v), nil
+}
+
+// Format
+// The text value of data, integers are shown as the VALUE names if defined,
+// values not matching the data type are shown as hex.
+func (a *Attribute) Format(data radius.Attribute) string {
+	if a.Encrypt != 0 {
+		return hex.EncodeToString(data)
+	}
+	switch a.DataType {
+	case dict.AttributeString:
+		// tag of the tunnel attributes (RFC 2868)
+		if a.HasTag && len(data) > 0 && data[0] <= 0x1f {
+			data = data[1:]
+		}
+		return radius.String(data)
+	case dict.AttributeIPAddr:
+		if ip, err := radius.IPAddr(data); err == nil {
+			return ip.String()
+		}
+	case dict.AttributeIPv6Addr:
+		if ip, err := radius.IPv6Addr(data); err == nil {
+			return ip.String()
+		}
+	case dict.AttributeIPv6Prefix:
+		if prefix, err := radius.IPv6Prefix(data); err == nil {
+			return prefix.String()
+		}
+	case dict.AttributeIFID:
+		if id, err := radius.IFID(data); err == nil {
+			return id.String()
+		}
+	case dict.AttributeEther:
+		if len(data) == 6 {
+			return net.HardwareAddr(data).String()
+		}
+	case dict.AttributeDate:
+		if t, err := radius.Date(data); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	case dict.AttributeInteger64:
+		if v, err := radius.Integer64(data); err == nil {
+			return strconv.FormatUint(v, 10)
+		}
+	case dict.AttributeInteger, dict.AttributeSigned:
+		if a.HasTag && len(data) == 4 {
+			data = append(radius.Attribute{0}, data[1:]...)
+		}
+		if v, err := radius.Integer(data); err == nil {
+			if name, ok := a.names[v]; ok {
+				return name
+			}
+			if a.DataType == dict.AttributeSigned {
+				return strconv.Itoa(int(int32(v)))
+			}
+			return strconv.FormatUint(uint64(v), 10)
+		}
+	case dict.AttributeShort:
+		if len(data) == 2 {
+			return strconv.Itoa(int(binary.BigEndian.Uint16(data)))
+		}
+	case dict.AttributeByte:
+		if len(data) == 1 {
+			return strconv.Itoa(int(data[0]))
+		}
+	}
+	return hex.EncodeToString(data)
+}
+
+// AttributeValue
+// A formatted attribute of a packet
+type AttributeValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// FormatAttributes
+// The attributes of p by name, Vendor-Specific attributes are decoded by the vendor dictionary,
+// unknown attributes are named by the type and shown as hex.
+func (d *Dictionary) FormatAttributes(p *radius.Packet) []AttributeValue {
+	var values []AttributeValue
+	for _, avp := range p.Attributes {
+		if avp.Type != rfc2865.VendorSpecific_Type {
+			values = append(values, d.formatValue(0, uint32(avp.Type), avp.Attribute))
+			continue
+		}
+		values = append(values, d.formatVendorSpecific(avp.Attribute)...)
+	}
+	return values
+}
+
+// FormatVendorAttributes
+// The Vendor-Specific attributes of p only
+func (d *Dictionary) FormatVendorAttributes(p *radius.Packet) []AttributeValue {
+	var values []AttributeValue
+	for _, avp := range p.Attributes {
+		if avp.Type == rfc2865.VendorSpecific_Type {
+			values = append(values, d.formatVendorSpecific(avp.Attribute)...)
+		}
+	}
+	return values
+}
+
+func (d *Dictionary) formatVendorSpecific(attr radius.Attribute) []AttributeValue {
+	vendorId, subs, err := d.DecodeVendorSpecific(attr)
+	if err != nil {
+		return []AttributeValue{{Name: "Vendor-Specific", Value: hex.EncodeToString(attr)}}
+	}
+	values := make([]AttributeValue, 0, len(subs))
+	for _, sub := range subs {
+		values = append(values, d.formatValue(vendorId, sub.Type, sub.Value))
+	}
+	return values
+}
+
+func (d *Dictionary) formatValue(vendor, typ uint32, data radius.Attribute) AttributeValue {
+	if attr, ok := d.LookupType(vendor, typ); ok {
+		return AttributeValue{Name: attr.Name, Value: attr.Format(data)}
+	}
+	name := "Attr-" + strconv.Itoa(int(typ))
+	if vendor != 0 {
+		name = fmt.Sprintf("Vendor-%d-Attr-%d", vendor, typ)
+	}
+	return AttributeValue{Name: name, Value: hex.EncodeToString(data)}
+}
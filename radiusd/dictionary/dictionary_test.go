@@ -0,0 +1,153 @@
+package dictionary
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+
+	"github.com/ca17/teamsacs/radiusd/vendors/huawei"
+)
+
+func TestBuiltin(t *testing.T) {
+	d := Builtin()
+	attr, ok := d.Lookup("huawei-input-average-rate")
+	if !ok || attr.Vendor != 2011 || attr.Type != 2 {
+		t.Fatalf("Huawei-Input-Average-Rate %+v", attr)
+	}
+	if attr, ok = d.LookupType(0, 6); !ok || attr.Name != "Service-Type" {
+		t.Fatalf("Service-Type %+v", attr)
+	}
+}
+
+func TestAddAndSet(t *testing.T) {
+	d := Builtin()
+	p := radius.New(radius.CodeAccessAccept, []byte("secret"))
+	if err := d.Add(p, "Service-Type", "Framed-User"); err != nil {
+		t.Fatal(err)
+	}
+	if v := rfc2865.ServiceType_Get(p); v != rfc2865.ServiceType_Value_FramedUser {
+		t.Errorf("Service-Type %d", v)
+	}
+	if err := d.Add(p, "Huawei-Input-Average-Rate", "1000"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Add(p, "Huawei-Domain-Name", "isp"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set(p, "Huawei-Input-Average-Rate", "2000"); err != nil {
+		t.Fatal(err)
+	}
+	rates, _ := huawei.HuaweiInputAverageRate_Gets(p)
+	if len(rates) != 1 || rates[0] != 2000 {
+		t.Errorf("Huawei-Input-Average-Rate %v", rates)
+	}
+	if v := huawei.HuaweiDomainName_GetString(p); v != "isp" {
+		t.Errorf("Huawei-Domain-Name %s", v)
+	}
+
+	for _, tt := range []struct{ name, value string }{
+		{"Framed-IP-Address", "2001:db8::1"},
+		{"Session-Timeout", "abc"},
+		{"Unknown-Attr", "1"},
+		{"User-Password", "secret"},
+	} {
+		if err := d.Add(p, tt.name, tt.value); err == nil {
+			t.Errorf("%s=%s added", tt.name, tt.value)
+		}
+	}
+}
+
+func TestParseVendorFormat(t *testing.T) {
+	d := New()
+	err := d.Parse("test", strings.NewReader(`
+VENDOR		Test	9999	format=2,1
+BEGIN-VENDOR	Test
+ATTRIBUTE	Test-Rate	300	integer
+VALUE	Test-Rate	High	3
+END-VENDOR	Test
+`))
+	if err != nil || len(d.Warnings) > 0 {
+		t.Fatal(err, d.Warnings)
+	}
+	p := radius.New(radius.CodeAccessAccept, []byte("secret"))
+	if err = d.Add(p, "Test-Rate", "high"); err != nil {
+		t.Fatal(err)
+	}
+	values := d.FormatAttributes(p)
+	if len(values) != 1 || values[0].Name != "Test-Rate" || values[0].Value != "High" {
+		t.Errorf("formatted %+v", values)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dictionary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"dictionary": `
+$INCLUDE	dictionary.acme
+$INCLUDE-	dictionary.missing
+ATTRIBUTE	Acme-Old-Style	10	string	Acme
+BEGIN-TLV	Acme-Tlv
+ATTRIBUTE	Acme-Tlv-Child	11.1	string
+`,
+		"dictionary.acme": `
+VENDOR		Acme	55555
+BEGIN-VENDOR	Acme
+VALUE	Acme-Level	Gold	2
+ATTRIBUTE	Acme-Level	1	uint32
+ATTRIBUTE	Acme-Pool	2	string
+ATTRIBUTE	Acme-Box	3	struct
+ATTRIBUTE	Acme-Time	4	time_delta
+END-VENDOR	Acme
+UNKNOWN-LINE	x
+`,
+		"other.txt": "not a dictionary",
+	}
+	for name, text := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := d.Stats()
+	if len(stats.Files) != 2 {
+		t.Errorf("files %v", stats.Files)
+	}
+	// unsupported type and unknown line
+	if len(stats.Warnings) != 2 {
+		t.Errorf("warnings %v", stats.Warnings)
+	}
+	if attr, ok := d.Lookup("Acme-Old-Style"); !ok || attr.Vendor != 55555 {
+		t.Errorf("Acme-Old-Style %+v", attr)
+	}
+	if _, ok := d.Lookup("Huawei-Domain-Name"); !ok {
+		t.Error("builtin attributes not loaded")
+	}
+
+	p := radius.New(radius.CodeAccountingRequest, []byte("secret"))
+	if err = d.Add(p, "Acme-Level", "Gold"); err != nil {
+		t.Fatal(err)
+	}
+	if err = d.Add(p, "Acme-Pool", "pool1"); err != nil {
+		t.Fatal(err)
+	}
+	values := d.FormatAttributes(p)
+	if len(values) != 2 || values[0].Value != "Gold" || values[1].Name != "Acme-Pool" || values[1].Value != "pool1" {
+		t.Errorf("formatted %+v", values)
+	}
+	// not loaded by the builtin dictionary
+	if values = Builtin().FormatAttributes(p); values[0].Name != "Vendor-55555-Attr-1" {
+		t.Errorf("builtin formatted %+v", values)
+	}
+}
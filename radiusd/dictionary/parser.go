@@ -0,0 +1,307 @@
+package dictionary
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	dict "layeh.com/radius/dictionary"
+)
+
+const maxIncludeDepth = 8
+
+// FreeRADIUS v3 and v4 type names, types not listed are not supported
+var attributeTypes = map[string]dict.AttributeType{
+	"string":     dict.AttributeString,
+	"octets":     dict.AttributeOctets,
+	"ipaddr":     dict.AttributeIPAddr,
+	"date":       dict.AttributeDate,
+	"integer":    dict.AttributeInteger,
+	"uint32":     dict.AttributeInteger,
+	"ipv6addr":   dict.AttributeIPv6Addr,
+	"ipv6prefix": dict.AttributeIPv6Prefix,
+	"ifid":       dict.AttributeIFID,
+	"integer64":  dict.AttributeInteger64,
+	"uint64":     dict.AttributeInteger64,
+	"ether":      dict.AttributeEther,
+	"abinary":    dict.AttributeABinary,
+	"byte":       dict.AttributeByte,
+	"uint8":      dict.AttributeByte,
+	"short":      dict.AttributeShort,
+	"uint16":     dict.AttributeShort,
+	"signed":     dict.AttributeSigned,
+	"int32":      dict.AttributeSigned,
+	"ipv4prefix": dict.AttributeIPv4Prefix,
+}
+
+// lines of the FreeRADIUS dictionary format not used by radiusd
+var ignoredKeywords = map[string]bool{
+	"BEGIN-TLV":      true,
+	"END-TLV":        true,
+	"PROTOCOL":       true,
+	"BEGIN-PROTOCOL": true,
+	"END-PROTOCOL":   true,
+	"FLAGS":          true,
+	"STRUCT":         true,
+	"MEMBER":         true,
+	"ENUM":           true,
+	"DEFINE":         true,
+	"ALIAS":          true,
+}
+
+type pendingValue struct {
+	attr, name string
+	number     uint32
+	pos        string
+}
+
+// parser
+// FreeRADIUS dictionary parser, lines not understood are recorded as warnings and skipped
+// so one unsupported definition does not prevent the others from loading.
+type parser struct {
+	dict   *Dictionary
+	parsed map[string]bool
+	values []pendingValue
+}
+
+func newParser(d *Dictionary) *parser {
+	return &parser{dict: d, parsed: make(map[string]bool)}
+}
+
+func (p *parser) warnf(pos string, format string, args ...interface{}) {
+	p.dict.Warnings = append(p.dict.Warnings, pos+": "+fmt.Sprintf(format, args...))
+}
+
+// finish
+// VALUE lines may come before the ATTRIBUTE, they are resolved after all files are parsed
+func (p *parser) finish() {
+	for _, v := range p.values {
+		if !p.dict.addValue(v.attr, v.name, v.number) {
+			p.warnf(v.pos, "VALUE of unknown attribute %s", v.attr)
+		}
+	}
+	p.values = nil
+}
+
+func (p *parser) parseFile(filename string, depth int) error {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+	if p.parsed[abs] {
+		return nil
+	}
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("%s include depth over %d", filename, maxIncludeDepth)
+	}
+	f, err := os.Open(abs)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	p.parsed[abs] = true
+	p.dict.Files = append(p.dict.Files, abs)
+	return p.parse(abs, f, depth)
+}
+
+func (p *parser) parse(name string, r io.Reader, depth int) error {
+	var vendor *Vendor
+	scanner := bufio.NewScanner(r)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		pos := fmt.Sprintf("%s:%d", name, lineno)
+		switch fields[0] {
+		case "$INCLUDE", "$INCLUDE-":
+			if len(fields) != 2 || !filepath.IsAbs(name) {
+				p.warnf(pos, "invalid include")
+				continue
+			}
+			inc := fields[1]
+			if !filepath.IsAbs(inc) {
+				inc = filepath.Join(filepath.Dir(name), inc)
+			}
+			if err := p.parseFile(inc, depth+1); err != nil {
+				if fields[0] == "$INCLUDE-" && os.IsNotExist(err) {
+					continue
+				}
+				p.warnf(pos, "include %s error, %s", fields[1], err.Error())
+			}
+		case "VENDOR":
+			p.parseVendor(pos, fields)
+		case "BEGIN-VENDOR":
+			if len(fields) < 2 {
+				p.warnf(pos, "invalid BEGIN-VENDOR")
+				continue
+			}
+			v, ok := p.dict.vendorNames[strings.ToLower(fields[1])]
+			if !ok {
+				p.warnf(pos, "unknown vendor %s", fields[1])
+				continue
+			}
+			vendor = v
+		case "END-VENDOR":
+			vendor = nil
+		case "ATTRIBUTE":
+			p.parseAttribute(pos, fields, vendor)
+		case "VALUE":
+			if len(fields) != 4 {
+				p.warnf(pos, "invalid VALUE")
+				continue
+			}
+			number, err := strconv.ParseUint(fields[3], 0, 32)
+			if err != nil {
+				p.warnf(pos, "invalid VALUE number %s", fields[3])
+				continue
+			}
+			p.values = append(p.values, pendingValue{attr: fields[1], name: fields[2], number: uint32(number), pos: pos})
+		default:
+			if !ignoredKeywords[fields[0]] {
+				p.warnf(pos, "unknown line %s", fields[0])
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// VENDOR name number [format=t,l]
+func (p *parser) parseVendor(pos string, fields []string) {
+	if len(fields) < 3 {
+		p.warnf(pos, "invalid VENDOR")
+		return
+	}
+	number, err := strconv.ParseUint(fields[2], 0, 32)
+	if err != nil {
+		p.warnf(pos, "invalid VENDOR number %s", fields[2])
+		return
+	}
+	vendor := &Vendor{Name: fields[1], Number: uint32(number), TypeOctets: 1, LengthOctets: 1}
+	if len(fields) > 3 && strings.HasPrefix(fields[3], "format=") {
+		format := strings.Split(strings.TrimPrefix(fields[3], "format="), ",")
+		if len(format) < 2 {
+			p.warnf(pos, "invalid VENDOR format %s", fields[3])
+			return
+		}
+		t, terr := strconv.Atoi(format[0])
+		l, lerr := strconv.Atoi(format[1])
+		if terr != nil || lerr != nil || (t != 1 && t != 2 && t != 4) || l < 0 || l > 2 {
+			p.warnf(pos, "invalid VENDOR format %s", fields[3])
+			return
+		}
+		vendor.TypeOctets, vendor.LengthOctets = t, l
+	}
+	p.dict.addVendor(vendor)
+}
+
+// ATTRIBUTE name number type [flags] [vendor]
+func (p *parser) parseAttribute(pos string, fields []string, vendor *Vendor) {
+	if len(fields) < 4 {
+		p.warnf(pos, "invalid ATTRIBUTE")
+		return
+	}
+	// nested tlv and extended attributes
+	if strings.Contains(fields[2], ".") {
+		return
+	}
+	number, err := strconv.ParseUint(fields[2], 0, 32)
+	if err != nil {
+		p.warnf(pos, "invalid ATTRIBUTE number %s", fields[2])
+		return
+	}
+	typename := strings.ToLower(fields[3])
+	if strings.HasPrefix(typename, "octets[") {
+		typename = "octets"
+	}
+	dataType, ok := attributeTypes[typename]
+	if !ok {
+		// vsa, tlv, struct, extended types are containers
+		switch typename {
+		case "vsa", "tlv", "struct", "extended", "long-extended", "evs", "group":
+		default:
+			p.warnf(pos, "ATTRIBUTE %s unsupported type %s", fields[1], fields[3])
+		}
+		return
+	}
+	attr := &Attribute{Name: fields[1], Type: uint32(number), DataType: dataType}
+	if vendor != nil {
+		attr.Vendor = vendor.Number
+	}
+	for _, field := range fields[4:] {
+		if !strings.Contains(field, "=") && field != "has_tag" && field != "concat" && !strings.Contains(field, ",") {
+			// old style vendor name after the type
+			v, ok := p.dict.vendorNames[strings.ToLower(field)]
+			if !ok {
+				p.warnf(pos, "ATTRIBUTE %s unknown vendor %s", fields[1], field)
+				return
+			}
+			attr.Vendor = v.Number
+			continue
+		}
+		for _, flag := range strings.Split(field, ",") {
+			switch {
+			case flag == "has_tag":
+				attr.HasTag = true
+			case strings.HasPrefix(flag, "encrypt="):
+				attr.Encrypt, _ = strconv.Atoi(strings.TrimPrefix(flag, "encrypt="))
+			}
+		}
+	}
+	p.dict.addAttribute(attr)
+}
+
+// Parse
+// Parse dictionary text into d, $INCLUDE is not supported
+func (d *Dictionary) Parse(name string, r io.Reader) error {
+	p := newParser(d)
+	err := p.parse(name, r, 0)
+	p.finish()
+	return err
+}
+
+// ParseFile
+// Parse the dictionary file into d, $INCLUDE paths are relative to the file
+func (d *Dictionary) ParseFile(filename string) error {
+	p := newParser(d)
+	err := p.parseFile(filename, 0)
+	p.finish()
+	return err
+}
+
+// LoadDir
+// The builtin dictionary extended with the files named dictionary or dictionary.* in dir,
+// attributes defined by the files override the builtin ones of the same name or code.
+func LoadDir(dir string) (*Dictionary, error) {
+	d := newBuiltin()
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return d, nil
+		}
+		return nil, err
+	}
+	p := newParser(d)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || (name != "dictionary" && !strings.HasPrefix(name, "dictionary.")) {
+			continue
+		}
+		if err = p.parseFile(filepath.Join(dir, name), 0); err != nil {
+			return nil, err
+		}
+	}
+	p.finish()
+	return d, nil
+}
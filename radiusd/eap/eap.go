@@ -0,0 +1,164 @@
+package eap
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2869"
+)
+
+// EAP (RFC 3748) over RADIUS (RFC 3579)
+
+const (
+	CodeRequest  uint8 = 1
+	CodeResponse uint8 = 2
+	CodeSuccess  uint8 = 3
+	CodeFailure  uint8 = 4
+
+	TypeIdentity     uint8 = 1
+	TypeNotification uint8 = 2
+	TypeNak          uint8 = 3
+	TypeMD5          uint8 = 4
+	TypeTLS          uint8 = 13
+	TypePEAP         uint8 = 25
+	TypeMSCHAPv2     uint8 = 26
+	TypeTLV          uint8 = 33
+
+	// EAP-Message attributes are limited to 253 bytes each
+	maxAttributeLength = 253
+)
+
+type Packet struct {
+	Code       uint8
+	Identifier uint8
+	Type       uint8
+	Data       []byte
+}
+
+// Parse
+// Decode an EAP packet, Success and Failure packets have no type field.
+func Parse(b []byte) (*Packet, error) {
+	if len(b) < 4 {
+		return nil, errors.New("eap packet too short")
+	}
+	length := int(binary.BigEndian.Uint16(b[2:4]))
+	if length < 4 || length > len(b) {
+		return nil, fmt.Errorf("eap packet length %d invalid", length)
+	}
+	p := &Packet{Code: b[0], Identifier: b[1]}
+	if (p.Code == CodeRequest || p.Code == CodeResponse) && length > 4 {
+		p.Type = b[4]
+		p.Data = append([]byte{}, b[5:length]...)
+	}
+	return p, nil
+}
+
+func (p *Packet) Encode() []byte {
+	length := 4
+	if p.Code == CodeRequest || p.Code == CodeResponse {
+		length += 1 + len(p.Data)
+	}
+	b := make([]byte, length)
+	b[0] = p.Code
+	b[1] = p.Identifier
+	binary.BigEndian.PutUint16(b[2:4], uint16(length))
+	if length > 4 {
+		b[4] = p.Type
+		copy(b[5:], p.Data)
+	}
+	return b
+}
+
+func NewRequest(identifier, eaptype uint8, data []byte) *Packet {
+	return &Packet{Code: CodeRequest, Identifier: identifier, Type: eaptype, Data: data}
+}
+
+func NewSuccess(identifier uint8) *Packet {
+	return &Packet{Code: CodeSuccess, Identifier: identifier}
+}
+
+func NewFailure(identifier uint8) *Packet {
+	return &Packet{Code: CodeFailure, Identifier: identifier}
+}
+
+// GetEapMessage
+// Concatenate all EAP-Message attributes, returns nil if the request is not EAP.
+func GetEapMessage(p *radius.Packet) []byte {
+	var msg []byte
+	for _, avp := range p.Attributes {
+		if avp.Type == rfc2869.EAPMessage_Type {
+			msg = append(msg, avp.Attribute...)
+		}
+	}
+	return msg
+}
+
+// SetEapMessage
+// Split the EAP packet into multiple EAP-Message attributes.
+func SetEapMessage(p *radius.Packet, eapPacket *Packet) {
+	p.Del(rfc2869.EAPMessage_Type)
+	b := eapPacket.Encode()
+	for len(b) > 0 {
+		n := len(b)
+		if n > maxAttributeLength {
+			n = maxAttributeLength
+		}
+		p.Add(rfc2869.EAPMessage_Type, radius.Attribute(b[:n]))
+		b = b[n:]
+	}
+}
+
+// computeMessageAuthenticator
+// HMAC-MD5 of the whole packet with the Message-Authenticator zeroed,
+// the authenticator field is the Request Authenticator for both requests and responses.
+func computeMessageAuthenticator(p *radius.Packet) ([]byte, error) {
+	q := *p
+	q.Attributes = make(radius.Attributes, 0, len(p.Attributes))
+	for _, avp := range p.Attributes {
+		if avp.Type == rfc2869.MessageAuthenticator_Type {
+			q.Attributes = append(q.Attributes, &radius.AVP{Type: avp.Type, Attribute: make(radius.Attribute, 16)})
+		} else {
+			q.Attributes = append(q.Attributes, avp)
+		}
+	}
+	b, err := q.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	hash := hmac.New(md5.New, p.Secret)
+	hash.Write(b)
+	return hash.Sum(nil), nil
+}
+
+// VerifyMessageAuthenticator
+// RFC 3579, Access-Request with EAP-Message must have a valid Message-Authenticator.
+func VerifyMessageAuthenticator(p *radius.Packet) error {
+	value, ok := p.Lookup(rfc2869.MessageAuthenticator_Type)
+	if !ok {
+		return errors.New("message authenticator is missing")
+	}
+	expect, err := computeMessageAuthenticator(p)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(value, expect) {
+		return errors.New("message authenticator is invalid")
+	}
+	return nil
+}
+
+// SetMessageAuthenticator
+// Must be called after all other attributes are set, right before the response is written.
+func SetMessageAuthenticator(p *radius.Packet) error {
+	p.Set(rfc2869.MessageAuthenticator_Type, make(radius.Attribute, 16))
+	value, err := computeMessageAuthenticator(p)
+	if err != nil {
+		return err
+	}
+	p.Set(rfc2869.MessageAuthenticator_Type, value)
+	return nil
+}
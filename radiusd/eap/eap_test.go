@@ -0,0 +1,161 @@
+package eap
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+)
+
+func TestPacketEncode(t *testing.T) {
+	p := NewRequest(7, TypeMD5, []byte{1, 2, 3})
+	q, err := Parse(p.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Identifier != 7 || q.Type != TypeMD5 || !bytes.Equal(q.Data, []byte{1, 2, 3}) {
+		t.Fatalf("decode error %+v", q)
+	}
+	if len(NewSuccess(7).Encode()) != 4 {
+		t.Fatal("success packet must be 4 bytes")
+	}
+}
+
+func TestEapMessageSplit(t *testing.T) {
+	packet := radius.New(radius.CodeAccessChallenge, []byte("secret"))
+	p := NewRequest(1, TypePEAP, make([]byte, 600))
+	SetEapMessage(packet, p)
+	if len(packet.Attributes) != 3 {
+		t.Fatalf("eap message must be split into 3 attributes, got %d", len(packet.Attributes))
+	}
+	if !bytes.Equal(GetEapMessage(packet), p.Encode()) {
+		t.Fatal("eap message not match")
+	}
+}
+
+func TestMessageAuthenticator(t *testing.T) {
+	packet := radius.New(radius.CodeAccessRequest, []byte("secret"))
+	_ = rfc2865.UserName_SetString(packet, "tim")
+	SetEapMessage(packet, &Packet{Code: CodeResponse, Identifier: 1, Type: TypeIdentity, Data: []byte("tim")})
+	if err := SetMessageAuthenticator(packet); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := packet.Encode()
+	received, _ := radius.Parse(b, []byte("secret"))
+	if err := VerifyMessageAuthenticator(received); err != nil {
+		t.Fatal(err)
+	}
+	received.Secret = []byte("other")
+	if err := VerifyMessageAuthenticator(received); err == nil {
+		t.Fatal("wrong secret must fail")
+	}
+}
+
+func testCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "teamsacs"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestTlsSessionHandshake(t *testing.T) {
+	session := NewTlsSession(&tls.Config{
+		Certificates: []tls.Certificate{testCertificate(t)},
+		MaxVersion:   tls.VersionTLS12,
+	})
+	defer session.Close()
+
+	// the tls client runs in its own goroutine over channels
+	toServer := make(chan []byte)
+	toClient := make(chan []byte)
+	cliend, srvend := net.Pipe()
+	client := tls.Client(cliend, &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12})
+	go func() {
+		buf := make([]byte, 16384)
+		for {
+			n, err := srvend.Read(buf)
+			if err != nil {
+				return
+			}
+			toServer <- append([]byte{}, buf[:n]...)
+		}
+	}()
+	go func() {
+		for b := range toClient {
+			_, _ = srvend.Write(b)
+		}
+	}()
+	go func() {
+		if err := client.Handshake(); err != nil {
+			t.Error(err)
+			return
+		}
+		_, _ = client.Write([]byte("hello"))
+	}()
+
+	var appdata []byte
+	for i := 0; i < 10 && appdata == nil; i++ {
+		var records []byte
+		select {
+		case records = <-toServer:
+		case <-time.After(time.Second * 5):
+			t.Fatal("client timeout")
+		}
+		output, data, err := session.Exchange(records)
+		if err != nil {
+			t.Fatal(err)
+		}
+		appdata = data
+		if len(output) > 0 {
+			toClient <- output
+		}
+	}
+	if !session.Handshake || string(appdata) != "hello" {
+		t.Fatalf("handshake %v, appdata %q", session.Handshake, appdata)
+	}
+	if _, _, err := session.MppeKeys(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTlsFragment(t *testing.T) {
+	session := NewTlsSession(&tls.Config{})
+	message := make([]byte, 2500)
+	_, _ = rand.Read(message)
+	session.Send(message)
+
+	receiver := NewTlsSession(&tls.Config{})
+	var complete bool
+	for session.HasPending() {
+		frag := session.NextFragment(0)
+		var err error
+		complete, err = receiver.Receive(frag)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !complete || !bytes.Equal(receiver.TakeInput(), message) {
+		t.Fatal("fragment reassembly error")
+	}
+}
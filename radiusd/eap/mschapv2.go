@@ -0,0 +1,105 @@
+package eap
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// EAP-MSCHAPv2 (draft-kamath-pppext-eap-mschapv2) and
+// the PEAP Result TLV, used as PEAPv0 inner method.
+
+const (
+	MsChapOpChallenge = 1
+	MsChapOpResponse  = 2
+	MsChapOpSuccess   = 3
+	MsChapOpFailure   = 4
+
+	TlvResultSuccess = 1
+	TlvResultFailure = 2
+)
+
+type MsChapResponse struct {
+	Identifier    uint8
+	PeerChallenge []byte
+	NtResponse    []byte
+	Flags         uint8
+	Name          string
+}
+
+func msChapPacket(opcode, identifier uint8, value []byte) []byte {
+	b := make([]byte, 4+len(value))
+	b[0] = opcode
+	b[1] = identifier
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+	copy(b[4:], value)
+	return b
+}
+
+// NewMsChapChallenge
+// Challenge request data, value size is always 16.
+func NewMsChapChallenge(identifier uint8, challenge []byte, name string) []byte {
+	value := append([]byte{byte(len(challenge))}, challenge...)
+	value = append(value, []byte(name)...)
+	return msChapPacket(MsChapOpChallenge, identifier, value)
+}
+
+func NewMsChapSuccess(identifier uint8, message string) []byte {
+	return msChapPacket(MsChapOpSuccess, identifier, []byte(message))
+}
+
+func NewMsChapFailure(identifier uint8, message string) []byte {
+	return msChapPacket(MsChapOpFailure, identifier, []byte(message))
+}
+
+func ParseMsChapResponse(data []byte) (*MsChapResponse, error) {
+	if len(data) < 5+49 || data[0] != MsChapOpResponse || data[4] != 49 {
+		return nil, errors.New("eap mschapv2 response invalid")
+	}
+	value := data[5:54]
+	return &MsChapResponse{
+		Identifier:    data[1],
+		PeerChallenge: value[0:16],
+		NtResponse:    value[24:48],
+		Flags:         value[48],
+		Name:          string(data[54:]),
+	}, nil
+}
+
+// RadiusResponse
+// Same layout as the MS-CHAP2-Response RADIUS attribute (RFC 2548), so the
+// RADIUS MSCHAPv2 check can be reused.
+func (r *MsChapResponse) RadiusResponse() []byte {
+	b := make([]byte, 50)
+	b[0] = r.Identifier
+	b[1] = r.Flags
+	copy(b[2:18], r.PeerChallenge)
+	copy(b[26:50], r.NtResponse)
+	return b
+}
+
+// NewTlvResult
+// A full EAP packet carrying the Result TLV.
+func NewTlvResult(identifier uint8, result uint16) *Packet {
+	data := make([]byte, 6)
+	binary.BigEndian.PutUint16(data[0:2], 0x8003)
+	binary.BigEndian.PutUint16(data[2:4], 2)
+	binary.BigEndian.PutUint16(data[4:6], result)
+	return NewRequest(identifier, TypeTLV, data)
+}
+
+// ParseTlvResult
+// Returns the result value of a Result TLV.
+func ParseTlvResult(data []byte) (uint16, error) {
+	for len(data) >= 4 {
+		tlvType := binary.BigEndian.Uint16(data[0:2]) & 0x3fff
+		tlvLen := int(binary.BigEndian.Uint16(data[2:4]))
+		if len(data) < 4+tlvLen {
+			break
+		}
+		if tlvType == 3 && tlvLen == 2 {
+			return binary.BigEndian.Uint16(data[4:6]), nil
+		}
+		data = data[4+tlvLen:]
+	}
+	return 0, errors.New("eap result tlv not found")
+}
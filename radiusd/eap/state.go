@@ -0,0 +1,122 @@
+package eap
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// State
+// The EAP conversation of one supplicant, carried between Access-Challenge
+// round trips by the RADIUS State attribute.
+type State struct {
+	Key        string
+	Username   string
+	Method     uint8
+	Identifier uint8
+	Stage      int
+	Challenge  []byte
+	Tls        *TlsSession
+	// the last request sent and the response it answered, resent when the
+	// NAS repeats the response because the Access-Challenge was lost
+	Request  *Packet
+	Response []byte
+	expire   time.Time
+}
+
+// IsRetransmit
+// The response is the one answered by the last request.
+func (s *State) IsRetransmit(identifier uint8, response []byte) bool {
+	return s.Request != nil && identifier == s.Identifier-1 && bytes.Equal(response, s.Response)
+}
+
+// NextIdentifier
+// Each new request of the conversation uses a new identifier.
+func (s *State) NextIdentifier() uint8 {
+	s.Identifier++
+	return s.Identifier
+}
+
+func (s *State) Close() {
+	if s.Tls != nil {
+		s.Tls.Close()
+	}
+}
+
+// StateCache
+// Per-session state cache keyed by the State attribute,
+// abandoned conversations are released after ttl.
+type StateCache struct {
+	ttl    time.Duration
+	lock   sync.Mutex
+	states map[string]*State
+}
+
+func NewStateCache(ttl time.Duration) *StateCache {
+	c := &StateCache{ttl: ttl, states: make(map[string]*State)}
+	go c.cleanLoop()
+	return c
+}
+
+// NewState
+// Create a conversation with a random State value.
+func (c *StateCache) NewState(username string, method uint8) *State {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	// start identifier from a random value to avoid replays across sessions
+	return &State{Key: hex.EncodeToString(b), Username: username, Method: method, Identifier: b[0]}
+}
+
+func (c *StateCache) Put(state *State) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	state.expire = time.Now().Add(c.ttl)
+	c.states[state.Key] = state
+}
+
+func (c *StateCache) Get(key string) *State {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	state, ok := c.states[key]
+	if !ok || state.expire.Before(time.Now()) {
+		return nil
+	}
+	return state
+}
+
+// Remove
+// Remove and release the conversation once it is finished.
+func (c *StateCache) Remove(key string) {
+	c.lock.Lock()
+	state, ok := c.states[key]
+	delete(c.states, key)
+	c.lock.Unlock()
+	if ok {
+		state.Close()
+	}
+}
+
+func (c *StateCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.states)
+}
+
+func (c *StateCache) cleanLoop() {
+	for range time.Tick(c.ttl) {
+		var expired []*State
+		c.lock.Lock()
+		for key, state := range c.states {
+			if state.expire.Before(time.Now()) {
+				expired = append(expired, state)
+				delete(c.states, key)
+			}
+		}
+		c.lock.Unlock()
+		for _, state := range expired {
+			state.Close()
+		}
+	}
+}
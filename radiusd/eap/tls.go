@@ -0,0 +1,274 @@
+package eap
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// TLS based EAP methods (EAP-TLS RFC 5216, PEAP)
+// crypto/tls runs on an in-memory connection, every Access-Challenge round trip
+// feeds the received TLS records into it and sends back what it has written.
+
+const (
+	TlsFlagLength = 0x80
+	TlsFlagMore   = 0x40
+	TlsFlagStart  = 0x20
+
+	// fragment size keeps the whole RADIUS packet below common path MTU
+	tlsFragmentSize = 1000
+	tlsStepTimeout  = time.Second * 5
+
+	// RFC 5216, used by EAP-TLS and PEAPv0 to derive the MPPE keys
+	keyingMaterialLabel = "client EAP encryption"
+)
+
+type tlsEvent struct {
+	handshake bool
+	wantRead  bool
+	data      []byte
+	err       error
+}
+
+// memConn
+// net.Conn used by crypto/tls, Read blocks until the next EAP round trip supplies data.
+type memConn struct {
+	session *TlsSession
+	pending []byte
+	out     bytes.Buffer
+	lock    sync.Mutex
+}
+
+func (c *memConn) Read(b []byte) (int, error) {
+	if len(c.pending) == 0 {
+		if !c.session.emit(tlsEvent{wantRead: true}) {
+			return 0, io.EOF
+		}
+		select {
+		case data := <-c.session.input:
+			c.pending = data
+		case <-c.session.closed:
+			return 0, io.EOF
+		}
+	}
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *memConn) Write(b []byte) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.out.Write(b)
+}
+
+func (c *memConn) takeOutput() []byte {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	b := append([]byte{}, c.out.Bytes()...)
+	c.out.Reset()
+	return b
+}
+
+func (c *memConn) Close() error                       { return nil }
+func (c *memConn) LocalAddr() net.Addr                { return &net.IPAddr{} }
+func (c *memConn) RemoteAddr() net.Addr               { return &net.IPAddr{} }
+func (c *memConn) SetDeadline(t time.Time) error      { return nil }
+func (c *memConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *memConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type TlsSession struct {
+	conn      *tls.Conn
+	mem       *memConn
+	input     chan []byte
+	events    chan tlsEvent
+	closed    chan struct{}
+	closeOnce sync.Once
+	started   bool
+	waiting   bool
+
+	// Handshake is true once the TLS handshake has finished
+	Handshake bool
+
+	// fragment reassembly and sending
+	inbuf  []byte
+	outbuf []byte
+	outlen int
+}
+
+func NewTlsSession(config *tls.Config) *TlsSession {
+	s := &TlsSession{
+		input:  make(chan []byte),
+		events: make(chan tlsEvent),
+		closed: make(chan struct{}),
+	}
+	s.mem = &memConn{session: s}
+	s.conn = tls.Server(s.mem, config)
+	return s
+}
+
+func (s *TlsSession) emit(ev tlsEvent) bool {
+	select {
+	case s.events <- ev:
+		return true
+	case <-s.closed:
+		return false
+	}
+}
+
+func (s *TlsSession) run() {
+	err := s.conn.Handshake()
+	if !s.emit(tlsEvent{handshake: true, err: err}) || err != nil {
+		return
+	}
+	buf := make([]byte, 4096)
+	for {
+		n, err := s.conn.Read(buf)
+		if err != nil {
+			s.emit(tlsEvent{err: err})
+			return
+		}
+		if !s.emit(tlsEvent{data: append([]byte{}, buf[:n]...)}) {
+			return
+		}
+	}
+}
+
+// Exchange
+// Feed received TLS records, returns the records to send back and any decrypted application data.
+func (s *TlsSession) Exchange(records []byte) (output []byte, appdata []byte, err error) {
+	if !s.started {
+		s.started = true
+		go s.run()
+	}
+	pushed := false
+	if s.waiting {
+		s.input <- records
+		s.waiting = false
+		pushed = true
+	}
+	timeout := time.After(tlsStepTimeout)
+	for {
+		select {
+		case ev := <-s.events:
+			switch {
+			case ev.err != nil:
+				return nil, nil, ev.err
+			case ev.handshake:
+				s.Handshake = true
+			case ev.data != nil:
+				appdata = append(appdata, ev.data...)
+			case ev.wantRead:
+				if !pushed {
+					s.input <- records
+					pushed = true
+					continue
+				}
+				s.waiting = true
+				return s.mem.takeOutput(), appdata, nil
+			}
+		case <-timeout:
+			return nil, nil, errors.New("eap tls session timeout")
+		}
+	}
+}
+
+// WriteApp
+// Encrypt application data (PEAP phase 2), returns the TLS records to send.
+func (s *TlsSession) WriteApp(data []byte) ([]byte, error) {
+	if _, err := s.conn.Write(data); err != nil {
+		return nil, err
+	}
+	return s.mem.takeOutput(), nil
+}
+
+func (s *TlsSession) PeerCertificates() []*x509.Certificate {
+	return s.conn.ConnectionState().PeerCertificates
+}
+
+// MppeKeys
+// MS-MPPE-Recv-Key and MS-MPPE-Send-Key derived from the TLS master secret.
+func (s *TlsSession) MppeKeys() (recvKey, sendKey []byte, err error) {
+	state := s.conn.ConnectionState()
+	material, err := state.ExportKeyingMaterial(keyingMaterialLabel, nil, 128)
+	if err != nil {
+		return nil, nil, err
+	}
+	return material[0:32], material[32:64], nil
+}
+
+// Receive
+// Reassemble fragmented EAP-TLS data, returns true when a complete message is buffered.
+func (s *TlsSession) Receive(data []byte) (bool, error) {
+	if len(data) == 0 {
+		return false, errors.New("eap tls data is empty")
+	}
+	flags := data[0]
+	data = data[1:]
+	if flags&TlsFlagLength != 0 {
+		if len(data) < 4 {
+			return false, errors.New("eap tls length field invalid")
+		}
+		data = data[4:]
+	}
+	s.inbuf = append(s.inbuf, data...)
+	return flags&TlsFlagMore == 0, nil
+}
+
+// TakeInput
+// The reassembled message, the buffer is reset for the next message.
+func (s *TlsSession) TakeInput() []byte {
+	b := s.inbuf
+	s.inbuf = nil
+	return b
+}
+
+// IsAck
+// An empty response acknowledges a fragment or the final server flight.
+func IsAck(data []byte) bool {
+	return len(data) == 1 && data[0]&(TlsFlagLength|TlsFlagMore) == 0
+}
+
+// Send
+// Queue a message to be sent in fragments.
+func (s *TlsSession) Send(b []byte) {
+	s.outbuf = b
+	s.outlen = len(b)
+}
+
+func (s *TlsSession) HasPending() bool {
+	return len(s.outbuf) > 0
+}
+
+// NextFragment
+// Build the next EAP-TLS request payload, version is the PEAP version for PEAP and 0 for EAP-TLS.
+func (s *TlsSession) NextFragment(version uint8) []byte {
+	var flags = version
+	var header []byte
+	n := len(s.outbuf)
+	if s.outlen > tlsFragmentSize && n == s.outlen {
+		flags |= TlsFlagLength
+		header = make([]byte, 4)
+		binary.BigEndian.PutUint32(header, uint32(s.outlen))
+	}
+	if n > tlsFragmentSize {
+		n = tlsFragmentSize
+		flags |= TlsFlagMore
+	}
+	frag := append([]byte{flags}, header...)
+	frag = append(frag, s.outbuf[:n]...)
+	s.outbuf = s.outbuf[n:]
+	return frag
+}
+
+func (s *TlsSession) Close() {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+	})
+}
@@ -0,0 +1,51 @@
+package radiusd
+
+import (
+	"net"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+	"layeh.com/radius/rfc2869"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/constant"
+	"github.com/ca17/teamsacs/models"
+	"github.com/ca17/teamsacs/radiusd/radlog"
+	"github.com/ca17/teamsacs/radiusd/radparser"
+)
+
+// AllocateFramedIp
+// The addr_pool of the user is a pool managed by TeamsACS, an address is leased
+// and sent as Framed-IP-Address instead of Framed-Pool and the vendor pool attributes.
+// Pools unknown to TeamsACS are left to the NAS, static ipaddr takes precedence.
+func (s *AuthService) AllocateFramedIp(user *models.Subscribe, vr *radparser.VendorRequest, vpe *models.Vpe, accept *radius.Packet) error {
+	if common.IsNotEmptyAndNA(user.GetIpaddr()) {
+		return nil
+	}
+	poolname := user.GetAddrPool()
+	if common.IsEmptyOrNA(poolname) {
+		return nil
+	}
+	im := s.Manager.GetIpamManager()
+	pool, err := im.GetIpPool(poolname)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if pool.Status != constant.ENABLED {
+		return nil
+	}
+	lease, err := im.AllocateLease(pool, user.GetUsername(), vpe.GetIpaddr(), vr.Macaddr)
+	if err != nil {
+		return err
+	}
+	radlog.Infof("user:%s lease %s from ip pool %s", user.GetUsername(), lease.Ipaddr, pool.Name)
+	rfc2869.FramedPool_Del(accept)
+	if mapping, _, err := s.GetVendorMapping(vpe.GetVendorCode()); err == nil {
+		mapping.DelAddrPoolAttributes(accept)
+	}
+	return rfc2865.FramedIPAddress_Set(accept, net.ParseIP(lease.Ipaddr))
+}
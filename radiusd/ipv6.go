@@ -0,0 +1,27 @@
+package radiusd
+
+import (
+	"layeh.com/radius"
+	"layeh.com/radius/rfc3162"
+	"layeh.com/radius/rfc4818"
+	"layeh.com/radius/rfc6911"
+
+	"github.com/ca17/teamsacs/models"
+)
+
+// setAccountingIpv6
+// IPv6 attributes of Accounting-Request, RFC 3162, RFC 4818 and RFC 6911
+func setAccountingIpv6(p *radius.Packet, online *models.Accounting) {
+	if prefix, err := rfc3162.FramedIPv6Prefix_Lookup(p); err == nil && prefix != nil {
+		online.FramedIpv6Prefix = prefix.String()
+	}
+	if ifid, err := rfc3162.FramedInterfaceID_Lookup(p); err == nil && len(ifid) > 0 {
+		online.FramedInterfaceId = ifid.String()
+	}
+	if addr, err := rfc6911.FramedIPv6Address_Lookup(p); err == nil && addr != nil {
+		online.FramedIpv6Address = addr.String()
+	}
+	if prefix, err := rfc4818.DelegatedIPv6Prefix_Lookup(p); err == nil && prefix != nil {
+		online.DelegatedIpv6Prefix = prefix.String()
+	}
+}
@@ -0,0 +1,65 @@
+package radiusd
+
+import (
+	"errors"
+)
+
+// Access-Reject reasons of the metrics
+const (
+	RejectReasonNas          = "nas_unauthorized"
+	RejectReasonUsername     = "username_empty"
+	RejectReasonUserNotExist = "user_not_exists"
+	RejectReasonUserDisabled = "user_disabled"
+	RejectReasonUserExpire   = "user_expire"
+	RejectReasonPassword     = "password_error"
+	RejectReasonMfa          = "mfa_error"
+	RejectReasonEap          = "eap_error"
+	RejectReasonBind         = "bind_not_match"
+	RejectReasonOnlineLimit  = "online_limit"
+	RejectReasonQuota        = "quota_exhausted"
+	RejectReasonSchedule     = "schedule"
+	RejectReasonLockout      = "lockout"
+	RejectReasonProxy        = "proxy"
+	RejectReasonIpPool       = "ip_pool_error"
+	RejectReasonVoucher      = "voucher_error"
+	RejectReasonOther        = "other"
+)
+
+// RejectError
+// An authentication error with the reject reason of the metrics, the messages contain
+// user names and can not be labels
+type RejectError struct {
+	Reason string
+	Err    error
+}
+
+func (e *RejectError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RejectError) Unwrap() error {
+	return e.Err
+}
+
+// NewRejectError
+// Wrap err with the reject reason, nil if err is nil, the reason of an error already wrapped is kept
+func NewRejectError(reason string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var rerr *RejectError
+	if errors.As(err, &rerr) {
+		return err
+	}
+	return &RejectError{Reason: reason, Err: err}
+}
+
+// RejectReason
+// The metrics label of the reject error
+func RejectReason(err error) string {
+	var rerr *RejectError
+	if errors.As(err, &rerr) {
+		return rerr.Reason
+	}
+	return RejectReasonOther
+}
@@ -0,0 +1,36 @@
+package radiusd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+func TestRejectReason(t *testing.T) {
+	disabled := NewRejectError(RejectReasonUserDisabled, fmt.Errorf("user:%s status is disabled", "tim"))
+	tests := []struct {
+		err    error
+		reason string
+	}{
+		{NewRejectError(RejectReasonNas, fmt.Errorf("Unauthorized access to device, Ip=%s", "10.0.0.1")), RejectReasonNas},
+		{NewRejectError(RejectReasonPassword, fmt.Errorf("user:%s pap password is not match", "tim")), RejectReasonPassword},
+		{pkgerrors.WithStack(NewRejectError(RejectReasonQuota, errors.New("traffic quota exhausted"))), RejectReasonQuota},
+		// the reason of the service error is kept by the auth check
+		{NewRejectError(RejectReasonUserNotExist, disabled), RejectReasonUserDisabled},
+		// the reason is not guessed from the message
+		{fmt.Errorf("user:%s mfa code is not match", "tim"), RejectReasonOther},
+	}
+	for _, tt := range tests {
+		if reason := RejectReason(tt.err); reason != tt.reason {
+			t.Errorf("%s reason %s, expect %s", tt.err, reason, tt.reason)
+		}
+	}
+	if NewRejectError(RejectReasonOther, nil) != nil {
+		t.Error("nil error is wrapped")
+	}
+	if err := NewRejectError(RejectReasonMfa, errors.New("mfa code is not match")); err.Error() != "mfa code is not match" {
+		t.Errorf("error message %s", err.Error())
+	}
+}
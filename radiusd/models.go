@@ -3,16 +3,20 @@ package radiusd
 import "time"
 
 type AuthorizationProfile struct {
-	ExpireTime      time.Time
-	InterimInterval int
-	AddrPool        string
-	Ipaddr          string
-	UpRateKbps      int
-	DownRateKbps    int
-	Domain          string
-	LimitPolicy     string
-	UpLimitPolicy   string
-	DownLimitPolicy string
+	ExpireTime          time.Time
+	InterimInterval     int
+	AddrPool            string
+	Ipaddr              string
+	Ipv6AddrPool        string
+	Ipv6Prefix          string
+	Ipv6InterfaceId     string
+	DelegatedIpv6Prefix string
+	UpRateKbps          int
+	DownRateKbps        int
+	Domain              string
+	LimitPolicy         string
+	UpLimitPolicy       string
+	DownLimitPolicy     string
 }
 
 func (a AuthorizationProfile) GetExpireTime() time.Time {
@@ -31,6 +35,22 @@ func (a AuthorizationProfile) GetIpaddr() string {
 	return a.Ipaddr
 }
 
+func (a AuthorizationProfile) GetIpv6AddrPool() string {
+	return a.Ipv6AddrPool
+}
+
+func (a AuthorizationProfile) GetIpv6Prefix() string {
+	return a.Ipv6Prefix
+}
+
+func (a AuthorizationProfile) GetIpv6InterfaceId() string {
+	return a.Ipv6InterfaceId
+}
+
+func (a AuthorizationProfile) GetDelegatedIpv6Prefix() string {
+	return a.DelegatedIpv6Prefix
+}
+
 func (a AuthorizationProfile) GetUpRateKbps() int {
 	return a.UpRateKbps
 }
@@ -0,0 +1,89 @@
+package radiusd
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/ca17/teamsacs/models"
+)
+
+const (
+	NasCacheUnknownTTL = time.Second * 10
+	nasCacheCleanSize  = 1024
+)
+
+// NasCache
+// Unknown NAS addresses cache, known devices are cached by models and invalidated on change,
+// unknown addresses are kept here so forged packets do not reach mongodb on every request.
+type NasCache struct {
+	ttl   time.Duration
+	lock  sync.RWMutex
+	items map[string]time.Time
+}
+
+func NewNasCache(ttl time.Duration) *NasCache {
+	return &NasCache{ttl: ttl, items: make(map[string]time.Time)}
+}
+
+// IsUnknown
+// Whether ip is a recently looked up unknown NAS
+func (c *NasCache) IsUnknown(ip string) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	expire, ok := c.items[ip]
+	return ok && expire.After(time.Now())
+}
+
+// PutUnknown
+// Expired items are removed here, the service is also created per NBI request,
+// so no background goroutine is started.
+func (c *NasCache) PutUnknown(ip string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now()
+	if len(c.items) >= nasCacheCleanSize {
+		for k, expire := range c.items {
+			if expire.Before(now) {
+				delete(c.items, k)
+			}
+		}
+	}
+	c.items[ip] = now.Add(c.ttl)
+}
+
+// Lookup
+// Find the NAS by ip, a not found ip is cached as unknown and refused without find until it expires
+func (c *NasCache) Lookup(ip string, find func(ip string) (*models.Vpe, error)) (*models.Vpe, error) {
+	if c.IsUnknown(ip) {
+		return nil, fmt.Errorf("Unauthorized access to device, Ip=%s", ip)
+	}
+	vpe, err := find(ip)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.PutUnknown(ip)
+			return nil, fmt.Errorf("Unauthorized access to device, Ip=%s", ip)
+		}
+		return nil, err
+	}
+	return vpe, nil
+}
+
+// addrIp
+// The ip part of a udp/tcp remote address
+func addrIp(addr net.Addr) string {
+	switch v := addr.(type) {
+	case *net.UDPAddr:
+		return v.IP.String()
+	case *net.TCPAddr:
+		return v.IP.String()
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
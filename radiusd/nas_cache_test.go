@@ -0,0 +1,48 @@
+package radiusd
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	cmap "github.com/orcaman/concurrent-map"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/ca17/teamsacs/models"
+)
+
+func TestNasCacheLookup(t *testing.T) {
+	c := NewNasCache(NasCacheUnknownTTL)
+	finds := 0
+	find := func(ip string) (*models.Vpe, error) {
+		finds++
+		return nil, mongo.ErrNoDocuments
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := c.Lookup("10.0.0.9", find); err == nil {
+			t.Fatal("unknown ip must be refused")
+		}
+	}
+	if finds != 1 || !c.IsUnknown("10.0.0.9") {
+		t.Fatalf("unknown ip must be cached, finds %d", finds)
+	}
+	if c.IsUnknown("10.0.0.10") {
+		t.Fatal("other ip is not unknown")
+	}
+}
+
+func TestRADIUSSecret(t *testing.T) {
+	m := &models.ModelManager{ManagerMap: cmap.New(), Cache: models.NewCacheManager(models.CacheDefaultTTL)}
+	m.ManagerMap.Set("VpeManager", &models.VpeManager{ModelManager: m})
+	m.Cache.Vpe.Set("ip:10.0.0.1", "1", models.Vpe{"_id": "1", "ipaddr": "10.0.0.1", "secret": "testing123"})
+	s := NewRadiusService(m)
+
+	secret, err := s.RADIUSSecret(context.TODO(), &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1812})
+	if err != nil || string(secret) != "testing123" {
+		t.Fatalf("known nas secret %s, %v", secret, err)
+	}
+	s.NasCache.PutUnknown("10.0.0.9")
+	if _, err = s.RADIUSSecret(context.TODO(), &net.UDPAddr{IP: net.ParseIP("10.0.0.9"), Port: 1812}); err == nil {
+		t.Fatal("unknown nas must be refused")
+	}
+}
@@ -0,0 +1,223 @@
+package radiusd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+	"layeh.com/radius/rfc2869"
+
+	"github.com/ca17/teamsacs/constant"
+	"github.com/ca17/teamsacs/models"
+	"github.com/ca17/teamsacs/radiusd/eap"
+	"github.com/ca17/teamsacs/radiusd/radlog"
+	"github.com/ca17/teamsacs/radiusd/vendors/microsoft"
+)
+
+const (
+	ProxyDefaultTimeout  = 5
+	ProxyDefaultAuthPort = 1812
+	ProxyDefaultAcctPort = 1813
+
+	// a failed upstream is tried after the others within this time
+	proxyUpstreamDownTime = time.Second * 30
+
+	vendorMicrosoft   = 311
+	msMppeSendKeyType = 16
+	msMppeRecvKeyType = 17
+)
+
+// upstream address => down until
+var proxyUpstreamDown sync.Map
+
+// ParseUserRealm
+// Split user@realm, realm is empty if the username has no realm
+func ParseUserRealm(username string) (user, realm string) {
+	i := strings.LastIndex(username, "@")
+	if i <= 0 || i == len(username)-1 {
+		return username, ""
+	}
+	return username[:i], strings.ToLower(username[i+1:])
+}
+
+// GetProxyRealm
+// The enabled realm of username, nil if the request is served locally
+func (s *RadiusService) GetProxyRealm(username string) *models.Realm {
+	_, name := ParseUserRealm(username)
+	if name == "" {
+		return nil
+	}
+	realm, err := s.Manager.GetRealmManager().GetRealm(name)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			radlog.Errorf("query realm %s error, %s", name, err.Error())
+		}
+		return nil
+	}
+	if realm.Status != constant.ENABLED {
+		return nil
+	}
+	return realm
+}
+
+// ProxyRequest
+// Forward the request to the upstreams of realm in order, returns the reply to relay back to the NAS.
+func (s *RadiusService) ProxyRequest(r *radius.Request, realm *models.Realm) (*radius.Packet, error) {
+	timeout := time.Duration(realm.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = ProxyDefaultTimeout * time.Second
+	}
+	var lastErr error
+	for _, upstream := range sortProxyUpstreams(realm.Upstreams, r.Code) {
+		addr := getProxyUpstreamAddr(upstream, r.Code)
+		request, err := newProxyRequest(r.Packet, realm, upstream.Secret)
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		response, err := radius.Exchange(ctx, request, addr)
+		cancel()
+		if err != nil {
+			radlog.Errorf("radius proxy realm %s to %s failure, %s", realm.Realm, addr, err.Error())
+			proxyUpstreamDown.Store(addr, time.Now().Add(proxyUpstreamDownTime))
+			lastErr = err
+			continue
+		}
+		proxyUpstreamDown.Delete(addr)
+		radlog.Infof("radius proxy realm %s to %s, reply %s", realm.Realm, addr, response.Code)
+		return newProxyReply(r, request, response)
+	}
+	if lastErr == nil {
+		return nil, fmt.Errorf("realm %s has no upstream", realm.Realm)
+	}
+	return nil, fmt.Errorf("realm %s all upstreams failure, %s", realm.Realm, lastErr.Error())
+}
+
+func getProxyUpstreamAddr(upstream models.RealmUpstream, code radius.Code) string {
+	if code == radius.CodeAccountingRequest {
+		if upstream.AcctPort == 0 {
+			return fmt.Sprintf("%s:%d", upstream.Addr, ProxyDefaultAcctPort)
+		}
+		return fmt.Sprintf("%s:%d", upstream.Addr, upstream.AcctPort)
+	}
+	if upstream.AuthPort == 0 {
+		return fmt.Sprintf("%s:%d", upstream.Addr, ProxyDefaultAuthPort)
+	}
+	return fmt.Sprintf("%s:%d", upstream.Addr, upstream.AuthPort)
+}
+
+// sortProxyUpstreams
+// Keep the configured order, upstreams failed recently are moved to the end
+func sortProxyUpstreams(upstreams []models.RealmUpstream, code radius.Code) []models.RealmUpstream {
+	var alive, down []models.RealmUpstream
+	now := time.Now()
+	for _, upstream := range upstreams {
+		if until, ok := proxyUpstreamDown.Load(getProxyUpstreamAddr(upstream, code)); ok && until.(time.Time).After(now) {
+			down = append(down, upstream)
+			continue
+		}
+		alive = append(alive, upstream)
+	}
+	return append(alive, down...)
+}
+
+// newProxyRequest
+// Copy the request with the upstream secret, the secret dependent attributes are encoded again
+func newProxyRequest(src *radius.Packet, realm *models.Realm, secret string) (*radius.Packet, error) {
+	request := radius.New(src.Code, []byte(secret))
+	for _, avp := range src.Attributes {
+		switch avp.Type {
+		case rfc2865.UserName_Type, rfc2865.UserPassword_Type, rfc2869.MessageAuthenticator_Type:
+			continue
+		}
+		request.Attributes = append(request.Attributes, &radius.AVP{Type: avp.Type, Attribute: avp.Attribute})
+	}
+
+	username := rfc2865.UserName_GetString(src)
+	if realm.StripRealm {
+		username, _ = ParseUserRealm(username)
+	}
+	if err := rfc2865.UserName_SetString(request, username); err != nil {
+		return nil, err
+	}
+	if password, err := rfc2865.UserPassword_Lookup(src); err == nil {
+		if err = rfc2865.UserPassword_Set(request, padUserPassword(password)); err != nil {
+			return nil, err
+		}
+	}
+	// CHAP without CHAP-Challenge uses the request authenticator as challenge
+	if _, ok := src.Lookup(rfc2865.CHAPPassword_Type); ok {
+		if _, ok := src.Lookup(rfc2865.CHAPChallenge_Type); !ok {
+			_ = rfc2865.CHAPChallenge_Set(request, src.Authenticator[:])
+		}
+	}
+	if _, ok := src.Lookup(rfc2869.MessageAuthenticator_Type); ok {
+		if err := eap.SetMessageAuthenticator(request); err != nil {
+			return nil, err
+		}
+	}
+	return request, nil
+}
+
+// newProxyReply
+// Copy the upstream response as the response of the NAS request,
+// MPPE keys are encrypted with the secret and authenticator and must be encoded again
+func newProxyReply(r *radius.Request, request, response *radius.Packet) (*radius.Packet, error) {
+	reply := r.Response(response.Code)
+
+	// the response attributes are encrypted with the upstream request authenticator
+	response.Secret = request.Secret
+	response.Authenticator = request.Authenticator
+	recvKey, recvErr := microsoft.MSMPPERecvKey_Lookup(response)
+	sendKey, sendErr := microsoft.MSMPPESendKey_Lookup(response)
+
+	var hasAuthenticator bool
+	for _, avp := range response.Attributes {
+		if avp.Type == rfc2869.MessageAuthenticator_Type {
+			hasAuthenticator = true
+			continue
+		}
+		if avp.Type == rfc2865.VendorSpecific_Type && isMppeKeyAttribute(avp.Attribute) {
+			continue
+		}
+		reply.Attributes = append(reply.Attributes, &radius.AVP{Type: avp.Type, Attribute: avp.Attribute})
+	}
+	if recvErr == nil {
+		_ = microsoft.MSMPPERecvKey_Add(reply, recvKey)
+	}
+	if sendErr == nil {
+		_ = microsoft.MSMPPESendKey_Add(reply, sendKey)
+	}
+	if hasAuthenticator {
+		if err := eap.SetMessageAuthenticator(reply); err != nil {
+			return nil, err
+		}
+	}
+	return reply, nil
+}
+
+func isMppeKeyAttribute(attr radius.Attribute) bool {
+	vendorId, value, err := radius.VendorSpecific(attr)
+	if err != nil || vendorId != vendorMicrosoft || len(value) == 0 {
+		return false
+	}
+	return value[0] == msMppeSendKeyType || value[0] == msMppeRecvKeyType
+}
+
+// padUserPassword
+// radius.NewUserPassword expects the plaintext padded to a multiple of 16 with nulls (RFC 2865 5.2),
+// the decoded password has the padding removed.
+func padUserPassword(password []byte) []byte {
+	n := (len(password) + 15) / 16 * 16
+	if n == 0 {
+		n = 16
+	}
+	padded := make([]byte, n)
+	copy(padded, password)
+	return padded
+}
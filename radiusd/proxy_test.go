@@ -0,0 +1,90 @@
+package radiusd
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/models"
+	"github.com/ca17/teamsacs/radiusd/vendors/microsoft"
+)
+
+func TestParseUserRealm(t *testing.T) {
+	tests := []struct {
+		username string
+		user     string
+		realm    string
+	}{
+		{"tim", "tim", ""},
+		{"tim@Partner.com", "tim", "partner.com"},
+		{"tim@a@partner", "tim@a", "partner"},
+		{"@partner", "@partner", ""},
+		{"tim@", "tim@", ""},
+	}
+	for _, tt := range tests {
+		user, realm := ParseUserRealm(tt.username)
+		if user != tt.user || realm != tt.realm {
+			t.Errorf("ParseUserRealm(%q) = %q, %q", tt.username, user, realm)
+		}
+	}
+}
+
+func TestProxyRequest(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	common.Must(err)
+	mppeKey := bytes.Repeat([]byte{7}, 32)
+	upstream := radius.PacketServer{
+		SecretSource: radius.StaticSecretSource([]byte("upstream")),
+		Handler: radius.HandlerFunc(func(w radius.ResponseWriter, r *radius.Request) {
+			code := radius.CodeAccessReject
+			if rfc2865.UserName_GetString(r.Packet) == "tim" && rfc2865.UserPassword_GetString(r.Packet) == "pass" {
+				code = radius.CodeAccessAccept
+			}
+			resp := r.Response(code)
+			_ = microsoft.MSMPPERecvKey_Add(resp, mppeKey)
+			_ = w.Write(resp)
+		}),
+	}
+	go upstream.Serve(conn)
+	defer conn.Close()
+
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	realm := &models.Realm{
+		Realm:      "partner",
+		StripRealm: true,
+		Timeout:    1,
+		Upstreams: []models.RealmUpstream{
+			// the first upstream is down, the request fails over to the second one
+			{Addr: "127.0.0.1", AuthPort: 9, Secret: "down"},
+			{Addr: "127.0.0.1", AuthPort: port, Secret: "upstream"},
+		},
+	}
+
+	packet := radius.New(radius.CodeAccessRequest, []byte("nassecret"))
+	common.Must(rfc2865.UserName_SetString(packet, "tim@partner"))
+	common.Must(rfc2865.UserPassword_Set(packet, padUserPassword([]byte("pass"))))
+	request := &radius.Request{Packet: packet}
+
+	s := &RadiusService{}
+	reply, err := s.ProxyRequest(request, realm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Code != radius.CodeAccessAccept {
+		t.Fatalf("reply code %s", reply.Code)
+	}
+	// the reply is encoded with the NAS secret
+	b, err := reply.Encode()
+	common.Must(err)
+	received, err := radius.Parse(b, []byte("nassecret"))
+	common.Must(err)
+	received.Authenticator = packet.Authenticator
+	key, err := microsoft.MSMPPERecvKey_Lookup(received)
+	if err != nil || !bytes.Equal(key[:32], mppeKey) {
+		t.Fatalf("mppe key not match %x, %v", key, err)
+	}
+}
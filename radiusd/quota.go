@@ -0,0 +1,92 @@
+package radiusd
+
+import (
+	"fmt"
+	"time"
+
+	"layeh.com/radius"
+
+	"github.com/ca17/teamsacs/models"
+	"github.com/ca17/teamsacs/radiusd/authorization"
+	"github.com/ca17/teamsacs/radiusd/radlog"
+	"github.com/ca17/teamsacs/radiusd/radparser"
+)
+
+// GetQuotaProfile
+// The authorization profile with the quota applied and the remaining seconds (-1 is unlimited),
+// an exhausted time quota or traffic quota with the disconnect action rejects the user.
+func (s *RadiusService) GetQuotaProfile(user *models.Subscribe) (authorization.Profile, int64, error) {
+	if !user.HasQuota() {
+		return user, -1, nil
+	}
+	usage, err := s.Manager.GetQuotaManager().GetQuotaUsage(user)
+	if err != nil {
+		return nil, 0, err
+	}
+	remainSeconds := usage.RemainSeconds(user)
+	if remainSeconds == 0 {
+		return nil, 0, fmt.Errorf("user:%s time quota exhausted", user.GetUsername())
+	}
+	if usage.RemainBytes(user) == 0 {
+		if user.GetQuotaAction() != models.QuotaActionThrottle {
+			return nil, 0, fmt.Errorf("user:%s traffic quota exhausted", user.GetUsername())
+		}
+		return getThrottleProfile(user), remainSeconds, nil
+	}
+	return user, remainSeconds, nil
+}
+
+func getThrottleProfile(user *models.Subscribe) *models.Subscribe {
+	profile := user.Copy()
+	upRate, downRate := user.GetQuotaThrottleRate()
+	(*profile)["up_rate"] = upRate
+	(*profile)["down_rate"] = downRate
+	return profile
+}
+
+// processAcctQuota
+// Accumulate the quota usage of interim and stop, the session is disconnected
+// or throttled once when the quota is exhausted.
+func (s *AcctService) processAcctQuota(r *radius.Request, vr *radparser.VendorRequest, user *models.Subscribe, vpe *models.Vpe, nasrip string, stop bool) {
+	if !user.HasQuota() {
+		return
+	}
+	username := user.GetUsername()
+	online := GetRadiusOnlineFromRequest(r, vr, vpe, nasrip)
+	sessionid := online.AcctSessionId
+
+	// the usage is read and saved again if changed by a concurrent request of the subscriber
+	var exhausted bool
+	usage, err := s.Manager.GetQuotaManager().ModifyQuotaUsage(user, func(usage *models.QuotaUsage) {
+		exhausted = false
+		if stop {
+			// a retransmitted stop or a stop of the session closed as stale is not counted again
+			usage.Stop(sessionid, online.AcctInputCounter, online.AcctOutputCounter, int64(online.AcctSessionTime), time.Now())
+			return
+		}
+		usage.Add(sessionid, online.AcctInputCounter, online.AcctOutputCounter, int64(online.AcctSessionTime))
+		if session := usage.Sessions[sessionid]; usage.IsExhausted(user) && !session.Exhausted {
+			session.Exhausted = true
+			usage.Sessions[sessionid] = session
+			exhausted = true
+		}
+	})
+	if err != nil {
+		radlog.Errorf("UpdateQuotaUsage user:%s error, %s", username, err.Error())
+		return
+	}
+
+	if exhausted {
+		// the accounting response is not delayed by the NAS round trip
+		go s.processQuotaExhausted(user, usage, sessionid, nasrip, vpe)
+	}
+}
+
+func (s *AcctService) processQuotaExhausted(user *models.Subscribe, usage *models.QuotaUsage, sessionid, nasrip string, vpe *models.Vpe) {
+	coaService := NewCoaService(s.RadiusService)
+	if usage.RemainSeconds(user) == 0 || user.GetQuotaAction() != models.QuotaActionThrottle {
+		coaService.Disconnect(user.GetUsername(), sessionid, nasrip, vpe, CoaOperatorSystem, "quota exhausted")
+		return
+	}
+	coaService.Coa(getThrottleProfile(user), sessionid, nasrip, vpe, CoaOperatorSystem, "traffic quota exhausted")
+}
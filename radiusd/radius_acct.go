@@ -3,11 +3,13 @@ package radiusd
 import (
 	"errors"
 	"strings"
+	"time"
 
 	"layeh.com/radius"
 	"layeh.com/radius/rfc2865"
 	"layeh.com/radius/rfc2866"
 
+	"github.com/ca17/teamsacs/common/metrics"
 	"github.com/ca17/teamsacs/radiusd/debug"
 	"github.com/ca17/teamsacs/radiusd/radlog"
 	"github.com/ca17/teamsacs/radiusd/radparser"
@@ -23,6 +25,12 @@ func NewAcctService(radiusService *RadiusService) *AcctService {
 }
 
 func (s *AcctService) ServeRADIUS(w radius.ResponseWriter, r *radius.Request) {
+	var start = time.Now()
+	var vendorCode string
+	var result = metrics.ResultDrop
+	defer func() {
+		metrics.ObserveRadiusAcct(start, vendorCode, rfc2866.AcctStatusType_Get(r.Packet).String(), result)
+	}()
 	defer func() {
 		if ret := recover(); ret != nil {
 			err, ok := ret.(error)
@@ -39,13 +47,12 @@ func (s *AcctService) ServeRADIUS(w radius.ResponseWriter, r *radius.Request) {
 	// NAS 接入检查
 	raddrstr := r.RemoteAddr.String()
 	nasrip := raddrstr[:strings.Index(raddrstr, ":")]
-	var identifier = rfc2865.NASIdentifier_GetString(r.Packet)
-	vpe, err := s.GetNas(nasrip, identifier)
+	vpe, err := s.GetRequestNas(r, nasrip)
 	radlog.CheckError(err)
+	vendorCode = vpe.GetVendorCode()
 
 	// 重新设置数据报文秘钥
-	r.Secret = []byte(vpe.GetSecret())
-	r.Packet.Secret = []byte(vpe.GetSecret())
+	s.SetupRequestSecret(r, vpe)
 
 	// 用户名检查
 	username := rfc2865.UserName_GetString(r.Packet)
@@ -53,7 +60,19 @@ func (s *AcctService) ServeRADIUS(w radius.ResponseWriter, r *radius.Request) {
 		radlog.CheckError(errors.New("username is empty"))
 	}
 
-	vendorReq := radparser.ParseVendor(r, vpe.GetVendorCode())
+	// 域代理, user@realm 的记账转发到域上游服务器
+	if realm := s.GetProxyRealm(username); realm != nil {
+		reply, err := s.ProxyRequest(r, realm)
+		radlog.CheckError(err)
+		radlog.Infof("Writing proxy %v to %v", reply.Code, r.RemoteAddr)
+		if err = w.Write(reply); err != nil {
+			radlog.Error(err)
+		}
+		result = metrics.ResultResponse
+		return
+	}
+
+	vendorReq := radparser.ParseVendor(r, vpe.GetVendorCode(), vpe.GetVlanFormat())
 
 	// 获取有效用户
 	user, err := s.GetUserForAcct(username)
@@ -62,11 +81,14 @@ func (s *AcctService) ServeRADIUS(w radius.ResponseWriter, r *radius.Request) {
 	statusType := rfc2866.AcctStatusType_Get(r.Packet)
 	switch statusType {
 	case rfc2866.AcctStatusType_Value_Start:
-		s.processAcctStart(r, vendorReq, user.GetUsername(), vpe, nasrip)
+		s.processAcctStart(r, vendorReq, user, vpe, nasrip)
 	case rfc2866.AcctStatusType_Value_InterimUpdate:
 		s.processAcctUpdateBefore(r, vendorReq, user, vpe, nasrip)
+		s.processAcctQuota(r, vendorReq, user, vpe, nasrip, false)
+		s.processAcctSchedule(r, vendorReq, user, vpe, nasrip)
 	case rfc2866.AcctStatusType_Value_Stop:
 		s.processAcctStop(r, vendorReq, user.GetUsername(), vpe, nasrip)
+		s.processAcctQuota(r, vendorReq, user, vpe, nasrip, true)
 	case rfc2866.AcctStatusType_Value_AccountingOn:
 		s.processAcctNasOn(r)
 	case rfc2866.AcctStatusType_Value_AccountingOff:
@@ -74,6 +96,7 @@ func (s *AcctService) ServeRADIUS(w radius.ResponseWriter, r *radius.Request) {
 	}
 
 	s.SendResponse(w, r)
+	result = metrics.ResultResponse
 }
 
 func (s *AcctService) SendResponse(w radius.ResponseWriter, r *radius.Request) {
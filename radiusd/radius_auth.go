@@ -1,16 +1,23 @@
 package radiusd
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
 	"strings"
+	"sync"
 	"time"
 
 	"layeh.com/radius"
 	"layeh.com/radius/rfc2865"
+	"layeh.com/radius/rfc2869"
 
+	"github.com/ca17/teamsacs/common/metrics"
+	"github.com/ca17/teamsacs/models"
 	"github.com/ca17/teamsacs/radiusd/authorization"
 	"github.com/ca17/teamsacs/radiusd/debug"
+	"github.com/ca17/teamsacs/radiusd/eap"
 	"github.com/ca17/teamsacs/radiusd/radlog"
 	"github.com/ca17/teamsacs/radiusd/radparser"
 )
@@ -18,21 +25,41 @@ import (
 // 认证服务
 type AuthService struct {
 	*RadiusService
+	EapStates    *eap.StateCache
+	MfaStates    *eap.StateCache
+	eapTlsOnce   sync.Once
+	eapTlsConfig *tls.Config
+	eapTlsErr    error
 }
 
 func NewAuthService(radiusService *RadiusService) *AuthService {
-	return &AuthService{RadiusService: radiusService}
+	return &AuthService{
+		RadiusService: radiusService,
+		EapStates:     eap.NewStateCache(time.Second * 60),
+		MfaStates:     eap.NewStateCache(time.Second * 60),
+	}
 }
 
 // RADIUS Auth
 func (s *AuthService) ServeRADIUS(w radius.ResponseWriter, r *radius.Request) {
 	var start = time.Now()
+	var vendorCode string
+	var result = metrics.ResultDrop
+	var reason string
+	defer func() {
+		metrics.ObserveRadiusAuth(start, vendorCode, result)
+		s.publishAuthEvent(r, vendorCode, result, reason)
+	}()
 	defer func() {
 		if ret := recover(); ret != nil {
 			err, ok := ret.(error)
 			if ok {
 				radlog.Error(err)
 				s.SendReject(w, r, err.Error())
+				s.AddLockoutFailure(start, r)
+				result = metrics.ResultReject
+				reason = err.Error()
+				metrics.RadiusRejects.WithLabelValues(RejectReason(err)).Inc()
 			}
 		}
 	}()
@@ -41,70 +68,189 @@ func (s *AuthService) ServeRADIUS(w radius.ResponseWriter, r *radius.Request) {
 		radlog.Info(debug.FmtRequest(r))
 	}
 
+	// Access-Request 的 Request Authenticator 为随机值, 携带 Message-Authenticator 时校验, 失败静默丢弃 (RFC 3579)
+	if _, ok := r.Packet.Lookup(rfc2869.MessageAuthenticator_Type); ok {
+		if err := eap.VerifyMessageAuthenticator(r.Packet); err != nil {
+			radlog.Errorf("radius request from %s dropped, %s", r.RemoteAddr, err.Error())
+			return
+		}
+	}
+
 	// nas access check
 	raddrstr := r.RemoteAddr.String()
 	ip := raddrstr[:strings.Index(raddrstr, ":")]
-	var identifier = rfc2865.NASIdentifier_GetString(r.Packet)
 	username := rfc2865.UserName_GetString(r.Packet)
 
 	// Username empty  check
 	if username == "" {
-		s.CheckRadAuthError(start, rfc2865.CallingStationID_GetString(r.Packet), ip, errors.New("username is empty of client mac"))
+		s.CheckRadAuthError(start, rfc2865.CallingStationID_GetString(r.Packet), ip, RejectReasonUsername, errors.New("username is empty of client mac"))
 	}
 
-	vpe, err := s.GetNas(ip, identifier)
-	s.CheckRadAuthError(start, username, ip, err)
+	vpe, err := s.GetRequestNas(r, ip)
+	s.CheckRadAuthError(start, username, ip, RejectReasonNas, err)
+	vendorCode = vpe.GetVendorCode()
 
 	//  setup new packet secret
-	r.Secret = []byte(vpe.GetSecret())
-	r.Packet.Secret = []byte(vpe.GetSecret())
+	s.SetupRequestSecret(r, vpe)
+
+	// brute force lockout, rejected without counting the failure again
+	if err = s.CheckLockout(username, GetCallingStationMac(r)); err != nil {
+		radlog.Error(err)
+		s.addAuthlog(start, username, ip, RadiusAuthLockout, err.Error())
+		s.SendReject(w, r, err.Error())
+		result = metrics.ResultReject
+		reason = err.Error()
+		metrics.RadiusRejects.WithLabelValues(RejectReasonLockout).Inc()
+		return
+	}
+
+	// realm proxy, user@realm is served by the upstreams of the realm
+	if realm := s.GetProxyRealm(username); realm != nil {
+		reply, err := s.ProxyRequest(r, realm)
+		s.CheckRadAuthError(start, username, ip, RejectReasonProxy, err)
+		s.SendProxyReply(w, r, reply)
+		switch reply.Code {
+		case radius.CodeAccessAccept:
+			s.ClearLockout(username, GetCallingStationMac(r))
+			s.LogAuthSucess(start, username, ip)
+			result = metrics.ResultAccept
+		case radius.CodeAccessReject:
+			s.AddLockoutFailure(start, r)
+			result = metrics.ResultReject
+			reason = "realm " + realm.Realm + " upstream reject"
+			metrics.RadiusRejects.WithLabelValues(RejectReasonProxy).Inc()
+		case radius.CodeAccessChallenge:
+			result = metrics.ResultChallenge
+		}
+		return
+	}
 	response := r.Response(radius.CodeAccessAccept)
 
-	vendorReq := radparser.ParseVendor(r, vpe.GetVendorCode())
+	vendorReq := radparser.ParseVendor(r, vpe.GetVendorCode(), vpe.GetVlanFormat())
 
 	// ----------------------------------------------------------------------------------------------------
 	// Fetch validate user
 	isMacAuth := vendorReq.Macaddr == username
 	user, err := s.GetUser(username, isMacAuth)
-	s.CheckRadAuthError(start, username, ip, err)
+	// first login of a prepaid voucher
+	var voucher *models.Voucher
+	if err != nil && !isMacAuth {
+		if vuser, v := s.GetVoucherUser(username); vuser != nil {
+			user, voucher, err = vuser, v, nil
+		}
+	}
+	s.CheckRadAuthError(start, username, ip, RejectReasonUserNotExist, err)
 
 	activeNum := user.GetActiveNum()
 	if !isMacAuth {
 		if activeNum != 0 {
 			onlineCount, _ := s.Manager.GetRadiusManager().GetOnlineCount(username)
 			if int(onlineCount) > activeNum {
-				s.CheckRadAuthError(start, username, ip, fmt.Errorf("user:%s active num over limit(max=%d)", username, activeNum))
+				s.CheckRadAuthError(start, username, ip, RejectReasonOnlineLimit, fmt.Errorf("user:%s active num over limit(max=%d)", username, activeNum))
 			}
 		}
 
-		s.CheckRadAuthError(start, username, ip, s.CheckOnlineCount(username, activeNum))
+		s.CheckRadAuthError(start, username, ip, RejectReasonOnlineLimit, s.CheckOnlineCount(username, activeNum))
 
 		// Username Mac bind check
-		s.CheckRadAuthError(start, username, ip, s.CheckMacBind(user, vendorReq))
+		s.CheckRadAuthError(start, username, ip, RejectReasonBind, s.CheckMacBind(user, vendorReq))
 
 		// Username vlanid check
-		s.CheckRadAuthError(start, username, ip, s.CheckVlanBind(user, vendorReq))
+		s.CheckRadAuthError(start, username, ip, RejectReasonBind, s.CheckVlanBind(user, vendorReq))
 	}
 
 	// Password check
-	// if mschapv2 auth, will set accept attribute
 	localpwd, err := s.GetLocalPassword(user, isMacAuth)
-	s.CheckRadAuthError(start, username, ip, err)
-	s.CheckRadAuthError(start, username, ip, s.CheckPassword(r, username, localpwd, response, isMacAuth))
+	s.CheckRadAuthError(start, username, ip, RejectReasonPassword, err)
+	if eap.GetEapMessage(r.Packet) != nil {
+		if s.IsMfaRequired(user, isMacAuth) {
+			s.CheckRadAuthError(start, username, ip, RejectReasonMfa, fmt.Errorf("user:%s mfa requires pap authentication", username))
+		}
+		// EAP auth, continue with Access-Challenge until the method finished
+		challenge, err := s.ServeEAP(r, user, localpwd, response)
+		s.CheckRadAuthError(start, username, ip, RejectReasonEap, err)
+		if challenge != nil {
+			s.SendChallenge(w, r, challenge)
+			result = metrics.ResultChallenge
+			return
+		}
+	} else if state := s.GetMfaState(r, username); state != nil {
+		// reply of the mfa prompt, the password has been checked in the first round
+		s.CheckRadAuthError(start, username, ip, RejectReasonMfa, s.CheckMfaChallenge(r, user, state))
+	} else if s.IsMfaRequired(user, isMacAuth) {
+		challenge, err := s.CheckMfaPassword(r, user, localpwd)
+		s.CheckRadAuthError(start, username, ip, RejectReasonMfa, err)
+		if challenge != nil {
+			s.SendChallenge(w, r, challenge)
+			result = metrics.ResultChallenge
+			return
+		}
+	} else {
+		// if mschapv2 auth, will set accept attribute
+		s.CheckRadAuthError(start, username, ip, RejectReasonPassword, s.CheckPassword(r, username, localpwd, response, isMacAuth))
+	}
+
+	// quota check, Session-Timeout is limited to the remaining time
+	profile, remainSeconds, err := s.GetQuotaProfile(user)
+	s.CheckRadAuthError(start, username, ip, RejectReasonQuota, err)
+
+	// login schedule, Session-Timeout is limited to the end of the window
+	scheduleSeconds, err := s.CheckSchedule(user)
+	s.CheckRadAuthError(start, username, ip, RejectReasonSchedule, err)
 
 	// setup accept
-	authorization.UpdateAuthorization(user, vpe.GetVendorCode(), response)
+	s.UpdateVendorAuthorization(profile, vpe.GetVendorCode(), response)
+	authorization.LimitSessionTimeout(response, remainSeconds)
+	authorization.LimitSessionTimeout(response, scheduleSeconds)
+
+	// lease the address of the managed pool
+	s.CheckRadAuthError(start, username, ip, RejectReasonIpPool, s.AllocateFramedIp(user, vendorReq, vpe, response))
+
+	// the voucher validity starts now and the subscriber is created, after every check that may reject,
+	// the address offered by a failed activation is reused by the retry or released by the offer timeout
+	if voucher != nil {
+		s.CheckRadAuthError(start, username, ip, RejectReasonVoucher, s.Manager.GetVoucherManager().ActivateVoucher(voucher, username))
+	}
 
 	// send accept
 	s.SendAccept(w, r, response)
+	result = metrics.ResultAccept
 	// update mac & vlan
 	s.UpdateBind(user, vendorReq)
 
+	s.ClearLockout(username, GetCallingStationMac(r))
 	s.LogAuthSucess(start, username, ip)
 }
 
+// publishAuthEvent
+// radius.auth.accept and radius.auth.reject events, challenges and dropped requests are not published
+func (s *AuthService) publishAuthEvent(r *radius.Request, vendorCode, result, reason string) {
+	var etype string
+	switch result {
+	case metrics.ResultAccept:
+		etype = models.EventAuthAccept
+	case metrics.ResultReject:
+		etype = models.EventAuthReject
+	default:
+		return
+	}
+	nasip, _, _ := net.SplitHostPort(r.RemoteAddr.String())
+	data := map[string]interface{}{
+		"username":    rfc2865.UserName_GetString(r.Packet),
+		"nas_addr":    nasip,
+		"nas_id":      rfc2865.NASIdentifier_GetString(r.Packet),
+		"vendor_code": vendorCode,
+		"mac_addr":    GetCallingStationMac(r),
+	}
+	if reason != "" {
+		data["reason"] = reason
+	}
+	s.Manager.PublishEvent(etype, data)
+}
+
 // send accept
 func (s *AuthService) SendAccept(w radius.ResponseWriter, r *radius.Request, resp *radius.Packet) {
+	s.setupEapResponse(resp)
 	radlog.Infof("Writing %v to %v", resp.Code, r.RemoteAddr)
 	if s.GetAppConfig().Radiusd.Debug {
 		radlog.Info(debug.FmtResponse(resp, r.RemoteAddr))
@@ -115,7 +261,20 @@ func (s *AuthService) SendAccept(w radius.ResponseWriter, r *radius.Request, res
 	}
 }
 
+// send the reply relayed from the realm upstream
+func (s *AuthService) SendProxyReply(w radius.ResponseWriter, r *radius.Request, reply *radius.Packet) {
+	radlog.Infof("Writing proxy %v to %v", reply.Code, r.RemoteAddr)
+	if s.GetAppConfig().Radiusd.Debug {
+		radlog.Info(debug.FmtResponse(reply, r.RemoteAddr))
+	}
+	err := w.Write(reply)
+	if err != nil {
+		radlog.Error(err)
+	}
+}
+
 // send reject
+// The reject is written after RadiusRejectDelay seconds by a timer, the worker goroutine is not blocked.
 func (s *AuthService) SendReject(w radius.ResponseWriter, r *radius.Request, message string) {
 	defer func() {
 		if ret := recover(); ret != nil {
@@ -134,7 +293,22 @@ func (s *AuthService) SendReject(w radius.ResponseWriter, r *radius.Request, mes
 		}
 		_ = rfc2865.ReplyMessage_SetString(resp, message)
 	}
-	radlog.Infof("Writing %v to %v", code, r.RemoteAddr)
+	if msg, err := eap.Parse(eap.GetEapMessage(r.Packet)); err == nil {
+		eap.SetEapMessage(resp, eap.NewFailure(msg.Identifier))
+		s.setupEapResponse(resp)
+	}
+	delay := s.GetRejectDelay()
+	if delay <= 0 {
+		s.writeReject(w, r, resp)
+		return
+	}
+	time.AfterFunc(delay, func() {
+		s.writeReject(w, r, resp)
+	})
+}
+
+func (s *AuthService) writeReject(w radius.ResponseWriter, r *radius.Request, resp *radius.Packet) {
+	radlog.Infof("Writing %v to %v", resp.Code, r.RemoteAddr)
 	if s.GetAppConfig().Radiusd.Debug {
 		radlog.Info(debug.FmtResponse(resp, r.RemoteAddr))
 	}
@@ -143,3 +317,27 @@ func (s *AuthService) SendReject(w radius.ResponseWriter, r *radius.Request, mes
 		radlog.Error(err)
 	}
 }
+
+// send challenge
+func (s *AuthService) SendChallenge(w radius.ResponseWriter, r *radius.Request, resp *radius.Packet) {
+	s.setupEapResponse(resp)
+	radlog.Infof("Writing %v to %v", resp.Code, r.RemoteAddr)
+	if s.GetAppConfig().Radiusd.Debug {
+		radlog.Info(debug.FmtResponse(resp, r.RemoteAddr))
+	}
+	err := w.Write(resp)
+	if err != nil {
+		radlog.Error(err)
+	}
+}
+
+// setupEapResponse
+// Responses carrying EAP-Message must be signed by Message-Authenticator, after all attributes are set.
+func (s *AuthService) setupEapResponse(resp *radius.Packet) {
+	if eap.GetEapMessage(resp) == nil {
+		return
+	}
+	if err := eap.SetMessageAuthenticator(resp); err != nil {
+		radlog.Error(err)
+	}
+}
@@ -17,8 +17,12 @@ func (s *RadiusService) addAuthlog(start time.Time, username string, nasip strin
 	}
 }
 
-func (s *RadiusService) CheckRadAuthError(start time.Time,username, nasip string, err error) {
+// CheckRadAuthError
+// Log the error and abort the authentication, reason is the metrics label of the reject
+// unless err is a RejectError already.
+func (s *RadiusService) CheckRadAuthError(start time.Time,username, nasip, reason string, err error) {
 	if err != nil {
+		err = NewRejectError(reason, err)
 		logLevel := s.GetStringConfig(constant.RadiusAuthlogLevel, RadiusAuthlogAll)
 		if logLevel != RadiusAuthlogNone && (logLevel == RadiusAuthlogAll || logLevel == RadiusAuthFailure) {
 			s.addAuthlog(start, username, nasip, RadiusAuthFailure, err.Error())
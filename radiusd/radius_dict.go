@@ -0,0 +1,54 @@
+package radiusd
+
+import (
+	"strings"
+
+	"layeh.com/radius"
+
+	"github.com/ca17/teamsacs/models"
+	"github.com/ca17/teamsacs/radiusd/dictionary"
+	"github.com/ca17/teamsacs/radiusd/radlog"
+)
+
+// LoadDictionary
+// Load the FreeRADIUS dictionary files (dictionary, dictionary.*) of the radius workdir,
+// the dictionary names the attributes of the packet logs, the accounting vendor attributes
+// and the reply attributes of the vendor mappings.
+func LoadDictionary(manager *models.ModelManager) (dictionary.Stats, error) {
+	d, err := dictionary.LoadDir(manager.Config.GetRadiusDir())
+	if err != nil {
+		return dictionary.Stats{}, err
+	}
+	for _, warning := range d.Warnings {
+		radlog.Warningf("radius dictionary %s", warning)
+	}
+	dictionary.SetDefault(d)
+	stats := d.Stats()
+	radlog.Infof("radius dictionary loaded, files %d, vendors %d, attributes %d", len(stats.Files), stats.Vendors, stats.Attributes)
+	return stats, nil
+}
+
+// GetDictionary
+// The dictionary resolving attributes by name
+func (s *RadiusService) GetDictionary() *dictionary.Dictionary {
+	return dictionary.Default()
+}
+
+// setAccountingVendorAttrs
+// Vendor-Specific attributes of the accounting request by name, values of a repeated attribute are joined by ";"
+func setAccountingVendorAttrs(p *radius.Packet, online *models.Accounting) {
+	values := dictionary.Default().FormatVendorAttributes(p)
+	if len(values) == 0 {
+		return
+	}
+	online.VendorAttrs = make(map[string]string, len(values))
+	for _, v := range values {
+		// mongodb field names
+		name := strings.NewReplacer(".", "_", "$", "_").Replace(v.Name)
+		if old, ok := online.VendorAttrs[name]; ok {
+			online.VendorAttrs[name] = old + ";" + v.Value
+			continue
+		}
+		online.VendorAttrs[name] = v.Value
+	}
+}
@@ -2,7 +2,6 @@ package radiusd
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"net"
 	"time"
@@ -12,6 +11,8 @@ import (
 	"layeh.com/radius/rfc2866"
 	"layeh.com/radius/rfc2869"
 
+	"go.mongodb.org/mongo-driver/mongo"
+
 	"github.com/ca17/teamsacs/common"
 	"github.com/ca17/teamsacs/config"
 	"github.com/ca17/teamsacs/models"
@@ -20,44 +21,71 @@ import (
 )
 
 const (
-	VendorMikrotik = "14988"
-	VendorIkuai    = "10055"
-	VendorHuawei   = "2011"
-	VendorZte      = "3902"
-	VendorH3c      = "25506"
-	VendorRadback  = "2352"
-	VendorCisco    = "9"
+	VendorMikrotik  = "14988"
+	VendorIkuai     = "10055"
+	VendorHuawei    = "2011"
+	VendorZte       = "3902"
+	VendorH3c       = "25506"
+	VendorRadback   = "2352"
+	VendorCisco     = "9"
+	VendorJuniper   = "2636"
+	VendorAruba     = "14823"
+	VendorAlcatel   = "3041"
+	VendorHillstone = "28557"
+	VendorF5        = "3375"
+	VendorPfSense   = "13644"
 
 	RadiusAuthlogAll  = "all"
 	RadiusAuthlogNone = "none"
 	RadiusAuthSucces  = "success"
 	RadiusAuthFailure = "failure"
+	RadiusAuthLockout = "lockout"
 )
 
 type RadiusService struct {
-	Manager   *models.ModelManager
+	Manager  *models.ModelManager
+	NasCache *NasCache
 }
 
 func NewRadiusService(manager *models.ModelManager) *RadiusService {
-	return &RadiusService{Manager: manager}
+	return &RadiusService{Manager: manager, NasCache: NewNasCache(NasCacheUnknownTTL)}
 }
 
 func (s *RadiusService) GetAppConfig() *config.AppConfig {
 	return s.Manager.Config
 }
 
+// RADIUSSecret
+// 按来源 IP 查询 NAS 秘钥, 未知 NAS 返回错误, 报文在解析前即被丢弃
 func (s *RadiusService) RADIUSSecret(ctx context.Context, remoteAddr net.Addr) ([]byte, error) {
-	return []byte("greensecret"), nil
+	ip := addrIp(remoteAddr)
+	vpe, err := s.GetNasByIp(ip)
+	if err != nil {
+		return nil, err
+	}
+	secret := vpe.GetSecret()
+	if secret == "" {
+		return nil, fmt.Errorf("nas %s secret is empty", ip)
+	}
+	return []byte(secret), nil
 }
 
-// 查询 NAS 设备, 优先查询IP, 然后ID
+// GetNasByIp
+// 按 IP 查询 NAS 设备, 已知设备由 models 缓存, 未知设备在此缓存一段时间
+func (s *RadiusService) GetNasByIp(ip string) (*models.Vpe, error) {
+	return s.NasCache.Lookup(ip, s.Manager.GetVpeManager().GetVpeByIpaddr)
+}
+
+// GetNas
+// 查询 NAS 设备, 优先查询IP, 然后ID. 用于 CoA 等按记录查询的场景,
+// 数据报文的 NAS 由 GetRequestNas 按来源 IP 确定, 未知 IP 的报文在解析前已被丢弃
 func (s *RadiusService) GetNas(ip, identifier string) (*models.Vpe, error) {
 	vstore := s.Manager.GetVpeManager()
-	vpe, err := vstore.GetVpeByIpaddr(ip)
+	vpe, err := s.GetNasByIp(ip)
 	if err != nil {
 		nvpe, err := vstore.GetVpeByIdentifier(identifier)
 		if err != nil {
-			if err == sql.ErrNoRows {
+			if err == mongo.ErrNoDocuments {
 				return nil, fmt.Errorf("Unauthorized access to device, Ip=%s, Identifier=%s, %s", ip, identifier, err.Error())
 			}
 			return nil, err
@@ -67,31 +95,52 @@ func (s *RadiusService) GetNas(ip, identifier string) (*models.Vpe, error) {
 	return vpe, nil
 }
 
+// GetRequestNas
+// RadSec 请求的 NAS 已通过客户端证书确定, 其他请求按来源 IP 查询
+func (s *RadiusService) GetRequestNas(r *radius.Request, ip string) (*models.Vpe, error) {
+	if vpe := GetRadsecVpe(r); vpe != nil {
+		return vpe, nil
+	}
+	return s.GetNasByIp(ip)
+}
+
+// SetupRequestSecret
+// 重新设置数据报文秘钥, RadSec 请求固定使用 radsec 秘钥
+func (s *RadiusService) SetupRequestSecret(r *radius.Request, vpe *models.Vpe) {
+	if GetRadsecVpe(r) != nil {
+		return
+	}
+	r.Secret = []byte(vpe.GetSecret())
+	r.Packet.Secret = []byte(vpe.GetSecret())
+}
+
 // 获取有效用户, 初步判断用户有效性
 func (s *RadiusService) GetUser(username string, macauth bool) (*models.Subscribe, error) {
 	m := s.Manager.GetSubscribeManager()
 	user := new(models.Subscribe)
 	var err error
 	if macauth {
-		user, err = m.GetSubscribeByUser(username)
-		if err != nil {
-			return nil, err
-		}
-	} else {
 		user, err = m.GetSubscribeByMac(username)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				return nil, fmt.Errorf("user:%s not exists", username)
-			}
-			return nil, err
+	} else {
+		user, err = m.GetSubscribeByUser(username)
+	}
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("user:%s not exists", username)
 		}
+		return nil, err
+	}
+	// product defaults
+	user, err = m.GetProductManager().GetEffectiveSubscribe(user)
+	if err != nil {
+		return nil, err
 	}
 	if user.GetStatus() == common.DISABLED {
-		return nil, fmt.Errorf("user:%s status is disabled", username)
+		return nil, NewRejectError(RejectReasonUserDisabled, fmt.Errorf("user:%s status is disabled", username))
 	}
 
 	if user.GetExpireTime().Before(time.Now()) {
-		return nil, fmt.Errorf("user:%s expire", username)
+		return nil, NewRejectError(RejectReasonUserExpire, fmt.Errorf("user:%s expire", username))
 	}
 	return user, nil
 }
@@ -104,7 +153,13 @@ func (s *RadiusService) GetUserForAcct(username string) (*models.Subscribe, erro
 	if err != nil {
 		return nil, err
 	}
-	return user, nil
+	// the session is accounted even if the product is removed
+	effective, err := m.GetProductManager().GetEffectiveSubscribe(user)
+	if err != nil {
+		radlog.Warning(err.Error())
+		return user, nil
+	}
+	return effective, nil
 
 }
 
@@ -139,16 +194,15 @@ func (s *RadiusService) GetStringConfig(name string, defval string) string {
 
 func GetRadiusOnlineFromRequest(r *radius.Request, vr *radparser.VendorRequest, vpe *models.Vpe, nasrip string) models.Accounting {
 
-	acctInputOctets := int(rfc2866.AcctInputOctets_Get(r.Packet))
-	acctInputGigawords := int(rfc2869.AcctInputGigawords_Get(r.Packet))
-	acctOutputOctets := int(rfc2866.AcctOutputOctets_Get(r.Packet))
-	acctOutputGigawords := int(rfc2869.AcctOutputGigawords_Get(r.Packet))
+	// the absolute counters of the request, the session totals are accumulated by the delta
+	acctInputCounter := int64(rfc2866.AcctInputOctets_Get(r.Packet)) + int64(rfc2869.AcctInputGigawords_Get(r.Packet))<<32
+	acctOutputCounter := int64(rfc2866.AcctOutputOctets_Get(r.Packet)) + int64(rfc2869.AcctOutputGigawords_Get(r.Packet))<<32
 
 	getAcctStartTime := func(sessionTime int) time.Time {
 		m, _ := time.ParseDuration(fmt.Sprintf("-%ds", sessionTime))
 		return time.Now().Add(m)
 	}
-	return models.Accounting{
+	online := models.Accounting{
 		Username:          rfc2865.UserName_GetString(r.Packet),
 		NasId:             common.IfEmptyStr(rfc2865.NASIdentifier_GetString(r.Packet), common.NA),
 		NasAddr:           vpe.GetIpaddr(),
@@ -164,12 +218,16 @@ func GetRadiusOnlineFromRequest(r *radius.Request, vr *radparser.VendorRequest,
 		ServiceType:       0,
 		AcctSessionId:     rfc2866.AcctSessionID_GetString(r.Packet),
 		AcctSessionTime:   int(rfc2866.AcctSessionTime_Get(r.Packet)),
-		AcctInputTotal:    int64(acctInputOctets) + int64(acctInputGigawords)*4*1024*1024*1024,
-		AcctOutputTotal:   int64(acctOutputOctets) + int64(acctOutputGigawords)*4*1024*1024*1024,
+		AcctInputTotal:    acctInputCounter,
+		AcctOutputTotal:   acctOutputCounter,
+		AcctInputCounter:  acctInputCounter,
+		AcctOutputCounter: acctOutputCounter,
 		AcctInputPackets:  int(rfc2866.AcctInputPackets_Get(r.Packet)),
-		AcctOutputPackets: int(rfc2866.AcctInputPackets_Get(r.Packet)),
+		AcctOutputPackets: int(rfc2866.AcctOutputPackets_Get(r.Packet)),
 		AcctStartTime:     getAcctStartTime(int(rfc2866.AcctSessionTime_Get(r.Packet))),
 		LastUpdate:        time.Now(),
 	}
-
+	setAccountingIpv6(r.Packet, &online)
+	setAccountingVendorAttrs(r.Packet, &online)
+	return online
 }
@@ -0,0 +1,38 @@
+package radiusd
+
+import (
+	"testing"
+	"time"
+
+	cmap "github.com/orcaman/concurrent-map"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/models"
+)
+
+func TestGetUser(t *testing.T) {
+	m := &models.ModelManager{ManagerMap: cmap.New(), Cache: models.NewCacheManager(models.CacheDefaultTTL)}
+	m.ManagerMap.Set("SubscribeManager", &models.SubscribeManager{ModelManager: m})
+	m.ManagerMap.Set("ProductManager", &models.ProductManager{ModelManager: m})
+	expire := time.Now().Add(time.Hour)
+	// the same key is cached as a username and as a mac address of different subscribers
+	key := "11:22:33:44:55:66"
+	m.Cache.Subscribe.Set("user:"+key, "1", models.Subscribe{"_id": "1", "username": key, "status": common.ENABLED, "expire_time": expire})
+	m.Cache.Subscribe.Set("mac:"+key, "2", models.Subscribe{"_id": "2", "username": "tim", "macaddr": key, "status": common.ENABLED, "expire_time": expire})
+
+	s := &RadiusService{Manager: m}
+	user, err := s.GetUser(key, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.GetUsername() != key {
+		t.Errorf("username lookup got %s", user.GetUsername())
+	}
+	user, err = s.GetUser(key, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.GetUsername() != "tim" {
+		t.Errorf("mac lookup got %s", user.GetUsername())
+	}
+}
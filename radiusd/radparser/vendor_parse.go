@@ -3,7 +3,6 @@ package radparser
 import (
 	"fmt"
 	"regexp"
-	"strconv"
 	"strings"
 
 	"layeh.com/radius"
@@ -12,6 +11,8 @@ import (
 
 	"github.com/ca17/teamsacs/radiusd/radlog"
 	"github.com/ca17/teamsacs/radiusd/vendors"
+	"github.com/ca17/teamsacs/radiusd/vendors/alcatel"
+	"github.com/ca17/teamsacs/radiusd/vendors/aruba"
 	"github.com/ca17/teamsacs/radiusd/vendors/h3c"
 	"github.com/ca17/teamsacs/radiusd/vendors/radback"
 )
@@ -22,31 +23,20 @@ type VendorRequest struct {
 	Vlanid2 int64
 }
 
-var (
-	vlanStdRegexp1 = regexp.MustCompile(`\w?\s?\d+/\d+/\d+:(\d+)(\.(\d+))?\s?`)
-	vlanStdRegexp2 = regexp.MustCompile(`vlanid=(\d+);(vlanid2=?(\d+);)?`)
-)
-
-// 解析标准 VLANID 值
-func ParseVlanIds(nasportid string) (int64, int64) {
-	var vlanid1 int64 = 0
-	var vlanid2 int64 = 0
-	attrs := vlanStdRegexp1.FindStringSubmatch(nasportid)
-	if attrs == nil {
-		attrs = vlanStdRegexp2.FindStringSubmatch(nasportid)
-	}
+var macHexRegexp = regexp.MustCompile(`^[0-9a-fA-F]{12}$`)
 
-	if attrs != nil {
-		vlanid1, _ = strconv.ParseInt(attrs[1], 10, 64)
-		if attrs[2] != "" {
-			vlanid2, _ = strconv.ParseInt(attrs[3], 10, 64)
-		}
+// 解析厂商私有属性
+// vlanFormat 为 VPE 的 NAS-Port-Id VLAN 格式, 厂商属性提供的 VLAN 优先
+func ParseVendor(r *radius.Request, vendorCode string, vlanFormat string) *VendorRequest {
+	attrs := parseVendorAttrs(r, vendorCode)
+	if attrs.Vlanid1 == 0 {
+		nasportid := rfc2869.NASPortID_GetString(r.Packet)
+		attrs.Vlanid1, attrs.Vlanid2 = ParseVlanIdsWithFormat(nasportid, VlanFormats(vendorCode, vlanFormat)...)
 	}
-	return vlanid1, vlanid2
+	return attrs
 }
 
-// 解析厂商私有属性
-func ParseVendor(r *radius.Request, vendorCode string) *VendorRequest {
+func parseVendorAttrs(r *radius.Request, vendorCode string) *VendorRequest {
 	switch vendorCode {
 	case vendors.VendorH3c:
 		return parseVendorH3c(r)
@@ -54,6 +44,12 @@ func ParseVendor(r *radius.Request, vendorCode string) *VendorRequest {
 		return parseVendorRadback(r)
 	case vendors.VendorZte:
 		return parseVendorZte(r)
+	case vendors.VendorAruba:
+		return parseVendorAruba(r)
+	case vendors.VendorAlcatel:
+		return parseVendorAlcatel(r)
+	case vendors.VendorJuniper, vendors.VendorHillstone, vendors.VendorF5, vendors.VendorPfSense:
+		return parseVendorStd(r)
 	default:
 		return parseVendorDefault(r)
 	}
@@ -69,11 +65,6 @@ func parseVendorDefault(r *radius.Request) *VendorRequest {
 	} else {
 		radlog.Warning("rfc2865.CallingStationID is empty")
 	}
-	nasportid := rfc2869.NASPortID_GetString(r.Packet)
-	if nasportid == "" {
-		attrs.Vlanid1 = 0
-		attrs.Vlanid2 = 0
-	}
 	return attrs
 }
 
@@ -98,11 +89,6 @@ func parseVendorH3c(r *radius.Request) *VendorRequest {
 		attrs.Macaddr = ipha
 	}
 
-	nasportid := rfc2869.NASPortID_GetString(r.Packet)
-	if nasportid == "" {
-		attrs.Vlanid1 = 0
-		attrs.Vlanid2 = 0
-	}
 	return attrs
 }
 
@@ -116,11 +102,6 @@ func parseVendorZte(r *radius.Request) *VendorRequest {
 	} else {
 		radlog.Warning("rfc2865.CallingStationID length < 12")
 	}
-	nasportid := rfc2869.NASPortID_GetString(r.Packet)
-	if nasportid == "" {
-		attrs.Vlanid1 = 0
-		attrs.Vlanid2 = 0
-	}
 	return attrs
 }
 
@@ -134,10 +115,45 @@ func parseVendorRadback(r *radius.Request) *VendorRequest {
 	} else {
 		radlog.Warning("rfc2865.CallingStationID is empty")
 	}
-	nasportid := rfc2869.NASPortID_GetString(r.Packet)
-	if nasportid == "" {
-		attrs.Vlanid1 = 0
-		attrs.Vlanid2 = 0
+	return attrs
+}
+
+// 格式化 MAC 地址, 支持 00-11-22-33-44-55, 0011.2233.4455, 001122334455 格式
+func FormatMacAddr(macval string) string {
+	hex := strings.NewReplacer("-", "", ":", "", ".", "").Replace(strings.TrimSpace(macval))
+	if !macHexRegexp.MatchString(hex) {
+		return strings.ReplaceAll(macval, "-", ":")
+	}
+	return fmt.Sprintf("%s:%s:%s:%s:%s:%s", hex[0:2], hex[2:4], hex[4:6], hex[6:8], hex[8:10], hex[10:12])
+}
+
+// 解析 Calling-Station-Id MAC 地址
+func parseVendorStd(r *radius.Request) *VendorRequest {
+	var attrs = new(VendorRequest)
+	macval := rfc2865.CallingStationID_GetString(r.Packet)
+	if macval != "" {
+		attrs.Macaddr = FormatMacAddr(macval)
+	} else {
+		radlog.Warning("rfc2865.CallingStationID is empty")
+	}
+	return attrs
+}
+
+// 解析 Aruba 属性, 无线用户 VLAN 由 Aruba-User-Vlan 提供
+func parseVendorAruba(r *radius.Request) *VendorRequest {
+	var attrs = parseVendorStd(r)
+	if vlan, err := aruba.ArubaUserVlan_Lookup(r.Packet); err == nil {
+		attrs.Vlanid1 = int64(vlan)
 	}
 	return attrs
 }
+
+// 解析 Alcatel 属性
+func parseVendorAlcatel(r *radius.Request) *VendorRequest {
+	macval := alcatel.AATUserMACAddress_GetString(r.Packet)
+	if macval == "" {
+		radlog.Warning("alcatel.AATUserMACAddress is empty")
+		return parseVendorStd(r)
+	}
+	return &VendorRequest{Macaddr: FormatMacAddr(macval)}
+}
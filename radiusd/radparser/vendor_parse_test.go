@@ -3,6 +3,14 @@ package radparser
 import (
 	"fmt"
 	"testing"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+	"layeh.com/radius/rfc2869"
+
+	"github.com/ca17/teamsacs/radiusd/vendors"
+	"github.com/ca17/teamsacs/radiusd/vendors/alcatel"
+	"github.com/ca17/teamsacs/radiusd/vendors/aruba"
 )
 
 func TestParseVlansOfStd(t *testing.T) {
@@ -14,3 +22,66 @@ func TestParseVlansOfStd(t *testing.T) {
 	s3 := "slot=2;subslot=2;port=22;vlanid=503;"
 	fmt.Println(ParseVlanIds(s3))
 }
+
+func TestFormatMacAddr(t *testing.T) {
+	tests := []struct {
+		mac    string
+		expect string
+	}{
+		{"00-11-22-aa-bb-cc", "00:11:22:aa:bb:cc"},
+		{"00:11:22:aa:bb:cc", "00:11:22:aa:bb:cc"},
+		{"0011.22aa.bbcc", "00:11:22:aa:bb:cc"},
+		{"001122aabbcc", "00:11:22:aa:bb:cc"},
+		{"10.0.0.1", "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		if v := FormatMacAddr(tt.mac); v != tt.expect {
+			t.Errorf("%s formatted %s, expect %s", tt.mac, v, tt.expect)
+		}
+	}
+}
+
+func TestParseMoreVendors(t *testing.T) {
+	tests := []struct {
+		vendor    string
+		mac       string
+		nasportid string
+		setup     func(p *radius.Packet)
+		expect    VendorRequest
+	}{
+		{vendors.VendorJuniper, "00:11:22:aa:bb:cc", "ge-1/0/0.1073741823:100-200", nil,
+			VendorRequest{Macaddr: "00:11:22:aa:bb:cc", Vlanid1: 100, Vlanid2: 200}},
+		{vendors.VendorJuniper, "0011.22aa.bbcc", "xe-0/0/1.3221225472:300", nil,
+			VendorRequest{Macaddr: "00:11:22:aa:bb:cc", Vlanid1: 300}},
+		{vendors.VendorAruba, "001122aabbcc", "", func(p *radius.Packet) {
+			_ = aruba.ArubaUserVlan_Add(p, 20)
+		}, VendorRequest{Macaddr: "00:11:22:aa:bb:cc", Vlanid1: 20}},
+		{vendors.VendorAlcatel, "", "slot=2;subslot=2;port=22;vlanid=503;", func(p *radius.Packet) {
+			_ = alcatel.AATUserMACAddress_AddString(p, "00-11-22-aa-bb-cc")
+		}, VendorRequest{Macaddr: "00:11:22:aa:bb:cc", Vlanid1: 503}},
+		{vendors.VendorAlcatel, "00-11-22-aa-bb-cc", "", nil,
+			VendorRequest{Macaddr: "00:11:22:aa:bb:cc"}},
+		{vendors.VendorHillstone, "00-11-22-aa-bb-cc", "eth 3/0/1:2814.727", nil,
+			VendorRequest{Macaddr: "00:11:22:aa:bb:cc", Vlanid1: 2814, Vlanid2: 727}},
+		{vendors.VendorF5, "10.0.0.1", "", nil,
+			VendorRequest{Macaddr: "10.0.0.1"}},
+		{vendors.VendorPfSense, "00:11:22:aa:bb:cc", "", nil,
+			VendorRequest{Macaddr: "00:11:22:aa:bb:cc"}},
+	}
+	for _, tt := range tests {
+		p := radius.New(radius.CodeAccessRequest, []byte("secret"))
+		if tt.mac != "" {
+			_ = rfc2865.CallingStationID_SetString(p, tt.mac)
+		}
+		if tt.nasportid != "" {
+			_ = rfc2869.NASPortID_SetString(p, tt.nasportid)
+		}
+		if tt.setup != nil {
+			tt.setup(p)
+		}
+		vr := ParseVendor(&radius.Request{Packet: p}, tt.vendor, "")
+		if *vr != tt.expect {
+			t.Errorf("vendor %s parsed %+v, expect %+v", tt.vendor, *vr, tt.expect)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package radparser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ca17/teamsacs/radiusd/vendors"
+)
+
+// NAS-Port-Id VLAN formats, the VPE vlan_format selects one or more formats separated by comma,
+// empty or auto uses the formats of the vendor.
+const (
+	VlanFormatAuto    = "auto"
+	VlanFormatStd     = "std"     // eth 3/0/1:2814.727, slot=2;subslot=2;port=22;vlanid=503;vlanid2=100;
+	VlanFormatColon   = "colon"   // ge-1/0/0.1073741823:100-200, GigabitEthernet0/3/0.100:100.200
+	VlanFormatRadback = "radback" // 2/1 vlan-id 100:200 pppoe 3
+	VlanFormatCisco   = "cisco"   // 0/0/1/100.200
+	VlanFormatIfname  = "ifname"  // vlan100, ether1.100.200
+	VlanFormatNone    = "none"
+)
+
+// The first group is the outer (service) vlan, the second is the inner (customer) vlan
+var vlanFormatRegexps = map[string][]*regexp.Regexp{
+	VlanFormatStd: {
+		regexp.MustCompile(`\d+/\d+/\d+:(\d+)(?:\.(\d+))?`),
+		regexp.MustCompile(`(?i)vlanid=(\d+)(?:;\s*vlanid2=(\d+))?`),
+	},
+	VlanFormatColon: {
+		regexp.MustCompile(`:(\d+)(?:[.\-](\d+))?\s*$`),
+	},
+	VlanFormatRadback: {
+		regexp.MustCompile(`(?i)vlan-id\s+(\d+)(?::(\d+))?`),
+	},
+	VlanFormatCisco: {
+		regexp.MustCompile(`^\d+/\d+/\d+/(\d+)(?:\.(\d+))?$`),
+	},
+	VlanFormatIfname: {
+		regexp.MustCompile(`(?i)vlan[\-_]?(\d+)(?:[.\-_](\d+))?$`),
+		regexp.MustCompile(`\.(\d+)(?:\.(\d+))?$`),
+	},
+}
+
+// The NAS-Port-Id formats of the vendors, tried in order
+var vendorVlanFormats = map[string][]string{
+	vendors.VendorHuawei:   {VlanFormatStd, VlanFormatColon},
+	vendors.VendorZte:      {VlanFormatStd, VlanFormatColon},
+	vendors.VendorH3c:      {VlanFormatStd, VlanFormatColon},
+	vendors.VendorRadback:  {VlanFormatRadback, VlanFormatStd},
+	vendors.VendorCisco:    {VlanFormatCisco, VlanFormatColon, VlanFormatIfname},
+	vendors.VendorMikrotik: {VlanFormatIfname},
+	vendors.VendorIkuai:    {VlanFormatIfname, VlanFormatStd},
+	vendors.VendorJuniper:  {VlanFormatColon, VlanFormatStd},
+	vendors.VendorPfSense:  {VlanFormatIfname},
+}
+
+// VlanFormats
+// The formats of vlanFormat, the vendor formats for auto
+func VlanFormats(vendorCode, vlanFormat string) []string {
+	vlanFormat = strings.ToLower(strings.TrimSpace(vlanFormat))
+	if vlanFormat == "" || vlanFormat == VlanFormatAuto {
+		if formats, ok := vendorVlanFormats[vendorCode]; ok {
+			return formats
+		}
+		return []string{VlanFormatStd}
+	}
+	return strings.Split(strings.ReplaceAll(vlanFormat, " ", ""), ",")
+}
+
+// ParseVlanIdsWithFormat
+// The outer and inner VLANID of nasportid matched by the first of the formats, 0 if not found
+func ParseVlanIdsWithFormat(nasportid string, formats ...string) (int64, int64) {
+	if nasportid == "" {
+		return 0, 0
+	}
+	for _, format := range formats {
+		for _, re := range vlanFormatRegexps[format] {
+			attrs := re.FindStringSubmatch(nasportid)
+			if attrs == nil {
+				continue
+			}
+			vlanid1, _ := strconv.ParseInt(attrs[1], 10, 64)
+			vlanid2, _ := strconv.ParseInt(attrs[2], 10, 64)
+			if !validVlanId(vlanid1) || (attrs[2] != "" && !validVlanId(vlanid2)) {
+				continue
+			}
+			return vlanid1, vlanid2
+		}
+	}
+	return 0, 0
+}
+
+func validVlanId(vid int64) bool {
+	return vid > 0 && vid < 4095
+}
+
+// 解析标准 VLANID 值
+func ParseVlanIds(nasportid string) (int64, int64) {
+	return ParseVlanIdsWithFormat(nasportid, VlanFormatStd)
+}
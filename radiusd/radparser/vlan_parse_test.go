@@ -0,0 +1,71 @@
+package radparser
+
+import (
+	"testing"
+
+	"github.com/ca17/teamsacs/radiusd/vendors"
+)
+
+// NAS-Port-Id samples of the devices
+var nasPortIdCorpus = []struct {
+	vendor    string
+	format    string
+	nasportid string
+	vlanid1   int64
+	vlanid2   int64
+}{
+	// Huawei ME60 / NE40E
+	{vendors.VendorHuawei, "", "eth 3/0/1:2814.727", 2814, 727},
+	{vendors.VendorHuawei, "", "trunk 1/0/12:3062.1021", 3062, 1021},
+	{vendors.VendorHuawei, "", "eth 0/1/0:100", 100, 0},
+	{vendors.VendorHuawei, "", "slot=2;subslot=2;port=22;vlanid=503;", 503, 0},
+	{vendors.VendorHuawei, "", "slot=1;subslot=0;port=4;vlanid=1001;vlanid2=2002;", 1001, 2002},
+	{vendors.VendorHuawei, "", "GigabitEthernet1/0/0.100:100.200", 100, 200},
+	{vendors.VendorHuawei, "", "eth 0/1/0:4095.20", 0, 0},
+	// ZTE M6000
+	{vendors.VendorZte, "", "slot=3;subslot=1;port=2;vlanid=1234;vlanid2=56;", 1234, 56},
+	{vendors.VendorZte, "", "xgei-0/2/0/1.1001:1001.3001", 1001, 3001},
+	{vendors.VendorZte, "", "smartgroup1.400:400", 400, 0},
+	// H3C SR8800 / CR16000
+	{vendors.VendorH3c, "", "SlotNumber=2;SubSlotNumber=0;PortNumber=1;VLANID=100;", 100, 0},
+	{vendors.VendorH3c, "", "SlotNumber=2;SubSlotNumber=0;PortNumber=1;VLANID=100;VLANID2=200;", 100, 200},
+	{vendors.VendorH3c, "", "GigabitEthernet3/0/1:1234.56", 1234, 56},
+	// Redback / Ericsson SmartEdge
+	{vendors.VendorRadback, "", "2/1 vlan-id 100:200 pppoe 3", 100, 200},
+	{vendors.VendorRadback, "", "3/2 vlan-id 10 pppoe 1", 10, 0},
+	{vendors.VendorRadback, "", "1/1 clips 123", 0, 0},
+	// Cisco ASR1000 / ASR9000
+	{vendors.VendorCisco, "", "0/0/1/100.200", 100, 200},
+	{vendors.VendorCisco, "", "0/0/1/100", 100, 0},
+	{vendors.VendorCisco, "", "Gi0/0/1.100:100-200", 100, 200},
+	{vendors.VendorCisco, "", "GigabitEthernet0/0/0/0.100", 100, 0},
+	{vendors.VendorCisco, "", "Bundle-Ether1.100.200", 100, 200},
+	// Mikrotik, the pppoe server interface name
+	{vendors.VendorMikrotik, "", "vlan100", 100, 0},
+	{vendors.VendorMikrotik, "", "vlan100-200", 100, 200},
+	{vendors.VendorMikrotik, "", "ether1.100", 100, 0},
+	{vendors.VendorMikrotik, "", "sfp1.100.200", 100, 200},
+	{vendors.VendorMikrotik, "", "vlan-pppoe", 0, 0},
+	// Juniper MX
+	{vendors.VendorJuniper, "", "ge-1/0/0.1073741823:100-200", 100, 200},
+	{vendors.VendorJuniper, "", "xe-0/0/1.3221225472:300", 300, 0},
+	{vendors.VendorJuniper, "", "ae0.demux0.3221225473:1000-2000", 1000, 2000},
+	// unknown vendors use the std format
+	{"", "", "0/0/0/0:100.200", 100, 200},
+	{"", "", "vlan100", 0, 0},
+	// formats selected by the VPE
+	{vendors.VendorHuawei, "ifname", "vlan100", 100, 0},
+	{vendors.VendorHuawei, "none", "eth 3/0/1:2814.727", 0, 0},
+	{vendors.VendorMikrotik, "radback, std", "2/1 vlan-id 100:200 pppoe 3", 100, 200},
+	{vendors.VendorCisco, "auto", "0/0/1/100", 100, 0},
+}
+
+func TestParseVlanIdsWithFormat(t *testing.T) {
+	for _, tt := range nasPortIdCorpus {
+		vid1, vid2 := ParseVlanIdsWithFormat(tt.nasportid, VlanFormats(tt.vendor, tt.format)...)
+		if vid1 != tt.vlanid1 || vid2 != tt.vlanid2 {
+			t.Errorf("vendor %s format %q %q parsed %d.%d, expect %d.%d",
+				tt.vendor, tt.format, tt.nasportid, vid1, vid2, tt.vlanid1, tt.vlanid2)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package radiusd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"path"
+	"sync"
+
+	"layeh.com/radius"
+
+	"github.com/ca17/teamsacs/common/log"
+	"github.com/ca17/teamsacs/config"
+	"github.com/ca17/teamsacs/models"
+	"github.com/ca17/teamsacs/radiusd/radlog"
+)
+
+// RadSec (RADIUS over TLS, RFC 6614)
+// All packets on a RadSec connection use the fixed shared secret "radsec",
+// the NAS is authenticated by its client certificate instead.
+
+const (
+	RadsecSecret   = "radsec"
+	RadsecCertFile = "teamsacs-radsec.tls.crt"
+	RadsecKeyFile  = "teamsacs-radsec.tls.key"
+	RadsecCaFile   = "teamsacs-radsec.ca.crt"
+)
+
+type radsecVpeKey struct{}
+
+// GetRadsecVpe
+// Returns the VPE bound to the RadSec connection of the request, nil for UDP requests.
+func GetRadsecVpe(r *radius.Request) *models.Vpe {
+	vpe, _ := r.Context().Value(radsecVpeKey{}).(*models.Vpe)
+	return vpe
+}
+
+// radsecResponseWriter
+// Responses of concurrent requests share one TLS connection.
+type radsecResponseWriter struct {
+	conn net.Conn
+	lock *sync.Mutex
+}
+
+func (w *radsecResponseWriter) Write(packet *radius.Packet) error {
+	encoded, err := packet.Encode()
+	if err != nil {
+		return err
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	_, err = w.conn.Write(encoded)
+	return err
+}
+
+type RadsecServer struct {
+	Manager     *models.ModelManager
+	AuthHandler radius.Handler
+	AcctHandler radius.Handler
+}
+
+func NewRadsecServer(manager *models.ModelManager) *RadsecServer {
+	return &RadsecServer{
+		Manager:     manager,
+		AuthHandler: NewAuthService(NewRadiusService(manager)),
+		AcctHandler: NewAcctService(NewRadiusService(manager)),
+	}
+}
+
+// loadRadsecTLSConfig
+// Load server certificate and client CA from the private dir,
+// NAS devices must present a certificate signed by the CA.
+func loadRadsecTLSConfig(cfg *config.AppConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(
+		path.Join(cfg.GetPrivateDir(), RadsecCertFile),
+		path.Join(cfg.GetPrivateDir(), RadsecKeyFile),
+	)
+	if err != nil {
+		return nil, err
+	}
+	capem, err := ioutil.ReadFile(path.Join(cfg.GetPrivateDir(), RadsecCaFile))
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(capem) {
+		return nil, fmt.Errorf("radsec client ca %s is invalid", RadsecCaFile)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// GetCertVpe
+// Map the client certificate to a VPE, the certificate CommonName or one of the
+// DNS SANs must match the VPE identifier. The remote ip is only used when the
+// certificate matches no VPE and radsec_ip_fallback is enabled.
+func (s *RadsecServer) GetCertVpe(cert *x509.Certificate, remoteAddr net.Addr) (*models.Vpe, error) {
+	vstore := s.Manager.GetVpeManager()
+	for _, name := range certVpeNames(cert) {
+		vpe, err := vstore.GetVpeByIdentifier(name)
+		if err == nil {
+			return vpe, nil
+		}
+	}
+	ip, _, _ := net.SplitHostPort(remoteAddr.String())
+	if !s.Manager.Config.Radiusd.RadsecIpFallback {
+		return nil, fmt.Errorf("radsec unauthorized device, CN=%s, Ip=%s, no vpe identifier matches the certificate",
+			cert.Subject.CommonName, ip)
+	}
+	vpe, err := vstore.GetVpeByIpaddr(ip)
+	if err != nil {
+		return nil, fmt.Errorf("radsec unauthorized device, CN=%s, Ip=%s, %s", cert.Subject.CommonName, ip, err.Error())
+	}
+	return vpe, nil
+}
+
+// certVpeNames
+// The names of the certificate matched against the VPE identifier, CommonName first
+func certVpeNames(cert *x509.Certificate) []string {
+	var names []string
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	for _, name := range cert.DNSNames {
+		if name != "" && name != cert.Subject.CommonName {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (s *RadsecServer) handleConn(conn *tls.Conn) {
+	defer conn.Close()
+	if err := conn.Handshake(); err != nil {
+		radlog.Errorf("radsec handshake with %s error, %s", conn.RemoteAddr(), err.Error())
+		return
+	}
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		radlog.Errorf("radsec client %s has no certificate", conn.RemoteAddr())
+		return
+	}
+	vpe, err := s.GetCertVpe(certs[0], conn.RemoteAddr())
+	if err != nil {
+		radlog.Error(err)
+		return
+	}
+	radlog.Infof("radsec client %s connected, CN=%s", conn.RemoteAddr(), certs[0].Subject.CommonName)
+
+	ctx := context.WithValue(context.Background(), radsecVpeKey{}, vpe)
+	writer := &radsecResponseWriter{conn: conn, lock: new(sync.Mutex)}
+	for {
+		buff, err := readRadsecPacket(conn)
+		if err != nil {
+			if err != io.EOF {
+				radlog.Errorf("radsec read from %s error, %s", conn.RemoteAddr(), err.Error())
+			}
+			return
+		}
+		packet, err := radius.Parse(buff, []byte(RadsecSecret))
+		if err != nil {
+			radlog.Errorf("radsec unable to parse packet from %s, %s", conn.RemoteAddr(), err.Error())
+			continue
+		}
+
+		var handler radius.Handler
+		switch packet.Code {
+		case radius.CodeAccessRequest:
+			handler = s.AuthHandler
+		case radius.CodeAccountingRequest:
+			handler = s.AcctHandler
+		default:
+			radlog.Warningf("radsec unsupported packet code %v from %s", packet.Code, conn.RemoteAddr())
+			continue
+		}
+		request := &radius.Request{
+			LocalAddr:  conn.LocalAddr(),
+			RemoteAddr: conn.RemoteAddr(),
+			Packet:     packet,
+		}
+		go handler.ServeRADIUS(writer, request.WithContext(ctx))
+	}
+}
+
+// readRadsecPacket
+// RadSec is a stream, each packet is delimited by the length field of the RADIUS header.
+func readRadsecPacket(r io.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint16(header[2:4]))
+	if length < 20 || length > radius.MaxPacketLength {
+		return nil, errors.New("radsec invalid packet length")
+	}
+	buff := make([]byte, length)
+	copy(buff, header)
+	if _, err := io.ReadFull(r, buff[4:]); err != nil {
+		return nil, err
+	}
+	return buff, nil
+}
+
+func ListenRadsecServer(manager *models.ModelManager) error {
+	tlsConfig, err := loadRadsecTLSConfig(manager.Config)
+	if err != nil {
+		return err
+	}
+	addr := fmt.Sprintf("%s:%d", manager.Config.Radiusd.Host, manager.Config.Radiusd.RadsecPort)
+	listener, err := tls.Listen("tcp", addr, tlsConfig)
+	if err != nil {
+		return err
+	}
+	server := NewRadsecServer(manager)
+	log.Infof("Starting Radsec server on %s", addr)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				radlog.Errorf("radsec accept error, %s", err.Error())
+				continue
+			}
+			return err
+		}
+		go server.handleConn(conn.(*tls.Conn))
+	}
+}
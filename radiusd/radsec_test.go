@@ -0,0 +1,54 @@
+package radiusd
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"reflect"
+	"testing"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+
+	"github.com/ca17/teamsacs/common"
+)
+
+func TestReadRadsecPacket(t *testing.T) {
+	packet := radius.New(radius.CodeAccessRequest, []byte(RadsecSecret))
+	common.Must(rfc2865.UserName_SetString(packet, "tim"))
+	encoded, err := packet.Encode()
+	common.Must(err)
+
+	// two packets back to back in one stream
+	stream := bytes.NewReader(append(append([]byte{}, encoded...), encoded...))
+	for i := 0; i < 2; i++ {
+		buff, err := readRadsecPacket(stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := radius.Parse(buff, []byte(RadsecSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rfc2865.UserName_GetString(p) != "tim" {
+			t.Fatal("username not match")
+		}
+	}
+
+	if _, err := readRadsecPacket(bytes.NewReader([]byte{1, 1, 0, 3})); err == nil {
+		t.Fatal("invalid length must be rejected")
+	}
+}
+
+func TestCertVpeNames(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "bras01"},
+		DNSNames: []string{"bras01", "bras01.isp.net"},
+	}
+	if names := certVpeNames(cert); !reflect.DeepEqual(names, []string{"bras01", "bras01.isp.net"}) {
+		t.Errorf("names %v", names)
+	}
+	if names := certVpeNames(&x509.Certificate{}); len(names) != 0 {
+		t.Errorf("empty certificate names %v", names)
+	}
+}
@@ -0,0 +1,359 @@
+package radiusd
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2759"
+	"layeh.com/radius/rfc2865"
+	"layeh.com/radius/rfc2866"
+	"layeh.com/radius/rfc2869"
+
+	"github.com/ca17/teamsacs/radiusd/debug"
+	"github.com/ca17/teamsacs/radiusd/dictionary"
+	"github.com/ca17/teamsacs/radiusd/vendors/microsoft"
+)
+
+// Authentication methods of the radtest client
+const (
+	RadtestPap      = "pap"
+	RadtestChap     = "chap"
+	RadtestMschapv2 = "mschapv2"
+)
+
+// Accounting requests of the radtest client
+const (
+	RadtestAcctStart   = "start"
+	RadtestAcctInterim = "interim"
+	RadtestAcctStop    = "stop"
+)
+
+// RadtestOptions
+// The NAS simulated by the radtest client, Attrs are extra attributes "Name=value" resolved by the dictionary
+type RadtestOptions struct {
+	Server       string
+	AuthPort     int
+	AcctPort     int
+	Secret       string
+	Username     string
+	Password     string
+	AuthType     string
+	NasIp        string
+	NasId        string
+	NasPortId    string
+	MacAddr      string
+	SessionId    string
+	SessionTime  uint32
+	InputOctets  uint64
+	OutputOctets uint64
+	Attrs        []string
+	Timeout      time.Duration
+}
+
+func (o *RadtestOptions) authAddr() string {
+	return net.JoinHostPort(o.Server, strconv.Itoa(o.AuthPort))
+}
+
+func (o *RadtestOptions) acctAddr() string {
+	return net.JoinHostPort(o.Server, strconv.Itoa(o.AcctPort))
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return b
+}
+
+// setRadtestNasAttrs
+// The NAS attributes and the extra attributes of the request
+func setRadtestNasAttrs(p *radius.Packet, opts *RadtestOptions) error {
+	if err := rfc2865.UserName_SetString(p, opts.Username); err != nil {
+		return err
+	}
+	if opts.NasIp != "" {
+		ip := net.ParseIP(opts.NasIp)
+		if ip == nil {
+			return fmt.Errorf("invalid nas ip %s", opts.NasIp)
+		}
+		_ = rfc2865.NASIPAddress_Set(p, ip)
+	}
+	if opts.NasId != "" {
+		_ = rfc2865.NASIdentifier_SetString(p, opts.NasId)
+	}
+	if opts.NasPortId != "" {
+		_ = rfc2869.NASPortID_SetString(p, opts.NasPortId)
+	}
+	if opts.MacAddr != "" {
+		_ = rfc2865.CallingStationID_SetString(p, opts.MacAddr)
+	}
+	_ = rfc2865.NASPortType_Set(p, rfc2865.NASPortType_Value_Ethernet)
+	_ = rfc2865.ServiceType_Set(p, rfc2865.ServiceType_Value_FramedUser)
+	_ = rfc2865.FramedProtocol_Set(p, rfc2865.FramedProtocol_Value_PPP)
+	for _, attr := range opts.Attrs {
+		kv := strings.SplitN(attr, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid attribute %s, Name=value is expected", attr)
+		}
+		if err := dictionary.Default().Add(p, strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewRadtestAuthRequest
+// Access-Request of the user with the password encoded by the authentication method
+func NewRadtestAuthRequest(opts *RadtestOptions) (*radius.Packet, error) {
+	p := radius.New(radius.CodeAccessRequest, []byte(opts.Secret))
+	if err := setRadtestNasAttrs(p, opts); err != nil {
+		return nil, err
+	}
+	switch opts.AuthType {
+	case RadtestPap, "":
+		if err := rfc2865.UserPassword_Set(p, padUserPassword([]byte(opts.Password))); err != nil {
+			return nil, err
+		}
+	case RadtestChap:
+		ident := randomBytes(1)[0]
+		challenge := randomBytes(16)
+		w := md5.New()
+		w.Write([]byte{ident})
+		w.Write([]byte(opts.Password))
+		w.Write(challenge)
+		_ = rfc2865.CHAPPassword_Set(p, append([]byte{ident}, w.Sum(nil)...))
+		_ = rfc2865.CHAPChallenge_Set(p, challenge)
+	case RadtestMschapv2:
+		challenge := randomBytes(16)
+		peerChallenge := randomBytes(16)
+		ntResponse, err := rfc2759.GenerateNTResponse(challenge, peerChallenge, []byte(opts.Username), []byte(opts.Password))
+		if err != nil {
+			return nil, err
+		}
+		// ident, flags, peer challenge, 8 reserved, nt response
+		response := make([]byte, 50)
+		response[0] = randomBytes(1)[0]
+		copy(response[2:18], peerChallenge)
+		copy(response[26:50], ntResponse)
+		_ = microsoft.MSCHAPChallenge_Set(p, challenge)
+		_ = microsoft.MSCHAP2Response_Set(p, response)
+	default:
+		return nil, fmt.Errorf("unsupported auth type %s", opts.AuthType)
+	}
+	return p, nil
+}
+
+// VerifyMschapv2Success
+// Check the authenticator response of MS-CHAP2-Success in the accept, the server knows the password
+func VerifyMschapv2Success(request, reply *radius.Packet, username, password string) error {
+	challenge := microsoft.MSCHAPChallenge_Get(request)
+	response := microsoft.MSCHAP2Response_Get(request)
+	success := microsoft.MSCHAP2Success_Get(reply)
+	if len(challenge) != 16 || len(response) != 50 {
+		return fmt.Errorf("request is not mschapv2")
+	}
+	if len(success) != 43 {
+		return fmt.Errorf("MS-CHAP2-Success length %d, expect 43", len(success))
+	}
+	expect, err := rfc2759.GenerateAuthenticatorResponse(challenge, response[2:18], response[26:50], []byte(username), []byte(password))
+	if err != nil {
+		return err
+	}
+	if success[0] != response[0] || string(success[1:]) != expect {
+		return fmt.Errorf("MS-CHAP2-Success authenticator response mismatch")
+	}
+	return nil
+}
+
+// NewRadtestAcctRequest
+// Accounting-Request of the session, the octets are split into the counters and gigawords
+func NewRadtestAcctRequest(opts *RadtestOptions, statusType rfc2866.AcctStatusType, framedIp net.IP) (*radius.Packet, error) {
+	p := radius.New(radius.CodeAccountingRequest, []byte(opts.Secret))
+	if err := setRadtestNasAttrs(p, opts); err != nil {
+		return nil, err
+	}
+	_ = rfc2866.AcctStatusType_Set(p, statusType)
+	_ = rfc2866.AcctSessionID_SetString(p, opts.SessionId)
+	if framedIp != nil {
+		_ = rfc2865.FramedIPAddress_Set(p, framedIp)
+	}
+	if statusType == rfc2866.AcctStatusType_Value_Start {
+		return p, nil
+	}
+	_ = rfc2866.AcctSessionTime_Set(p, rfc2866.AcctSessionTime(opts.SessionTime))
+	_ = rfc2866.AcctInputOctets_Set(p, rfc2866.AcctInputOctets(opts.InputOctets&math.MaxUint32))
+	_ = rfc2866.AcctOutputOctets_Set(p, rfc2866.AcctOutputOctets(opts.OutputOctets&math.MaxUint32))
+	_ = rfc2869.AcctInputGigawords_Set(p, rfc2869.AcctInputGigawords(opts.InputOctets>>32))
+	_ = rfc2869.AcctOutputGigawords_Set(p, rfc2869.AcctOutputGigawords(opts.OutputOctets>>32))
+	if statusType == rfc2866.AcctStatusType_Value_Stop {
+		_ = rfc2866.AcctTerminateCause_Set(p, rfc2866.AcctTerminateCause_Value_UserRequest)
+	}
+	return p, nil
+}
+
+// RadtestExchange
+// Send the request and wait for the reply, the request is retransmitted until the timeout
+func RadtestExchange(p *radius.Packet, addr string, timeout time.Duration) (*radius.Packet, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	reply, err := radius.Exchange(ctx, p, addr)
+	return reply, time.Since(start), err
+}
+
+// RunRadtest
+// Authenticate the user and send the accounting requests of acct in order, the packets are written to w
+func RunRadtest(opts *RadtestOptions, auth bool, acct []string, w io.Writer) error {
+	var framedIp net.IP
+	if auth {
+		request, err := NewRadtestAuthRequest(opts)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Sending Access-Request (%s) to %s\n%s", opts.AuthType, opts.authAddr(), debug.FormatPacket(request))
+		reply, latency, err := RadtestExchange(request, opts.authAddr(), opts.Timeout)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Received %s in %s\n%s", reply.Code, latency, debug.FormatPacket(reply))
+		if reply.Code != radius.CodeAccessAccept {
+			return fmt.Errorf("%s: %s", reply.Code, rfc2865.ReplyMessage_GetString(reply))
+		}
+		if opts.AuthType == RadtestMschapv2 {
+			if err = VerifyMschapv2Success(request, reply, opts.Username, opts.Password); err != nil {
+				return err
+			}
+			fmt.Fprintln(w, "MS-CHAP2-Success authenticator response verified")
+		}
+		framedIp = rfc2865.FramedIPAddress_Get(reply)
+	}
+	for _, name := range acct {
+		var statusType rfc2866.AcctStatusType
+		switch name {
+		case RadtestAcctStart:
+			statusType = rfc2866.AcctStatusType_Value_Start
+		case RadtestAcctInterim:
+			statusType = rfc2866.AcctStatusType_Value_InterimUpdate
+		case RadtestAcctStop:
+			statusType = rfc2866.AcctStatusType_Value_Stop
+		default:
+			return fmt.Errorf("unsupported accounting request %s", name)
+		}
+		request, err := NewRadtestAcctRequest(opts, statusType, framedIp)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Sending Accounting-Request (%s) to %s\n%s", statusType, opts.acctAddr(), debug.FormatPacket(request))
+		reply, latency, err := RadtestExchange(request, opts.acctAddr(), opts.Timeout)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Received %s in %s\n%s", reply.Code, latency, debug.FormatPacket(reply))
+	}
+	return nil
+}
+
+// RadtestStats
+// Results of the load mode, the latencies of the replied requests are sorted
+type RadtestStats struct {
+	Requests   int
+	Accepts    int
+	Rejects    int
+	Challenges int
+	Errors     int
+	Duration   time.Duration
+	Latencies  []time.Duration
+}
+
+// Percentile
+// The nearest rank percentile of the latencies, 0 if no request is replied
+func (s *RadtestStats) Percentile(p float64) time.Duration {
+	n := len(s.Latencies)
+	if n == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(n)))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > n {
+		rank = n
+	}
+	return s.Latencies[rank-1]
+}
+
+func (s *RadtestStats) String() string {
+	var buff strings.Builder
+	rate := float64(s.Requests) / s.Duration.Seconds()
+	buff.WriteString(fmt.Sprintf("requests: %d, duration: %s, rate: %.1f/s\n", s.Requests, s.Duration, rate))
+	buff.WriteString(fmt.Sprintf("accept: %d, reject: %d, challenge: %d, error: %d\n", s.Accepts, s.Rejects, s.Challenges, s.Errors))
+	buff.WriteString(fmt.Sprintf("latency p50: %s, p90: %s, p95: %s, p99: %s, max: %s\n",
+		s.Percentile(50), s.Percentile(90), s.Percentile(95), s.Percentile(99), s.Percentile(100)))
+	return buff.String()
+}
+
+// RunRadtestLoad
+// Send count Access-Requests by concurrency workers, %d in the username is replaced by the request number
+func RunRadtestLoad(opts *RadtestOptions, count, concurrency int) (*RadtestStats, error) {
+	if count <= 0 || concurrency <= 0 {
+		return nil, fmt.Errorf("count and concurrency must be positive")
+	}
+	// check the options before the load starts
+	if _, err := NewRadtestAuthRequest(opts); err != nil {
+		return nil, err
+	}
+	stats := &RadtestStats{Requests: count, Latencies: make([]time.Duration, 0, count)}
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	start := time.Now()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range jobs {
+				o := *opts
+				if strings.Contains(o.Username, "%d") {
+					o.Username = fmt.Sprintf(o.Username, n)
+				}
+				request, _ := NewRadtestAuthRequest(&o)
+				reply, latency, err := RadtestExchange(request, o.authAddr(), o.Timeout)
+				lock.Lock()
+				switch {
+				case err != nil:
+					stats.Errors++
+				case reply.Code == radius.CodeAccessAccept:
+					stats.Accepts++
+				case reply.Code == radius.CodeAccessReject:
+					stats.Rejects++
+				case reply.Code == radius.CodeAccessChallenge:
+					stats.Challenges++
+				}
+				if err == nil {
+					stats.Latencies = append(stats.Latencies, latency)
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	for n := 1; n <= count; n++ {
+		jobs <- n
+	}
+	close(jobs)
+	wg.Wait()
+	stats.Duration = time.Since(start)
+	sort.Slice(stats.Latencies, func(i, j int) bool {
+		return stats.Latencies[i] < stats.Latencies[j]
+	})
+	return stats, nil
+}
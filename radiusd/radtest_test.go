@@ -0,0 +1,149 @@
+package radiusd
+
+import (
+	"bytes"
+	"crypto/md5"
+	"net"
+	"testing"
+	"time"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+	"layeh.com/radius/rfc2866"
+	"layeh.com/radius/rfc2869"
+
+	"github.com/ca17/teamsacs/common"
+	"github.com/ca17/teamsacs/radiusd/vendors/microsoft"
+)
+
+// a server accepting tim/pass by pap, chap and mschapv2 with the checks of AuthService
+func startRadtestServer() (*net.UDPAddr, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	common.Must(err)
+	s := &AuthService{}
+	server := radius.PacketServer{
+		SecretSource: radius.StaticSecretSource([]byte("secret")),
+		Handler: radius.HandlerFunc(func(w radius.ResponseWriter, r *radius.Request) {
+			if r.Code == radius.CodeAccountingRequest {
+				_ = w.Write(r.Response(radius.CodeAccountingResponse))
+				return
+			}
+			username := rfc2865.UserName_GetString(r.Packet)
+			resp := r.Response(radius.CodeAccessAccept)
+			var err error
+			switch {
+			case microsoft.MSCHAP2Response_Get(r.Packet) != nil:
+				err = s.CheckMsChapPassword(username, "pass", microsoft.MSCHAPChallenge_Get(r.Packet), microsoft.MSCHAP2Response_Get(r.Packet), resp)
+			case rfc2865.CHAPPassword_Get(r.Packet) != nil:
+				chap := rfc2865.CHAPPassword_Get(r.Packet)
+				w := md5.New()
+				w.Write(chap[:1])
+				w.Write([]byte("pass"))
+				w.Write(rfc2865.CHAPChallenge_Get(r.Packet))
+				if !bytes.Equal(w.Sum(nil), chap[1:]) {
+					err = radius.ErrNoAttribute
+				}
+			default:
+				if rfc2865.UserPassword_GetString(r.Packet) != "pass" {
+					err = radius.ErrNoAttribute
+				}
+			}
+			if err != nil || username != "tim" {
+				resp = r.Response(radius.CodeAccessReject)
+			}
+			_ = rfc2865.FramedIPAddress_Set(resp, net.ParseIP("10.0.0.9"))
+			_ = w.Write(resp)
+		}),
+	}
+	go server.Serve(conn)
+	return conn.LocalAddr().(*net.UDPAddr), func() { _ = conn.Close() }
+}
+
+func newRadtestOptions(addr *net.UDPAddr, authType string) *RadtestOptions {
+	return &RadtestOptions{
+		Server:    "127.0.0.1",
+		AuthPort:  addr.Port,
+		AcctPort:  addr.Port,
+		Secret:    "secret",
+		Username:  "tim",
+		Password:  "pass",
+		AuthType:  authType,
+		NasIp:     "127.0.0.1",
+		SessionId: "s1",
+		Attrs:     []string{"Calling-Station-Id=11:22:33:44:55:66"},
+		Timeout:   time.Second * 2,
+	}
+}
+
+func TestRadtestAuth(t *testing.T) {
+	addr, stop := startRadtestServer()
+	defer stop()
+	for _, authType := range []string{RadtestPap, RadtestChap, RadtestMschapv2} {
+		opts := newRadtestOptions(addr, authType)
+		var out bytes.Buffer
+		if err := RunRadtest(opts, true, []string{RadtestAcctStart, RadtestAcctInterim, RadtestAcctStop}, &out); err != nil {
+			t.Errorf("%s radtest error, %s\n%s", authType, err.Error(), out.String())
+		}
+		opts.Password = "wrong"
+		if err := RunRadtest(opts, true, nil, &out); err == nil {
+			t.Errorf("%s wrong password accepted", authType)
+		}
+	}
+}
+
+func TestRadtestAcctRequest(t *testing.T) {
+	opts := &RadtestOptions{Username: "tim", SessionId: "s1", SessionTime: 120, InputOctets: 5<<32 + 100, OutputOctets: 200}
+	p, err := NewRadtestAcctRequest(opts, rfc2866.AcctStatusType_Value_InterimUpdate, net.ParseIP("10.0.0.9"))
+	common.Must(err)
+	if rfc2866.AcctInputOctets_Get(p) != 100 || rfc2869.AcctInputGigawords_Get(p) != 5 || rfc2866.AcctOutputOctets_Get(p) != 200 {
+		t.Errorf("octets %d %d %d", rfc2866.AcctInputOctets_Get(p), rfc2869.AcctInputGigawords_Get(p), rfc2866.AcctOutputOctets_Get(p))
+	}
+	if !rfc2865.FramedIPAddress_Get(p).Equal(net.ParseIP("10.0.0.9")) {
+		t.Error("Framed-IP-Address not set")
+	}
+	if _, err = NewRadtestAuthRequest(&RadtestOptions{Username: "tim", Attrs: []string{"Unknown-Attr=1"}}); err == nil {
+		t.Error("unknown attribute accepted")
+	}
+}
+
+func TestRadtestLoad(t *testing.T) {
+	addr, stop := startRadtestServer()
+	defer stop()
+	opts := newRadtestOptions(addr, RadtestChap)
+	opts.Username = "tim%d"
+	stats, err := RunRadtestLoad(opts, 20, 4)
+	common.Must(err)
+	if stats.Rejects != 20 || stats.Errors != 0 || len(stats.Latencies) != 20 {
+		t.Errorf("load stats %s", stats)
+	}
+	opts.Username = "tim"
+	stats, err = RunRadtestLoad(opts, 20, 4)
+	common.Must(err)
+	if stats.Accepts != 20 {
+		t.Errorf("load stats %s", stats)
+	}
+	if stats.Percentile(50) > stats.Percentile(99) || stats.Percentile(100) != stats.Latencies[19] {
+		t.Errorf("percentiles %s", stats)
+	}
+}
+
+func TestRadtestPercentile(t *testing.T) {
+	stats := &RadtestStats{}
+	for i := 1; i <= 100; i++ {
+		stats.Latencies = append(stats.Latencies, time.Duration(i)*time.Millisecond)
+	}
+	tests := []struct {
+		p      float64
+		expect time.Duration
+	}{
+		{0, time.Millisecond},
+		{50, 50 * time.Millisecond},
+		{99, 99 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if v := stats.Percentile(tt.p); v != tt.expect {
+			t.Errorf("p%v %s, expect %s", tt.p, v, tt.expect)
+		}
+	}
+}
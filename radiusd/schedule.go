@@ -0,0 +1,51 @@
+package radiusd
+
+import (
+	"fmt"
+	"time"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2866"
+
+	"github.com/ca17/teamsacs/models"
+	"github.com/ca17/teamsacs/radiusd/radlog"
+	"github.com/ca17/teamsacs/radiusd/radparser"
+)
+
+// CheckSchedule
+// The seconds to the end of the login window of user (-1 is unlimited),
+// logins outside the windows are rejected.
+func (s *RadiusService) CheckSchedule(user *models.Subscribe) (int64, error) {
+	schedule, err := s.Manager.GetScheduleManager().GetSubscribeSchedule(user)
+	if err != nil {
+		return 0, err
+	}
+	if schedule == nil {
+		return -1, nil
+	}
+	allowed, remainSeconds := schedule.Window(time.Now().In(s.Manager.Location))
+	if !allowed {
+		return 0, fmt.Errorf("user:%s login is not allowed at this time", user.GetUsername())
+	}
+	return remainSeconds, nil
+}
+
+// processAcctSchedule
+// Sessions crossing the end of the login window are disconnected at the interim update,
+// the NAS may not enforce the Session-Timeout sent at login.
+func (s *AcctService) processAcctSchedule(r *radius.Request, vr *radparser.VendorRequest, user *models.Subscribe, vpe *models.Vpe, nasrip string) {
+	schedule, err := s.Manager.GetScheduleManager().GetSubscribeSchedule(user)
+	if err != nil {
+		radlog.Errorf("GetSubscribeSchedule user:%s error, %s", user.GetUsername(), err.Error())
+		return
+	}
+	if schedule == nil {
+		return
+	}
+	if allowed, _ := schedule.Window(time.Now().In(s.Manager.Location)); allowed {
+		return
+	}
+	sessionid := rfc2866.AcctSessionID_GetString(r.Packet)
+	// the accounting response is not delayed by the NAS round trip
+	go NewCoaService(s.RadiusService).Disconnect(user.GetUsername(), sessionid, nasrip, vpe, CoaOperatorSystem, "login schedule window closed")
+}
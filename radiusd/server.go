@@ -15,7 +15,6 @@ func ListenRadiusAuthServer(manager *models.ModelManager) error {
 		Addr: fmt.Sprintf("%s:%d", manager.Config.Radiusd.Host, manager.Config.Radiusd.AuthPort),
 		Handler:      service,
 		SecretSource: service,
-		InsecureSkipVerify: true,
 	}
 
 	log.Infof("Starting Radius Auth server on %s", server.Addr)
@@ -28,7 +27,6 @@ func ListenRadiusAcctServer(manager *models.ModelManager) error {
 		Addr: fmt.Sprintf("%s:%d", manager.Config.Radiusd.Host, manager.Config.Radiusd.AcctPort),
 		Handler:      service,
 		SecretSource: service,
-		InsecureSkipVerify: true,
 	}
 
 	log.Infof("Starting Radius Acct server on %s", server.Addr)
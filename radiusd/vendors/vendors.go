@@ -1,12 +1,17 @@
 package vendors
 
 const (
-	VendorMikrotik = "14988"
-	VendorIkuai    = "10055"
-	VendorHuawei   = "2011"
-	VendorZte      = "3902"
-	VendorH3c      = "25506"
-	VendorRadback  = "2352"
-	VendorCisco    = "9"
+	VendorMikrotik  = "14988"
+	VendorIkuai     = "10055"
+	VendorHuawei    = "2011"
+	VendorZte       = "3902"
+	VendorH3c       = "25506"
+	VendorRadback   = "2352"
+	VendorCisco     = "9"
+	VendorJuniper   = "2636"
+	VendorAruba     = "14823"
+	VendorAlcatel   = "3041"
+	VendorHillstone = "28557"
+	VendorF5        = "3375"
+	VendorPfSense   = "13644"
 )
-
@@ -0,0 +1,23 @@
+package radiusd
+
+import (
+	"github.com/ca17/teamsacs/models"
+	"github.com/ca17/teamsacs/radiusd/radlog"
+)
+
+// GetVoucherUser
+// The subscriber of the first login of a prepaid voucher, the username is the voucher code
+// and the password is the pin. nil if username is not an available voucher, the subscriber
+// is saved by ActivateVoucher after all checks passed.
+func (s *RadiusService) GetVoucherUser(username string) (*models.Subscribe, *models.Voucher) {
+	user, voucher := s.Manager.GetVoucherManager().GetVoucherSubscribe(username)
+	if user == nil {
+		return nil, nil
+	}
+	user, err := s.Manager.GetProductManager().GetEffectiveSubscribe(user)
+	if err != nil {
+		radlog.Errorf("voucher %s error, %s", username, err.Error())
+		return nil, nil
+	}
+	return user, voucher
+}
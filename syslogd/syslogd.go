@@ -25,6 +25,7 @@ import (
 	"github.com/influxdata/go-syslog/v3/rfc5424"
 
 	"github.com/ca17/teamsacs/common/log"
+	"github.com/ca17/teamsacs/common/metrics"
 	"github.com/ca17/teamsacs/models"
 )
 
@@ -47,8 +48,10 @@ func NewSyslogServer(manager *models.ModelManager) *SyslogServer {
 // HandleRfc3164
 // Handling Rfc3164 messages
 func (s SyslogServer) HandleRfc3164(remoteaddr net.Addr, data []byte) {
+	metrics.SyslogMessages.WithLabelValues("rfc3164").Inc()
 	defer func() {
 		if ret := recover(); ret != nil {
+			metrics.SyslogDrops.WithLabelValues("rfc3164", "panic").Inc()
 			err, ok := ret.(error)
 			if ok {
 				log.Error(err)
@@ -57,12 +60,13 @@ func (s SyslogServer) HandleRfc3164(remoteaddr net.Addr, data []byte) {
 	}()
 	message, err := s.Rfc3164Parser.Parse(data)
 	if err != nil {
+		metrics.SyslogDrops.WithLabelValues("rfc3164", "parse").Inc()
 		log.Error(err)
 		return
 	}
 
 	slog := *message.(*rfc3164.SyslogMessage)
-	s.Manager.GetOpsManager().AddSyslog(&models.Syslog{
+	s.saveSyslog(remoteaddr, &models.Syslog{
 		Logtype:   "rfc3164",
 		Attrs:     map[string]interface{}{
 			"Message" : *slog.Message,
@@ -82,8 +86,10 @@ func (s SyslogServer) HandleRfc3164(remoteaddr net.Addr, data []byte) {
 // HandleRfc5424
 // Handling Rfc5424 messages
 func (s SyslogServer) HandleRfc5424(remoteaddr net.Addr, data []byte) {
+	metrics.SyslogMessages.WithLabelValues("rfc5424").Inc()
 	defer func() {
 		if ret := recover(); ret != nil {
+			metrics.SyslogDrops.WithLabelValues("rfc5424", "panic").Inc()
 			err, ok := ret.(error)
 			if ok {
 				log.Error(err)
@@ -92,11 +98,12 @@ func (s SyslogServer) HandleRfc5424(remoteaddr net.Addr, data []byte) {
 	}()
 	message, err := s.Rfc5424Parser.Parse(data)
 	if err != nil {
+		metrics.SyslogDrops.WithLabelValues("rfc5424", "parse").Inc()
 		log.Error(err)
 		return
 	}
 	slog := *message.(*rfc5424.SyslogMessage)
-	s.Manager.GetOpsManager().AddSyslog(&models.Syslog{
+	s.saveSyslog(remoteaddr, &models.Syslog{
 		Logtype:   "rfc5424",
 		Attrs:     map[string]interface{}{
 			"Message" : *slog.Message,
@@ -119,8 +126,10 @@ func (s SyslogServer) HandleRfc5424(remoteaddr net.Addr, data []byte) {
 // HandleText
 // Handling Text messages
 func (s SyslogServer) HandleText(remoteaddr net.Addr, data []byte) {
+	metrics.SyslogMessages.WithLabelValues("text").Inc()
 	defer func() {
 		if ret := recover(); ret != nil {
+			metrics.SyslogDrops.WithLabelValues("text", "panic").Inc()
 			err, ok := ret.(error)
 			if ok {
 				log.Error(err)
@@ -128,7 +137,7 @@ func (s SyslogServer) HandleText(remoteaddr net.Addr, data []byte) {
 		}
 	}()
 	var message = string(data)
-	s.Manager.GetOpsManager().AddSyslog(&models.Syslog{
+	s.saveSyslog(remoteaddr, &models.Syslog{
 		Logtype:   "text",
 		Attrs:     map[string]interface{}{
 			"Message" : message,
@@ -137,6 +146,21 @@ func (s SyslogServer) HandleText(remoteaddr net.Addr, data []byte) {
 	})
 }
 
+// saveSyslog
+// Store the message and publish the syslog.message event
+func (s SyslogServer) saveSyslog(remoteaddr net.Addr, item *models.Syslog) {
+	if err := s.Manager.GetOpsManager().AddSyslog(item); err != nil {
+		metrics.SyslogDrops.WithLabelValues(item.Logtype, "store").Inc()
+		log.Error(err)
+		return
+	}
+	s.Manager.PublishEvent(models.EventSyslogMessage, map[string]interface{}{
+		"logtype":     item.Logtype,
+		"remote_addr": remoteaddr.String(),
+		"attrs":       item.Attrs,
+	})
+}
+
 
 func (s SyslogServer) StartRfc3164() error {
 	ip := net.ParseIP(s.Manager.Config.Syslogd.Host)